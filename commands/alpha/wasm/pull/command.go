@@ -16,12 +16,14 @@ package pull
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 
 	"github.com/GoogleContainerTools/kpt/internal/docs/generated/wasmdocs"
 	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
 	"github.com/GoogleContainerTools/kpt/pkg/wasm"
 	"github.com/spf13/cobra"
 )
@@ -62,17 +64,21 @@ func (r *runner) runE(_ *cobra.Command, args []string) error {
 		return errors.E(op, "2 positional arguments (a OCI image and a local path) required")
 	}
 
-	var err error
+	registries, err := fnruntime.DefaultRegistryConfig()
+	if err != nil {
+		return errors.E(op, err)
+	}
 	if r.storageClient == nil {
 		r.storageClient, err = wasm.NewClient(filepath.Join(os.TempDir(), "kpt"))
 		if err != nil {
 			return err
 		}
+		r.storageClient.Keychain = registries.Keychain()
 	}
 
 	wasmImg := args[0]
 	fileName := args[1]
-	wasmFileReadCloser, err := r.storageClient.LoadWasm(r.ctx, wasmImg)
+	wasmFileReadCloser, abi, err := r.storageClient.LoadWasm(r.ctx, registries.Mirror(wasmImg))
 	if err != nil {
 		return err
 	}
@@ -89,5 +95,12 @@ func (r *runner) runE(_ *cobra.Command, args []string) error {
 	if err = os.WriteFile(fileName, data, 0666); err != nil {
 		return errors.E(op, "unable to write to file", fileName)
 	}
+	if abi == "" {
+		// images pushed by older versions don't record the ABI.
+		if abi, err = wasm.DetectABI(data); err != nil {
+			return errors.E(op, err)
+		}
+	}
+	fmt.Printf("ABI of the wasm module: %v\n", abi)
 	return nil
 }
@@ -16,12 +16,15 @@ package push
 
 import (
 	"context"
+	"crypto"
 	"fmt"
 	"os"
 	"path"
 
 	"github.com/GoogleContainerTools/kpt/internal/docs/generated/wasmdocs"
 	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/pkg/signature"
 	"github.com/GoogleContainerTools/kpt/pkg/wasm"
 	"github.com/spf13/cobra"
 )
@@ -41,6 +44,8 @@ func newRunner(ctx context.Context) *runner {
 		Example: wasmdocs.PushExamples,
 		RunE:    r.runE,
 	}
+	c.Flags().StringVar(&r.signKey, "sign-key", "",
+		"path to the private key to sign the image with. Keys encrypted by cosign are decrypted with the password in $COSIGN_PASSWORD.")
 	r.Command = c
 	return r
 }
@@ -53,6 +58,7 @@ type runner struct {
 	ctx           context.Context
 	Command       *cobra.Command
 	storageClient *wasm.Client
+	signKey       string
 }
 
 func (r *runner) runE(_ *cobra.Command, args []string) error {
@@ -68,12 +74,25 @@ func (r *runner) runE(_ *cobra.Command, args []string) error {
 		if err != nil {
 			return err
 		}
+		registries, err := fnruntime.DefaultRegistryConfig()
+		if err != nil {
+			return errors.E(op, err)
+		}
+		r.storageClient.Keychain = registries.Keychain()
 	}
 
 	wasmFile := args[0]
 	img := args[1]
 
-	err = r.storageClient.PushWasm(r.ctx, wasmFile, img)
+	var signer crypto.Signer
+	if r.signKey != "" {
+		signer, err = signature.LoadPrivateKey(r.signKey)
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	err = r.storageClient.PushWasm(r.ctx, wasmFile, img, signer)
 	if err != nil {
 		return err
 	}
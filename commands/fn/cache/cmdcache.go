@@ -0,0 +1,154 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cache contains the commands to inspect and prune the cache of
+// function invocations.
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/pkg/oci"
+	"github.com/GoogleContainerTools/kpt/pkg/printer"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+// NewCommand returns the `fn cache` command group.
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "cache",
+		Short: fndocs.CacheShort,
+		Long:  fndocs.CacheShort + "\n" + fndocs.CacheLong,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Usage()
+		},
+	}
+	c.AddCommand(
+		newListRunner(ctx, parent).Command,
+		newPruneRunner(ctx, parent).Command,
+	)
+	cmdutil.FixDocs("kpt", parent, c)
+	return c
+}
+
+// openStorage returns the storage of the function result cache.
+func openStorage() (*oci.Storage, error) {
+	dir, err := fnruntime.GetFnCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	cache, err := fnruntime.NewResultCache(dir)
+	if err != nil {
+		return nil, err
+	}
+	return cache.Storage(), nil
+}
+
+type listRunner struct {
+	ctx     context.Context
+	Command *cobra.Command
+}
+
+func newListRunner(ctx context.Context, parent string) *listRunner {
+	r := &listRunner{ctx: ctx}
+	c := &cobra.Command{
+		Use:     "list",
+		Args:    cobra.NoArgs,
+		Short:   fndocs.ListShort,
+		Long:    fndocs.ListShort + "\n" + fndocs.ListLong,
+		Example: fndocs.ListExamples,
+		RunE:    r.runE,
+	}
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func (r *listRunner) runE(_ *cobra.Command, _ []string) error {
+	storage, err := openStorage()
+	if err != nil {
+		return err
+	}
+	entries, err := storage.ListFnResults()
+	if err != nil {
+		return err
+	}
+
+	pr := printer.FromContextOrDie(r.ctx)
+	t := table.NewWriter()
+	t.SetOutputMirror(pr.OutStream())
+	t.AppendHeader(table.Row{"KEY", "SIZE", "LAST USED"})
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+		t.AppendRow(table.Row{e.Key, e.Size, e.LastUsed.Format(time.RFC3339)})
+	}
+	t.AppendFooter(table.Row{fmt.Sprintf("%d entries", len(entries)), total, ""})
+	t.Render()
+	return nil
+}
+
+type pruneRunner struct {
+	ctx       context.Context
+	Command   *cobra.Command
+	olderThan time.Duration
+	all       bool
+}
+
+func newPruneRunner(ctx context.Context, parent string) *pruneRunner {
+	r := &pruneRunner{ctx: ctx}
+	c := &cobra.Command{
+		Use:     "prune",
+		Args:    cobra.NoArgs,
+		Short:   fndocs.PruneShort,
+		Long:    fndocs.PruneShort + "\n" + fndocs.PruneLong,
+		Example: fndocs.PruneExamples,
+		RunE:    r.runE,
+	}
+	c.Flags().DurationVar(&r.olderThan, "older-than", 7*24*time.Hour,
+		"remove the cached invocations that have not been used for this duration.")
+	c.Flags().BoolVar(&r.all, "all", false,
+		"remove all the cached invocations.")
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func (r *pruneRunner) runE(_ *cobra.Command, _ []string) error {
+	storage, err := openStorage()
+	if err != nil {
+		return err
+	}
+	before := time.Now().Add(-r.olderThan)
+	if r.all {
+		before = time.Now().Add(time.Hour)
+	}
+	pruned, err := storage.PruneFnResults(before)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, e := range pruned {
+		total += e.Size
+	}
+	pr := printer.FromContextOrDie(r.ctx)
+	pr.Printf("Removed %d cached function invocation(s), %d bytes.\n", len(pruned), total)
+	return nil
+}
@@ -69,6 +69,11 @@ func (r *Runner) runE(c *cobra.Command, _ []string) error {
 	if err != nil {
 		return err
 	}
+	registries, err := fnruntime.DefaultRegistryConfig()
+	if err != nil {
+		return err
+	}
+	image = registries.Mirror(image)
 	var out, errout bytes.Buffer
 	dockerRunArgs := []string{
 		"run",
@@ -88,6 +93,14 @@ func (r *Runner) runE(c *cobra.Command, _ []string) error {
 	}
 
 	cmd := exec.Command(runtime.GetBin(), dockerRunArgs...)
+	env, cleanup, err := registries.ContainerAuthEnv(runtime.GetBin(), image)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 	cmd.Stdout = &out
 	cmd.Stderr = &errout
 	err = cmd.Run()
@@ -17,8 +17,11 @@ package fn
 import (
 	"context"
 
+	"github.com/GoogleContainerTools/kpt/commands/fn/cache"
 	"github.com/GoogleContainerTools/kpt/commands/fn/doc"
+	"github.com/GoogleContainerTools/kpt/commands/fn/lock"
 	"github.com/GoogleContainerTools/kpt/commands/fn/render"
+	"github.com/GoogleContainerTools/kpt/commands/fn/trace"
 	"github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
 	"github.com/GoogleContainerTools/kpt/thirdparty/cmdconfig/commands/cmdeval"
 	"github.com/GoogleContainerTools/kpt/thirdparty/cmdconfig/commands/cmdsink"
@@ -48,6 +51,9 @@ func GetCommand(ctx context.Context, name string) *cobra.Command {
 		cmdeval.EvalCommand(ctx, name),
 		render.NewCommand(ctx, name),
 		doc.NewCommand(ctx, name),
+		cache.NewCommand(ctx, name),
+		lock.NewCommand(ctx, name),
+		trace.NewCommand(ctx, name),
 		cmdsource.NewCommand(ctx, name),
 		cmdsink.NewCommand(ctx, name),
 	)
@@ -0,0 +1,94 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lock
+
+import (
+	"context"
+
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/util/argutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/fnlock"
+	"github.com/GoogleContainerTools/kpt/internal/util/pathutil"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(ctx context.Context, parent string) *Runner {
+	r := &Runner{
+		ctx: ctx,
+	}
+	c := &cobra.Command{
+		Use:     "lock [PKG_PATH]",
+		Args:    cobra.MaximumNArgs(1),
+		Short:   fndocs.LockShort,
+		Long:    fndocs.LockShort + "\n" + fndocs.LockLong,
+		Example: fndocs.LockExamples,
+		PreRunE: r.preRunE,
+		RunE:    r.runE,
+	}
+	c.Flags().BoolVar(&r.Lock.Update, "update", false,
+		"resolve the digests of the images which are already pinned again.")
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+// NewCommand returns a lock command instance.
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	return NewRunner(ctx, parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	ctx     context.Context
+	Command *cobra.Command
+	Lock    fnlock.Command
+}
+
+func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
+	const op errors.Op = "cmdlock.preRunE"
+	if len(args) == 0 {
+		args = append(args, pkg.CurDir)
+	}
+	resolvedPath, err := argutil.ResolveSymlink(r.ctx, args[0])
+	if err != nil {
+		return err
+	}
+	absResolvedPath, _, err := pathutil.ResolveAbsAndRelPaths(resolvedPath)
+	if err != nil {
+		return err
+	}
+	p, err := pkg.New(filesys.FileSystemOrOnDisk{}, absResolvedPath)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	if _, err := p.Kptfile(); err != nil {
+		return errors.E(op, p.UniquePath, err)
+	}
+	r.Lock.Pkg = p
+	return nil
+}
+
+func (r *Runner) runE(_ *cobra.Command, _ []string) error {
+	const op errors.Op = "cmdlock.runE"
+	if err := r.Lock.Run(r.ctx); err != nil {
+		return errors.E(op, r.Lock.Pkg.UniquePath, err)
+	}
+	return nil
+}
@@ -21,6 +21,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
 
 	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
 	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
@@ -48,6 +51,8 @@ func NewRunner(ctx context.Context, parent string) *Runner {
 	}
 	c.Flags().StringVar(&r.resultsDirPath, "results-dir", "",
 		"path to a directory to save function results")
+	c.Flags().StringSliceVar(&r.resultsFormats, "results-format", nil,
+		fmt.Sprintf("formats to save function results in, can be repeated. Allowed values: %s. Defaults to %s.", strings.Join(fnruntime.ResultsFormats, "|"), fnruntime.ResultsFormatYAML))
 	c.Flags().StringVarP(&r.dest, "output", "o", "",
 		fmt.Sprintf("output resources are written to provided location. Allowed values: %s|%s|<OUT_DIR_PATH>", cmdutil.Stdout, cmdutil.Unwrap))
 
@@ -56,13 +61,36 @@ func NewRunner(ctx context.Context, parent string) *Runner {
 	_ = c.RegisterFlagCompletionFunc("image-pull-policy", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return r.RunnerOptions.ImagePullPolicy.AllStrings(), cobra.ShellCompDirectiveDefault
 	})
+	c.Flags().Var(&r.RunnerOptions.BuiltinCatalog, "builtin-catalog",
+		"run the images of the catalog functions implemented by kpt with the builtin function instead, when auto "+r.RunnerOptions.BuiltinCatalog.HelpAllowedValues())
+	_ = c.RegisterFlagCompletionFunc("builtin-catalog", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return r.RunnerOptions.BuiltinCatalog.AllStrings(), cobra.ShellCompDirectiveDefault
+	})
 
 	c.Flags().BoolVar(&r.RunnerOptions.AllowExec, "allow-exec", r.RunnerOptions.AllowExec,
 		"allow binary executable to be run during pipeline execution.")
+	c.Flags().BoolVar(&r.RunnerOptions.SandboxExec, "sandbox-exec", false,
+		"run binary executables in a sandbox without access to the host filesystem, the environment or, unless --allow-network is set, the network. Linux only.")
 	c.Flags().BoolVar(&r.RunnerOptions.AllowNetwork, "allow-network", false,
 		"allow functions to access network during pipeline execution.")
+	c.Flags().BoolVar(&r.RunnerOptions.AllowMount, "allow-mount", false,
+		"allow container functions to mount the package files declared in their mounts during pipeline execution.")
 	c.Flags().BoolVar(&r.RunnerOptions.AllowWasm, "allow-alpha-wasm", r.RunnerOptions.AllowWasm,
 		"allow wasm to be used during pipeline execution.")
+	c.Flags().BoolVar(&r.RunnerOptions.Locked, "locked", false,
+		"fail if the image of a function is not pinned to a digest. See `kpt fn lock`.")
+	c.Flags().BoolVar(&r.noCache, "no-cache", false,
+		"do not replay cached function outputs and do not cache the outputs of this run.")
+	c.Flags().StringVar(&r.traceDir, "trace-dir", "",
+		"path to a directory to write the resources, diff, stderr and timing of every pipeline step to.")
+	c.Flags().IntVar(&r.RunnerOptions.Parallelism, "parallelism", 1,
+		"maximum number of package pipelines to run concurrently. Sibling subpackages are rendered concurrently when greater than 1.")
+	c.Flags().BoolVar(&r.watch, "watch", false,
+		"render the package again every time one of its files changes, until interrupted.")
+	c.Flags().StringVar(&r.fnPolicy, "fn-policy", "",
+		fmt.Sprintf("path to a function policy file restricting the functions that can be run, in addition to the %s/%s files of the package repository and of the user home directory.", ".kpt", fnruntime.FnPolicyFileName))
+	c.Flags().StringVar(&r.fnRuntimeEndpoint, "fn-runtime-endpoint", "",
+		fmt.Sprintf("endpoint of a function evaluator service to run function images with, of the form [grpc://|grpcs://]HOST:PORT. Defaults to $%s if it is a grpc:// or grpcs:// endpoint.", fnruntime.ContainerRuntimeEnv))
 	cmdutil.FixDocs("kpt", parent, c)
 	r.Command = c
 	return r
@@ -76,9 +104,17 @@ func NewCommand(ctx context.Context, parent string) *cobra.Command {
 type Runner struct {
 	pkgPath        string
 	resultsDirPath string
+	resultsFormats []string
 	dest           string
-	Command        *cobra.Command
-	ctx            context.Context
+	noCache        bool
+	traceDir       string
+	watch          bool
+	// fnRuntimeEndpoint is the endpoint of the function evaluator service.
+	fnRuntimeEndpoint string
+	// fnPolicy is the path of the function policy file.
+	fnPolicy string
+	Command  *cobra.Command
+	ctx      context.Context
 
 	RunnerOptions fnruntime.RunnerOptions
 }
@@ -109,6 +145,33 @@ func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
 			return err
 		}
 	}
+	if r.watch && (r.dest != "" || r.traceDir != "") {
+		return fmt.Errorf("--watch renders the package in-place and can't be used with --output or --trace-dir")
+	}
+	if r.traceDir != "" {
+		if err := cmdutil.CheckDirectoryNotPresent(r.traceDir); err != nil {
+			return err
+		}
+		// the trace would otherwise be read as part of the package by the
+		// next render.
+		inPkg, err := isSubdir(r.pkgPath, r.traceDir)
+		if err != nil {
+			return err
+		}
+		if inPkg {
+			return fmt.Errorf("--trace-dir %q must be outside of the package %q", r.traceDir, r.pkgPath)
+		}
+	}
+	r.RunnerOptions.Policies, err = fnruntime.FindFunctionPolicies(r.fnPolicy, r.pkgPath)
+	if err != nil {
+		return err
+	}
+	if err := fnruntime.ValidateResultsFormats(r.resultsFormats); err != nil {
+		return err
+	}
+	if r.RunnerOptions.Parallelism < 1 {
+		return fmt.Errorf("--parallelism must be at least 1, got %d", r.RunnerOptions.Parallelism)
+	}
 	if r.resultsDirPath != "" {
 		err := os.MkdirAll(r.resultsDirPath, 0755)
 		if err != nil {
@@ -118,6 +181,23 @@ func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
 	return nil
 }
 
+// isSubdir returns true if path is dir or is inside of it.
+func isSubdir(dir, path string) (bool, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return false, err
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)), nil
+}
+
 func (r *Runner) runE(_ *cobra.Command, _ []string) error {
 	var output io.Writer
 	outContent := bytes.Buffer{}
@@ -130,12 +210,38 @@ func (r *Runner) runE(_ *cobra.Command, _ []string) error {
 	if err != nil {
 		return err
 	}
+	if !r.noCache {
+		cacheDir, err := fnruntime.GetFnCacheDir()
+		if err != nil {
+			return err
+		}
+		r.RunnerOptions.ResultCache, err = fnruntime.NewResultCache(cacheDir)
+		if err != nil {
+			return err
+		}
+	}
 	executor := render.Renderer{
 		PkgPath:        absPkgPath,
 		ResultsDirPath: r.resultsDirPath,
+		ResultsFormats: r.resultsFormats,
 		Output:         output,
 		RunnerOptions:  r.RunnerOptions,
 		FileSystem:     filesys.FileSystemOrOnDisk{},
+		TraceDir:       r.traceDir,
+	}
+	if endpoint := fnruntime.RemoteRuntimeEndpoint(r.fnRuntimeEndpoint); endpoint != "" {
+		runtime, err := fnruntime.NewRemoteRuntime(endpoint, nil)
+		if err != nil {
+			return err
+		}
+		defer runtime.Close()
+		executor.Runtime = runtime
+	}
+	if r.watch {
+		ctx, stop := signal.NotifyContext(r.ctx, os.Interrupt)
+		defer stop()
+		watcher := &render.Watcher{Renderer: &executor}
+		return watcher.Run(ctx)
 	}
 	if _, err := executor.Execute(r.ctx); err != nil {
 		return err
@@ -43,5 +43,31 @@ func TestCmd_flagAndArgParsing_Symlink(t *testing.T) {
 	assert.Equal(t, filepath.Join("path", "to", "pkg", "dir"), r.pkgPath)
 }
 
+func TestCmd_traceDirInPackage(t *testing.T) {
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+
+	err := os.MkdirAll(filepath.Join(dir, "pkg"), 0700)
+	assert.NoError(t, err)
+
+	for traceDir, inPkg := range map[string]bool{
+		filepath.Join("pkg", "trace"):       true,
+		filepath.Join(dir, "pkg", "trace"):  true,
+		"trace":                             false,
+		"pkg-trace":                         false,
+		filepath.Join("pkg", "..", "trace"): false,
+	} {
+		r := NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+		r.Command.RunE = NoOpRunE
+		r.Command.SetArgs([]string{"pkg", "--trace-dir", traceDir})
+		err = r.Command.Execute()
+		if inPkg {
+			assert.ErrorContains(t, err, "must be outside of the package", traceDir)
+		} else {
+			assert.NoError(t, err, traceDir)
+		}
+	}
+}
+
 // NoOpRunE is a noop function to replace the run function of a command.  Useful for testing argument parsing.
 var NoOpRunE = func(cmd *cobra.Command, args []string) error { return nil }
@@ -0,0 +1,147 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trace contains the commands to browse the traces written by
+// `kpt fn render --trace-dir`.
+package trace
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/render"
+	"github.com/GoogleContainerTools/kpt/pkg/printer"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// NewCommand returns the `fn trace` command group.
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "trace",
+		Short: fndocs.TraceShort,
+		Long:  fndocs.TraceShort + "\n" + fndocs.TraceLong,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Usage()
+		},
+	}
+	c.AddCommand(
+		newViewRunner(ctx, parent).Command,
+	)
+	cmdutil.FixDocs("kpt", parent, c)
+	return c
+}
+
+type viewRunner struct {
+	ctx       context.Context
+	Command   *cobra.Command
+	pkg       string
+	step      int
+	resources bool
+}
+
+func newViewRunner(ctx context.Context, parent string) *viewRunner {
+	r := &viewRunner{ctx: ctx}
+	c := &cobra.Command{
+		Use:     "view TRACE_DIR",
+		Args:    cobra.ExactArgs(1),
+		Short:   fndocs.ViewShort,
+		Long:    fndocs.ViewShort + "\n" + fndocs.ViewLong,
+		Example: fndocs.ViewExamples,
+		RunE:    r.runE,
+	}
+	c.Flags().StringVar(&r.pkg, "pkg", ".",
+		"path of the package relative to the root package, used with --step.")
+	c.Flags().IntVar(&r.step, "step", 0,
+		"index of the step to display the details of.")
+	c.Flags().BoolVar(&r.resources, "resources", false,
+		"display the resources after the step instead of the diff, used with --step.")
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func (r *viewRunner) runE(_ *cobra.Command, args []string) error {
+	fsys := filesys.MakeFsOnDisk()
+	steps, err := render.ReadTrace(fsys, args[0])
+	if err != nil {
+		return err
+	}
+	if r.step == 0 {
+		return r.printSummary(fsys, steps)
+	}
+	pkg := filepath.ToSlash(filepath.Clean(r.pkg))
+	for _, s := range steps {
+		if s.Package == pkg && s.Index == r.step {
+			return r.printStep(fsys, s)
+		}
+	}
+	return fmt.Errorf("step %d of package %q not found in trace %q", r.step, pkg, args[0])
+}
+
+// printSummary prints a table of all the steps of the trace.
+func (r *viewRunner) printSummary(fsys filesys.FileSystem, steps []*render.TraceStep) error {
+	pr := printer.FromContextOrDie(r.ctx)
+	t := table.NewWriter()
+	t.SetOutputMirror(pr.OutStream())
+	t.AppendHeader(table.Row{"PACKAGE", "STEP", "STAGE", "FUNCTION", "STATUS", "DURATION", "CHANGES"})
+	for _, s := range steps {
+		changes := ""
+		if diff, err := fsys.ReadFile(filepath.Join(s.Dir, render.TraceDiffFile)); err == nil {
+			added, removed := render.DiffStat(string(diff))
+			changes = fmt.Sprintf("+%d -%d", added, removed)
+		}
+		t.AppendRow(table.Row{s.Package, s.Index, s.Stage, s.Function, s.Status, s.Duration, changes})
+	}
+	t.Render()
+	return nil
+}
+
+// printStep prints the details of a step: its metadata, the stderr of the
+// function and either the diff or the resources after the step.
+func (r *viewRunner) printStep(fsys filesys.FileSystem, s *render.TraceStep) error {
+	pr := printer.FromContextOrDie(r.ctx)
+	out := pr.OutStream()
+	fmt.Fprintf(out, "Package:   %s\n", s.Package)
+	fmt.Fprintf(out, "Step:      %d (%s)\n", s.Index, s.Stage)
+	fmt.Fprintf(out, "Function:  %s\n", s.Function)
+	if s.Name != "" {
+		fmt.Fprintf(out, "Name:      %s\n", s.Name)
+	}
+	fmt.Fprintf(out, "Status:    %s\n", s.Status)
+	if s.Duration != "" {
+		fmt.Fprintf(out, "Duration:  %s\n", s.Duration)
+	}
+	fmt.Fprintf(out, "Exit Code: %d\n", s.ExitCode)
+
+	if stderr, err := fsys.ReadFile(filepath.Join(s.Dir, render.TraceStderrFile)); err == nil {
+		fmt.Fprintf(out, "\nStderr:\n%s", stderr)
+	}
+
+	file, title := render.TraceDiffFile, "Diff"
+	if r.resources {
+		file, title = render.TraceResourcesFile, "Resources"
+	}
+	content, err := fsys.ReadFile(filepath.Join(s.Dir, file))
+	if err != nil {
+		// only successful mutator steps have resources and a diff
+		return nil
+	}
+	fmt.Fprintf(out, "\n%s:\n%s", title, content)
+	return nil
+}
@@ -39,7 +39,7 @@ func NewRunner(ctx context.Context, parent string) *Runner {
 		ctx: ctx,
 	}
 	c := &cobra.Command{
-		Use:        "get REPO_URI[.git]/PKG_PATH[@VERSION] [LOCAL_DEST_DIRECTORY]",
+		Use:        "get {REPO_URI[.git]/PKG_PATH[@VERSION] | oci://IMAGE[//PKG_PATH]} [LOCAL_DEST_DIRECTORY]",
 		Args:       cobra.MinimumNArgs(1),
 		Short:      docs.GetShort,
 		Long:       docs.GetShort + "\n" + docs.GetLong,
@@ -88,20 +88,31 @@ func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
 			args[1] = resolvedPath
 		}
 	}
-	t, err := parse.GitParseArgs(r.ctx, args)
-	if err != nil {
-		return errors.E(op, err)
+	var destination string
+	if parse.IsOciArg(args[0]) {
+		t, err := parse.OciParseArgs(args)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		r.Get.Oci = &t.Oci
+		destination = t.Destination
+	} else {
+		t, err := parse.GitParseArgs(r.ctx, args)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		r.Get.Git = &t.Git
+		destination = t.Destination
 	}
 
-	r.Get.Git = &t.Git
-	absDestPath, _, err := pathutil.ResolveAbsAndRelPaths(t.Destination)
+	absDestPath, _, err := pathutil.ResolveAbsAndRelPaths(destination)
 	if err != nil {
 		return err
 	}
 
 	p, err := pkg.New(filesys.FileSystemOrOnDisk{}, absDestPath)
 	if err != nil {
-		return errors.E(op, types.UniquePath(t.Destination), err)
+		return errors.E(op, types.UniquePath(destination), err)
 	}
 	r.Get.Destination = string(p.UniquePath)
 
@@ -0,0 +1,136 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outdated
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/util/argutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/outdated"
+	"github.com/GoogleContainerTools/kpt/internal/util/pathutil"
+	"github.com/GoogleContainerTools/kpt/pkg/printer"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(ctx context.Context, parent string) *Runner {
+	r := &Runner{
+		ctx: ctx,
+	}
+	c := &cobra.Command{
+		Use:     "outdated [DIR] [flags]",
+		Args:    cobra.MaximumNArgs(1),
+		Short:   pkgdocs.OutdatedShort,
+		Long:    pkgdocs.OutdatedShort + "\n" + pkgdocs.OutdatedLong,
+		Example: pkgdocs.OutdatedExamples,
+		PreRunE: r.preRunE,
+		RunE:    r.runE,
+	}
+	c.Flags().StringVar(&r.output, "output", "text",
+		"the output format -- must be one of: text,json")
+	_ = c.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"text", "json"}, cobra.ShellCompDirectiveDefault
+	})
+	c.Flags().BoolVar(&r.failIfOutdated, "fail-if-outdated", false,
+		"exit with a non-zero status if a package is outdated.")
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+// NewCommand returns an outdated command instance.
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	return NewRunner(ctx, parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	ctx            context.Context
+	output         string
+	failIfOutdated bool
+	Outdated       outdated.Command
+	Command        *cobra.Command
+}
+
+func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
+	const op errors.Op = "cmdoutdated.preRunE"
+	if len(args) == 0 {
+		args = append(args, pkg.CurDir)
+	}
+	if r.output != "text" && r.output != "json" {
+		return errors.E(op, errors.InvalidParam, fmt.Errorf("--output must be one of: text,json"))
+	}
+
+	resolvedPath, err := argutil.ResolveSymlink(r.ctx, args[0])
+	if err != nil {
+		return err
+	}
+	absResolvedPath, _, err := pathutil.ResolveAbsAndRelPaths(resolvedPath)
+	if err != nil {
+		return err
+	}
+	p, err := pkg.New(filesys.FileSystemOrOnDisk{}, absResolvedPath)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	if _, err := p.Kptfile(); err != nil {
+		return errors.E(op, p.UniquePath, err)
+	}
+	r.Outdated.Pkg = p
+	return nil
+}
+
+func (r *Runner) runE(_ *cobra.Command, _ []string) error {
+	const op errors.Op = "cmdoutdated.runE"
+	statuses, err := r.Outdated.Run(r.ctx)
+	if err != nil {
+		return errors.E(op, r.Outdated.Pkg.UniquePath, err)
+	}
+
+	out := printer.FromContextOrDie(r.ctx).OutStream()
+	if r.output == "json" {
+		if statuses == nil {
+			statuses = []outdated.Status{}
+		}
+		b, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return errors.E(op, r.Outdated.Pkg.UniquePath, err)
+		}
+		fmt.Fprintln(out, string(b))
+	} else if err := outdated.PrintStatuses(out, statuses); err != nil {
+		return errors.E(op, r.Outdated.Pkg.UniquePath, err)
+	}
+
+	if !r.failIfOutdated {
+		return nil
+	}
+	count := 0
+	for _, s := range statuses {
+		if s.Outdated {
+			count++
+		}
+	}
+	if count > 0 {
+		return errors.E(op, r.Outdated.Pkg.UniquePath, fmt.Errorf("%d package(s) are outdated", count))
+	}
+	return nil
+}
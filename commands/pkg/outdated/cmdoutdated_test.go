@@ -0,0 +1,104 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outdated_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/commands/pkg/get"
+	"github.com/GoogleContainerTools/kpt/commands/pkg/outdated"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/GoogleContainerTools/kpt/pkg/printer/fake"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.ConfigureTestKptCache(m))
+}
+
+// TestCmd_execute verifies that the outdated packages are reported, and fail
+// the command with --fail-if-outdated.
+func TestCmd_execute(t *testing.T) {
+	g, w, clean := testutil.SetupRepoAndWorkspace(t, testutil.Content{
+		Data:   testutil.Dataset1,
+		Branch: "master",
+	})
+	defer clean()
+
+	defer testutil.Chdir(t, w.WorkspaceDirectory)()
+
+	// clone the repo
+	getCmd := get.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	getCmd.Command.SetArgs([]string{"file://" + g.RepoDirectory + ".git", w.WorkspaceDirectory})
+	if !assert.NoError(t, getCmd.Command.Execute()) {
+		return
+	}
+
+	// the package is up to date
+	outdatedCmd := outdated.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	outdatedCmd.Command.SetArgs([]string{g.RepoName, "--fail-if-outdated"})
+	if !assert.NoError(t, outdatedCmd.Command.Execute()) {
+		return
+	}
+
+	// update the master branch
+	if !assert.NoError(t, g.ReplaceData(testutil.Dataset2)) {
+		return
+	}
+	if _, err := g.Commit("modify upstream package -- ds2"); !assert.NoError(t, err) {
+		return
+	}
+
+	out := &bytes.Buffer{}
+	outdatedCmd = outdated.NewRunner(fake.CtxWithPrinter(out, nil), "kpt")
+	outdatedCmd.Command.SetArgs([]string{g.RepoName, "--output", "json"})
+	if !assert.NoError(t, outdatedCmd.Command.Execute()) {
+		return
+	}
+	var statuses []struct {
+		Package       string `json:"package"`
+		CommitsBehind int    `json:"commitsBehind"`
+		Outdated      bool   `json:"outdated"`
+	}
+	if !assert.NoError(t, json.Unmarshal(out.Bytes(), &statuses)) {
+		return
+	}
+	if assert.Len(t, statuses, 1) {
+		assert.Equal(t, ".", statuses[0].Package)
+		assert.Equal(t, 1, statuses[0].CommitsBehind)
+		assert.True(t, statuses[0].Outdated)
+	}
+
+	outdatedCmd = outdated.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	outdatedCmd.Command.SetArgs([]string{g.RepoName, "--fail-if-outdated"})
+	err := outdatedCmd.Command.Execute()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "1 package(s) are outdated")
+	}
+}
+
+func TestCmd_invalidOutput(t *testing.T) {
+	r := outdated.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	r.Command.SetArgs([]string{"--output", "yaml"})
+	r.Command.RunE = func(*cobra.Command, []string) error { return nil }
+	err := r.Command.Execute()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--output must be one of: text,json")
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"context"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/util/argutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/parse"
+	"github.com/GoogleContainerTools/kpt/internal/util/pathutil"
+	"github.com/GoogleContainerTools/kpt/pkg/oci"
+	"github.com/GoogleContainerTools/kpt/pkg/printer"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(ctx context.Context, parent string) *Runner {
+	r := &Runner{
+		ctx: ctx,
+	}
+	c := &cobra.Command{
+		Use:     "push [oci://]IMAGE [PKG_PATH]",
+		Args:    cobra.RangeArgs(1, 2),
+		Short:   pkgdocs.PushShort,
+		Long:    pkgdocs.PushShort + "\n" + pkgdocs.PushLong,
+		Example: pkgdocs.PushExamples,
+		PreRunE: r.preRunE,
+		RunE:    r.runE,
+	}
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+// NewCommand returns a push command instance.
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	return NewRunner(ctx, parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	ctx     context.Context
+	Command *cobra.Command
+
+	// Image is the image the package is published as.
+	Image string
+
+	// Pkg is the package to publish.
+	Pkg *pkg.Pkg
+}
+
+func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
+	const op errors.Op = "cmdpush.preRunE"
+	if len(args) == 1 {
+		args = append(args, pkg.CurDir)
+	}
+	r.Image = strings.TrimPrefix(args[0], parse.OciPrefix)
+
+	resolvedPath, err := argutil.ResolveSymlink(r.ctx, args[1])
+	if err != nil {
+		return err
+	}
+	absResolvedPath, _, err := pathutil.ResolveAbsAndRelPaths(resolvedPath)
+	if err != nil {
+		return err
+	}
+	p, err := pkg.New(filesys.FileSystemOrOnDisk{}, absResolvedPath)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	if _, err := p.Kptfile(); err != nil {
+		return errors.E(op, p.UniquePath, err)
+	}
+	r.Pkg = p
+	return nil
+}
+
+func (r *Runner) runE(_ *cobra.Command, _ []string) error {
+	const op errors.Op = "cmdpush.runE"
+	pr := printer.FromContextOrDie(r.ctx)
+	pr.Printf("Pushing package %q to %s\n", r.Pkg.DisplayPath, r.Image)
+	digest, err := oci.PushPackage(r.ctx, r.Pkg.UniquePath.String(), r.Image)
+	if err != nil {
+		return errors.E(op, errors.OCI, r.Pkg.UniquePath, err)
+	}
+	pr.Printf("Pushed %s@%s\n", r.Image, digest)
+	return nil
+}
@@ -16,6 +16,7 @@ package update
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -30,6 +31,7 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/util/pathutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/update"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/printer"
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
@@ -57,6 +59,19 @@ func NewRunner(ctx context.Context, parent string) *Runner {
 	_ = c.RegisterFlagCompletionFunc("strategy", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return kptfilev1.UpdateStrategiesAsStrings(), cobra.ShellCompDirectiveDefault
 	})
+	c.Flags().StringVar(&r.resolve, "resolve", "",
+		"resolve the conflicts recorded by a previous update instead of updating the package "+
+			"-- must be one of: "+strings.Join(update.Resolutions(), ","))
+	_ = c.RegisterFlagCompletionFunc("resolve", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return update.Resolutions(), cobra.ShellCompDirectiveDefault
+	})
+	c.Flags().BoolVar(&r.dryRun, "dry-run", false,
+		"print the changes the update would make to the package without changing it.")
+	c.Flags().StringVar(&r.output, "output", "text",
+		"the output format of the changes printed with --dry-run -- must be one of: text,json")
+	_ = c.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"text", "json"}, cobra.ShellCompDirectiveDefault
+	})
 	cmdutil.FixDocs("kpt", parent, c)
 	r.Command = c
 	return r
@@ -71,6 +86,9 @@ func NewCommand(ctx context.Context, parent string) *cobra.Command {
 type Runner struct {
 	ctx      context.Context
 	strategy string
+	resolve  string
+	dryRun   bool
+	output   string
 	Update   update.Command
 	Command  *cobra.Command
 }
@@ -90,6 +108,22 @@ func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
 	if len(parts) > 2 {
 		return errors.E(op, errors.InvalidParam, fmt.Errorf("at most 1 version permitted"))
 	}
+	if r.resolve != "" {
+		if !contains(update.Resolutions(), r.resolve) {
+			return errors.E(op, errors.InvalidParam, fmt.Errorf("--resolve must be one of: %s",
+				strings.Join(update.Resolutions(), ",")))
+		}
+		if len(parts) > 1 {
+			return errors.E(op, errors.InvalidParam, fmt.Errorf("a version can't be used with --resolve"))
+		}
+		if r.dryRun {
+			return errors.E(op, errors.InvalidParam, fmt.Errorf("--dry-run can't be used with --resolve"))
+		}
+		r.Update.Resolve = update.Resolution(r.resolve)
+	}
+	if r.output != "text" && r.output != "json" {
+		return errors.E(op, errors.InvalidParam, fmt.Errorf("--output must be one of: text,json"))
+	}
 
 	resolvedPath, err := argutil.ResolveSymlink(r.ctx, parts[0])
 	if err != nil {
@@ -124,6 +158,16 @@ func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
 
 func (r *Runner) runE(_ *cobra.Command, _ []string) error {
 	const op errors.Op = "cmdupdate.runE"
+	if r.dryRun {
+		plan, err := r.Update.DryRun(r.ctx)
+		if err != nil {
+			return errors.E(op, r.Update.Pkg.UniquePath, err)
+		}
+		if err := r.printPlan(plan); err != nil {
+			return errors.E(op, r.Update.Pkg.UniquePath, err)
+		}
+		return nil
+	}
 	if err := r.Update.Run(r.ctx); err != nil {
 		return errors.E(op, r.Update.Pkg.UniquePath, err)
 	}
@@ -131,6 +175,20 @@ func (r *Runner) runE(_ *cobra.Command, _ []string) error {
 	return nil
 }
 
+// printPlan prints the changes of a dry run to stdout in the output format.
+func (r *Runner) printPlan(plan *update.Plan) error {
+	out := printer.FromContextOrDie(r.ctx).OutStream()
+	if r.output == "json" {
+		b, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(b))
+		return err
+	}
+	return plan.Print(out)
+}
+
 func resolveRelPath(path types.UniquePath) (string, error) {
 	const op errors.Op = "cmdupdate.resolveRelPath"
 	cwd, err := os.Getwd()
@@ -146,3 +204,12 @@ func resolveRelPath(path types.UniquePath) (string, error) {
 	}
 	return relPath, nil
 }
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -16,6 +16,7 @@ package update_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -342,6 +343,101 @@ func TestCmd_Execute_flagAndArgParsing(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, kptfilev1.ResourceMerge, r.Update.Strategy)
 	assert.Equal(t, "", r.Update.Ref)
+
+	// verify the conflict resolution is set
+	r = update.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	r.Command.RunE = NoOpRunE
+	r.Command.SetArgs([]string{dir, "--resolve", "theirs"})
+	err = r.Command.Execute()
+	assert.NoError(t, err)
+	assert.Equal(t, "theirs", string(r.Update.Resolve))
+
+	// verify an error is thrown for unknown resolutions
+	r = update.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	r.Command.SilenceErrors = true
+	r.Command.RunE = failRun
+	r.Command.SetArgs([]string{dir, "--resolve", "mine"})
+	err = r.Command.Execute()
+	assert.Contains(t, err.Error(), "--resolve must be one of: ours,theirs,interactive")
+
+	// verify an error is thrown if a version is resolved
+	r = update.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	r.Command.SilenceErrors = true
+	r.Command.RunE = failRun
+	r.Command.SetArgs([]string{dir + "@v1", "--resolve", "ours"})
+	err = r.Command.Execute()
+	assert.Contains(t, err.Error(), "a version can't be used with --resolve")
+
+	// verify an error is thrown if conflicts are resolved in a dry run
+	r = update.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	r.Command.SilenceErrors = true
+	r.Command.RunE = failRun
+	r.Command.SetArgs([]string{dir, "--resolve", "ours", "--dry-run"})
+	err = r.Command.Execute()
+	assert.Contains(t, err.Error(), "--dry-run can't be used with --resolve")
+
+	// verify an error is thrown for unknown output formats
+	r = update.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	r.Command.SilenceErrors = true
+	r.Command.RunE = failRun
+	r.Command.SetArgs([]string{dir, "--dry-run", "--output", "yaml"})
+	err = r.Command.Execute()
+	assert.Contains(t, err.Error(), "--output must be one of: text,json")
+}
+
+// TestCmd_dryRun verifies that a dry run prints the changes of the update
+// without changing the package.
+func TestCmd_dryRun(t *testing.T) {
+	g, w, clean := testutil.SetupRepoAndWorkspace(t, testutil.Content{
+		Data:   testutil.Dataset1,
+		Branch: "master",
+	})
+	defer clean()
+
+	defer testutil.Chdir(t, w.WorkspaceDirectory)()
+
+	dest := filepath.Join(w.WorkspaceDirectory, g.RepoName)
+
+	// clone the repo
+	getCmd := get.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	getCmd.Command.SetArgs([]string{"file://" + g.RepoDirectory + ".git", w.WorkspaceDirectory})
+	if !assert.NoError(t, getCmd.Command.Execute()) {
+		return
+	}
+
+	// update the master branch
+	if !assert.NoError(t, g.ReplaceData(testutil.Dataset2)) {
+		return
+	}
+	if _, err := g.Commit("modify upstream package -- ds2"); !assert.NoError(t, err) {
+		return
+	}
+
+	// print the changes of the update
+	out := &bytes.Buffer{}
+	updateCmd := update.NewRunner(fake.CtxWithPrinter(out, nil), "kpt")
+	updateCmd.Command.SetArgs([]string{g.RepoName, "--dry-run", "--output", "json"})
+	if !assert.NoError(t, updateCmd.Command.Execute()) {
+		return
+	}
+	var plan struct {
+		Resources []struct {
+			File   string `json:"file"`
+			Action string `json:"action"`
+		} `json:"resources"`
+	}
+	if !assert.NoError(t, json.Unmarshal(out.Bytes(), &plan)) {
+		return
+	}
+	assert.Contains(t, plan.Resources, struct {
+		File   string `json:"file"`
+		Action string `json:"action"`
+	}{File: "java/java-deployment.resource.yaml", Action: "modified"})
+
+	// the package is unchanged
+	if !g.AssertEqual(t, filepath.Join(g.DatasetDirectory, testutil.Dataset1), dest, true) {
+		return
+	}
 }
 
 func TestCmd_flagAndArgParsing_Symlink(t *testing.T) {
@@ -806,7 +902,10 @@ Updated 2 package(s).
 }
 
 const (
-	gitOutputPattern = `From \/.*(\r\n|\r|\n)( * .*(\r\n|\r|\n))+`
+	// gitOutputPattern matches the output of git fetch for the exec
+	// backend, and the progress sent by the remote for the go backend.
+	gitOutputPattern = `From \/.*(\r\n|\r|\n)( * .*(\r\n|\r|\n))+|` +
+		`((Enumerating|Counting|Compressing) objects: .*(\r\n|\r|\n))*Total .*(\r\n|\r|\n)`
 )
 
 func scrubGitOutput(output string) string {
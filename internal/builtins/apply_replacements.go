@@ -0,0 +1,52 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/api/filters/replacement"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ApplyReplacements is a builtin implementation of the apply-replacements
+// catalog function. It copies fields of resources to other fields, as
+// kustomize replacements do.
+type ApplyReplacements struct{}
+
+// Process implements framework.ResourceListProcessor interface.
+func (ar *ApplyReplacements) Process(resourceList *framework.ResourceList) error {
+	fnConfig := resourceList.FunctionConfig
+	if fnConfig.IsNilOrEmpty() || fnConfig.GetKind() != "ApplyReplacements" {
+		return resultError(resourceList, fmt.Errorf("functionConfig must be an ApplyReplacements"))
+	}
+	var config struct {
+		Replacements []types.Replacement `yaml:"replacements,omitempty"`
+	}
+	s, err := fnConfig.String()
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal([]byte(s), &config); err != nil {
+		return resultError(resourceList, fmt.Errorf("invalid ApplyReplacements: %w", err))
+	}
+	resourceList.Items, err = replacement.Filter{Replacements: config.Replacements}.Filter(resourceList.Items)
+	if err != nil {
+		return resultError(resourceList, err)
+	}
+	return nil
+}
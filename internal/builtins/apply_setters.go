@@ -0,0 +1,139 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// setterCommentPrefix is the prefix of the line comments declaring the
+// setter pattern of a field, e.g. `# kpt-set: ${image}:${tag}`.
+const setterCommentPrefix = "kpt-set:"
+
+// setterRef matches the references to setters in a setter pattern.
+var setterRef = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// ApplySetters is a builtin implementation of the apply-setters catalog
+// function. It sets the fields with a `# kpt-set: PATTERN` line comment to
+// the pattern with the references to setters replaced by their values.
+type ApplySetters struct{}
+
+// Process implements framework.ResourceListProcessor interface.
+func (as *ApplySetters) Process(resourceList *framework.ResourceList) error {
+	setters, err := configData(resourceList.FunctionConfig, "ApplySetters", "setters")
+	if err != nil {
+		return resultError(resourceList, err)
+	}
+	for _, rn := range resourceList.Items {
+		err := walkFields(rn, func(path []pathElem, key, node *yaml.Node) error {
+			switch node.Kind {
+			case yaml.ScalarNode:
+				pattern, found := setterPattern(node.LineComment)
+				if !found {
+					return nil
+				}
+				value, found := applySetters(pattern, setters)
+				if !found || value == node.Value {
+					return nil
+				}
+				setScalar(node, value)
+				resourceList.Results = append(resourceList.Results,
+					fileResult(rn, pathString(path), fmt.Sprintf("set field value to %q", value)))
+			case yaml.SequenceNode:
+				comment := node.LineComment
+				if key != nil && key.LineComment != "" {
+					comment = key.LineComment
+				}
+				pattern, found := setterPattern(comment)
+				if !found {
+					return nil
+				}
+				// sequences can only be set by a single setter whose value
+				// is a list, e.g. `[dev, prod]`.
+				m := setterRef.FindStringSubmatch(pattern)
+				if m == nil || m[0] != pattern {
+					return nil
+				}
+				value, found := setters[m[1]]
+				if !found {
+					return nil
+				}
+				list, err := yaml.Parse(value)
+				if err != nil || list.YNode().Kind != yaml.SequenceNode {
+					return fmt.Errorf("value %q of setter %q must be a list to set field %q", value, m[1], pathString(path))
+				}
+				if equalScalars(node.Content, list.YNode().Content) {
+					return nil
+				}
+				node.Content = list.YNode().Content
+				for _, e := range node.Content {
+					e.Style = 0
+				}
+				resourceList.Results = append(resourceList.Results,
+					fileResult(rn, pathString(path), fmt.Sprintf("set field value to %q", value)))
+			}
+			return nil
+		})
+		if err != nil {
+			return resultError(resourceList, err)
+		}
+	}
+	return nil
+}
+
+// setterPattern returns the setter pattern declared by the line comment.
+func setterPattern(comment string) (string, bool) {
+	comment = strings.TrimSpace(strings.TrimPrefix(comment, "#"))
+	if !strings.HasPrefix(comment, setterCommentPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(comment, setterCommentPrefix)), true
+}
+
+// applySetters returns the pattern with the references to setters replaced
+// by their values. It returns false if the pattern doesn't reference any
+// setter or references a setter which isn't set.
+func applySetters(pattern string, setters map[string]string) (string, bool) {
+	refs := setterRef.FindAllStringSubmatch(pattern, -1)
+	if len(refs) == 0 {
+		return "", false
+	}
+	for _, ref := range refs {
+		if _, found := setters[ref[1]]; !found {
+			return "", false
+		}
+	}
+	return setterRef.ReplaceAllStringFunc(pattern, func(ref string) string {
+		return setters[ref[2:len(ref)-1]]
+	}), true
+}
+
+// equalScalars returns true if both lists hold the same scalar values.
+func equalScalars(a, b []*yaml.Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Kind != yaml.ScalarNode || b[i].Kind != yaml.ScalarNode || a[i].Value != b[i].Value {
+			return false
+		}
+	}
+	return true
+}
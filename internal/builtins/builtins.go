@@ -0,0 +1,196 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/filters"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// ImagePrefix is the prefix of the images of the builtin functions.
+	ImagePrefix = "builtins/"
+
+	// catalogRepository is the repository of the catalog function images.
+	catalogRepository = "gcr.io/kpt-fn/"
+
+	// fnConfigAPIVersion is the apiVersion of the functionConfig kinds
+	// defined by the catalog functions.
+	fnConfigAPIVersion = "fn.kpt.dev/v1alpha1"
+)
+
+// builtin is a KRM function built into kpt.
+type builtin struct {
+	// name is the name of the function, which is the name of its catalog
+	// image for the builtins implementing a catalog function.
+	name string
+
+	// version is the minor release of the catalog function the builtin
+	// implements, e.g. v0.4. It is empty for the functions which are only
+	// available as builtins.
+	version string
+
+	// run returns the run function of the builtin.
+	run func() func(io.Reader, io.Writer) error
+}
+
+var registry = []builtin{
+	{name: "gen-pkg-context", run: func() func(io.Reader, io.Writer) error {
+		return (&PackageContextGenerator{}).Run
+	}},
+	{name: "set-namespace", version: "v0.4", run: processorRun(&SetNamespace{})},
+	{name: "set-labels", version: "v0.2", run: processorRun(&SetLabels{})},
+	{name: "set-annotations", version: "v0.1", run: processorRun(&SetAnnotations{})},
+	{name: "apply-setters", version: "v0.2", run: processorRun(&ApplySetters{})},
+	{name: "apply-replacements", version: "v0.1", run: processorRun(&ApplyReplacements{})},
+	{name: "starlark", version: "v0.4", run: func() func(io.Reader, io.Writer) error {
+		return (&Starlark{}).Run
+	}},
+	{name: "search-replace", version: "v0.2", run: processorRun(&SearchReplace{})},
+}
+
+// IsBuiltin returns true if the image is the image of a builtin function,
+// i.e. it starts with `builtins/`.
+func IsBuiltin(image string) bool {
+	return strings.HasPrefix(image, ImagePrefix)
+}
+
+// Lookup returns the run function of the builtin function of a
+// `builtins/NAME` image.
+func Lookup(image string) (func(io.Reader, io.Writer) error, bool) {
+	for _, b := range registry {
+		if image == ImagePrefix+b.name {
+			return b.run(), true
+		}
+	}
+	return nil, false
+}
+
+// CatalogBuiltin returns the `builtins/NAME` image of the builtin function
+// implementing the image of a catalog function `gcr.io/kpt-fn/NAME:TAG`,
+// if the tag is a release of the minor version the builtin implements.
+// Images pinned to a digest have no builtin.
+func CatalogBuiltin(image string) (string, bool) {
+	if strings.Contains(image, "@") {
+		return "", false
+	}
+	for _, b := range registry {
+		if b.version == "" || !strings.HasPrefix(image, catalogRepository+b.name+":") {
+			continue
+		}
+		tag := strings.TrimPrefix(image, catalogRepository+b.name+":")
+		if semver.IsValid(tag) && semver.Prerelease(tag) == "" && semver.MajorMinor(tag) == b.version {
+			return ImagePrefix + b.name, true
+		}
+	}
+	return "", false
+}
+
+// processorRun returns a function returning the run function of a builtin
+// implemented as a framework.ResourceListProcessor.
+func processorRun(p framework.ResourceListProcessor) func() func(io.Reader, io.Writer) error {
+	return func() func(io.Reader, io.Writer) error {
+		return func(r io.Reader, w io.Writer) error {
+			rw := &kio.ByteReadWriter{
+				Reader:                r,
+				Writer:                w,
+				KeepReaderAnnotations: true,
+			}
+			return framework.Execute(p, rw)
+		}
+	}
+}
+
+// configData returns the key value pairs configuring a function. They are
+// the data of a ConfigMap functionConfig, or the map in the given field of
+// a functionConfig of the given kind.
+func configData(fnConfig *yaml.RNode, kind, field string) (map[string]string, error) {
+	if fnConfig.IsNilOrEmpty() {
+		return nil, nil
+	}
+	switch {
+	case fnConfig.GetKind() == "ConfigMap":
+		return fnConfig.GetDataMap(), nil
+	case kind != "" && fnConfig.GetKind() == kind:
+		m, err := fnConfig.Pipe(yaml.Lookup(field))
+		if err != nil || m == nil {
+			return nil, err
+		}
+		data := map[string]string{}
+		if err := m.YNode().Decode(&data); err != nil {
+			return nil, fmt.Errorf("invalid `%s` in %s: %w", field, kind, err)
+		}
+		return data, nil
+	case kind == "":
+		return nil, fmt.Errorf("functionConfig must be a ConfigMap, got %s", fnConfig.GetKind())
+	default:
+		return nil, fmt.Errorf("functionConfig must be a ConfigMap or a %s, got %s", kind, fnConfig.GetKind())
+	}
+}
+
+// fileResult returns a result referring to a field of the resource.
+func fileResult(rn *yaml.RNode, fieldPath, message string) *framework.Result {
+	path, index, _ := kioutil.GetFileAnnotations(rn)
+	result := &framework.Result{
+		Message:  message,
+		Severity: framework.Info,
+		ResourceRef: &yaml.ResourceIdentifier{
+			TypeMeta: yaml.TypeMeta{APIVersion: rn.GetApiVersion(), Kind: rn.GetKind()},
+			NameMeta: yaml.NameMeta{Name: rn.GetName(), Namespace: rn.GetNamespace()},
+		},
+		File: &framework.File{Path: path},
+	}
+	if fieldPath != "" {
+		result.Field = &framework.Field{Path: fieldPath}
+	}
+	if i, err := strconv.Atoi(index); err == nil {
+		result.File.Index = i
+	}
+	return result
+}
+
+// isLocalConfig returns true if the resource is only used to configure
+// the package and is not applied to the cluster.
+func isLocalConfig(rn *yaml.RNode) bool {
+	return rn.GetAnnotations()[filters.LocalConfigAnnotation] == "true"
+}
+
+// stringField returns the value of a string field of the resource, or an
+// empty string if the field isn't set.
+func stringField(rn *yaml.RNode, field string) string {
+	if f := rn.Field(field); f != nil {
+		return yaml.GetValue(f.Value)
+	}
+	return ""
+}
+
+// resultError adds an error result for err to the resource list and returns
+// the results.
+func resultError(resourceList *framework.ResourceList, err error) error {
+	resourceList.Results = append(resourceList.Results, &framework.Result{
+		Message:  err.Error(),
+		Severity: framework.Error,
+	})
+	return resourceList.Results
+}
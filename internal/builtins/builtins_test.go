@@ -0,0 +1,90 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBuiltins runs the builtins implementing catalog functions on the
+// testdata fixtures. The fixtures are written from the documented behavior
+// of the catalog functions, they are not generated by the catalog images.
+func TestBuiltins(t *testing.T) {
+	for _, b := range registry {
+		if b.version == "" {
+			continue
+		}
+		dirs, err := os.ReadDir(filepath.Join("testdata", b.name))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		for _, dir := range dirs {
+			dir := filepath.Join("testdata", b.name, dir.Name())
+			t.Run(dir, func(t *testing.T) {
+				in, err := os.ReadFile(filepath.Join(dir, "in.yaml"))
+				assert.NoError(t, err)
+				exp, err := os.ReadFile(filepath.Join(dir, "out.yaml"))
+				assert.NoError(t, err)
+
+				run, found := Lookup(ImagePrefix + b.name)
+				assert.True(t, found)
+				out := &bytes.Buffer{}
+				assert.NoError(t, run(bytes.NewReader(in), out))
+				if diff := cmp.Diff(string(exp), out.String()); diff != "" {
+					t.Errorf("%s output mismatch (-want +got):\n%s", b.name, diff)
+				}
+			})
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	testCases := map[string]bool{
+		"builtins/gen-pkg-context":         true,
+		"builtins/set-namespace":           true,
+		"builtins/unknown":                 false,
+		"gcr.io/kpt-fn/set-namespace:v0.4": false,
+	}
+	for image, expected := range testCases {
+		_, found := Lookup(image)
+		assert.Equal(t, expected, found, image)
+	}
+}
+
+func TestCatalogBuiltin(t *testing.T) {
+	testCases := map[string]string{
+		"builtins/set-namespace":                "",
+		"gcr.io/kpt-fn/set-namespace:v0.4":      "builtins/set-namespace",
+		"gcr.io/kpt-fn/set-namespace:v0.4.1":    "builtins/set-namespace",
+		"gcr.io/kpt-fn/set-namespace:v0.3.4":    "",
+		"gcr.io/kpt-fn/set-namespace:v0.5.0-rc": "",
+		"gcr.io/kpt-fn/set-namespace:latest":    "",
+		"gcr.io/kpt-fn/set-labels:v0.2.0":       "builtins/set-labels",
+		"gcr.io/kpt-fn/gen-pkg-context:v0.1":    "",
+		"example.com/set-namespace:v0.4":        "",
+		"gcr.io/kpt-fn/set-namespace:v0.4@sha256:0000000000000000000000000000000000000000000000000000000000000000": "",
+	}
+	for image, expected := range testCases {
+		builtin, found := CatalogBuiltin(image)
+		assert.Equal(t, expected, builtin, image)
+		assert.Equal(t, expected != "", found, image)
+	}
+}
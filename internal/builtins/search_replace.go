@@ -0,0 +1,206 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/utils"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// The keys of the search-replace functionConfig.
+const (
+	byValue      = "by-value"
+	byValueRegex = "by-value-regex"
+	byPath       = "by-path"
+	byFilePath   = "by-file-path"
+	putValue     = "put-value"
+	putComment   = "put-comment"
+)
+
+// groupRef matches the references to the groups of the by-value-regex in
+// put-value and put-comment, e.g. `${1}`.
+var groupRef = regexp.MustCompile(`\$\{([0-9]+)\}`)
+
+// SearchReplace is a builtin implementation of the search-replace catalog
+// function. It searches the scalar fields matching a path, a value or a
+// regular expression, and optionally sets their value or line comment.
+type SearchReplace struct{}
+
+// Process implements framework.ResourceListProcessor interface.
+func (sr *SearchReplace) Process(resourceList *framework.ResourceList) error {
+	data, err := configData(resourceList.FunctionConfig, "", "")
+	if err != nil {
+		return resultError(resourceList, err)
+	}
+	if data[byValue] == "" && data[byValueRegex] == "" && data[byPath] == "" {
+		return resultError(resourceList, fmt.Errorf("at least one of [%s, %s, %s] must be provided", byValue, byValueRegex, byPath))
+	}
+	if data[byValue] != "" && data[byValueRegex] != "" {
+		return resultError(resourceList, fmt.Errorf("only one of [%s, %s] can be provided", byValue, byValueRegex))
+	}
+	var valueRegex *regexp.Regexp
+	if data[byValueRegex] != "" {
+		valueRegex, err = regexp.Compile("^(?:" + data[byValueRegex] + ")$")
+		if err != nil {
+			return resultError(resourceList, fmt.Errorf("invalid %s: %w", byValueRegex, err))
+		}
+	}
+	var pathPattern []string
+	if data[byPath] != "" {
+		pathPattern = splitPathPattern(data[byPath])
+	}
+
+	for _, rn := range resourceList.Items {
+		if data[byFilePath] != "" {
+			filePath, _, _ := kioutil.GetFileAnnotations(rn)
+			if matched, err := filepath.Match(data[byFilePath], filePath); err != nil || !matched {
+				continue
+			}
+		}
+		err := walkFields(rn, func(path []pathElem, _, node *yaml.Node) error {
+			if node.Kind != yaml.ScalarNode {
+				return nil
+			}
+			var groups []string
+			switch {
+			case data[byValue] != "" && node.Value != data[byValue]:
+				return nil
+			case valueRegex != nil:
+				if groups = valueRegex.FindStringSubmatch(node.Value); groups == nil {
+					return nil
+				}
+			}
+			if pathPattern != nil && !matchPath(pathPattern, path) {
+				return nil
+			}
+
+			fieldPath := pathString(path)
+			_, setValue := data[putValue]
+			_, setComment := data[putComment]
+			if setValue {
+				value := expandGroups(data[putValue], groups)
+				setScalar(node, value)
+				resourceList.Results = append(resourceList.Results,
+					fileResult(rn, fieldPath, fmt.Sprintf("Mutated field value to %q", value)))
+			}
+			if setComment {
+				comment := expandGroups(data[putComment], groups)
+				node.LineComment = "# " + comment
+				resourceList.Results = append(resourceList.Results,
+					fileResult(rn, fieldPath, fmt.Sprintf("Mutated field comment to %q", comment)))
+			}
+			if !setValue && !setComment {
+				resourceList.Results = append(resourceList.Results,
+					fileResult(rn, fieldPath, fmt.Sprintf("Matched field with value %q", node.Value)))
+			}
+			return nil
+		})
+		if err != nil {
+			return resultError(resourceList, err)
+		}
+	}
+	return nil
+}
+
+// expandGroups replaces the references to the groups of the by-value-regex
+// match in s.
+func expandGroups(s string, groups []string) string {
+	return groupRef.ReplaceAllStringFunc(s, func(ref string) string {
+		i, _ := strconv.Atoi(ref[2 : len(ref)-1])
+		if i >= len(groups) {
+			return ref
+		}
+		return groups[i]
+	})
+}
+
+// splitPathPattern splits a by-path pattern, e.g.
+// `spec.containers[name=nginx].image`, into the patterns of the elements of
+// the path. `*` matches any single element and `**` matches any number of
+// elements. Sequence elements are matched by index, e.g. `[0]`, or by the
+// value of one of their fields, e.g. `[name=nginx]`.
+func splitPathPattern(pattern string) []string {
+	var parts []string
+	for _, p := range utils.SmarterPathSplitter(pattern, ".") {
+		for p != "" {
+			i := strings.Index(p, "[")
+			if i < 0 {
+				parts = append(parts, p)
+				break
+			}
+			if i > 0 {
+				parts = append(parts, p[:i])
+			}
+			j := strings.Index(p[i:], "]")
+			if j < 0 {
+				parts = append(parts, p[i:])
+				break
+			}
+			parts = append(parts, p[i:i+j+1])
+			p = p[i+j+1:]
+		}
+	}
+	return parts
+}
+
+// matchPath returns true if the path matches the pattern elements.
+func matchPath(pattern []string, path []pathElem) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchPath(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 || !matchElem(pattern[0], path[0]) {
+		return false
+	}
+	return matchPath(pattern[1:], path[1:])
+}
+
+// matchElem returns true if the path element matches the pattern element.
+func matchElem(pattern string, elem pathElem) bool {
+	if pattern == "*" {
+		return true
+	}
+	if !strings.HasPrefix(pattern, "[") {
+		return elem.field != "" && elem.field == pattern
+	}
+	if elem.field != "" {
+		return false
+	}
+	selector := pattern[1 : len(pattern)-1]
+	if selector == "*" {
+		return true
+	}
+	if k, v, found := strings.Cut(selector, "="); found {
+		rn := yaml.NewRNode(elem.node)
+		return rn.YNode().Kind == yaml.MappingNode && stringField(rn, k) == v
+	}
+	i, err := strconv.Atoi(selector)
+	return err == nil && i == elem.index
+}
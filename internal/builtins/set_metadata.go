@@ -0,0 +1,81 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/api/filters/annotations"
+	"sigs.k8s.io/kustomize/api/filters/labels"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// SetLabels is a builtin implementation of the set-labels catalog function.
+// It sets labels on the resources and on the selectors and templates
+// matching them.
+type SetLabels struct{}
+
+// Process implements framework.ResourceListProcessor interface.
+func (sl *SetLabels) Process(resourceList *framework.ResourceList) error {
+	data, err := configData(resourceList.FunctionConfig, "SetLabels", "labels")
+	if err != nil {
+		return resultError(resourceList, err)
+	}
+	fltr := &labels.Filter{Labels: data, FsSlice: fieldSpecs(commonLabelFieldSpecs)}
+	count := 0
+	fltr.WithMutationTracker(countMutations(&count))
+	if resourceList.Items, err = fltr.Filter(resourceList.Items); err != nil {
+		return err
+	}
+	resourceList.Results = append(resourceList.Results, &framework.Result{
+		Message:  fmt.Sprintf("set %d labels in total", count),
+		Severity: framework.Info,
+	})
+	return nil
+}
+
+// SetAnnotations is a builtin implementation of the set-annotations catalog
+// function. It sets annotations on the resources and on their templates.
+type SetAnnotations struct{}
+
+// Process implements framework.ResourceListProcessor interface.
+func (sa *SetAnnotations) Process(resourceList *framework.ResourceList) error {
+	data, err := configData(resourceList.FunctionConfig, "SetAnnotations", "annotations")
+	if err != nil {
+		return resultError(resourceList, err)
+	}
+	fltr := &annotations.Filter{Annotations: data, FsSlice: fieldSpecs(commonAnnotationFieldSpecs)}
+	count := 0
+	fltr.WithMutationTracker(countMutations(&count))
+	if resourceList.Items, err = fltr.Filter(resourceList.Items); err != nil {
+		return err
+	}
+	resourceList.Results = append(resourceList.Results, &framework.Result{
+		Message:  fmt.Sprintf("set %d annotations in total", count),
+		Severity: framework.Info,
+	})
+	return nil
+}
+
+// countMutations returns a mutation tracker counting the entries whose
+// value is changed.
+func countMutations(count *int) func(key, value, tag string, node *yaml.RNode) {
+	return func(key, value, _ string, node *yaml.RNode) {
+		if f := node.Field(key); f == nil || yaml.GetValue(f.Value) != value {
+			*count++
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const dependsOnAnnotation = "config.kubernetes.io/depends-on"
+
+// SetNamespace is a builtin implementation of the set-namespace catalog
+// function. It moves the namespace-scoped resources of the package from
+// their namespace to the configured one.
+type SetNamespace struct{}
+
+// Process implements framework.ResourceListProcessor interface.
+func (sn *SetNamespace) Process(resourceList *framework.ResourceList) error {
+	namespace, matcher, err := sn.config(resourceList)
+	if err != nil {
+		return resultError(resourceList, err)
+	}
+	origin := matcher
+	if origin == "" {
+		origin, err = originNamespace(resourceList.Items)
+		if err != nil {
+			return resultError(resourceList, err)
+		}
+	}
+
+	count := 0
+	set := func(node *yaml.Node) {
+		if node.Value != namespace {
+			setScalar(node, namespace)
+			count++
+		}
+	}
+	for _, rn := range resourceList.Items {
+		if isLocalConfig(rn) {
+			continue
+		}
+		kind := rn.GetKind()
+		switch {
+		case kind == "Namespace" && rn.GetApiVersion() == "v1":
+			if rn.GetName() == origin && origin != namespace {
+				if err := rn.SetName(namespace); err != nil {
+					return err
+				}
+				count++
+			}
+		case !isClusterScoped(rn):
+			if ns := rn.GetNamespace(); ns == origin || ns == "" {
+				if ns != namespace {
+					if err := rn.SetNamespace(namespace); err != nil {
+						return err
+					}
+					count++
+				}
+			}
+		}
+		if (kind == "RoleBinding" || kind == "ClusterRoleBinding") &&
+			strings.HasPrefix(rn.GetApiVersion(), "rbac.authorization.k8s.io/") {
+			subjects, err := rn.Pipe(yaml.Lookup("subjects"))
+			if err != nil {
+				return err
+			}
+			if subjects != nil {
+				for _, s := range subjects.Content() {
+					subject := yaml.NewRNode(s)
+					ns := subject.Field(yaml.NamespaceField)
+					if stringField(subject, yaml.KindField) == "ServiceAccount" &&
+						ns != nil && ns.Value.YNode().Value == origin {
+						set(ns.Value.YNode())
+					}
+				}
+			}
+		}
+		if dependsOn, found := rn.GetAnnotations()[dependsOnAnnotation]; found {
+			updated := dependsOnNamespace(dependsOn, origin, namespace)
+			if updated != dependsOn {
+				if err := rn.PipeE(yaml.SetAnnotation(dependsOnAnnotation, updated)); err != nil {
+					return err
+				}
+				count++
+			}
+		}
+	}
+
+	resourceList.Results = append(resourceList.Results, &framework.Result{
+		Message:  fmt.Sprintf("namespace %q updated to %q, %d value(s) changed", origin, namespace, count),
+		Severity: framework.Info,
+	})
+	return nil
+}
+
+// config returns the namespace to set and the namespace of the resources to
+// update. The namespace defaults to the name of the package, from the
+// package context.
+func (sn *SetNamespace) config(resourceList *framework.ResourceList) (namespace string, matcher string, err error) {
+	fnConfig := resourceList.FunctionConfig
+	switch {
+	case fnConfig.IsNilOrEmpty():
+	case fnConfig.GetKind() == "ConfigMap" && fnConfig.GetName() == PkgContextName:
+		namespace = fnConfig.GetDataMap()["name"]
+	case fnConfig.GetKind() == "ConfigMap":
+		namespace = fnConfig.GetDataMap()["namespace"]
+		matcher = fnConfig.GetDataMap()["namespaceMatcher"]
+	case fnConfig.GetKind() == "SetNamespace":
+		namespace = stringField(fnConfig, "namespace")
+		matcher = stringField(fnConfig, "namespaceMatcher")
+	default:
+		return "", "", fmt.Errorf("functionConfig must be a ConfigMap or a SetNamespace, got %s", fnConfig.GetKind())
+	}
+	if namespace == "" && fnConfig.IsNilOrEmpty() {
+		for _, rn := range resourceList.Items {
+			if rn.GetKind() == "ConfigMap" && rn.GetName() == PkgContextName {
+				namespace = rn.GetDataMap()["name"]
+			}
+		}
+	}
+	if namespace == "" {
+		return "", "", fmt.Errorf("`namespace` must not be empty")
+	}
+	return namespace, matcher, nil
+}
+
+// originNamespace returns the namespace all the namespace-scoped resources
+// and Namespace objects of the package are in. Resources without a
+// namespace are ignored.
+func originNamespace(items []*yaml.RNode) (string, error) {
+	namespaces := map[string]bool{}
+	for _, rn := range items {
+		switch {
+		case isLocalConfig(rn):
+		case rn.GetKind() == "Namespace" && rn.GetApiVersion() == "v1":
+			namespaces[rn.GetName()] = true
+		case !isClusterScoped(rn) && rn.GetNamespace() != "":
+			namespaces[rn.GetNamespace()] = true
+		}
+	}
+	var found []string
+	for ns := range namespaces {
+		found = append(found, ns)
+	}
+	sort.Strings(found)
+	switch len(found) {
+	case 0:
+		return "", nil
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("all namespace-scoped resources must be in the same namespace, found %q; "+
+			"use `namespaceMatcher` to select the namespace to update", found)
+	}
+}
+
+// dependsOnNamespace updates the namespace of the namespace-scoped
+// resources referenced by a depends-on annotation, whose references have
+// the format `GROUP/namespaces/NAMESPACE/KIND/NAME`.
+func dependsOnNamespace(dependsOn, origin, namespace string) string {
+	refs := strings.Split(dependsOn, ",")
+	for i, ref := range refs {
+		parts := strings.Split(strings.TrimSpace(ref), "/")
+		if len(parts) == 5 && parts[1] == "namespaces" && parts[2] == origin {
+			parts[2] = namespace
+			refs[i] = strings.Join(parts, "/")
+		}
+	}
+	return strings.Join(refs, ",")
+}
+
+// isClusterScoped returns true if the resource is known to be
+// cluster-scoped. Resources of unknown types are namespace-scoped.
+func isClusterScoped(rn *yaml.RNode) bool {
+	return openapi.IsCertainlyClusterScoped(yaml.TypeMeta{APIVersion: rn.GetApiVersion(), Kind: rn.GetKind()})
+}
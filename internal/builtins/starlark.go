@@ -0,0 +1,55 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/starlark"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Starlark is a builtin implementation of the starlark catalog function.
+// It runs the starlark program in the `source` of its StarlarkRun
+// functionConfig, which reads and updates `ctx.resource_list`.
+type Starlark struct{}
+
+// Run function reads the function input `resourceList` from a given reader `r`
+// and writes the function output to the provided writer `w`.
+func (s *Starlark) Run(r io.Reader, w io.Writer) error {
+	in, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	rl, err := yaml.Parse(string(in))
+	if err != nil {
+		return err
+	}
+	fnConfig, err := rl.Pipe(yaml.Lookup("functionConfig"))
+	if err != nil {
+		return err
+	}
+	if fnConfig.IsNilOrEmpty() || fnConfig.GetKind() != "StarlarkRun" {
+		return fmt.Errorf("functionConfig must be a StarlarkRun")
+	}
+	source := stringField(fnConfig, "source")
+	if source == "" {
+		return fmt.Errorf("`source` must not be empty")
+	}
+	fltr := &starlark.Filter{Name: fnConfig.GetName(), Program: source}
+	return fltr.Run(bytes.NewReader(in), w)
+}
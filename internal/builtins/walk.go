@@ -0,0 +1,112 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// pathElem is an element of the path of a field in a resource.
+type pathElem struct {
+	// field is the name of the mapping field. It is empty for sequence
+	// elements.
+	field string
+
+	// index is the index of the sequence element.
+	index int
+
+	// node is the value of the field or the sequence element.
+	node *yaml.Node
+}
+
+// fieldVisitor is called with the path of every field and sequence element
+// of a resource. key is the key of the field, nil for sequence elements.
+type fieldVisitor func(path []pathElem, key, node *yaml.Node) error
+
+// walkFields calls visit for every field and sequence element of the
+// resource, except the annotations used internally by kpt.
+func walkFields(rn *yaml.RNode, visit fieldVisitor) error {
+	return walkNode(nil, nil, rn.YNode(), visit)
+}
+
+func walkNode(path []pathElem, key, node *yaml.Node, visit fieldVisitor) error {
+	if len(path) > 0 {
+		if err := visit(path, key, node); err != nil {
+			return err
+		}
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+			if isInternalAnnotation(path, k.Value) {
+				continue
+			}
+			elem := pathElem{field: k.Value, node: v}
+			if err := walkNode(append(path[:len(path):len(path)], elem), k, v, visit); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, e := range node.Content {
+			elem := pathElem{index: i, node: e}
+			if err := walkNode(append(path[:len(path):len(path)], elem), nil, e, visit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isInternalAnnotation returns true if the field of the mapping at path is
+// one of the annotations recording the origin of the resource.
+func isInternalAnnotation(path []pathElem, field string) bool {
+	if len(path) != 2 || path[0].field != yaml.MetadataField || path[1].field != yaml.AnnotationsField {
+		return false
+	}
+	return strings.HasPrefix(field, "internal.config.kubernetes.io/") ||
+		field == kioutil.LegacyPathAnnotation || field == kioutil.LegacyIndexAnnotation ||
+		field == kioutil.LegacyIdAnnotation
+}
+
+// pathString returns the path in the format used by function results,
+// e.g. `spec.containers[0].image`.
+func pathString(path []pathElem) string {
+	var b strings.Builder
+	for _, e := range path {
+		if e.field == "" {
+			b.WriteString("[" + strconv.Itoa(e.index) + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString(".")
+		}
+		b.WriteString(e.field)
+	}
+	return b.String()
+}
+
+// setScalar sets the value of a scalar node. Strings stay strings, and the
+// tag of other plain scalars is resolved again from the new value.
+func setScalar(node *yaml.Node, value string) {
+	node.Value = value
+	if node.Style == 0 && node.Tag != yaml.NodeTagString {
+		node.Tag = ""
+	}
+}
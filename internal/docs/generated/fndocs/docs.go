@@ -7,6 +7,74 @@ The ` + "`" + `fn` + "`" + ` command group contains subcommands for transforming
 using containerized functions.
 `
 
+var CacheShort = `Inspect and prune the cache of function invocations.`
+var CacheLong = `
+The ` + "`" + `cache` + "`" + ` command group contains subcommands for inspecting and pruning the
+cache of function invocations.
+
+` + "`" + `kpt fn render` + "`" + ` caches the output of container and exec functions. An invocation
+is addressed by the hash of its input resources, its ` + "`" + `functionConfig` + "`" + ` and the
+digest of the function image or executable. When the same invocation is made
+again, the cached output and results are replayed instead of running the
+function. Functions run with ` + "`" + `--allow-network` + "`" + `, and images always pulled by tag,
+are not cached. Pass ` + "`" + `--no-cache` + "`" + ` to ` + "`" + `kpt fn render` + "`" + ` to disable the cache.
+
+The cache is stored in the directory given by the ` + "`" + `KPT_FN_CACHE_DIR` + "`" + ` environment
+variable, which defaults to ` + "`" + `<HOME>/.kpt/fn/` + "`" + `.
+
+The same directory stores the precompiled wasm modules run with wasmtime, in
+the ` + "`" + `wasm-compiled` + "`" + ` subdirectory. A module is compiled once per module digest,
+wasmtime version and host CPU features, and the compiled artifact is reused by
+later ` + "`" + `kpt fn render` + "`" + ` and ` + "`" + `kpt fn eval` + "`" + ` runs. Artifacts which are corrupted or
+incompatible with the current binary are ignored and the module is compiled
+again. Remove the ` + "`" + `wasm-compiled` + "`" + ` directory to clear them.
+`
+
+var ListShort = `List the cached function invocations.`
+var ListLong = `
+  kpt fn cache list
+
+Environment Variables:
+
+  KPT_FN_CACHE_DIR:
+    Controls where function invocations are cached.
+    Defaults to <HOME>/.kpt/fn/
+`
+var ListExamples = `
+  # list the cached function invocations
+  $ kpt fn cache list
+`
+
+var PruneShort = `Remove cached function invocations.`
+var PruneLong = `
+  kpt fn cache prune [flags]
+
+Flags:
+
+  --all:
+    Remove all the cached function invocations.
+  
+  --older-than:
+    Remove the cached function invocations that have not been used for this
+    duration. Default: ` + "`" + `168h` + "`" + `.
+
+Environment Variables:
+
+  KPT_FN_CACHE_DIR:
+    Controls where function invocations are cached.
+    Defaults to <HOME>/.kpt/fn/
+`
+var PruneExamples = `
+  # remove the cached function invocations not used in the last 7 days
+  $ kpt fn cache prune
+
+  # remove the cached function invocations not used in the last day
+  $ kpt fn cache prune --older-than 24h
+
+  # empty the cache
+  $ kpt fn cache prune --all
+`
+
 var DocShort = `Display the documentation for a function`
 var DocLong = `
 ` + "`" + `kpt fn doc` + "`" + ` invokes the function container with ` + "`" + `--help` + "`" + ` flag.
@@ -19,13 +87,19 @@ Flags:
 
   --image, i: (required flag)
     Container image of the function e.g. ` + "`" + `gcr.io/kpt-fn/set-namespace:v0.1` + "`" + `.
-    For convenience, if full image path is not specified, ` + "`" + `gcr.io/kpt-fn/` + "`" + ` is added as default prefix.
+    For convenience, if full image path is not specified, the default registry of the
+    function registry config, ` + "`" + `gcr.io/kpt-fn/` + "`" + ` by default, is added as prefix.
     e.g. instead of passing ` + "`" + `gcr.io/kpt-fn/set-namespace:v0.1` + "`" + ` you can pass ` + "`" + `set-namespace:v0.1` + "`" + `.
 
 Environment Variables:
 
   KPT_FN_RUNTIME:
     The runtime to run kpt functions. It must be one of "docker", "podman" and "nerdctl".
+  
+  KPT_FN_REGISTRY_CONFIG:
+    The function registry config file setting the default registry, the mirrors
+    and the credentials of function images. Defaults to <HOME>/.kpt/fn-registry.yaml.
+    See ` + "`" + `kpt fn render` + "`" + ` for details.
 `
 var DocExamples = `
   # display the documentation for image set-namespace:v0.1.1
@@ -34,15 +108,18 @@ var DocExamples = `
 
 var EvalShort = `Execute function on resources`
 var EvalLong = `
-  kpt fn eval [DIR|-] [flags] [-- fn-args]
+  kpt fn eval [DIR...|-] [flags] [-- fn-args]
 
 Args:
 
-  DIR|-:
+  DIR...|-:
     Path to the local directory containing resources. Defaults to the current
-    working directory. Using '-' as the directory path will cause ` + "`" + `eval` + "`" + ` to
-    read resources from ` + "`" + `stdin` + "`" + ` and write the output to ` + "`" + `stdout` + "`" + `. When resources are
-    read from ` + "`" + `stdin` + "`" + `, they must be in one of the following input formats:
+    working directory. Several directories or glob patterns such as
+    ` + "`" + `deployments/*` + "`" + ` can be given to run the function on several packages, see
+    ` + "`" + `--batch` + "`" + `. The packages must not be nested. Using '-' as the directory path
+    will cause ` + "`" + `eval` + "`" + ` to read resources from ` + "`" + `stdin` + "`" + ` and write the output to
+    ` + "`" + `stdout` + "`" + `. When resources are read from ` + "`" + `stdin` + "`" + `, they must be in one of the
+    following input formats:
   
     1. Multi object YAML where resources are separated by ` + "`" + `---` + "`" + `.
   
@@ -63,6 +140,24 @@ Flags:
     By default, container function is executed as ` + "`" + `nobody` + "`" + ` user. You may want to use
     this flag to run higher privilege operations such as mounting the local filesystem.
   
+  --batch:
+    When several packages are given, send the resources of all the packages to
+    the function in a single ` + "`" + `ResourceList` + "`" + ` so that the function is run once.
+    The paths of the resources are relative to the common parent directory of
+    the packages during the run, and the output resources are written back to
+    the package of their path. Resources without a path, such as the ones
+    generated by the function, are written to the first package. By default,
+    the function is run once per package. In both cases, the results are
+    attributed to the package of the files they refer to. ` + "`" + `--output` + "`" + ` and
+    ` + "`" + `--save` + "`" + ` can't be used with several packages.
+  
+  --builtin-catalog:
+    Run the images of the catalog functions implemented by kpt with the builtin
+    function implementing them, in-process, when set to ` + "`" + `auto` + "`" + `. The builtin
+    is recorded in the ` + "`" + `builtin` + "`" + ` field of the function results. Images pinned to
+    a digest, and functions which need mounts or environment variables, are
+    always run in a container. One of ` + "`" + `auto` + "`" + `, ` + "`" + `off` + "`" + `. Default: ` + "`" + `off` + "`" + `.
+  
   --env, e:
     List of local environment variables to be exported to the container function.
     By default, none of local environment variables are made available to the
@@ -79,9 +174,26 @@ Flags:
   --fn-config:
     Path to the file containing ` + "`" + `functionConfig` + "`" + ` for the function.
   
+  --fn-policy:
+    Path to a function policy file restricting the functions that can be run. The
+    nearest ` + "`" + `.kpt/fn-policy.yaml` + "`" + ` file in the package directory or its parents and
+    the ` + "`" + `~/.kpt/fn-policy.yaml` + "`" + ` file are also enforced when they exist, and the
+    function must be allowed by all the policies. See ` + "`" + `kpt fn render` + "`" + ` for the
+    format of the policy file.
+  
+  --fn-runtime-endpoint:
+    Endpoint of a function evaluator service to run the function image with, of
+    the form ` + "`" + `[grpc://|grpcs://]HOST:PORT` + "`" + `. The resource list is sent to the
+    service over gRPC, using TLS unless the endpoint has the ` + "`" + `grpc://` + "`" + ` scheme. If
+    the service doesn't know about the image, or if the function needs mounts,
+    environment variables, network access or to run as the current user, it is
+    run locally. Defaults to ` + "`" + `$KPT_FN_RUNTIME` + "`" + ` if it is a ` + "`" + `grpc://` + "`" + ` or
+    ` + "`" + `grpcs://` + "`" + ` endpoint.
+  
   --image, i:
     Container image of the function to execute e.g. ` + "`" + `gcr.io/kpt-fn/set-namespace:v0.1` + "`" + `.
-    For convenience, if full image path is not specified, ` + "`" + `gcr.io/kpt-fn/` + "`" + ` is added as default prefix.
+    For convenience, if full image path is not specified, the default registry of the
+    function registry config, ` + "`" + `gcr.io/kpt-fn/` + "`" + ` by default, is added as prefix.
     e.g. instead of passing ` + "`" + `gcr.io/kpt-fn/set-namespace:v0.1` + "`" + ` you can pass ` + "`" + `set-namespace:v0.1` + "`" + `.
     ` + "`" + `eval` + "`" + ` executes only one function, so do not use ` + "`" + `--exec` + "`" + ` flag with this flag.
   
@@ -118,8 +230,8 @@ Flags:
     readonly by default. Specify ` + "`" + `rw=true` + "`" + ` to mount volumes in read-write mode.
   
   --network:
-    If enabled, container functions are allowed to access network.
-    By default it is disabled.
+    If enabled, container functions and sandboxed executables are allowed to
+    access network. By default it is disabled.
   
   --output, o:
     If specified, the output resources are written to provided location,
@@ -141,7 +253,27 @@ Flags:
     it doesn't exist. Structured results emitted by the functions are aggregated and saved
     to ` + "`" + `results.yaml` + "`" + ` file in the specified directory.
     If not specified, no result files are written to the local filesystem.
-    
+  
+  --results-format:
+    Formats to write the structured results in, with ` + "`" + `--results-dir` + "`" + `. Can be
+    repeated or comma separated to write several formats at once. One of:
+  
+    1. ` + "`" + `yaml` + "`" + `: the ` + "`" + `FunctionResultList` + "`" + ` resource, in ` + "`" + `results.yaml` + "`" + `.
+    2. ` + "`" + `sarif` + "`" + `: a SARIF 2.1.0 log with a run per function, in ` + "`" + `results.sarif` + "`" + `.
+       The file, resource and field of the results are mapped to locations
+       with the line number in the package file.
+    3. ` + "`" + `junit` + "`" + `: a JUnit XML report with a testcase per function, in
+       ` + "`" + `results.junit.xml` + "`" + `.
+  
+    Default: ` + "`" + `yaml` + "`" + `.
+  
+  --sandbox-exec:
+    Run the executable of ` + "`" + `--exec` + "`" + ` in a sandbox, on Linux only. The executable
+    only sees the system directories such as ` + "`" + `/usr` + "`" + `, read-only, and a writable
+    ` + "`" + `/tmp` + "`" + ` which is also its home and working directory. It runs without the
+    environment variables of kpt and without network access unless ` + "`" + `--network` + "`" + ` is
+    set. See ` + "`" + `kpt fn render` + "`" + ` for details.
+  
   --save, s:
     Save the function image and fn-config to Kptfile. Require ` + "`" + ` + "` + "`" + `" + ` + "`" + `--image` + "`" + ` + "` + "`" + `" + ` + "`" + `.
     
@@ -149,7 +281,14 @@ Flags:
 Environment Variables:
 
   KPT_FN_RUNTIME:
-    The runtime to run kpt functions. It must be one of "docker", "podman" and "nerdctl".
+    The runtime to run kpt functions. It must be one of "docker", "podman" and "nerdctl",
+    or the ` + "`" + `grpc://HOST:PORT` + "`" + ` or ` + "`" + `grpcs://HOST:PORT` + "`" + ` endpoint of a function evaluator
+    service, in which case docker runs the functions unknown to the service.
+  
+  KPT_FN_REGISTRY_CONFIG:
+    The function registry config file setting the default registry, the mirrors
+    and the credentials of function images. Defaults to <HOME>/.kpt/fn-registry.yaml.
+    See ` + "`" + `kpt fn render` + "`" + ` for details.
 `
 var EvalExamples = `
   # execute container my-fn on the resources in DIR directory and
@@ -220,9 +359,21 @@ var EvalExamples = `
   # in current directory
   kpt fn eval -i set-namespace:v0.1 --by-kind Deployment --by-name foo -- namespace=staging
 
+  # execute container set-image on the resources of all the packages in the
+  # deployments directory, once per package
+  $ kpt fn eval deployments/* -i set-image:v0.1 -- name=app newTag=v2
+  
+  # execute container set-image once on the resources of all the packages in the
+  # deployments directory
+  $ kpt fn eval 'deployments/*' --batch -i set-image:v0.1 -- name=app newTag=v2
+
   # execute container my-fn with podman on the resources in DIR directory and
   # write output back to DIR
   $ KPT_FN_RUNTIME=podman kpt fn eval DIR -i gcr.io/example.com/my-fn
+
+  # execute container my-fn with a function evaluator service on the resources
+  # in DIR directory and write output back to DIR
+  $ KPT_FN_RUNTIME=grpc://fn-evaluator:9445 kpt fn eval DIR -i gcr.io/example.com/my-fn
 `
 
 var ExportShort = `Auto-generating function pipelines for different workflow orchestrators`
@@ -257,6 +408,36 @@ var ExportExamples = `
   kpt fn export DIR/ --fn-path FUNCTIONS_DIR/ --workflow cloud-build
 `
 
+var LockShort = `Pin the images of the pipeline functions to digests.`
+var LockLong = `
+  kpt fn lock [PKG_PATH] [flags]
+
+Args:
+
+  PKG_PATH:
+    Local package path to lock. Directory must exist and contain a Kptfile.
+    Defaults to the current working directory.
+
+Flags:
+
+  --update:
+    Resolve the digests of the images which are already pinned again. By default,
+    only the images which are not pinned yet are resolved and the images removed
+    from the pipeline are unpinned.
+`
+var LockExamples = `
+  # Pin the function images of the package in the current directory and its
+  # subpackages.
+  $ kpt fn lock
+
+  # Pin the function images of my-package-dir to the digests their tags
+  # currently point to.
+  $ kpt fn lock my-package-dir --update
+
+  # Render my-package-dir, failing if a function image is not pinned.
+  $ kpt fn render my-package-dir --locked
+`
+
 var RenderShort = `Render a package.`
 var RenderLong = `
   kpt fn render [PKG_PATH] [flags]
@@ -274,14 +455,52 @@ Flags:
     can perform privileged operations on your system, so ensure that binaries
     referred in the pipeline are trusted and safe to execute.
   
+  --allow-mount:
+    Allow container functions to mount the files and directories of their package
+    declared in their ` + "`" + `mounts` + "`" + `. The mounts are read-only and must be inside the
+    package. Default: ` + "`" + `false` + "`" + `.
+  
   --allow-network:
-    Allow functions to access network during pipeline execution. Default: ` + "`" + `false` + "`" + `. Note that this is applicable to container based functions only.
+    Allow functions to access network during pipeline execution. Default: ` + "`" + `false` + "`" + `. Note that this is applicable to container based functions and sandboxed executable binaries only.
+  
+  --builtin-catalog:
+    Run the images of the catalog functions implemented by kpt with the builtin
+    function implementing them, in-process, when set to ` + "`" + `auto` + "`" + `. The builtin
+    is recorded in the ` + "`" + `builtin` + "`" + ` field of the function results. Images pinned to
+    a digest, and functions which need mounts, are always run in a container.
+    One of ` + "`" + `auto` + "`" + `, ` + "`" + `off` + "`" + `. Default: ` + "`" + `off` + "`" + `.
+  
+  --fn-policy:
+    Path to a function policy file restricting the functions that can be run. The
+    nearest ` + "`" + `.kpt/fn-policy.yaml` + "`" + ` file in the package directory or its parents and
+    the ` + "`" + `~/.kpt/fn-policy.yaml` + "`" + ` file are also enforced when they exist, and every
+    function must be allowed by all the policies. See Function Policies below.
+  
+  --fn-runtime-endpoint:
+    Endpoint of a function evaluator service to run function images with, of the
+    form ` + "`" + `[grpc://|grpcs://]HOST:PORT` + "`" + `. The resource list is sent to the service
+    over gRPC, using TLS unless the endpoint has the ` + "`" + `grpc://` + "`" + ` scheme. Functions
+    the service doesn't know about, exec functions, and functions with mounts or
+    memory or cpu limits, are run locally, as are all the functions when
+    ` + "`" + `--allow-network` + "`" + ` is set. Defaults to ` + "`" + `$KPT_FN_RUNTIME` + "`" + ` if it is a
+    ` + "`" + `grpc://` + "`" + ` or ` + "`" + `grpcs://` + "`" + ` endpoint.
   
   --image-pull-policy:
     If the image should be pulled before rendering the package(s). It can be set
     to one of always, ifNotPresent, never. If unspecified, always will be the
     default.
   
+  --locked:
+    Fail if the image of a function is not pinned to a digest in the
+    ` + "`" + `functionLock` + "`" + ` of the Kptfile of its package, or in the image itself. See
+    ` + "`" + `kpt fn lock` + "`" + `. Default: ` + "`" + `false` + "`" + `.
+  
+  --no-cache:
+    Do not replay the cached outputs of functions and do not cache the outputs of
+    this run. By default, the outputs of container and exec functions are cached
+    and replayed when a function is invoked again with the same input resources,
+    functionConfig and image digest (or executable). See ` + "`" + `kpt fn cache` + "`" + `.
+  
   --output, o:
     If specified, the output resources are written to provided location,
     if not specified, resources are modified in-place.
@@ -291,16 +510,155 @@ Flags:
     3. OUT_DIR_PATH: output resources are written to provided directory.
        The provided directory must not already exist.
   
+  --parallelism:
+    Maximum number of package pipelines to run concurrently. When greater than 1,
+    sibling subpackages are rendered concurrently. The output resources, the
+    structured results and the CLI output are the same as for a sequential render,
+    except when a subpackage fails: its siblings have been rendered as well, and
+    their results and CLI output are reported before the error.
+    Default: ` + "`" + `1` + "`" + `.
+  
   --results-dir:
     Path to a directory to write structured results. Directory will be created if
     it doesn't exist. Structured results emitted by the functions are aggregated and saved
     to ` + "`" + `results.yaml` + "`" + ` file in the specified directory.
     If not specified, no result files are written to the local filesystem.
+  
+  --results-format:
+    Formats to write the structured results in, with ` + "`" + `--results-dir` + "`" + `. Can be
+    repeated or comma separated to write several formats at once. One of:
+  
+    1. ` + "`" + `yaml` + "`" + `: the ` + "`" + `FunctionResultList` + "`" + ` resource, in ` + "`" + `results.yaml` + "`" + `.
+    2. ` + "`" + `sarif` + "`" + `: a SARIF 2.1.0 log with a run per function, in ` + "`" + `results.sarif` + "`" + `.
+       The file, resource and field of the results are mapped to locations
+       with the line number in the package file.
+    3. ` + "`" + `junit` + "`" + `: a JUnit XML report with a testcase per function, in
+       ` + "`" + `results.junit.xml` + "`" + `.
+  
+    Default: ` + "`" + `yaml` + "`" + `.
+  
+  --sandbox-exec:
+    Run executable binaries in a sandbox, on Linux only. The sandbox is built
+    from user, mount, pid and network namespaces and a seccomp filter. The
+    executable only sees the system directories such as ` + "`" + `/usr` + "`" + `, read-only, and a
+    writable ` + "`" + `/tmp` + "`" + ` which is also its home and working directory. It runs without
+    the environment variables of kpt and without network access unless
+    ` + "`" + `--allow-network` + "`" + ` is set. Unprivileged user namespaces must be enabled.
+    Default: ` + "`" + `false` + "`" + `.
+  
+  --trace-dir:
+    Path to a directory to write a trace of the pipeline steps to. The directory
+    must not already exist and must be outside of the package. For every package,
+    the input resources are written to ` + "`" + `<TRACE_DIR>/<PKG>/input.yaml` + "`" + ` and every
+    function run is recorded in
+    ` + "`" + `<TRACE_DIR>/<PKG>/step-<INDEX>-<STAGE>-<FUNCTION>/` + "`" + ` with its status, timing
+    and stderr. Mutator steps also include the resulting resources and a unified
+    diff against the previous step. Use ` + "`" + `kpt fn trace view` + "`" + ` to browse the trace.
+  
+  --watch:
+    Render the package, then render it again every time one of its files changes,
+    until interrupted. Only the packages containing the changed files and their
+    ancestors are rendered again, the output of the other packages is reused. A
+    burst of changes triggers a single render and the writes of render itself are
+    ignored. A summary is printed after every render, along with the output of
+    render when it fails. Cannot be used with ` + "`" + `--output` + "`" + ` or ` + "`" + `--trace-dir` + "`" + `.
+    Default: ` + "`" + `false` + "`" + `.
 
 Environment Variables:
 
   KPT_FN_RUNTIME:
-    The runtime to run kpt functions. It must be one of "docker", "podman" and "nerdctl".
+    The runtime to run kpt functions. It must be one of "docker", "podman" and "nerdctl",
+    or the ` + "`" + `grpc://HOST:PORT` + "`" + ` or ` + "`" + `grpcs://HOST:PORT` + "`" + ` endpoint of a function evaluator
+    service, in which case docker runs the functions unknown to the service.
+  
+  KPT_FN_CACHE_DIR:
+    Controls where function invocations are cached.
+    Defaults to <HOME>/.kpt/fn/
+  
+  KPT_FN_REGISTRY_CONFIG:
+    The function registry config file setting the default registry, the mirrors
+    and the credentials of function images. Defaults to <HOME>/.kpt/fn-registry.yaml.
+    See Function Registries below.
+
+Function Policies:
+
+A function policy file restricts the function images that can be run, for
+example to the images of an internal mirror:
+
+  apiVersion: kpt.dev/v1alpha1
+  kind: FunctionPolicy
+  # Glob patterns the image repository must match, ` + "`" + `*` + "`" + ` matches within a path
+  # segment and ` + "`" + `**` + "`" + ` across segments. All repositories are allowed if empty.
+  allowedImages:
+    - mirror.example.com/kpt-fn/**
+  # Glob patterns of denied image repositories, or repositories and tags.
+  deniedImages:
+    - mirror.example.com/kpt-fn/apply-setters:v0.1.*
+  # Require images to be pinned to a digest, see ` + "`" + `kpt fn lock` + "`" + `.
+  requireDigest: true
+  # Minimum semantic versions of the matching images.
+  minVersions:
+    - image: mirror.example.com/kpt-fn/set-namespace
+      version: v0.4.1
+  # Allow executable functions. Default: false.
+  allowExec: false
+  # Signatures required for the images of the matching repositories.
+  signatures:
+    # signed with a private key, e.g. by ` + "`" + `cosign sign --key cosign.key` + "`" + ` or
+    # ` + "`" + `kpt alpha wasm push --sign-key cosign.key` + "`" + `.
+    - image: mirror.example.com/kpt-fn/**
+      key: cosign.pub
+    # signed with a short-lived certificate issued to an identity.
+    - image: gcr.io/my-project/kpt-fn/**
+      keyless:
+        # sigstore trusted root with the certificate authorities and the
+        # transparency log keys, e.g. from ` + "`" + `cosign trusted-root create` + "`" + `.
+        bundle: trusted_root.json
+        identity: release@example.com
+        issuer: https://accounts.google.com
+
+Signatures are verified offline before running a function, and the function
+image is then run by the verified digest. Signatures are stored in the
+registry in the same format as cosign, so images signed with cosign can be
+verified and images signed with kpt can be verified with cosign. Keyless
+signatures must be recorded in the transparency log of the trusted root, and
+their certificate must be valid when they were recorded. Certificate
+transparency logs are not checked. The paths of the keys and bundles are
+relative to the policy file. The digest and the signer of the verified
+signature are recorded in the function results.
+
+Function Registries:
+
+A function registry config file, ` + "`" + `~/.kpt/fn-registry.yaml` + "`" + ` or the file in
+` + "`" + `$KPT_FN_REGISTRY_CONFIG` + "`" + `, configures the registries function images are
+pulled from, for example to pull them from an internal mirror:
+
+  apiVersion: kpt.dev/v1alpha1
+  kind: FunctionRegistryConfig
+  # Registry the short names of functions are resolved in.
+  # Default: gcr.io/kpt-fn
+  defaultRegistry: registry.example.com/kpt-fn
+  # Repositories images are pulled from instead of their declared repository.
+  # The first matching mirror is used, a trailing ` + "`" + `/*` + "`" + ` matches all the
+  # repositories under the prefix.
+  mirrors:
+    - source: gcr.io/kpt-fn/*
+      mirror: registry.example.com/kpt-fn/*
+  # Credentials of registries, used instead of the docker config.
+  credentials:
+    - registry: registry.example.com
+      username: kpt
+      # environment variable holding the password, or passwordFile with the
+      # path of the file holding it, relative to the config file.
+      passwordEnv: REGISTRY_PASSWORD
+
+Mirrors apply to container and wasm functions, and to ` + "`" + `kpt fn doc` + "`" + `. Function
+policies, including their required signatures, apply to the image pulled from
+the mirror, so a policy allowing only the images of the mirror allows the
+declared images it mirrors. The function lock and the ` + "`" + `kpt fn eval --save` + "`" + `
+option use the declared image, and the image pulled from the mirror must have
+the same digest. Signatures are read from the mirror. The declared image is
+recorded as ` + "`" + `originalImage` + "`" + ` in the function results when a mirror is used.
 `
 var RenderExamples = `
   # Render the package in current directory
@@ -309,9 +667,17 @@ var RenderExamples = `
   # Render the package in current directory and save results in my-results-dir
   $ kpt fn render --results-dir my-results-dir
 
+  # Render the package in current directory and save results in my-results-dir
+  # as SARIF and JUnit XML reports
+  $ kpt fn render --results-dir my-results-dir --results-format sarif,junit
+
   # Render my-package-dir
   $ kpt fn render my-package-dir
 
+  # Render the package in current directory and record a trace of the
+  # pipeline steps in my-trace-dir
+  $ kpt fn render --trace-dir my-trace-dir
+
   # Render the package in current directory and write output resources to another DIR
   $ kpt fn render -o path/to/dir
 
@@ -330,6 +696,19 @@ var RenderExamples = `
 
   # Render my-package-dir with network access enabled for functions
   $ kpt fn render --allow-network
+
+  # Render my-package-dir running the executable binaries in a sandbox
+  $ kpt fn render my-package-dir --allow-exec --sandbox-exec
+
+  # Render my-package-dir rendering up to 8 subpackages concurrently
+  $ kpt fn render my-package-dir --parallelism 8
+
+  # Render my-package-dir every time one of its files changes
+  $ kpt fn render my-package-dir --watch
+
+  # Render my-package-dir with the functions evaluated by a function evaluator
+  # service
+  $ kpt fn render my-package-dir --fn-runtime-endpoint fn-evaluator.example.com:443
 `
 
 var SinkShort = `Write resources to a local directory`
@@ -382,3 +761,47 @@ var SourceExamples = `
     kpt fn eval - --image gcr.io/example.com/my-fn - |
     kpt fn sink DIR
 `
+
+var TraceShort = `Browse the traces of the pipeline steps written by ` + "`" + `kpt fn render` + "`" + `.`
+var TraceLong = `
+The ` + "`" + `trace` + "`" + ` command group contains subcommands for browsing the traces written
+by ` + "`" + `kpt fn render --trace-dir` + "`" + `.
+
+A trace records every function run of the package pipelines: the resources
+after each mutator, a unified diff against the previous step, the stderr of the
+function and its timing. It helps finding which function of a pipeline
+introduced a change.
+`
+
+var ViewShort = `Display a trace written by ` + "`" + `kpt fn render --trace-dir` + "`" + `.`
+var ViewLong = `
+  kpt fn trace view TRACE_DIR [flags]
+
+Args:
+
+  TRACE_DIR:
+    Path to the directory given to ` + "`" + `kpt fn render --trace-dir` + "`" + `.
+
+Flags:
+
+  --pkg:
+    Path of the package relative to the root package, used with ` + "`" + `--step` + "`" + `.
+    Default: ` + "`" + `.` + "`" + `, i.e. the root package.
+  
+  --resources:
+    Display the resources after the step instead of the diff, used with ` + "`" + `--step` + "`" + `.
+  
+  --step:
+    Index of the step to display the details of. Steps are numbered from 1 in
+    the order of the pipeline of the package, validators after mutators.
+`
+var ViewExamples = `
+  # list the steps of the trace in my-trace-dir
+  $ kpt fn trace view my-trace-dir
+
+  # display the diff of the second step of the root package
+  $ kpt fn trace view my-trace-dir --step 2
+
+  # display the resources after the first step of the subpackage db
+  $ kpt fn trace view my-trace-dir --pkg db --step 1 --resources
+`
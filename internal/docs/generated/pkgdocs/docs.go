@@ -4,7 +4,7 @@ package pkgdocs
 var PkgShort = `Get, update, and describe packages with resources`
 var PkgLong = `
 The ` + "`" + `pkg` + "`" + ` command group contains subcommands for fetching, updating and describing ` + "`" + `kpt` + "`" + ` packages
-from git repositories.
+from git repositories and OCI registries.
 `
 
 var CatShort = `Print the resources in a file/directory`
@@ -39,6 +39,9 @@ Args:
     A git tag, branch, or commit. Specified after the local_package with @, for
     example my-package@master.
     Defaults to the local package version that was last fetched.
+    For packages fetched from an OCI registry, the version is the tag or digest
+    of the image and defaults to the image in the Kptfile, so that the remote
+    changes since the digest that was last fetched are shown.
 
 Flags:
 
@@ -88,6 +91,14 @@ Environment Variables:
     Defaults to <HOME>/.kpt/repos/
     On macOS and Linux <HOME> is determined by the $HOME env variable, while on
     Windows it is given by the %USERPROFILE% env variable.
+  
+  KPT_GIT_BACKEND:
+    Selects how kpt runs git to fetch remote packages. Must be one of ` + "`" + `go` + "`" + ` to
+    use the git implementation built into kpt, or ` + "`" + `exec` + "`" + ` to run the git
+    executable installed on the host. Defaults to ` + "`" + `go` + "`" + `, which reads the
+    credentials of https repos from the .netrc file, and falls back to the git
+    executable, e.g. to use its credential helpers, when a repo requires
+    credentials it doesn't have.
 `
 var DiffExamples = `
 
@@ -95,9 +106,10 @@ var DiffExamples = `
   $ kpt pkg diff
 `
 
-var GetShort = `Fetch a package from a git repo.`
+var GetShort = `Fetch a package from a git repo or an OCI registry.`
 var GetLong = `
   kpt pkg get REPO_URI[.git]/PKG_PATH[@VERSION] [LOCAL_DEST_DIRECTORY] [flags]
+  kpt pkg get oci://IMAGE[//PKG_PATH] [LOCAL_DEST_DIRECTORY] [flags]
 
 Args:
 
@@ -110,14 +122,26 @@ Args:
     the argument.
   
   PKG_PATH:
-    Path to remote subdirectory containing Kubernetes resource configuration
-    files or directories. Defaults to the root directory.
+    Path to remote subdirectory of the repository or image containing
+    Kubernetes resource configuration files or directories. Defaults to the
+    root directory.
     Uses '/' as the path separator (regardless of OS).
     e.g. staging/cockroachdb
   
   VERSION:
     A git tag, branch, ref or commit for the remote version of the package
     to fetch. Defaults to the default branch of the repository.
+    It can also be a semantic version constraint, e.g. '~1.4' or '>=2.0.0 <3',
+    which fetches the tag with the highest matching version. The tags prefixed
+    with the package directory are used first, like for other tags. A prefix
+    of the tags can be set before the constraint, e.g. 'package-a/v^1.2'.
+  
+  IMAGE:
+    Image in an OCI registry containing the package, including its tag or
+    digest. The tag defaults to 'latest'. The package is the content of the
+    image as published by 'kpt pkg push'. The digest of the fetched image is
+    recorded in the Kptfile.
+    e.g. us-docker.pkg.dev/my-project/blueprints/cockroachdb:v1
   
   LOCAL_DEST_DIRECTORY:
     The local directory to write the package to. Defaults to a subdirectory of the
@@ -148,6 +172,14 @@ Env Vars:
     Defaults to <HOME>/.kpt/repos/
     On macOS and Linux <HOME> is determined by the $HOME env variable, while on
     Windows it is given by the %USERPROFILE% env variable.
+  
+  KPT_GIT_BACKEND:
+    Selects how kpt runs git to fetch remote packages. Must be one of ` + "`" + `go` + "`" + ` to
+    use the git implementation built into kpt, or ` + "`" + `exec` + "`" + ` to run the git
+    executable installed on the host. Defaults to ` + "`" + `go` + "`" + `, which reads the
+    credentials of https repos from the .netrc file, and falls back to the git
+    executable, e.g. to use its credential helpers, when a repo requires
+    credentials it doesn't have.
 `
 var GetExamples = `
 
@@ -171,6 +203,11 @@ var GetExamples = `
   # Create a deployable instance of examples package from github.com/kubernetes/examples
   # This will create a new directory 'examples' for the package.
   $ kpt pkg get https://github.com/kubernetes/examples.git/@6fe2792 --for-deployment
+
+  # Fetch package cockroachdb from the image published in Artifact Registry
+  # with the tag v1.
+  # This creates a new subdirectory 'cockroachdb' for the downloaded package.
+  $ kpt pkg get oci://us-docker.pkg.dev/my-project/blueprints/cockroachdb:v1
 `
 
 var InitShort = `Initialize an empty package.`
@@ -203,6 +240,73 @@ var InitExamples = `
   $ kpt pkg init
 `
 
+var OutdatedShort = `Report the packages that are behind their upstream.`
+var OutdatedLong = `
+  kpt pkg outdated [DIR] [flags]
+
+Args:
+
+  DIR:
+    Local package path to check. Directory must exist and contain a Kptfile.
+    Defaults to the current working directory.
+
+Flags:
+
+  --output:
+    The output format. Must be one of: text, json. Defaults to text, which
+    prints a table with the following columns:
+  
+      * PACKAGE: the path of the package, relative to DIR.
+      * REF: the upstream ref of the package, or the tag or digest of its
+        upstream image.
+      * CURRENT: the ref and the commit, or the tag and the digest, the
+        package was last fetched from.
+      * LATEST TAG: the tag with the highest version in the upstream
+        repository, among the tags with the same prefix as the ref.
+      * BEHIND: the number of commits the package is behind its upstream ref.
+      * LOCAL CHANGES: whether the package has been changed locally since it
+        was fetched.
+      * STATUS: outdated if the upstream ref references a newer commit, if a
+        tag has a higher version than the current ref, or if the upstream
+        image resolves to a different digest.
+  
+  --fail-if-outdated:
+    Exit with a non-zero status if a package is outdated.
+`
+var OutdatedExamples = `
+  # Report the outdated packages in the current directory.
+  $ kpt pkg outdated
+
+  # Report the outdated packages in my-package-dir/ as JSON.
+  $ kpt pkg outdated my-package-dir/ --output json
+
+  # Fail if a package in my-package-dir/ is outdated, e.g. in CI.
+  $ kpt pkg outdated my-package-dir/ --fail-if-outdated
+`
+
+var PushShort = `Publish a package to an OCI registry.`
+var PushLong = `
+  kpt pkg push [oci://]IMAGE [PKG_PATH]
+
+Args:
+
+  IMAGE:
+    Image to publish the package as, including its tag. The tag defaults to
+    'latest'.
+    e.g. us-docker.pkg.dev/my-project/blueprints/cockroachdb:v1
+  
+  PKG_PATH:
+    Local package path to publish. Directory must exist and contain a Kptfile.
+    Defaults to the current working directory.
+`
+var PushExamples = `
+  # Publish the package in the current directory with the tag v1.
+  $ kpt pkg push us-docker.pkg.dev/my-project/blueprints/cockroachdb:v1
+
+  # Publish the package in the cockroachdb directory.
+  $ kpt pkg push oci://us-docker.pkg.dev/my-project/blueprints/cockroachdb:v1 cockroachdb
+`
+
 var TreeShort = `Display resources, files and packages in a tree structure.`
 var TreeLong = `
   kpt pkg tree [DIR]
@@ -231,6 +335,14 @@ Args:
       * branch: update the local contents to the tip of the remote branch
       * tag: update the local contents to the remote tag
       * commit: update the local contents to the remote commit
+      * version constraint: update the local contents to the remote tag with
+        the highest version matching the constraint, e.g. '~1.4' or
+        '>=2.0.0 <3', optionally prefixed by the prefix of the tags, e.g.
+        'package-a/v^1.2'. The constraint is kept in the Kptfile so that the
+        next updates move to the newest matching tag.
+  
+    For packages fetched from an OCI registry, the version is the tag or digest
+    of the image, e.g. pkg@v2 or pkg@sha256:<digest>.
 
 Flags:
 
@@ -246,6 +358,27 @@ Flags:
         since it was fetched.
       * force-delete-replace: Wipe all the local changes to the package and replace
         it with the remote version.
+  
+  --resolve:
+    Resolves the conflicts recorded by a previous update with the resource-merge
+    strategy in the .kptconflicts file of the package, instead of updating the
+    package.
+  
+      * ours: Keep the local values of the conflicting fields.
+      * theirs: Set the conflicting fields to their upstream values, or remove
+        them if they have been removed from upstream.
+      * interactive: Prompt for the resolution of each conflicting field. The
+        skipped conflicts stay in the .kptconflicts file.
+  
+  --dry-run:
+    Runs the update on a copy of the package and prints the changes it would make,
+    i.e. the subpackages that would be added or removed, the resources and non-KRM
+    files that would change and the conflicts that would be recorded, without
+    changing the package.
+  
+  --output:
+    The output format of the changes printed with --dry-run. Must be one of:
+    text, json. Defaults to text.
 
 Env Vars:
 
@@ -254,6 +387,14 @@ Env Vars:
     Defaults to <HOME>/.kpt/repos/
     On macOS and Linux <HOME> is determined by the $HOME env variable, while on
     Windows it is given by the %USERPROFILE% env variable.
+  
+  KPT_GIT_BACKEND:
+    Selects how kpt runs git to fetch remote packages. Must be one of ` + "`" + `go` + "`" + ` to
+    use the git implementation built into kpt, or ` + "`" + `exec` + "`" + ` to run the git
+    executable installed on the host. Defaults to ` + "`" + `go` + "`" + `, which reads the
+    credentials of https repos from the .netrc file, and falls back to the git
+    executable, e.g. to use its credential helpers, when a repo requires
+    credentials it doesn't have.
 `
 var UpdateExamples = `
   # Update package in the current directory.
@@ -264,7 +405,18 @@ var UpdateExamples = `
   # git add . && git commit -m 'some message'
   $ kpt pkg update my-package-dir/@v1.3
 
+  # Update my-package-dir/ to the newest 1.4.x patch version.
+  # git add . && git commit -m 'some message'
+  $ kpt pkg update 'my-package-dir/@~1.4'
+
   # Update with the fast-forward strategy.
   # git add . && git commit -m "some message"
   $ kpt pkg update my-package-dir/@master --strategy fast-forward
+
+  # Take the upstream values of the fields with conflicting changes.
+  # git add . && git commit -m "some message"
+  $ kpt pkg update my-package-dir/ --resolve theirs
+
+  # Print the changes an update to v1.4 would make as JSON.
+  $ kpt pkg update my-package-dir/@v1.4 --dry-run --output json
 `
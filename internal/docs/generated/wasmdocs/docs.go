@@ -4,6 +4,8 @@ package wasmdocs
 var WasmShort = `Manage WASM modules as OCI images.`
 var WasmLong = `
 The ` + "`" + `wasm` + "`" + ` command group contains subcommands for managing WASM modules as OCI images.
+Both modules built with ` + "`" + `GOOS=js GOARCH=wasm` + "`" + ` and WASI preview1 modules built
+with ` + "`" + `GOOS=wasip1 GOARCH=wasm` + "`" + ` are supported.
 `
 
 var PullShort = `Fetch and decompress OCI image to WASM module.`
@@ -32,8 +34,19 @@ Args:
     The path to the wasm file.
   IMAGE:
     The desired name of an image. It must be a tag.
+
+Flags:
+
+  --sign-key:
+    The path to the PEM encoded private key to sign the image and the image
+    index with. The signatures are compatible with cosign. Private keys
+    encrypted by ` + "`" + `cosign generate-key-pair` + "`" + ` are decrypted with the password
+    in the ` + "`" + `COSIGN_PASSWORD` + "`" + ` environment variable.
 `
 var PushExamples = `
   # compress ./my-fn.wasm and push it to gcr.io/my-org/my-fn:v1.0.0
   $ kpt alpha wasm push ./my-fn.wasm gcr.io/my-org/my-fn:v1.0.0
+
+  # compress ./my-fn.wasm, push it to gcr.io/my-org/my-fn:v1.0.0 and sign it
+  $ COSIGN_PASSWORD=... kpt alpha wasm push ./my-fn.wasm gcr.io/my-org/my-fn:v1.0.0 --sign-key cosign.key
 `
@@ -144,6 +144,7 @@ const (
 	Git                       // Errors from Git
 	IO                        // Error doing IO operations
 	YAML                      // yaml document can't be parsed
+	OCI                       // Errors from OCI registries
 )
 
 func (c Class) String() string {
@@ -164,6 +165,8 @@ func (c Class) String() string {
 		return "IO error"
 	case YAML:
 		return "yaml parsing error"
+	case OCI:
+		return "oci error"
 	}
 	return "unknown kind"
 }
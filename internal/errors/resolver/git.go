@@ -51,8 +51,7 @@ func (*gitExecErrorResolver) Resolve(err error) (ResolvedResult, bool) {
 
 	case gitutil.HTTPSAuthRequired:
 		msg = fmt.Sprintf("Error: Repository %q requires authentication.", gitExecErr.Repo)
-		msg += " kpt does not support this for the 'https' protocol."
-		msg += " Please use the 'git' protocol instead."
+		msg += " Please provide its credentials in the .netrc file or with a git credential helper."
 		msg = msg + "\n" + BuildOutputDetails(gitExecErr.StdOut, gitExecErr.StdErr)
 
 	case gitutil.RepositoryUnavailable:
@@ -0,0 +1,44 @@
+// Copyright 2021 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+)
+
+//nolint:gochecknoinits
+func init() {
+	AddErrorResolver(&policyErrorResolver{})
+}
+
+// policyErrorResolver is an implementation of the ErrorResolver interface
+// to resolve function policy violations.
+type policyErrorResolver struct{}
+
+func (*policyErrorResolver) Resolve(err error) (ResolvedResult, bool) {
+	var violation *fnruntime.PolicyViolationError
+	if !errors.As(err, &violation) {
+		return ResolvedResult{}, false
+	}
+	msg := fmt.Sprintf("Error: Function %q is not allowed by the function policy %q: %s.\n",
+		violation.Function, violation.Policy, violation.Reason)
+	msg += "Use a function allowed by the policy or ask the owner of the policy to allow it."
+	return ResolvedResult{
+		Message: msg,
+	}, true
+}
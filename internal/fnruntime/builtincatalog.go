@@ -0,0 +1,78 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// BuiltinCatalog controls whether the images of catalog functions are run
+// by the builtin function implementing them.
+type BuiltinCatalog string
+
+const (
+	// BuiltinCatalogAuto runs the images of catalog functions with the
+	// builtin implementing their minor version, if any.
+	BuiltinCatalogAuto BuiltinCatalog = "auto"
+	// BuiltinCatalogOff always runs the images of catalog functions. Only
+	// the `builtins/` images are run by builtin functions.
+	BuiltinCatalogOff BuiltinCatalog = "off"
+)
+
+var allBuiltinCatalog = []BuiltinCatalog{
+	BuiltinCatalogAuto,
+	BuiltinCatalogOff,
+}
+
+// BuiltinCatalog can be used in pflag
+var _ pflag.Value = ((*BuiltinCatalog)(nil))
+
+// String implements pflag.Value and fmt.Stringer
+func (e *BuiltinCatalog) String() string {
+	return string(*e)
+}
+
+// Set implements pflag.Value
+func (e *BuiltinCatalog) Set(v string) error {
+	l := strings.ToLower(v)
+	for _, c := range allBuiltinCatalog {
+		if string(c) == l {
+			*e = c
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %s", strings.Join(e.AllStrings(), ", "))
+}
+
+func (e *BuiltinCatalog) AllStrings() []string {
+	var allStrings []string
+	for _, c := range allBuiltinCatalog {
+		allStrings = append(allStrings, string(c))
+	}
+	return allStrings
+}
+
+// HelpAllowedValues builds help text for the allowed values
+func (e *BuiltinCatalog) HelpAllowedValues() string {
+	return "(one of " + strings.Join(e.AllStrings(), ", ") + ")"
+}
+
+// Type implements pflag.Value
+func (e *BuiltinCatalog) Type() string {
+	return "BuiltinCatalog"
+}
@@ -0,0 +1,147 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/oci"
+)
+
+// FnCacheDirEnv is the name of the environment variable that controls the
+// directory used to cache function artifacts.
+const FnCacheDirEnv = "KPT_FN_CACHE_DIR"
+
+// GetFnCacheDir returns the directory used to cache function artifacts.
+// It defaults to <HOME>/.kpt/fn.
+func GetFnCacheDir() (string, error) {
+	if dir := os.Getenv(FnCacheDirEnv); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error looking up user home dir: %w", err)
+	}
+	return filepath.Join(home, ".kpt", "fn"), nil
+}
+
+// ResultCache caches the output of function invocations. An invocation is
+// addressed by the hash of its input ResourceList, which includes the
+// functionConfig, and of the identity of the function, i.e. the image digest
+// or the hash of the executable.
+type ResultCache struct {
+	storage *oci.Storage
+}
+
+// NewResultCache returns a ResultCache storing its entries in the given dir.
+func NewResultCache(dir string) (*ResultCache, error) {
+	storage, err := oci.NewStorage(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &ResultCache{storage: storage}, nil
+}
+
+// Storage returns the underlying storage of the cache.
+func (c *ResultCache) Storage() *oci.Storage {
+	return c.storage
+}
+
+// cachedOutput is the cached output of a function invocation.
+type cachedOutput struct {
+	Output string `json:"output"`
+	Stderr string `json:"stderr,omitempty"`
+}
+
+// cachedFn wraps a function run with the result cache.
+type cachedFn struct {
+	cache *ResultCache
+	// identity returns a string identifying the function implementation.
+	// It returns an empty string if the identity can't be determined (yet),
+	// in which case the cache is not looked up.
+	identity func() string
+	run      func(io.Reader, io.Writer) error
+	fnResult *fnresult.Result
+}
+
+// Run replays the cached output of the function for the given input if
+// present, otherwise it runs the function and caches its output.
+func (f *cachedFn) Run(r io.Reader, w io.Writer) error {
+	input, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	id := f.identity()
+	if id != "" {
+		if b, err := f.cache.storage.LoadFnResult(cacheKey(id, input)); err == nil {
+			var out cachedOutput
+			if err := json.Unmarshal(b, &out); err == nil {
+				f.fnResult.Stderr = out.Stderr
+				_, err = io.WriteString(w, out.Output)
+				return err
+			}
+		}
+	}
+
+	var output bytes.Buffer
+	if err := f.run(bytes.NewReader(input), &output); err != nil {
+		return err
+	}
+	if id == "" {
+		// e.g. the image has been pulled by the run
+		id = f.identity()
+	}
+	if id != "" {
+		b, err := json.Marshal(cachedOutput{Output: output.String(), Stderr: f.fnResult.Stderr})
+		if err == nil {
+			// failing to cache the output must not fail the function
+			_ = f.cache.storage.StoreFnResult(cacheKey(id, input), b)
+		}
+	}
+	_, err = w.Write(output.Bytes())
+	return err
+}
+
+// cacheKey returns the content address of a function invocation.
+func cacheKey(identity string, input []byte) string {
+	h := sha256.New()
+	h.Write([]byte(identity))
+	h.Write([]byte{0})
+	h.Write(input)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fileDigest returns the sha256 digest of the given file.
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
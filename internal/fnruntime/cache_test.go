@@ -0,0 +1,111 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedFn(t *testing.T) {
+	cache, err := NewResultCache(t.TempDir())
+	assert.NoError(t, err)
+
+	runs := 0
+	identity := "image-a"
+	newFn := func() (*cachedFn, *fnresult.Result) {
+		fnResult := &fnresult.Result{}
+		return &cachedFn{
+			cache:    cache,
+			identity: func() string { return identity },
+			run: func(r io.Reader, w io.Writer) error {
+				runs++
+				in, err := io.ReadAll(r)
+				if err != nil {
+					return err
+				}
+				if string(in) == "fail" {
+					return fmt.Errorf("failed")
+				}
+				fnResult.Stderr = "stderr of " + string(in)
+				_, err = w.Write(bytes.ToUpper(in))
+				return err
+			},
+			fnResult: fnResult,
+		}, fnResult
+	}
+	run := func(input string) (string, string, error) {
+		f, fnResult := newFn()
+		var out bytes.Buffer
+		err := f.Run(strings.NewReader(input), &out)
+		return out.String(), fnResult.Stderr, err
+	}
+
+	out, stderr, err := run("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "FOO", out)
+	assert.Equal(t, "stderr of foo", stderr)
+	assert.Equal(t, 1, runs)
+
+	// same input and identity replays the cached output
+	out, stderr, err = run("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "FOO", out)
+	assert.Equal(t, "stderr of foo", stderr)
+	assert.Equal(t, 1, runs)
+
+	// a different input runs the function
+	out, _, err = run("bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "BAR", out)
+	assert.Equal(t, 2, runs)
+
+	// a different identity runs the function
+	identity = "image-b"
+	_, _, err = run("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, runs)
+
+	// failures are not cached
+	_, _, err = run("fail")
+	assert.Error(t, err)
+	_, _, err = run("fail")
+	assert.Error(t, err)
+	assert.Equal(t, 5, runs)
+
+	// an unknown identity disables the cache lookup
+	identity = ""
+	_, _, err = run("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, 6, runs)
+
+	entries, err := cache.Storage().ListFnResults()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	pruned, err := cache.Storage().PruneFnResults(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, pruned, 3)
+	entries, err = cache.Storage().ListFnResults()
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
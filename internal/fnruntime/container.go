@@ -18,17 +18,22 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	goerrors "errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/GoogleContainerTools/kpt/internal/types"
 	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/printer"
 	"golang.org/x/mod/semver"
 	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
@@ -80,7 +85,13 @@ type ContainerFn struct {
 	// Container function will be killed after this timeour.
 	// The default value is 5 minutes.
 	Timeout time.Duration
-	Perm    ContainerFnPermission
+	// Memory is the maximum amount of memory in bytes the container
+	// can use. Zero means no limit.
+	Memory int64
+	// CPUs is the maximum number of CPUs the container can use.
+	// Zero means no limit.
+	CPUs float64
+	Perm ContainerFnPermission
 	// UIDGID is the os User ID and Group ID that will be
 	// used to run the container in format userId:groupId.
 	// If it's empty, "nobody" will be used.
@@ -93,6 +104,9 @@ type ContainerFn struct {
 	// FnResult is used to store the information about the result from
 	// the function.
 	FnResult *fnresult.Result
+	// Registries holds the credentials the container runtime pulls the
+	// image with, if any.
+	Registries *RegistryConfig
 }
 
 func (r ContainerRuntime) GetBin() string {
@@ -135,8 +149,22 @@ func (f *ContainerFn) Run(reader io.Reader, writer io.Writer) error {
 
 func (f *ContainerFn) runCLI(reader io.Reader, writer io.Writer, bin string, filterCLIOutputFn func(io.Reader) string) error {
 	errSink := bytes.Buffer{}
-	cmd, cancel := f.getCmd(bin)
+	// setup container run timeout
+	timeout := defaultLongTimeout
+	if f.Timeout != 0 {
+		timeout = f.Timeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
+	cmd := f.getCmd(ctx, bin)
+	env, cleanup, err := f.Registries.ContainerAuthEnv(bin, f.Image)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 	cmd.Stdin = reader
 	cmd.Stdout = writer
 	cmd.Stderr = &errSink
@@ -144,12 +172,20 @@ func (f *ContainerFn) runCLI(reader io.Reader, writer io.Writer, bin string, fil
 	if err := cmd.Run(); err != nil {
 		var exitErr *exec.ExitError
 		if goerrors.As(err, &exitErr) {
-			return &ExecError{
+			err = &ExecError{
 				OriginalErr:    exitErr,
 				ExitCode:       exitErr.ExitCode(),
 				Stderr:         filterCLIOutputFn(&errSink),
 				TruncateOutput: printer.TruncateOutput,
 			}
+			if goerrors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return &LimitError{Limit: "timeout", Value: timeout.String(), Err: err}
+			}
+			// the container is killed with SIGKILL when it runs out of memory
+			if f.Memory > 0 && exitErr.ExitCode() == 137 {
+				return &LimitError{Limit: "memory", Value: fmt.Sprintf("%d bytes", f.Memory), Err: err}
+			}
+			return err
 		}
 		return fmt.Errorf("unexpected function error: %w", err)
 	}
@@ -160,9 +196,50 @@ func (f *ContainerFn) runCLI(reader io.Reader, writer io.Writer, bin string, fil
 	return nil
 }
 
+// cacheIdentity returns a string identifying the function image and the
+// settings affecting its output, or an empty string if the image is not
+// present in the local image store of the container runtime.
+func (f *ContainerFn) cacheIdentity() string {
+	runtime, err := StringToContainerRuntime(os.Getenv(ContainerRuntimeEnv))
+	if err != nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), versionCommandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, runtime.GetBin(), "image", "inspect", "--format", "{{.Id}}", f.Image).Output()
+	if err != nil {
+		return ""
+	}
+	imageID := strings.TrimSpace(string(out))
+	if imageID == "" {
+		return ""
+	}
+	b, err := json.Marshal(struct {
+		ImageID string
+		Env     []string
+		Mounts  []string
+	}{
+		ImageID: imageID,
+		Env:     f.Env,
+		Mounts:  storageMountStrings(f.StorageMounts),
+	})
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func storageMountStrings(mounts []runtimeutil.StorageMount) []string {
+	var s []string
+	for _, m := range mounts {
+		s = append(s, m.String())
+	}
+	return s
+}
+
 // getCmd assembles a command for docker, podman or nerdctl. The input binName
 // is expected to be one of "docker", "podman" and "nerdctl".
-func (f *ContainerFn) getCmd(binName string) (*exec.Cmd, context.CancelFunc) {
+func (f *ContainerFn) getCmd(ctx context.Context, binName string) *exec.Cmd {
 	network := networkNameNone
 	if f.Perm.AllowNetwork {
 		network = networkNameHost
@@ -189,19 +266,56 @@ func (f *ContainerFn) getCmd(binName string) (*exec.Cmd, context.CancelFunc) {
 	default:
 		args = append(args, "--pull", "missing")
 	}
+	if f.Memory > 0 {
+		args = append(args, "--memory", strconv.FormatInt(f.Memory, 10))
+	}
+	if f.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(f.CPUs, 'f', -1, 64))
+	}
 	for _, storageMount := range f.StorageMounts {
 		args = append(args, "--mount", storageMount.String())
 	}
 	args = append(args,
 		NewContainerEnvFromStringSlice(f.Env).GetDockerFlags()...)
 	args = append(args, f.Image)
-	// setup container run timeout
-	timeout := defaultLongTimeout
-	if f.Timeout != 0 {
-		timeout = f.Timeout
+	return exec.CommandContext(ctx, binName, args...)
+}
+
+// errAllowedMountNotSpecified indicates the user needs to authorize functions
+// to mount files of the package.
+var errAllowedMountNotSpecified = fmt.Errorf("must run with `--allow-mount` option to allow functions to mount package files")
+
+// functionMounts returns the read-only bind mounts of the files and
+// directories of the package at pkgPath declared in the mounts of the
+// function. Sources are resolved following symlinks and must stay inside the
+// package.
+func functionMounts(f *kptfilev1.Function, pkgPath types.UniquePath, opts RunnerOptions) ([]runtimeutil.StorageMount, error) {
+	if len(f.Mounts) == 0 {
+		return nil, nil
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	return exec.CommandContext(ctx, binName, args...), cancel
+	if !opts.AllowMount {
+		return nil, errAllowedMountNotSpecified
+	}
+	root, err := filepath.EvalSymlinks(pkgPath.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve package path %q: %w", pkgPath, err)
+	}
+	var mounts []runtimeutil.StorageMount
+	for _, m := range f.Mounts {
+		src, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(m.Src)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve mount %q: %w", m.Src, err)
+		}
+		if rel, err := filepath.Rel(root, src); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("mount %q must not be outside the package", m.Src)
+		}
+		mounts = append(mounts, runtimeutil.StorageMount{
+			MountType: "bind",
+			Src:       src,
+			DstPath:   m.Dst,
+		})
+	}
+	return mounts, nil
 }
 
 // NewContainerEnvFromStringSlice returns a new ContainerEnv pointer with parsing
@@ -225,12 +339,15 @@ func NewContainerEnvFromStringSlice(envStr []string) *runtimeutil.ContainerEnv {
 }
 
 // ResolveToImageForCLI converts the function short path to the full image url.
-// If the function is Catalog function, it adds "gcr.io/kpt-fn/".e.g. set-namespace:v0.1 --> gcr.io/kpt-fn/set-namespace:v0.1
+// If the function is Catalog function, it adds the default registry of the
+// function registry config, "gcr.io/kpt-fn/" by default.
+// e.g. set-namespace:v0.1 --> gcr.io/kpt-fn/set-namespace:v0.1
 func ResolveToImageForCLI(_ context.Context, image string) (string, error) {
-	if !strings.Contains(image, "/") {
-		return fmt.Sprintf("gcr.io/kpt-fn/%s", image), nil
+	registries, err := DefaultRegistryConfig()
+	if err != nil {
+		return "", err
 	}
-	return image, nil
+	return registries.Resolve(image), nil
 }
 
 // ContainerImageError is an error type which will be returned when
@@ -385,6 +502,11 @@ func StringToContainerRuntime(v string) (ContainerRuntime, error) {
 	case "":
 		return Docker, nil
 	default:
+		// functions not found by the function evaluator service fall back
+		// to the default container runtime.
+		if isRemoteEndpoint(v) {
+			return Docker, nil
+		}
 		return "", fmt.Errorf("unsupported runtime: %q the runtime must be either %s or %s", v, Docker, Podman)
 	}
 }
@@ -17,6 +17,7 @@ package fnruntime
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	goerrors "errors"
 	"fmt"
 	"io"
@@ -41,6 +42,13 @@ type ExecFn struct {
 	// FnResult is used to store the information about the result from
 	// the function.
 	FnResult *fnresult.Result
+	// Sandbox runs the executable in a sandbox without access to the
+	// filesystem of the host beyond its system directories, to the
+	// environment of kpt or, unless AllowNetwork is true, to the network.
+	// Sandboxes are only supported on linux.
+	Sandbox bool
+	// AllowNetwork gives the sandboxed executable access to the network.
+	AllowNetwork bool
 }
 
 // Run runs the executable file which reads the input from r and
@@ -54,26 +62,46 @@ func (f *ExecFn) Run(r io.Reader, w io.Writer) error {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, f.Path, f.Args...)
+	var cmd *exec.Cmd
+	if f.Sandbox {
+		var cleanup func()
+		var err error
+		cmd, cleanup, err = f.sandboxCommand(ctx)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+	} else {
+		cmd = exec.CommandContext(ctx, f.Path, f.Args...)
+		for k, v := range f.Env {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%v=%v", k, v))
+		}
+	}
 
 	errSink := bytes.Buffer{}
 	cmd.Stdin = r
 	cmd.Stdout = w
 	cmd.Stderr = &errSink
 
-	for k, v := range f.Env {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%v=%v", k, v))
+	if err := cmd.Start(); err != nil {
+		if f.Sandbox {
+			return sandboxStartError(err)
+		}
+		return fmt.Errorf("unexpected function error: %w", err)
 	}
-
-	if err := cmd.Run(); err != nil {
+	if err := cmd.Wait(); err != nil {
 		var exitErr *exec.ExitError
 		if goerrors.As(err, &exitErr) {
-			return &ExecError{
+			err = &ExecError{
 				OriginalErr:    exitErr,
 				ExitCode:       exitErr.ExitCode(),
 				Stderr:         errSink.String(),
 				TruncateOutput: printer.TruncateOutput,
 			}
+			if goerrors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return &LimitError{Limit: "timeout", Value: timeout.String(), Err: err}
+			}
+			return err
 		}
 		return fmt.Errorf("unexpected function error: %w", err)
 	}
@@ -84,3 +112,33 @@ func (f *ExecFn) Run(r io.Reader, w io.Writer) error {
 
 	return nil
 }
+
+// cacheIdentity returns a string identifying the executable and the settings
+// affecting its output, or an empty string if the executable can't be read.
+func (f *ExecFn) cacheIdentity() string {
+	path, err := exec.LookPath(f.Path)
+	if err != nil {
+		return ""
+	}
+	digest, err := fileDigest(path)
+	if err != nil {
+		return ""
+	}
+	b, err := json.Marshal(struct {
+		Digest       string
+		Args         []string
+		Env          map[string]string
+		Sandbox      bool
+		AllowNetwork bool
+	}{
+		Digest:       digest,
+		Args:         f.Args,
+		Env:          f.Env,
+		Sandbox:      f.Sandbox,
+		AllowNetwork: f.AllowNetwork,
+	})
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
@@ -59,3 +59,23 @@ func (fe *ExecError) String() string {
 func (fe *ExecError) Error() string {
 	return fe.String()
 }
+
+// LimitError is returned when a function exceeds one of its resource limits.
+type LimitError struct {
+	// Limit is the name of the exceeded limit, i.e. `timeout` or `memory`.
+	Limit string
+
+	// Value is the configured value of the limit.
+	Value string
+
+	// Err is the error returned from the function runtime, if any.
+	Err error
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("function exceeded its %s limit of %s", e.Limit, e.Value)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
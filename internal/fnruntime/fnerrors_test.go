@@ -211,3 +211,11 @@ lastline`,
 		})
 	}
 }
+
+func TestLimitErrorString(t *testing.T) {
+	err := &LimitError{Limit: "timeout", Value: "1s", Err: &ExecError{ExitCode: 137}}
+	assert.Equal(t, "function exceeded its timeout limit of 1s", err.Error())
+	var execErr *ExecError
+	assert.ErrorAs(t, err, &execErr)
+	assert.Equal(t, 137, execErr.ExitCode)
+}
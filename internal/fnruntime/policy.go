@@ -0,0 +1,422 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/builtins"
+	fnpolicy "github.com/GoogleContainerTools/kpt/pkg/api/fnpolicy/v1alpha1"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/signature"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"golang.org/x/mod/semver"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// FnPolicyFileName is the name of the function policy file in the `.kpt`
+	// directory of a repository or of the user home directory.
+	FnPolicyFileName = "fn-policy.yaml"
+
+	// fnPolicyDir is the directory holding the function policy file.
+	fnPolicyDir = ".kpt"
+)
+
+// FunctionPolicy is a function policy loaded from a file.
+type FunctionPolicy struct {
+	fnpolicy.FunctionPolicy
+
+	// Path is the path of the policy file.
+	Path string
+
+	allowed []*regexp.Regexp
+	denied  []*regexp.Regexp
+	min     []*regexp.Regexp
+
+	signed    []*regexp.Regexp
+	verifiers []signature.Verifier
+}
+
+// PolicyViolationError is returned when a function policy doesn't allow a
+// function to be run.
+type PolicyViolationError struct {
+	// Function is the image or the executable of the function.
+	Function string
+
+	// Policy is the path of the violated policy file.
+	Policy string
+
+	// Reason describes why the function is not allowed.
+	Reason string
+}
+
+func (e *PolicyViolationError) Error() string {
+	return fmt.Sprintf("function %q is not allowed by the function policy %q: %s", e.Function, e.Policy, e.Reason)
+}
+
+// LoadFunctionPolicy reads the function policy file at path.
+func LoadFunctionPolicy(path string) (*FunctionPolicy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read function policy: %w", err)
+	}
+	p := &FunctionPolicy{Path: path}
+	d := yaml.NewDecoder(bytes.NewReader(b))
+	d.KnownFields(true)
+	if err := d.Decode(&p.FunctionPolicy); err != nil {
+		return nil, fmt.Errorf("invalid function policy %q: %w", path, err)
+	}
+	gvk := fnpolicy.FunctionPolicyGVK()
+	if p.APIVersion != gvk.GroupVersion().String() || p.Kind != gvk.Kind {
+		return nil, fmt.Errorf("invalid function policy %q: apiVersion and kind must be %q and %q",
+			path, gvk.GroupVersion().String(), gvk.Kind)
+	}
+	for _, pattern := range p.AllowedImages {
+		p.allowed = append(p.allowed, globToRegexp(pattern))
+	}
+	for _, pattern := range p.DeniedImages {
+		p.denied = append(p.denied, globToRegexp(pattern))
+	}
+	for _, m := range p.MinVersions {
+		if !semver.IsValid(canonicalVersion(m.Version)) {
+			return nil, fmt.Errorf("invalid function policy %q: %q is not a semantic version", path, m.Version)
+		}
+		p.min = append(p.min, globToRegexp(m.Image))
+	}
+	for _, s := range p.Signatures {
+		v, err := newSignatureVerifier(s, filepath.Dir(path))
+		if err != nil {
+			return nil, fmt.Errorf("invalid function policy %q: %w", path, err)
+		}
+		p.signed = append(p.signed, globToRegexp(s.Image))
+		p.verifiers = append(p.verifiers, v)
+	}
+	return p, nil
+}
+
+// newSignatureVerifier returns the verifier of the signature requirement.
+// The paths of the requirement are relative to dir.
+func newSignatureVerifier(s fnpolicy.SignatureRequirement, dir string) (signature.Verifier, error) {
+	resolve := func(p string) string {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	switch {
+	case s.Key != "" && s.Keyless != nil:
+		return nil, fmt.Errorf("the signature requirement of %q must have only one of key and keyless", s.Image)
+	case s.Key != "":
+		pub, err := signature.LoadPublicKey(resolve(s.Key))
+		if err != nil {
+			return nil, err
+		}
+		return signature.NewKeyVerifier(pub)
+	case s.Keyless != nil:
+		if s.Keyless.Bundle == "" || s.Keyless.Identity == "" {
+			return nil, fmt.Errorf("the keyless signature requirement of %q must have a bundle and an identity", s.Image)
+		}
+		root, err := signature.LoadTrustedRoot(resolve(s.Keyless.Bundle))
+		if err != nil {
+			return nil, err
+		}
+		return signature.NewKeylessVerifier(root, s.Keyless.Identity, s.Keyless.Issuer), nil
+	default:
+		return nil, fmt.Errorf("the signature requirement of %q must have a key or keyless", s.Image)
+	}
+}
+
+// FindFunctionPolicies returns the function policies applying to the
+// package at pkgPath. These are the policy file at path, if set, the
+// nearest `.kpt/fn-policy.yaml` file in pkgPath or its parent directories,
+// and the `.kpt/fn-policy.yaml` file in the user home directory. A function
+// must be allowed by all of them.
+func FindFunctionPolicies(path string, pkgPath string) ([]*FunctionPolicy, error) {
+	var paths []string
+	if path != "" {
+		paths = append(paths, path)
+	}
+	if pkgPath != "" {
+		dir, err := filepath.Abs(pkgPath)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			p := filepath.Join(dir, fnPolicyDir, FnPolicyFileName)
+			if _, err := os.Stat(p); err == nil {
+				paths = append(paths, p)
+				break
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		p := filepath.Join(home, fnPolicyDir, FnPolicyFileName)
+		if _, err := os.Stat(p); err == nil {
+			paths = append(paths, p)
+		}
+	}
+
+	var policies []*FunctionPolicy
+	seen := map[string]bool{}
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		if seen[abs] {
+			continue
+		}
+		seen[abs] = true
+		policy, err := LoadFunctionPolicy(p)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// CheckImage returns a PolicyViolationError if the policy doesn't allow the
+// function image. image is the fully-qualified image of the function and
+// pinned is the image with the digest it is pinned to, if any.
+func (p *FunctionPolicy) CheckImage(image, pinned string) error {
+	// builtin functions are part of kpt
+	if builtins.IsBuiltin(image) {
+		return nil
+	}
+	violation := func(format string, args ...interface{}) error {
+		return &PolicyViolationError{Function: image, Policy: p.Path, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	// the tag is ignored if the image also has a digest
+	tag, err := imageTag(image)
+	if err != nil {
+		return violation("the image name is invalid: %v", err)
+	}
+	repo := tag.Context().Name()
+
+	if len(p.allowed) > 0 && !matchAny(p.allowed, repo) {
+		return violation("the image repository %q doesn't match any of the allowed images %q", repo, p.AllowedImages)
+	}
+	for i, re := range p.denied {
+		if re.MatchString(repo) || re.MatchString(repo+":"+tag.TagStr()) {
+			return violation("the image is denied by %q", p.DeniedImages[i])
+		}
+	}
+	for i, re := range p.min {
+		if !re.MatchString(repo) {
+			continue
+		}
+		minVersion := p.MinVersions[i].Version
+		version := canonicalVersion(tag.TagStr())
+		if !semver.IsValid(version) {
+			return violation("the tag %q is not a semantic version, at least %s is required", tag.TagStr(), minVersion)
+		}
+		if semver.Compare(version, canonicalVersion(minVersion)) < 0 {
+			return violation("the version %s is older than the minimum version %s", tag.TagStr(), minVersion)
+		}
+	}
+	if p.RequireDigest && !strings.Contains(pinned, "@") {
+		return violation("the image is not pinned to a digest, run `kpt fn lock` to pin it")
+	}
+	return nil
+}
+
+// signatureVerifiers returns the verifiers of the signatures required for
+// the images of the repository.
+func (p *FunctionPolicy) signatureVerifiers(repo string) []signature.Verifier {
+	var verifiers []signature.Verifier
+	for i, re := range p.signed {
+		if re.MatchString(repo) {
+			verifiers = append(verifiers, p.verifiers[i])
+		}
+	}
+	return verifiers
+}
+
+// CheckExec returns a PolicyViolationError if the policy doesn't allow
+// executable functions.
+func (p *FunctionPolicy) CheckExec(exec string) error {
+	if p.AllowExec {
+		return nil
+	}
+	return &PolicyViolationError{Function: exec, Policy: p.Path, Reason: "executable functions are not allowed"}
+}
+
+// CheckImagePolicies returns an error if one of the function policies
+// doesn't allow the function image. The policies apply to the image pulled
+// from the mirror of its repository, if any. See FunctionPolicy.CheckImage.
+func (o *RunnerOptions) CheckImagePolicies(image, pinned string) error {
+	if len(o.Policies) == 0 {
+		return nil
+	}
+	registries, err := o.RegistryConfig()
+	if err != nil {
+		return err
+	}
+	image, pinned = registries.Mirror(image), registries.Mirror(pinned)
+	for _, p := range o.Policies {
+		if err := p.CheckImage(image, pinned); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyImageSignatures verifies the signature of the function image if one
+// of the function policies requires it to be signed. It returns the image
+// pinned to the verified digest, which must be run instead of image, and
+// the result of the verification, which is nil if no signature is required.
+// A PolicyViolationError is returned if the image doesn't have a valid
+// signature required by a policy. The signatures required for the mirror of
+// the image repository, if any, are verified and the image and its
+// signatures are fetched from the mirror.
+func (o *RunnerOptions) VerifyImageSignatures(ctx context.Context, image string) (string, *fnresult.SignatureVerification, error) {
+	// builtin functions are part of kpt
+	if builtins.IsBuiltin(image) {
+		return image, nil, nil
+	}
+	tag, err := imageTag(image)
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot parse image name %q: %w", image, err)
+	}
+	registries, err := o.RegistryConfig()
+	if err != nil {
+		return "", nil, err
+	}
+	mirror := registries.Mirror(image)
+	mirrorTag, err := imageTag(mirror)
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot parse image name %q: %w", mirror, err)
+	}
+	repo := mirrorTag.Context().Name()
+	remoteOpts := []remote.Option{remote.WithAuthFromKeychain(registries.Keychain())}
+
+	var result *fnresult.SignatureVerification
+	var ref name.Digest
+	for _, p := range o.Policies {
+		verifiers := p.signatureVerifiers(repo)
+		if len(verifiers) == 0 {
+			continue
+		}
+		if result == nil {
+			// the digest is resolved once so that the verified digest is run.
+			if ref, err = signature.ResolveDigest(ctx, mirror, remoteOpts...); err != nil {
+				return "", nil, err
+			}
+		}
+		var errs []string
+		var verification *signature.Verification
+		for _, v := range verifiers {
+			verification, err = signature.VerifyImage(ctx, ref, v, remoteOpts...)
+			if err == nil {
+				break
+			}
+			errs = append(errs, err.Error())
+		}
+		if err != nil {
+			return "", nil, &PolicyViolationError{
+				Function: image,
+				Policy:   p.Path,
+				Reason:   "the image has no valid signature: " + strings.Join(errs, "; "),
+			}
+		}
+		if result == nil {
+			result = &fnresult.SignatureVerification{
+				Digest: verification.Digest,
+				Signer: verification.Signer,
+				Issuer: verification.Issuer,
+				Policy: p.Path,
+			}
+		}
+	}
+	if result == nil {
+		return image, nil, nil
+	}
+	// the digest is the same in the mirror.
+	return tag.Context().Digest(ref.DigestStr()).String(), result, nil
+}
+
+// CheckExecPolicies returns an error if one of the function policies
+// doesn't allow executable functions.
+func (o *RunnerOptions) CheckExecPolicies(exec string) error {
+	for _, p := range o.Policies {
+		if err := p.CheckExec(exec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// imageTag returns the tag of the image, ignoring its digest.
+func imageTag(image string) (name.Tag, error) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	return name.NewTag(image)
+}
+
+// globToRegexp returns the regular expression matching the same strings as
+// the glob pattern. `*` matches any sequence of characters except `/`, and
+// `**` matches any sequence of characters.
+func globToRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+func matchAny(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalVersion adds the `v` prefix expected by the semver package.
+func canonicalVersion(v string) string {
+	if strings.HasPrefix(v, "v") {
+		return v
+	}
+	return "v" + v
+}
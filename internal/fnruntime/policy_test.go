@@ -0,0 +1,310 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	goerrors "errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fnregistry "github.com/GoogleContainerTools/kpt/pkg/api/fnregistry/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/pkg/signature"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = `apiVersion: kpt.dev/v1alpha1
+kind: FunctionPolicy
+allowedImages:
+  - mirror.example.com/kpt-fn/**
+  - gcr.io/kpt-fn/*
+deniedImages:
+  - gcr.io/kpt-fn/apply-setters
+  - mirror.example.com/kpt-fn/set-labels:v0.1.*
+minVersions:
+  - image: gcr.io/kpt-fn/set-namespace
+    version: v0.4.1
+`
+
+func writePolicy(t *testing.T, dir, content string) string {
+	path := filepath.Join(dir, fnPolicyDir, FnPolicyFileName)
+	if !assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755)) ||
+		!assert.NoError(t, os.WriteFile(path, []byte(content), 0644)) {
+		t.FailNow()
+	}
+	return path
+}
+
+func TestFunctionPolicy_CheckImage(t *testing.T) {
+	p, err := LoadFunctionPolicy(writePolicy(t, t.TempDir(), testPolicy))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	testCases := map[string]struct {
+		image    string
+		pinned   string
+		digest   bool
+		expected string
+	}{
+		"allowed": {
+			image: "gcr.io/kpt-fn/set-labels:v0.1.5",
+		},
+		"allowed with nested repository": {
+			image: "mirror.example.com/kpt-fn/team/set-labels:v0.2",
+		},
+		"builtin": {
+			image: FuncGenPkgContext,
+		},
+		"not allowed registry": {
+			image:    "docker.io/evil/set-labels:v0.1",
+			expected: `the image repository "index.docker.io/evil/set-labels" doesn't match any of the allowed images ["mirror.example.com/kpt-fn/**" "gcr.io/kpt-fn/*"]`,
+		},
+		"single star doesn't match nested repository": {
+			image:    "gcr.io/kpt-fn/team/set-labels:v0.1",
+			expected: `the image repository "gcr.io/kpt-fn/team/set-labels" doesn't match any of the allowed images ["mirror.example.com/kpt-fn/**" "gcr.io/kpt-fn/*"]`,
+		},
+		"denied repository": {
+			image:    "gcr.io/kpt-fn/apply-setters:v0.2",
+			expected: `the image is denied by "gcr.io/kpt-fn/apply-setters"`,
+		},
+		"denied tag": {
+			image:    "mirror.example.com/kpt-fn/set-labels:v0.1.3",
+			expected: `the image is denied by "mirror.example.com/kpt-fn/set-labels:v0.1.*"`,
+		},
+		"minimum version": {
+			image: "gcr.io/kpt-fn/set-namespace:v0.4.1",
+		},
+		"older than minimum version": {
+			image:    "gcr.io/kpt-fn/set-namespace:v0.3.0",
+			expected: "the version v0.3.0 is older than the minimum version v0.4.1",
+		},
+		"not a semantic version": {
+			image:    "gcr.io/kpt-fn/set-namespace:latest",
+			expected: `the tag "latest" is not a semantic version, at least v0.4.1 is required`,
+		},
+		"digest required": {
+			image:    "gcr.io/kpt-fn/set-labels:v0.1.5",
+			digest:   true,
+			expected: "the image is not pinned to a digest, run `kpt fn lock` to pin it",
+		},
+		"pinned to a digest": {
+			image:  "gcr.io/kpt-fn/set-labels:v0.1.5",
+			pinned: "gcr.io/kpt-fn/set-labels@sha256:aaaa",
+			digest: true,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			p.RequireDigest = tc.digest
+			pinned := tc.pinned
+			if pinned == "" {
+				pinned = tc.image
+			}
+			err := p.CheckImage(tc.image, pinned)
+			if tc.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var violation *PolicyViolationError
+			if assert.True(t, goerrors.As(err, &violation)) {
+				assert.Equal(t, tc.image, violation.Function)
+				assert.Equal(t, p.Path, violation.Policy)
+				assert.Equal(t, tc.expected, violation.Reason)
+			}
+		})
+	}
+}
+
+func TestFunctionPolicy_CheckExec(t *testing.T) {
+	p, err := LoadFunctionPolicy(writePolicy(t, t.TempDir(), testPolicy))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.EqualError(t, p.CheckExec("./fn"),
+		`function "./fn" is not allowed by the function policy "`+p.Path+`": executable functions are not allowed`)
+	p.AllowExec = true
+	assert.NoError(t, p.CheckExec("./fn"))
+}
+
+func TestLoadFunctionPolicy(t *testing.T) {
+	testCases := map[string]struct {
+		content  string
+		expected string
+	}{
+		"wrong kind": {
+			content:  "apiVersion: kpt.dev/v1alpha1\nkind: Kptfile\n",
+			expected: `apiVersion and kind must be "kpt.dev/v1alpha1" and "FunctionPolicy"`,
+		},
+		"unknown field": {
+			content:  "apiVersion: kpt.dev/v1alpha1\nkind: FunctionPolicy\nallowedRegistries: []\n",
+			expected: "field allowedRegistries not found",
+		},
+		"invalid minimum version": {
+			content:  "apiVersion: kpt.dev/v1alpha1\nkind: FunctionPolicy\nminVersions:\n- image: foo\n  version: latest\n",
+			expected: `"latest" is not a semantic version`,
+		},
+		"signature without key": {
+			content:  "apiVersion: kpt.dev/v1alpha1\nkind: FunctionPolicy\nsignatures:\n- image: foo\n",
+			expected: `the signature requirement of "foo" must have a key or keyless`,
+		},
+		"missing signature key": {
+			content:  "apiVersion: kpt.dev/v1alpha1\nkind: FunctionPolicy\nsignatures:\n- image: foo\n  key: cosign.pub\n",
+			expected: "failed to read public key",
+		},
+		"keyless signature without identity": {
+			content:  "apiVersion: kpt.dev/v1alpha1\nkind: FunctionPolicy\nsignatures:\n- image: foo\n  keyless:\n    bundle: trusted_root.json\n",
+			expected: "must have a bundle and an identity",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadFunctionPolicy(writePolicy(t, t.TempDir(), tc.content))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.expected)
+			}
+		})
+	}
+}
+
+func TestFindFunctionPolicies(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	repo := t.TempDir()
+	pkgPath := filepath.Join(repo, "a", "b")
+	assert.NoError(t, os.MkdirAll(pkgPath, 0755))
+
+	policies, err := FindFunctionPolicies("", pkgPath)
+	assert.NoError(t, err)
+	assert.Empty(t, policies)
+
+	homePolicy := writePolicy(t, home, testPolicy)
+	repoPolicy := writePolicy(t, repo, testPolicy)
+	flagPolicy := writePolicy(t, t.TempDir(), testPolicy)
+	policies, err = FindFunctionPolicies(flagPolicy, pkgPath)
+	assert.NoError(t, err)
+	var paths []string
+	for _, p := range policies {
+		paths = append(paths, p.Path)
+	}
+	assert.Equal(t, []string{flagPolicy, repoPolicy, homePolicy}, paths)
+
+	// the same file is only loaded once
+	policies, err = FindFunctionPolicies(repoPolicy, pkgPath)
+	assert.NoError(t, err)
+	assert.Len(t, policies, 2)
+}
+
+func TestRunnerOptions_VerifyImageSignatures(t *testing.T) {
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+	dir := t.TempDir()
+	policyPath := writePolicy(t, dir, `apiVersion: kpt.dev/v1alpha1
+kind: FunctionPolicy
+signatures:
+  - image: `+host+`/signed/**
+    key: cosign.pub
+`)
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(policyPath), "cosign.pub"),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644))
+	p, err := LoadFunctionPolicy(policyPath)
+	require.NoError(t, err)
+	opts := RunnerOptions{Policies: []*FunctionPolicy{p}}
+
+	push := func(repo string, sign bool) (string, name.Digest) {
+		img, err := random.Image(64, 1)
+		require.NoError(t, err)
+		tag, err := name.NewTag(host + "/" + repo + ":v1")
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(tag, img))
+		h, err := img.Digest()
+		require.NoError(t, err)
+		ref := tag.Context().Digest(h.String())
+		if sign {
+			require.NoError(t, signature.SignImage(ctx, ref, key))
+		}
+		return tag.String(), ref
+	}
+
+	t.Run("signed", func(t *testing.T) {
+		image, ref := push("signed/set-labels", true)
+		pinned, verification, err := opts.VerifyImageSignatures(ctx, image)
+		require.NoError(t, err)
+		assert.Equal(t, ref.String(), pinned)
+		if assert.NotNil(t, verification) {
+			assert.Equal(t, ref.DigestStr(), verification.Digest)
+			assert.Equal(t, policyPath, verification.Policy)
+		}
+	})
+
+	t.Run("mirrored", func(t *testing.T) {
+		_, ref := push("signed/apply-setters", true)
+		opts := opts
+		opts.Registries = &RegistryConfig{}
+		opts.Registries.Mirrors = []fnregistry.Mirror{{Source: "mirrored.example.com/*", Mirror: host + "/signed/*"}}
+		pinned, verification, err := opts.VerifyImageSignatures(ctx, "mirrored.example.com/apply-setters:v1")
+		require.NoError(t, err)
+		assert.Equal(t, "mirrored.example.com/apply-setters@"+ref.DigestStr(), pinned)
+		if assert.NotNil(t, verification) {
+			assert.Equal(t, ref.DigestStr(), verification.Digest)
+		}
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		image, _ := push("signed/set-namespace", false)
+		_, _, err := opts.VerifyImageSignatures(ctx, image)
+		var violation *PolicyViolationError
+		if assert.True(t, goerrors.As(err, &violation)) {
+			assert.Contains(t, violation.Reason, "the image has no valid signature: no signature found")
+		}
+	})
+
+	t.Run("not required", func(t *testing.T) {
+		image, _ := push("unsigned/set-labels", false)
+		pinned, verification, err := opts.VerifyImageSignatures(ctx, image)
+		require.NoError(t, err)
+		assert.Equal(t, image, pinned)
+		assert.Nil(t, verification)
+	})
+
+	t.Run("builtin", func(t *testing.T) {
+		pinned, verification, err := (&RunnerOptions{Policies: []*FunctionPolicy{p}}).VerifyImageSignatures(ctx, "builtins/set-labels")
+		require.NoError(t, err)
+		assert.Equal(t, "builtins/set-labels", pinned)
+		assert.Nil(t, verification)
+	})
+}
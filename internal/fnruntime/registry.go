@@ -0,0 +1,345 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/GoogleContainerTools/kpt/internal/builtins"
+	fnregistry "github.com/GoogleContainerTools/kpt/pkg/api/fnregistry/v1alpha1"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/gcrane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// FnRegistryConfigFileName is the name of the function registry config
+	// file in the `.kpt` directory of the user home directory.
+	FnRegistryConfigFileName = "fn-registry.yaml"
+
+	// FnRegistryConfigEnv is the environment variable holding the path of
+	// the function registry config file, overriding the file in the user
+	// home directory.
+	FnRegistryConfigEnv = "KPT_FN_REGISTRY_CONFIG"
+
+	// DefaultFnRegistry is the registry the short names of functions are
+	// resolved in by default.
+	DefaultFnRegistry = "gcr.io/kpt-fn"
+
+	// dockerHubAuthKey is the key of the credentials of Docker Hub in the
+	// docker config.
+	dockerHubAuthKey = "https://index.docker.io/v1/"
+)
+
+// RegistryConfig is a function registry config loaded from a file. A nil
+// RegistryConfig is the default config, which doesn't rewrite images.
+type RegistryConfig struct {
+	fnregistry.FunctionRegistryConfig
+
+	// Path is the path of the config file.
+	Path string
+}
+
+// LoadRegistryConfig reads the function registry config file at path.
+func LoadRegistryConfig(path string) (*RegistryConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read function registry config: %w", err)
+	}
+	c := &RegistryConfig{Path: path}
+	d := yaml.NewDecoder(bytes.NewReader(b))
+	d.KnownFields(true)
+	if err := d.Decode(&c.FunctionRegistryConfig); err != nil {
+		return nil, fmt.Errorf("invalid function registry config %q: %w", path, err)
+	}
+	gvk := fnregistry.FunctionRegistryConfigGVK()
+	if c.APIVersion != gvk.GroupVersion().String() || c.Kind != gvk.Kind {
+		return nil, fmt.Errorf("invalid function registry config %q: apiVersion and kind must be %q and %q",
+			path, gvk.GroupVersion().String(), gvk.Kind)
+	}
+	if r := c.FunctionRegistryConfig.DefaultRegistry; r != "" {
+		if _, err := name.NewRepository(strings.TrimSuffix(r, "/")); err != nil {
+			return nil, fmt.Errorf("invalid function registry config %q: invalid default registry %q: %w", path, r, err)
+		}
+	}
+	for _, m := range c.Mirrors {
+		if m.Source == "" || m.Mirror == "" {
+			return nil, fmt.Errorf("invalid function registry config %q: mirrors must have a source and a mirror", path)
+		}
+		if strings.HasSuffix(m.Source, "/*") != strings.HasSuffix(m.Mirror, "/*") {
+			return nil, fmt.Errorf("invalid function registry config %q: the mirror %q of %q must end with '/*' if and only if its source does",
+				path, m.Mirror, m.Source)
+		}
+	}
+	for _, cred := range c.Credentials {
+		if cred.Registry == "" || cred.Username == "" {
+			return nil, fmt.Errorf("invalid function registry config %q: credentials must have a registry and a username", path)
+		}
+		if (cred.PasswordEnv == "") == (cred.PasswordFile == "") {
+			return nil, fmt.Errorf("invalid function registry config %q: the credentials of %q must have one of passwordEnv and passwordFile",
+				path, cred.Registry)
+		}
+	}
+	return c, nil
+}
+
+var (
+	defaultRegistryConfigOnce sync.Once
+	defaultRegistryConfig     *RegistryConfig
+	defaultRegistryConfigErr  error
+)
+
+// DefaultRegistryConfig returns the function registry config of the file
+// in the KPT_FN_REGISTRY_CONFIG environment variable, or else of the
+// `.kpt/fn-registry.yaml` file in the user home directory. It returns nil,
+// the default config, if there is no such file. The file is read once.
+func DefaultRegistryConfig() (*RegistryConfig, error) {
+	defaultRegistryConfigOnce.Do(func() {
+		if p := os.Getenv(FnRegistryConfigEnv); p != "" {
+			defaultRegistryConfig, defaultRegistryConfigErr = LoadRegistryConfig(p)
+			return
+		}
+		if home, err := os.UserHomeDir(); err == nil {
+			p := filepath.Join(home, fnPolicyDir, FnRegistryConfigFileName)
+			if _, err := os.Stat(p); err == nil {
+				defaultRegistryConfig, defaultRegistryConfigErr = LoadRegistryConfig(p)
+			}
+		}
+	})
+	return defaultRegistryConfig, defaultRegistryConfigErr
+}
+
+// DefaultRegistry returns the registry the short names of functions are
+// resolved in.
+func (c *RegistryConfig) DefaultRegistry() string {
+	if c == nil || c.FunctionRegistryConfig.DefaultRegistry == "" {
+		return DefaultFnRegistry
+	}
+	return strings.TrimSuffix(c.FunctionRegistryConfig.DefaultRegistry, "/")
+}
+
+// Resolve returns the fully-qualified image of the function image, which
+// is the image in the default registry if image is a short name.
+// e.g. set-namespace:v0.1 --> gcr.io/kpt-fn/set-namespace:v0.1
+func (c *RegistryConfig) Resolve(image string) string {
+	if strings.Contains(image, "/") {
+		return image
+	}
+	return c.DefaultRegistry() + "/" + image
+}
+
+// Mirror returns the image pulled instead of the fully-qualified image, in
+// the first mirror matching its repository. The image is returned as is if
+// no mirror matches. Tags and digests are kept.
+func (c *RegistryConfig) Mirror(image string) string {
+	if c == nil || builtins.IsBuiltin(image) {
+		return image
+	}
+	repo, ref := splitImage(image)
+	for _, m := range c.Mirrors {
+		if prefix := strings.TrimSuffix(m.Source, "*"); prefix != m.Source {
+			if len(repo) > len(prefix) && strings.HasPrefix(repo, prefix) {
+				return strings.TrimSuffix(m.Mirror, "*") + repo[len(prefix):] + ref
+			}
+		} else if repo == m.Source {
+			return m.Mirror + ref
+		}
+	}
+	return image
+}
+
+// splitImage splits the image into its repository and its tag and digest,
+// including their `:` and `@` separators.
+func splitImage(image string) (string, string) {
+	repo := image
+	if i := strings.Index(repo, "@"); i >= 0 {
+		repo = repo[:i]
+	}
+	if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo = repo[:i]
+	}
+	return repo, image[len(repo):]
+}
+
+// Keychain returns the keychain providing the credentials of the config,
+// and the credentials of the docker config and of the credential helpers
+// for the other registries.
+func (c *RegistryConfig) Keychain() authn.Keychain {
+	if c == nil || len(c.Credentials) == 0 {
+		return gcrane.Keychain
+	}
+	return authn.NewMultiKeychain(registryKeychain{config: c}, gcrane.Keychain)
+}
+
+// registryKeychain is the keychain of the credentials of a config.
+type registryKeychain struct {
+	config *RegistryConfig
+}
+
+func (k registryKeychain) Resolve(r authn.Resource) (authn.Authenticator, error) {
+	auth, err := k.config.credentials(r.RegistryStr())
+	if err != nil || auth == nil {
+		return authn.Anonymous, err
+	}
+	return authn.FromConfig(*auth), nil
+}
+
+// credentials returns the credentials of the registry in the config, or
+// nil if the config has none.
+func (c *RegistryConfig) credentials(registry string) (*authn.AuthConfig, error) {
+	if c == nil {
+		return nil, nil
+	}
+	for _, cred := range c.Credentials {
+		if normalizeRegistry(cred.Registry) != normalizeRegistry(registry) {
+			continue
+		}
+		var password string
+		if cred.PasswordEnv != "" {
+			password = os.Getenv(cred.PasswordEnv)
+			if password == "" {
+				return nil, fmt.Errorf("the environment variable %q holding the password of registry %q is not set",
+					cred.PasswordEnv, cred.Registry)
+			}
+		} else {
+			p := cred.PasswordFile
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(filepath.Dir(c.Path), p)
+			}
+			b, err := os.ReadFile(p)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the password of registry %q: %w", cred.Registry, err)
+			}
+			password = strings.TrimSpace(string(b))
+		}
+		return &authn.AuthConfig{Username: cred.Username, Password: password}, nil
+	}
+	return nil, nil
+}
+
+// normalizeRegistry returns the canonical name of the registry, e.g.
+// index.docker.io for docker.io.
+func normalizeRegistry(registry string) string {
+	r, err := name.NewRegistry(registry)
+	if err != nil {
+		return registry
+	}
+	return r.RegistryStr()
+}
+
+// ContainerAuthEnv returns the environment variables passing the
+// credentials of the registry of the image in the config to the container
+// runtime bin, and a function removing the files they refer to. It returns
+// no variables if the config has no credentials for the registry, in which
+// case the runtime uses its own.
+func (c *RegistryConfig) ContainerAuthEnv(bin, image string) ([]string, func(), error) {
+	noop := func() {}
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		// the container runtime reports the invalid image.
+		return nil, noop, nil
+	}
+	registry := ref.Context().RegistryStr()
+	auth, err := c.credentials(registry)
+	if err != nil || auth == nil {
+		return nil, noop, err
+	}
+	key := registry
+	if registry == name.DefaultRegistry {
+		key = dockerHubAuthKey
+	}
+	encoded := base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password))
+
+	dir, err := os.MkdirTemp("", "kpt-registry-auth-")
+	if err != nil {
+		return nil, noop, fmt.Errorf("failed to create registry auth directory: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(dir) }
+	if bin == podmanBin {
+		b, err := json.Marshal(map[string]interface{}{
+			"auths": map[string]interface{}{key: map[string]string{"auth": encoded}},
+		})
+		if err == nil {
+			err = os.WriteFile(filepath.Join(dir, "auth.json"), b, 0600)
+		}
+		if err != nil {
+			cleanup()
+			return nil, noop, fmt.Errorf("failed to write registry auth file: %w", err)
+		}
+		return []string{"REGISTRY_AUTH_FILE=" + filepath.Join(dir, "auth.json")}, cleanup, nil
+	}
+	if err := writeDockerConfig(dir, registry, key, encoded); err != nil {
+		cleanup()
+		return nil, noop, err
+	}
+	return []string{"DOCKER_CONFIG=" + dir}, cleanup, nil
+}
+
+// writeDockerConfig writes the docker config of the user to dir with the
+// encoded credentials of the registry, whose key in the config is key. The credential store and the
+// credential helper of the registry are removed from the config since they
+// take precedence over its credentials.
+func writeDockerConfig(dir, registry, key, encoded string) error {
+	userDir := os.Getenv("DOCKER_CONFIG")
+	if userDir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			userDir = filepath.Join(home, ".docker")
+		}
+	}
+	config := map[string]interface{}{}
+	if userDir != "" {
+		b, err := os.ReadFile(filepath.Join(userDir, "config.json"))
+		if err == nil {
+			if err := json.Unmarshal(b, &config); err != nil {
+				return fmt.Errorf("invalid docker config %q: %w", filepath.Join(userDir, "config.json"), err)
+			}
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read docker config: %w", err)
+		}
+		// the contexts select the docker daemon.
+		if _, err := os.Stat(filepath.Join(userDir, "contexts")); err == nil {
+			if err := os.Symlink(filepath.Join(userDir, "contexts"), filepath.Join(dir, "contexts")); err != nil {
+				return fmt.Errorf("failed to link docker contexts: %w", err)
+			}
+		}
+	}
+	auths, _ := config["auths"].(map[string]interface{})
+	if auths == nil {
+		auths = map[string]interface{}{}
+	}
+	auths[key] = map[string]string{"auth": encoded}
+	config["auths"] = auths
+	delete(config, "credsStore")
+	if helpers, ok := config["credHelpers"].(map[string]interface{}); ok {
+		delete(helpers, registry)
+		delete(helpers, key)
+	}
+	b, err := json.Marshal(config)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, "config.json"), b, 0600)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write docker config: %w", err)
+	}
+	return nil
+}
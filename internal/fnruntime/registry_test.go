@@ -0,0 +1,219 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRegistryConfig = `apiVersion: kpt.dev/v1alpha1
+kind: FunctionRegistryConfig
+defaultRegistry: registry.local/kpt-fn/
+mirrors:
+  - source: gcr.io/kpt-fn/starlark
+    mirror: registry.local/starlark
+  - source: gcr.io/kpt-fn/*
+    mirror: registry.local/kpt-fn/*
+credentials:
+  - registry: registry.local
+    username: kpt
+    passwordFile: password.txt
+  - registry: docker.io
+    username: kpt
+    passwordEnv: KPT_TEST_REGISTRY_PASSWORD
+`
+
+func writeRegistryConfig(t *testing.T, content string) string {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FnRegistryConfigFileName)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "password.txt"), []byte("secret\n"), 0600))
+	return path
+}
+
+func TestLoadRegistryConfig(t *testing.T) {
+	testCases := map[string]struct {
+		config string
+		err    string
+	}{
+		"valid": {
+			config: testRegistryConfig,
+		},
+		"wrong kind": {
+			config: "apiVersion: kpt.dev/v1alpha1\nkind: FunctionPolicy\n",
+			err:    `apiVersion and kind must be "kpt.dev/v1alpha1" and "FunctionRegistryConfig"`,
+		},
+		"unknown field": {
+			config: "apiVersion: kpt.dev/v1alpha1\nkind: FunctionRegistryConfig\nregistries: []\n",
+			err:    "field registries not found",
+		},
+		"invalid default registry": {
+			config: "apiVersion: kpt.dev/v1alpha1\nkind: FunctionRegistryConfig\ndefaultRegistry: Registry.local/KPT\n",
+			err:    `invalid default registry "Registry.local/KPT"`,
+		},
+		"mirror without wildcard": {
+			config: `apiVersion: kpt.dev/v1alpha1
+kind: FunctionRegistryConfig
+mirrors:
+  - source: gcr.io/kpt-fn/*
+    mirror: registry.local/kpt-fn
+`,
+			err: `the mirror "registry.local/kpt-fn" of "gcr.io/kpt-fn/*" must end with '/*' if and only if its source does`,
+		},
+		"credentials without password": {
+			config: `apiVersion: kpt.dev/v1alpha1
+kind: FunctionRegistryConfig
+credentials:
+  - registry: registry.local
+    username: kpt
+`,
+			err: `the credentials of "registry.local" must have one of passwordEnv and passwordFile`,
+		},
+	}
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			_, err := LoadRegistryConfig(writeRegistryConfig(t, tc.config))
+			if tc.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestRegistryConfig_Resolve(t *testing.T) {
+	c, err := LoadRegistryConfig(writeRegistryConfig(t, testRegistryConfig))
+	require.NoError(t, err)
+	var defaults *RegistryConfig
+
+	assert.Equal(t, "gcr.io/kpt-fn/set-labels:v0.1", defaults.Resolve("set-labels:v0.1"))
+	assert.Equal(t, "registry.local/kpt-fn/set-labels:v0.1", c.Resolve("set-labels:v0.1"))
+	assert.Equal(t, "example.com/set-labels:v0.1", c.Resolve("example.com/set-labels:v0.1"))
+}
+
+func TestRegistryConfig_Mirror(t *testing.T) {
+	c, err := LoadRegistryConfig(writeRegistryConfig(t, testRegistryConfig))
+	require.NoError(t, err)
+	digest := "@sha256:" + strings.Repeat("a", 64)
+
+	testCases := map[string]string{
+		"gcr.io/kpt-fn/set-labels:v0.1":         "registry.local/kpt-fn/set-labels:v0.1",
+		"gcr.io/kpt-fn/set-labels" + digest:     "registry.local/kpt-fn/set-labels" + digest,
+		"gcr.io/kpt-fn/x/y:v0.1" + digest:       "registry.local/kpt-fn/x/y:v0.1" + digest,
+		"gcr.io/kpt-fn/starlark:v0.4":           "registry.local/starlark:v0.4",
+		"gcr.io/kpt-fn-contrib/set-labels:v0.1": "gcr.io/kpt-fn-contrib/set-labels:v0.1",
+		"localhost:5000/set-labels":             "localhost:5000/set-labels",
+		"builtins/set-labels":                   "builtins/set-labels",
+	}
+	for image, expected := range testCases {
+		assert.Equal(t, expected, c.Mirror(image), image)
+	}
+	var defaults *RegistryConfig
+	assert.Equal(t, "gcr.io/kpt-fn/set-labels:v0.1", defaults.Mirror("gcr.io/kpt-fn/set-labels:v0.1"))
+}
+
+func TestRegistryConfig_Keychain(t *testing.T) {
+	c, err := LoadRegistryConfig(writeRegistryConfig(t, testRegistryConfig))
+	require.NoError(t, err)
+	t.Setenv("KPT_TEST_REGISTRY_PASSWORD", "token")
+
+	resolve := func(image string) *authn.AuthConfig {
+		ref, err := name.ParseReference(image)
+		require.NoError(t, err)
+		auth, err := c.Keychain().Resolve(ref.Context())
+		require.NoError(t, err)
+		cfg, err := auth.Authorization()
+		require.NoError(t, err)
+		return cfg
+	}
+	assert.Equal(t, &authn.AuthConfig{Username: "kpt", Password: "secret"}, resolve("registry.local/kpt-fn/set-labels:v0.1"))
+	assert.Equal(t, &authn.AuthConfig{Username: "kpt", Password: "token"}, resolve("index.docker.io/library/busybox"))
+
+	t.Setenv("KPT_TEST_REGISTRY_PASSWORD", "")
+	_, err = c.credentials("docker.io")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `the environment variable "KPT_TEST_REGISTRY_PASSWORD" holding the password of registry "docker.io" is not set`)
+	}
+}
+
+func TestRegistryConfig_ContainerAuthEnv(t *testing.T) {
+	c, err := LoadRegistryConfig(writeRegistryConfig(t, testRegistryConfig))
+	require.NoError(t, err)
+	dockerDir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dockerDir)
+	require.NoError(t, os.WriteFile(filepath.Join(dockerDir, "config.json"), []byte(`{
+  "credsStore": "desktop",
+  "credHelpers": {"registry.local": "gcloud", "gcr.io": "gcloud"},
+  "currentContext": "remote"
+}`), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(dockerDir, "contexts"), 0755))
+
+	readJSON := func(path string) map[string]interface{} {
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		config := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(b, &config))
+		return config
+	}
+	auth := map[string]interface{}{"auth": "a3B0OnNlY3JldA=="}
+
+	t.Run("docker", func(t *testing.T) {
+		env, cleanup, err := c.ContainerAuthEnv(dockerBin, "registry.local/kpt-fn/set-labels:v0.1")
+		require.NoError(t, err)
+		defer cleanup()
+		require.Len(t, env, 1)
+		dir := strings.TrimPrefix(env[0], "DOCKER_CONFIG=")
+		assert.Equal(t, map[string]interface{}{
+			"auths":          map[string]interface{}{"registry.local": auth},
+			"credHelpers":    map[string]interface{}{"gcr.io": "gcloud"},
+			"currentContext": "remote",
+		}, readJSON(filepath.Join(dir, "config.json")))
+		target, err := os.Readlink(filepath.Join(dir, "contexts"))
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(dockerDir, "contexts"), target)
+
+		cleanup()
+		assert.NoDirExists(t, dir)
+	})
+
+	t.Run("podman", func(t *testing.T) {
+		env, cleanup, err := c.ContainerAuthEnv(podmanBin, "registry.local/kpt-fn/set-labels:v0.1")
+		require.NoError(t, err)
+		defer cleanup()
+		require.Len(t, env, 1)
+		assert.Equal(t, map[string]interface{}{
+			"auths": map[string]interface{}{"registry.local": auth},
+		}, readJSON(strings.TrimPrefix(env[0], "REGISTRY_AUTH_FILE=")))
+	})
+
+	t.Run("no credentials", func(t *testing.T) {
+		env, cleanup, err := c.ContainerAuthEnv(dockerBin, "gcr.io/kpt-fn/set-labels:v0.1")
+		require.NoError(t, err)
+		defer cleanup()
+		assert.Empty(t, env)
+	})
+}
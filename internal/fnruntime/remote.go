@@ -0,0 +1,270 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	goerrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime/evaluator"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+	"github.com/GoogleContainerTools/kpt/pkg/printer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// plaintextScheme is the endpoint scheme for evaluators that don't
+	// use TLS.
+	plaintextScheme = "grpc://"
+	// tlsScheme is the endpoint scheme for evaluators using TLS. It is the
+	// default when the endpoint has no scheme.
+	tlsScheme = "grpcs://"
+)
+
+// RemoteRuntimeEndpoint returns the endpoint of the function evaluator
+// service, which is the endpoint set on the command line if any, and the
+// value of the KPT_FN_RUNTIME environment variable if it is a `grpc://` or
+// `grpcs://` endpoint otherwise. It returns an empty string if no function
+// evaluator service should be used.
+func RemoteRuntimeEndpoint(flag string) string {
+	if flag != "" {
+		return flag
+	}
+	if v := os.Getenv(ContainerRuntimeEnv); isRemoteEndpoint(v) {
+		return v
+	}
+	return ""
+}
+
+// isRemoteEndpoint returns true if v is the endpoint of a function evaluator
+// service rather than the name of a container runtime.
+func isRemoteEndpoint(v string) bool {
+	return strings.HasPrefix(v, plaintextScheme) || strings.HasPrefix(v, tlsScheme)
+}
+
+// RemoteRuntime is a FunctionRuntime evaluating function images with a
+// function evaluator service over gRPC. A single connection to the service
+// is shared by all the runners of the runtime.
+type RemoteRuntime struct {
+	// target is the address of the service.
+	target string
+	// creds are the transport credentials used to connect to the service.
+	creds credentials.TransportCredentials
+
+	mu   sync.Mutex
+	conn *grpc.ClientConn
+}
+
+var _ fn.FunctionRuntime = &RemoteRuntime{}
+
+// NewRemoteRuntime returns a RemoteRuntime for the function evaluator at the
+// endpoint. Endpoints are of the form `[grpc://|grpcs://]HOST:PORT`, TLS is
+// used unless the endpoint has the `grpc://` scheme. tlsConfig can be nil to
+// use the system certificate pool.
+func NewRemoteRuntime(endpoint string, tlsConfig *tls.Config) (*RemoteRuntime, error) {
+	r := &RemoteRuntime{}
+	switch {
+	case strings.HasPrefix(endpoint, plaintextScheme):
+		r.target = strings.TrimPrefix(endpoint, plaintextScheme)
+		r.creds = insecure.NewCredentials()
+	default:
+		r.target = strings.TrimPrefix(endpoint, tlsScheme)
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		r.creds = credentials.NewTLS(tlsConfig)
+	}
+	if r.target == "" || strings.Contains(r.target, "://") {
+		return nil, fmt.Errorf("invalid function runtime endpoint %q, must be of the form [grpc://|grpcs://]HOST:PORT", endpoint)
+	}
+	return r, nil
+}
+
+// GetRunner implements fn.FunctionRuntime. Only functions with an image can
+// be evaluated remotely, a fn.NotFoundError is returned for other functions.
+func (r *RemoteRuntime) GetRunner(ctx context.Context, f *kptfilev1.Function) (fn.FunctionRunner, error) {
+	if f.Image == "" {
+		return nil, &fn.NotFoundError{Function: *f}
+	}
+	timeout := defaultLongTimeout
+	if f.Timeout != "" {
+		d, err := time.ParseDuration(f.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout %q: %w", f.Timeout, err)
+		}
+		timeout = d
+	}
+	conn, err := r.connect()
+	if err != nil {
+		return nil, err
+	}
+	return &remoteFn{
+		ctx:      ctx,
+		client:   evaluator.NewFunctionEvaluatorClient(conn),
+		function: *f,
+		timeout:  timeout,
+	}, nil
+}
+
+// Close closes the connection to the function evaluator service.
+func (r *RemoteRuntime) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+// connect returns the connection to the service, dialing it on first use.
+func (r *RemoteRuntime) connect() (*grpc.ClientConn, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn != nil {
+		return r.conn, nil
+	}
+	conn, err := grpc.Dial(r.target, grpc.WithTransportCredentials(r.creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to function runtime %q: %w", r.target, err)
+	}
+	r.conn = conn
+	return conn, nil
+}
+
+// remoteFn evaluates a function image with the function evaluator service.
+type remoteFn struct {
+	ctx      context.Context
+	client   evaluator.FunctionEvaluatorClient
+	function kptfilev1.Function
+	timeout  time.Duration
+	// fnResult stores the log of the function, if set.
+	fnResult *fnresult.Result
+}
+
+// SetFnResult sets the result the log of the function is stored in.
+func (f *remoteFn) SetFnResult(fnResult *fnresult.Result) {
+	f.fnResult = fnResult
+}
+
+// Run sends the resource list read from r to the service and writes the
+// resulting resource list to w.
+func (f *remoteFn) Run(r io.Reader, w io.Writer) error {
+	in, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read function input: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(f.ctx, f.timeout)
+	defer cancel()
+
+	resp, err := f.client.EvaluateFunction(ctx, &evaluator.EvaluateFunctionRequest{
+		ResourceList: in,
+		Image:        f.function.Image,
+	})
+	if err != nil {
+		s := status.Convert(err)
+		switch s.Code() {
+		case codes.NotFound, codes.Unimplemented:
+			return &fn.NotFoundError{Function: f.function}
+		case codes.DeadlineExceeded:
+			return &LimitError{Limit: "timeout", Value: f.timeout.String(), Err: err}
+		case codes.Unavailable:
+			return fmt.Errorf("function runtime is unavailable: %w", err)
+		}
+		return &ExecError{
+			OriginalErr:    err,
+			ExitCode:       1,
+			Stderr:         s.Message(),
+			TruncateOutput: printer.TruncateOutput,
+		}
+	}
+	if len(resp.Log) > 0 && f.fnResult != nil {
+		f.fnResult.Stderr = string(resp.Log)
+	}
+	_, err = io.Copy(w, bytes.NewReader(resp.ResourceList))
+	return err
+}
+
+// fnResultSetter is implemented by the runners which store the stderr of
+// functions in their result.
+type fnResultSetter interface {
+	SetFnResult(fnResult *fnresult.Result)
+}
+
+// fallbackFn runs a function with the runner of a function runtime and falls
+// back to a built-in runner if the runtime reports the function as not found
+// when it runs.
+type fallbackFn struct {
+	run      func(io.Reader, io.Writer) error
+	fallback func() (func(io.Reader, io.Writer) error, error)
+}
+
+// Run implements the run function of runtimeutil.FunctionFilter.
+func (f *fallbackFn) Run(r io.Reader, w io.Writer) error {
+	in, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	err = f.run(bytes.NewReader(in), w)
+	var notFoundErr *fn.NotFoundError
+	if !goerrors.As(err, &notFoundErr) {
+		return err
+	}
+	run, err := f.fallback()
+	if err != nil {
+		return err
+	}
+	return run(bytes.NewReader(in), w)
+}
+
+// RunWithRuntime returns the run function of the runner that runtime
+// provides for the function. The builtin run function is used when runtime
+// is nil or doesn't know about the function. The stderr of the function is
+// stored in fnResult if the runner supports it.
+func RunWithRuntime(ctx context.Context, runtime fn.FunctionRuntime, f *kptfilev1.Function, fnResult *fnresult.Result,
+	builtin func() (func(io.Reader, io.Writer) error, error)) (func(io.Reader, io.Writer) error, error) {
+	if runtime == nil {
+		return builtin()
+	}
+	runner, err := runtime.GetRunner(ctx, f)
+	var notFoundErr *fn.NotFoundError
+	switch {
+	case goerrors.As(err, &notFoundErr):
+		return builtin()
+	case err != nil:
+		return nil, fmt.Errorf("function runtime failed to evaluate function %q: %w", f.Image, err)
+	case runner == nil:
+		return builtin()
+	}
+	if s, ok := runner.(fnResultSetter); ok {
+		s.SetFnResult(fnResult)
+	}
+	return (&fallbackFn{run: runner.Run, fallback: builtin}).Run, nil
+}
@@ -0,0 +1,299 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"context"
+	goerrors "errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime/evaluator"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+	"github.com/GoogleContainerTools/kpt/pkg/printer"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// fakeEvaluator upper cases the resource list for the `upper` image, and
+// logs that it did. Other images time out, are not found or fail depending
+// on their name.
+type fakeEvaluator struct {
+	evaluator.UnimplementedFunctionEvaluatorServer
+}
+
+func (e *fakeEvaluator) EvaluateFunction(ctx context.Context, req *evaluator.EvaluateFunctionRequest) (*evaluator.EvaluateFunctionResponse, error) {
+	switch req.Image {
+	case "upper":
+		return &evaluator.EvaluateFunctionResponse{
+			ResourceList: bytes.ToUpper(req.ResourceList),
+			Log:          []byte("upper cased the resources"),
+		}, nil
+	case "slow":
+		<-ctx.Done()
+		return nil, status.FromContextError(ctx.Err()).Err()
+	case "unknown":
+		return nil, status.Errorf(codes.NotFound, "image %q not found", req.Image)
+	default:
+		return nil, status.Errorf(codes.Internal, "function failed: bad input")
+	}
+}
+
+func startEvaluator(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	s := grpc.NewServer()
+	evaluator.RegisterFunctionEvaluatorServer(s, &fakeEvaluator{})
+	go func() { _ = s.Serve(l) }()
+	t.Cleanup(s.Stop)
+	return "grpc://" + l.Addr().String()
+}
+
+func TestRemoteRuntime(t *testing.T) {
+	runtime, err := NewRemoteRuntime(startEvaluator(t), nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer runtime.Close()
+	ctx := context.Background()
+
+	builtin := func() (func(io.Reader, io.Writer) error, error) {
+		return func(r io.Reader, w io.Writer) error {
+			_, err := io.Copy(w, io.MultiReader(strings.NewReader("builtin: "), r))
+			return err
+		}, nil
+	}
+
+	testCases := map[string]struct {
+		function    kptfilev1.Function
+		expected    string
+		expectedErr string
+	}{
+		"evaluated remotely": {
+			function: kptfilev1.Function{Image: "upper"},
+			expected: "KIND: CONFIGMAP",
+		},
+		"not found falls back to builtin": {
+			function: kptfilev1.Function{Image: "unknown"},
+			expected: "builtin: kind: ConfigMap",
+		},
+		"exec functions are not evaluated remotely": {
+			function: kptfilev1.Function{Exec: "fn"},
+			expected: "builtin: kind: ConfigMap",
+		},
+		"deadline": {
+			function:    kptfilev1.Function{Image: "slow", Timeout: "100ms"},
+			expectedErr: "function exceeded its timeout limit of 100ms",
+		},
+		"function error": {
+			function:    kptfilev1.Function{Image: "failing"},
+			expectedErr: "function failed: bad input",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			run, err := RunWithRuntime(ctx, runtime, &tc.function, nil, builtin)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			var out bytes.Buffer
+			err = run(strings.NewReader("kind: ConfigMap"), &out)
+			if tc.expectedErr != "" {
+				assert.Error(t, err)
+				var execErr *ExecError
+				if goerrors.As(err, &execErr) {
+					assert.Equal(t, tc.expectedErr, execErr.Stderr)
+				} else {
+					assert.Equal(t, tc.expectedErr, err.Error())
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, out.String())
+		})
+	}
+}
+
+func TestRemoteRuntime_log(t *testing.T) {
+	runtime, err := NewRemoteRuntime(startEvaluator(t), nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer runtime.Close()
+
+	fnResult := &fnresult.Result{}
+	run, err := RunWithRuntime(context.Background(), runtime, &kptfilev1.Function{Image: "upper"}, fnResult, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, run(strings.NewReader("a"), io.Discard))
+	assert.Equal(t, "upper cased the resources", fnResult.Stderr)
+}
+
+// TestNewRunner_remoteRuntime verifies that functions are run locally when
+// they need what the function evaluator service can't provide.
+func TestNewRunner_remoteRuntime(t *testing.T) {
+	runtime, err := NewRemoteRuntime(startEvaluator(t), nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer runtime.Close()
+
+	testCases := map[string]struct {
+		function kptfilev1.Function
+		opts     RunnerOptions
+		remote   bool
+	}{
+		"remote": {
+			function: kptfilev1.Function{Image: "upper", Timeout: "1m"},
+			remote:   true,
+		},
+		"network": {
+			function: kptfilev1.Function{Image: "upper"},
+			opts:     RunnerOptions{AllowNetwork: true},
+		},
+		"memory": {
+			function: kptfilev1.Function{Image: "upper", Memory: "64Mi"},
+		},
+		"cpu": {
+			function: kptfilev1.Function{Image: "upper", CPU: "500m"},
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			tc.opts.ResolveToImage = func(_ context.Context, image string) (string, error) { return image, nil }
+			// the image can't be run locally.
+			tc.opts.ImagePullPolicy = NeverPull
+			ctx := printer.WithContext(context.Background(), printer.New(nil, &bytes.Buffer{}))
+			fr, err := NewRunner(ctx, filesys.MakeFsInMemory(), &tc.function, "/", fnresult.NewResultList(), tc.opts, runtime)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			var out bytes.Buffer
+			err = fr.filter.Run(strings.NewReader("kind: ConfigMap"), &out)
+			if tc.remote {
+				assert.NoError(t, err)
+				assert.Equal(t, "KIND: CONFIGMAP", out.String())
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestRemoteRuntime_reusesConnection(t *testing.T) {
+	runtime, err := NewRemoteRuntime(startEvaluator(t), nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer runtime.Close()
+
+	first, err := runtime.connect()
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		runner, err := runtime.GetRunner(context.Background(), &kptfilev1.Function{Image: "upper"})
+		assert.NoError(t, err)
+		var out bytes.Buffer
+		assert.NoError(t, runner.Run(strings.NewReader("a"), &out))
+		assert.Equal(t, "A", out.String())
+	}
+	second, err := runtime.connect()
+	assert.NoError(t, err)
+	assert.Same(t, first, second)
+}
+
+func TestRemoteRuntime_notFound(t *testing.T) {
+	runtime, err := NewRemoteRuntime(startEvaluator(t), nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer runtime.Close()
+
+	runner, err := runtime.GetRunner(context.Background(), &kptfilev1.Function{Image: "unknown"})
+	assert.NoError(t, err)
+	err = runner.Run(strings.NewReader(""), io.Discard)
+	var notFoundErr *fn.NotFoundError
+	assert.True(t, goerrors.As(err, &notFoundErr))
+
+	// other runtimes take over in a MultiRuntime
+	_, err = fn.NewMultiRuntime([]fn.FunctionRuntime{runtime}).GetRunner(context.Background(), &kptfilev1.Function{Exec: "fn"})
+	assert.True(t, goerrors.As(err, &notFoundErr))
+}
+
+func TestNewRemoteRuntime(t *testing.T) {
+	testCases := map[string]struct {
+		endpoint    string
+		target      string
+		tls         bool
+		expectedErr string
+	}{
+		"plaintext": {
+			endpoint: "grpc://evaluator:9445",
+			target:   "evaluator:9445",
+		},
+		"tls": {
+			endpoint: "grpcs://evaluator.example.com:443",
+			target:   "evaluator.example.com:443",
+			tls:      true,
+		},
+		"tls by default": {
+			endpoint: "evaluator.example.com:443",
+			target:   "evaluator.example.com:443",
+			tls:      true,
+		},
+		"unsupported scheme": {
+			endpoint:    "http://evaluator:9445",
+			expectedErr: `invalid function runtime endpoint "http://evaluator:9445", must be of the form [grpc://|grpcs://]HOST:PORT`,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			r, err := NewRemoteRuntime(tc.endpoint, nil)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.target, r.target)
+			assert.Equal(t, tc.tls, r.creds.Info().SecurityProtocol == "tls")
+		})
+	}
+}
+
+func TestRemoteRuntimeEndpoint(t *testing.T) {
+	t.Setenv(ContainerRuntimeEnv, "grpc://from-env:9445")
+	assert.Equal(t, "grpc://from-flag:9445", RemoteRuntimeEndpoint("grpc://from-flag:9445"))
+	assert.Equal(t, "grpc://from-env:9445", RemoteRuntimeEndpoint(""))
+	runtime, err := StringToContainerRuntime(os.Getenv(ContainerRuntimeEnv))
+	assert.NoError(t, err)
+	assert.Equal(t, Docker, runtime)
+
+	t.Setenv(ContainerRuntimeEnv, "podman")
+	assert.Equal(t, "", RemoteRuntimeEndpoint(""))
+}
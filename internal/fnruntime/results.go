@@ -0,0 +1,501 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/utils"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Formats function results can be saved in.
+const (
+	// ResultsFormatYAML is the FunctionResultList format.
+	ResultsFormatYAML = "yaml"
+	// ResultsFormatSARIF is the SARIF 2.1.0 format of static analysis
+	// tools.
+	ResultsFormatSARIF = "sarif"
+	// ResultsFormatJUnit is the JUnit XML format of test reports.
+	ResultsFormatJUnit = "junit"
+)
+
+// ResultsFormats are the supported formats of function results.
+var ResultsFormats = []string{ResultsFormatYAML, ResultsFormatSARIF, ResultsFormatJUnit}
+
+// resultsFileNames are the names of the results files by format.
+var resultsFileNames = map[string]string{
+	ResultsFormatYAML:  "results.yaml",
+	ResultsFormatSARIF: "results.sarif",
+	ResultsFormatJUnit: "results.junit.xml",
+}
+
+// ValidateResultsFormats returns an error if one of the formats is not
+// supported.
+func ValidateResultsFormats(formats []string) error {
+	for _, f := range formats {
+		if _, found := resultsFileNames[f]; !found {
+			return fmt.Errorf("unsupported results format %q, must be one of %s", f, strings.Join(ResultsFormats, ", "))
+		}
+	}
+	return nil
+}
+
+// writeResults writes the function results to w in the format.
+func writeResults(w io.Writer, format string, fnResults *fnresult.ResultList, l *resultLocator) error {
+	switch format {
+	case ResultsFormatSARIF:
+		return writeSARIF(w, fnResults, l)
+	case ResultsFormatJUnit:
+		return writeJUnit(w, fnResults, l)
+	default:
+		// use kyaml encoder to ensure consistent indentation
+		e := yaml.NewEncoderWithOptions(w, &yaml.EncoderOptions{SeqIndent: yaml.WideSequenceStyle})
+		return e.Encode(fnResults)
+	}
+}
+
+// functionName returns the image or the executable of the function of the
+// result.
+func functionName(r *fnresult.Result) string {
+	name := r.ExecPath
+	if r.Image != "" {
+		name = r.Image
+	}
+	if r.Pkg != "" {
+		name += fmt.Sprintf(" (package %s)", r.Pkg)
+	}
+	return name
+}
+
+// resultLocation is the location in the package of the resource or field a
+// function result refers to.
+type resultLocation struct {
+	// Path is the path of the file relative to the package directory, or
+	// relative to the current directory for results of several packages.
+	Path string
+	// Line and Column start at 1, they are 0 if unknown.
+	Line   int
+	Column int
+}
+
+// resultLocator resolves the location of function results in the files of
+// a package.
+type resultLocator struct {
+	fsys    filesys.FileSystem
+	pkgPath string
+
+	// resources are the resources of the package, read on first use.
+	resources []*yaml.RNode
+	// docs are the parsed documents of the package files, by path.
+	docs map[string][]*yaml.Node
+
+	// pkgs are the locators of the packages of the results which have one.
+	pkgs map[string]*resultLocator
+	// prefix is prepended to the located paths.
+	prefix string
+}
+
+func newResultLocator(fsys filesys.FileSystem, pkgPath string) *resultLocator {
+	return &resultLocator{fsys: fsys, pkgPath: pkgPath, docs: map[string][]*yaml.Node{}}
+}
+
+// forResult returns the locator of the results of the function result,
+// i.e. the locator of its package if it has one.
+func (l *resultLocator) forResult(item *fnresult.Result) *resultLocator {
+	if item.Pkg == "" {
+		return l
+	}
+	if l.pkgs == nil {
+		l.pkgs = map[string]*resultLocator{}
+	}
+	pl, found := l.pkgs[item.Pkg]
+	if !found {
+		pl = newResultLocator(l.fsys, item.Pkg)
+		pl.prefix = filepath.ToSlash(item.Pkg)
+		l.pkgs[item.Pkg] = pl
+	}
+	return pl
+}
+
+// locate returns the location of the result, or nil if the result doesn't
+// refer to a file or resource of the package.
+func (l *resultLocator) locate(r *framework.Result) *resultLocation {
+	var path string
+	var index int
+	if r.File != nil {
+		path, index = filepath.ToSlash(filepath.Clean(r.File.Path)), r.File.Index
+	}
+	// function results in subpackages have paths relative to the
+	// subpackage, resources give the paths relative to the package.
+	if r.ResourceRef != nil && l.pkgPath != "" {
+		if p, i, found := l.findResource(r.ResourceRef, path); found {
+			path, index = p, i
+		}
+	}
+	if path == "" {
+		return nil
+	}
+	loc := &resultLocation{Path: path}
+	if l.prefix != "" {
+		loc.Path = filepath.ToSlash(filepath.Join(l.prefix, path))
+	}
+	node := l.document(path, index)
+	if node == nil {
+		return loc
+	}
+	if r.Field != nil && r.Field.Path != "" {
+		if field, err := yaml.NewRNode(node).Pipe(yaml.Lookup(fieldPath(r.Field.Path)...)); err == nil && field != nil {
+			node = field.YNode()
+		}
+	}
+	loc.Line, loc.Column = node.Line, node.Column
+	return loc
+}
+
+// findResource returns the path and index of the resource in the package.
+// If several resources match, the one whose path ends with hint wins.
+func (l *resultLocator) findResource(id *yaml.ResourceIdentifier, hint string) (string, int, bool) {
+	if l.resources == nil {
+		reader := &kio.LocalPackageReader{
+			PackagePath:        l.pkgPath,
+			IncludeSubpackages: true,
+			FileSystem:         filesys.FileSystemOrOnDisk{FileSystem: l.fsys},
+			PreserveSeqIndent:  true,
+			WrapBareSeqNode:    true,
+		}
+		resources, err := reader.Read()
+		if err != nil {
+			return "", 0, false
+		}
+		l.resources = resources
+	}
+	var path string
+	var index int
+	found := false
+	for _, r := range l.resources {
+		if r.GetApiVersion() != id.APIVersion || r.GetKind() != id.Kind ||
+			r.GetName() != id.Name || r.GetNamespace() != id.Namespace {
+			continue
+		}
+		p, i, err := kioutil.GetFileAnnotations(r)
+		if err != nil {
+			continue
+		}
+		idx, _ := strconv.Atoi(i)
+		p = filepath.ToSlash(p)
+		if !found || (hint != "" && (p == hint || strings.HasSuffix(p, "/"+hint))) {
+			path, index, found = p, idx, true
+		}
+	}
+	return path, index, found
+}
+
+// document returns the document at index in the file at path, relative to
+// the package.
+func (l *resultLocator) document(path string, index int) *yaml.Node {
+	docs, found := l.docs[path]
+	if !found {
+		b, err := l.fsys.ReadFile(filepath.Join(l.pkgPath, filepath.FromSlash(path)))
+		if err == nil {
+			d := yaml.NewDecoder(bytes.NewReader(b))
+			for {
+				var doc yaml.Node
+				if err := d.Decode(&doc); err != nil {
+					break
+				}
+				if len(doc.Content) > 0 {
+					docs = append(docs, doc.Content[0])
+				}
+			}
+		}
+		l.docs[path] = docs
+	}
+	if index < 0 || index >= len(docs) {
+		return nil
+	}
+	return docs[index]
+}
+
+// fieldPath splits a field path such as `spec.containers[name=app].image`
+// or `spec.containers[0].image` into lookup path elements.
+func fieldPath(path string) []string {
+	var parts []string
+	for _, p := range utils.SmarterPathSplitter(path, ".") {
+		for p != "" {
+			i := strings.Index(p, "[")
+			if i < 0 {
+				parts = append(parts, p)
+				break
+			}
+			if i > 0 {
+				parts = append(parts, p[:i])
+			}
+			j := strings.Index(p[i:], "]")
+			if j < 0 {
+				parts = append(parts, p[i:])
+				break
+			}
+			elem := p[i : i+j+1]
+			if yaml.IsIdxNumber(elem[1 : len(elem)-1]) {
+				elem = elem[1 : len(elem)-1]
+			}
+			parts = append(parts, elem)
+			p = p[i+j+1:]
+		}
+	}
+	return parts
+}
+
+// resourceName returns a human readable reference to the resource.
+func resourceName(id *yaml.ResourceIdentifier) string {
+	name := id.Name
+	if id.Namespace != "" {
+		name = id.Namespace + "/" + name
+	}
+	return fmt.Sprintf("%s/%s/%s", id.APIVersion, id.Kind, name)
+}
+
+// The SARIF 2.1.0 log format, reduced to the properties used by kpt.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name string `json:"name"`
+}
+
+type sarifResult struct {
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifLevels maps the severities of function results to SARIF levels.
+var sarifLevels = map[framework.Severity]string{
+	framework.Error:   "error",
+	framework.Warning: "warning",
+	framework.Info:    "note",
+}
+
+// writeSARIF writes the function results as a SARIF log with a run per
+// function.
+func writeSARIF(w io.Writer, fnResults *fnresult.ResultList, l *resultLocator) error {
+	log := sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{},
+	}
+	for i := range fnResults.Items {
+		item := &fnResults.Items[i]
+		run := sarifRun{
+			Tool:    sarifTool{Driver: sarifDriver{Name: functionName(item)}},
+			Results: []sarifResult{},
+		}
+		for _, r := range item.Results {
+			level, found := sarifLevels[r.Severity]
+			if !found {
+				level = "error"
+			}
+			result := sarifResult{Level: level, Message: sarifMessage{Text: r.Message}}
+			var loc sarifLocation
+			if rl := l.forResult(item).locate(r); rl != nil {
+				loc.PhysicalLocation = &sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: rl.Path}}
+				if rl.Line > 0 {
+					loc.PhysicalLocation.Region = &sarifRegion{StartLine: rl.Line, StartColumn: rl.Column}
+				}
+			}
+			if r.ResourceRef != nil {
+				name := resourceName(r.ResourceRef)
+				if r.Field != nil && r.Field.Path != "" {
+					name += "." + r.Field.Path
+				}
+				loc.LogicalLocations = []sarifLogicalLocation{{FullyQualifiedName: name, Kind: "resource"}}
+			}
+			if loc.PhysicalLocation != nil || loc.LogicalLocations != nil {
+				result.Locations = []sarifLocation{loc}
+			}
+			run.Results = append(run.Results, result)
+		}
+		if item.ExitCode != 0 && len(item.Results) == 0 {
+			// the function failed without structured results
+			run.Results = append(run.Results, sarifResult{
+				Level:   "error",
+				Message: sarifMessage{Text: fmt.Sprintf("function failed with exit code %d: %s", item.ExitCode, strings.TrimSpace(item.Stderr))},
+			})
+		}
+		log.Runs = append(log.Runs, run)
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(log)
+}
+
+// The JUnit XML report format, as understood by most CI systems.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes the function results as a JUnit XML report with a
+// testcase per function. A function fails if it exited with an error or
+// reported error results.
+func writeJUnit(w io.Writer, fnResults *fnresult.ResultList, l *resultLocator) error {
+	suite := junitTestSuite{Name: "kpt functions"}
+	for i := range fnResults.Items {
+		item := &fnResults.Items[i]
+		tc := junitTestCase{
+			Name:      functionName(item),
+			ClassName: "kpt.functions",
+			SystemErr: item.Stderr,
+		}
+		var errs, lines []string
+		for _, r := range item.Results {
+			line := fmt.Sprintf("[%s] %s", r.Severity, r.Message)
+			if r.ResourceRef != nil {
+				line += " (" + resourceName(r.ResourceRef)
+				if r.Field != nil && r.Field.Path != "" {
+					line += " " + r.Field.Path
+				}
+				line += ")"
+			}
+			if loc := l.forResult(item).locate(r); loc != nil {
+				line += " at " + loc.Path
+				if loc.Line > 0 {
+					line += fmt.Sprintf(":%d", loc.Line)
+				}
+			}
+			lines = append(lines, line)
+			if r.Severity == framework.Error || r.Severity == "" {
+				errs = append(errs, r.Message)
+			}
+		}
+		switch {
+		case item.Skipped:
+			tc.Skipped = &struct{}{}
+			suite.Skipped++
+		case item.ExitCode != 0 || len(errs) > 0:
+			message := fmt.Sprintf("function failed with exit code %d", item.ExitCode)
+			if len(errs) > 0 {
+				message = errs[0]
+				if len(errs) > 1 {
+					message += fmt.Sprintf(" (and %d more errors)", len(errs)-1)
+				}
+			}
+			tc.Failure = &junitFailure{Message: message, Type: "error", Text: strings.Join(lines, "\n")}
+			suite.Failures++
+		default:
+			tc.SystemOut = strings.Join(lines, "\n")
+		}
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	report := junitTestSuites{
+		Name:     "kpt",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Suites:   []junitTestSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,208 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const deploymentYAML = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: prod
+spec:
+  replicas: -1
+  template:
+    spec:
+      containers:
+        - name: app
+          image: nginx
+`
+
+func testResults() *fnresult.ResultList {
+	results := fnresult.NewResultList()
+	results.ExitCode = 1
+	results.Items = []fnresult.Result{
+		{
+			Image:    "gcr.io/kpt-fn/kubeval:v0.3",
+			ExitCode: 1,
+			Results: framework.Results{
+				{
+					Message:  "spec.replicas must be positive",
+					Severity: framework.Error,
+					ResourceRef: &yaml.ResourceIdentifier{
+						TypeMeta: yaml.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+						NameMeta: yaml.NameMeta{Name: "app", Namespace: "prod"},
+					},
+					Field: &framework.Field{Path: "spec.replicas"},
+					// paths are relative to the package of the function
+					File: &framework.File{Path: "deployment.yaml", Index: 1},
+				},
+				{
+					Message:  "image is not pinned",
+					Severity: framework.Warning,
+					File:     &framework.File{Path: "sub/deployment.yaml", Index: 1},
+					Field:    &framework.Field{Path: "spec.template.spec.containers[name=app].image"},
+				},
+			},
+		},
+		{
+			Image:    "gcr.io/kpt-fn/set-labels:v0.1",
+			ExitCode: 0,
+			Results:  framework.Results{{Message: "labels set", Severity: framework.Info}},
+		},
+		{
+			ExecPath: "./fn",
+			Skipped:  true,
+		},
+		{
+			Image:    "gcr.io/kpt-fn/failing:v0.1",
+			ExitCode: 1,
+			Stderr:   "boom\n",
+		},
+	}
+	return results
+}
+
+func TestSaveResults(t *testing.T) {
+	fsys := filesys.MakeFsInMemory()
+	assert.NoError(t, fsys.MkdirAll("/pkg/sub"))
+	assert.NoError(t, fsys.MkdirAll("/results"))
+	assert.NoError(t, fsys.WriteFile("/pkg/sub/deployment.yaml", []byte(deploymentYAML)))
+
+	files, err := SaveResults(fsys, "/results", testResults(), []string{ResultsFormatYAML, ResultsFormatSARIF, ResultsFormatJUnit}, "/pkg")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join("/results", "results.yaml"),
+		filepath.Join("/results", "results.sarif"),
+		filepath.Join("/results", "results.junit.xml"),
+	}, files)
+
+	b, err := fsys.ReadFile("/results/results.sarif")
+	assert.NoError(t, err)
+	var log sarifLog
+	assert.NoError(t, json.Unmarshal(b, &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	if assert.Len(t, log.Runs, 4) {
+		assert.Equal(t, "gcr.io/kpt-fn/kubeval:v0.3", log.Runs[0].Tool.Driver.Name)
+		assert.Equal(t, []sarifResult{
+			{
+				Level:   "error",
+				Message: sarifMessage{Text: "spec.replicas must be positive"},
+				Locations: []sarifLocation{{
+					PhysicalLocation: &sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{URI: "sub/deployment.yaml"},
+						Region:           &sarifRegion{StartLine: 12, StartColumn: 13},
+					},
+					LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: "apps/v1/Deployment/prod/app.spec.replicas", Kind: "resource"}},
+				}},
+			},
+			{
+				Level:   "warning",
+				Message: sarifMessage{Text: "image is not pinned"},
+				Locations: []sarifLocation{{
+					PhysicalLocation: &sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{URI: "sub/deployment.yaml"},
+						Region:           &sarifRegion{StartLine: 17, StartColumn: 18},
+					},
+				}},
+			},
+		}, log.Runs[0].Results)
+		assert.Equal(t, []sarifResult{{Level: "note", Message: sarifMessage{Text: "labels set"}}}, log.Runs[1].Results)
+		assert.Empty(t, log.Runs[2].Results)
+		assert.Equal(t, []sarifResult{{Level: "error", Message: sarifMessage{Text: "function failed with exit code 1: boom"}}}, log.Runs[3].Results)
+	}
+
+	b, err = fsys.ReadFile("/results/results.junit.xml")
+	assert.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="kpt" tests="4" failures="2" skipped="1">
+  <testsuite name="kpt functions" tests="4" failures="2" skipped="1">
+    <testcase name="gcr.io/kpt-fn/kubeval:v0.3" classname="kpt.functions">
+      <failure message="spec.replicas must be positive" type="error">[error] spec.replicas must be positive (apps/v1/Deployment/prod/app spec.replicas) at sub/deployment.yaml:12&#xA;[warning] image is not pinned at sub/deployment.yaml:17</failure>
+    </testcase>
+    <testcase name="gcr.io/kpt-fn/set-labels:v0.1" classname="kpt.functions">
+      <system-out>[info] labels set</system-out>
+    </testcase>
+    <testcase name="./fn" classname="kpt.functions">
+      <skipped></skipped>
+    </testcase>
+    <testcase name="gcr.io/kpt-fn/failing:v0.1" classname="kpt.functions">
+      <failure message="function failed with exit code 1" type="error"></failure>
+      <system-err>boom&#xA;</system-err>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, string(b))
+
+	b, err = fsys.ReadFile("/results/results.yaml")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(b), "apiVersion: kpt.dev/v1\nkind: FunctionResultList\n"))
+}
+
+func TestSaveResults_packages(t *testing.T) {
+	fsys := filesys.MakeFsInMemory()
+	assert.NoError(t, fsys.MkdirAll("/pkgs/a"))
+	assert.NoError(t, fsys.MkdirAll("/results"))
+	assert.NoError(t, fsys.WriteFile("/pkgs/a/deployment.yaml", []byte(deploymentYAML)))
+
+	results := fnresult.NewResultList()
+	results.Items = []fnresult.Result{
+		{
+			Image: "gcr.io/kpt-fn/kubeval:v0.3",
+			Pkg:   "/pkgs/a",
+			Results: framework.Results{{
+				Message:  "image is not pinned",
+				Severity: framework.Warning,
+				File:     &framework.File{Path: "deployment.yaml", Index: 1},
+				Field:    &framework.Field{Path: "spec.template.spec.containers[name=app].image"},
+			}},
+		},
+	}
+	_, err := SaveResults(fsys, "/results", results, []string{ResultsFormatJUnit}, "")
+	assert.NoError(t, err)
+
+	b, err := fsys.ReadFile("/results/results.junit.xml")
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `<testcase name="gcr.io/kpt-fn/kubeval:v0.3 (package /pkgs/a)" classname="kpt.functions">`)
+	assert.Contains(t, string(b), "[warning] image is not pinned at /pkgs/a/deployment.yaml:17")
+}
+
+func TestSaveResults_invalidFormat(t *testing.T) {
+	_, err := SaveResults(filesys.MakeFsInMemory(), "/results", testResults(), []string{"html"}, "/pkg")
+	assert.EqualError(t, err, `unsupported results format "html", must be one of yaml, sarif, junit`)
+}
+
+func TestFieldPath(t *testing.T) {
+	assert.Equal(t, []string{"spec", "replicas"}, fieldPath("spec.replicas"))
+	assert.Equal(t, []string{"spec", "containers", "0", "image"}, fieldPath("spec.containers[0].image"))
+	assert.Equal(t, []string{"spec", "containers", "[name=app]", "image"}, fieldPath("spec.containers[name=app].image"))
+	assert.Equal(t, []string{"metadata", "annotations", "config.kubernetes.io/path"}, fieldPath("metadata.annotations.[config.kubernetes.io/path]"))
+}
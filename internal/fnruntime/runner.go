@@ -29,11 +29,14 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/errors"
 	"github.com/GoogleContainerTools/kpt/internal/pkg"
 	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/celutil"
 	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/fn"
 	"github.com/GoogleContainerTools/kpt/pkg/printer"
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/shlex"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/kustomize/kyaml/fn/framework"
 	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
@@ -61,19 +64,69 @@ type RunnerOptions struct {
 	// privileged operation, so explicit permission is required.
 	AllowExec bool
 
+	// SandboxExec runs function binary executables in a sandbox without
+	// access to the host filesystem, the environment of kpt or, unless
+	// AllowNetwork is true, the network.
+	SandboxExec bool
+
 	// AllowNetwork specifies if container based functions are allowed
 	// to access network during pipeline execution. Accessing network is
 	// considered a privileged operation (and makes render operation non-hermetic),
 	// so explicit permission is desired.
 	AllowNetwork bool
 
+	// AllowMount specifies if container based functions are allowed to
+	// mount the files and directories of their package declared in their
+	// `mounts`. Mounts give functions access to files which are not part of
+	// their input, so explicit permission is desired.
+	AllowMount bool
+
 	// allowWasm determines if function wasm are allowed to be run during pipeline
 	// execution. Running wasm function is an alpha feature, so it needs to be
 	// enabled explicitly.
 	AllowWasm bool
 
+	// BuiltinCatalog controls whether the images of catalog functions are
+	// run in-process by the builtin function implementing them. The images
+	// are run by default.
+	BuiltinCatalog BuiltinCatalog
+
 	// ResolveToImage will resolve a partial image to a fully-qualified one
 	ResolveToImage ImageResolveFunc
+
+	// ResultCache, if set, is used to replay the output of container and exec
+	// functions whose input, config and image (or executable) are unchanged
+	// since a previous run instead of running them again.
+	ResultCache *ResultCache
+
+	// Parallelism is the maximum number of package pipelines that can be run
+	// concurrently during render. Sibling subpackages are hydrated concurrently
+	// when it is greater than 1.
+	Parallelism int
+
+	// FunctionLock holds the digests the function images are pinned to. The
+	// image of a function is replaced with its pinned digest before running.
+	FunctionLock []kptfilev1.FunctionLock
+
+	// Locked requires the image of every function to be pinned to a digest,
+	// either in FunctionLock or in the image itself.
+	Locked bool
+
+	// Policies are the function policies restricting the functions that
+	// can be run. A function must be allowed by all the policies.
+	Policies []*FunctionPolicy
+
+	// Registries configures the mirrors and the credentials function images
+	// are pulled with. Defaults to DefaultRegistryConfig().
+	Registries *RegistryConfig
+}
+
+// RegistryConfig returns the function registry config of the options.
+func (o *RunnerOptions) RegistryConfig() (*RegistryConfig, error) {
+	if o.Registries != nil {
+		return o.Registries, nil
+	}
+	return DefaultRegistryConfig()
 }
 
 // ImageResolveFunc is the type for a function that can resolve a partial image to a (more) fully-qualified name
@@ -81,6 +134,7 @@ type ImageResolveFunc func(ctx context.Context, image string) (string, error)
 
 func (o *RunnerOptions) InitDefaults() {
 	o.ImagePullPolicy = IfNotPresentPull
+	o.BuiltinCatalog = BuiltinCatalogOff
 	o.ResolveToImage = ResolveToImageForCLI
 }
 
@@ -99,17 +153,77 @@ func NewRunner(
 	if err != nil {
 		return nil, err
 	}
+	registries, err := opts.RegistryConfig()
+	if err != nil {
+		return nil, err
+	}
+	var verification *fnresult.SignatureVerification
 	if f.Image != "" {
 		img, err := opts.ResolveToImage(ctx, f.Image)
 		if err != nil {
 			return nil, err
 		}
-		f.Image = img
+		pinned, err := pinImage(f.Image, img, opts)
+		if err != nil {
+			return nil, err
+		}
+		if err := opts.CheckImagePolicies(img, pinned); err != nil {
+			return nil, err
+		}
+		pinned, verification, err = opts.VerifyImageSignatures(ctx, pinned)
+		if err != nil {
+			return nil, err
+		}
+		f.Image = pinned
+	} else if f.Exec != "" {
+		if err := opts.CheckExecPolicies(f.Exec); err != nil {
+			return nil, err
+		}
+	}
+
+	timeout, memory, cpus, err := functionLimits(f)
+	if err != nil {
+		return nil, err
+	}
+	mounts, err := functionMounts(f, pkgPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	if _, remote := runtime.(*RemoteRuntime); remote && (len(mounts) > 0 || opts.AllowNetwork || memory > 0 || cpus > 0) {
+		// the function evaluator service can't mount the package files,
+		// give functions network access or enforce their resource limits
+		// other than the timeout.
+		runtime = nil
+	}
+	// functions built into kpt run in-process. Catalog functions are only
+	// run by their builtin if enabled, and unless they need mounts only
+	// containers can provide.
+	var catalogBuiltin string
+	if opts.BuiltinCatalog == BuiltinCatalogAuto && len(mounts) == 0 {
+		catalogBuiltin, _ = builtins.CatalogBuiltin(f.Image)
+	}
+	builtinRun, isBuiltin := builtins.Lookup(f.Image)
+	if catalogBuiltin != "" {
+		builtinRun, isBuiltin = builtins.Lookup(catalogBuiltin)
+	}
+	if isBuiltin && len(mounts) > 0 {
+		return nil, fmt.Errorf("mounts are not supported for builtin function %q", f.Image)
+	}
+	// the image is pulled from its mirror, which the policies were checked
+	// against.
+	var originalImage string
+	if f.Image != "" && !isBuiltin {
+		if mirror := registries.Mirror(f.Image); mirror != f.Image {
+			originalImage, f.Image = f.Image, mirror
+		}
 	}
 
 	fnResult := &fnresult.Result{
-		Image:    f.Image,
-		ExecPath: f.Exec,
+		Image:         f.Image,
+		OriginalImage: originalImage,
+		Builtin:       catalogBuiltin,
+		ExecPath:      f.Exec,
+		Signature:     verification,
 		// TODO(droot): This is required for making structured results subpackage aware.
 		// Enable this once test harness supports filepath based assertions.
 		// Pkg: string(pkgPath),
@@ -124,77 +238,174 @@ func NewRunner(
 		GlobalScope: true,
 	}
 
-	if runtime != nil {
-		if runner, err := runtime.GetRunner(ctx, f); err != nil {
-			return nil, fmt.Errorf("function runtime failed to evaluate function %q: %w", f.Image, err)
-		} else if runner != nil {
-			fltr.Run = runner.Run
-		}
-	}
-	if fltr.Run == nil {
-		if f.Image == FuncGenPkgContext {
-			pkgCtxGenerator := &builtins.PackageContextGenerator{}
-			fltr.Run = pkgCtxGenerator.Run
-		} else {
-			switch {
-			case f.Image != "":
-				// If allowWasm is true, we will use wasm runtime for image field.
-				if opts.AllowWasm {
-					wFn, err := NewWasmFn(NewOciLoader(filepath.Join(os.TempDir(), "kpt-fn-wasm"), f.Image))
-					if err != nil {
-						return nil, err
-					}
-					fltr.Run = wFn.Run
-				} else {
-					cfn := &ContainerFn{
-						Image:           f.Image,
-						ImagePullPolicy: opts.ImagePullPolicy,
-						Perm: ContainerFnPermission{
-							AllowNetwork: opts.AllowNetwork,
-							// mounts are disabled for render operations (currently)
-							// but it may change in the future.
-							// AllowMount: true,
-						},
-						Ctx:      ctx,
-						FnResult: fnResult,
-					}
-					fltr.Run = cfn.Run
+	// builtin returns the run function of the runtimes built into kpt. It is
+	// only called if the function runtime can't run the function, so
+	// the wasm module or the executable are loaded only when needed.
+	builtin := func() (func(io.Reader, io.Writer) error, error) {
+		switch {
+		case builtins.IsBuiltin(f.Image):
+			return nil, fmt.Errorf("unknown builtin function %q", f.Image)
+		case f.Image != "":
+			// If allowWasm is true, we will use wasm runtime for image field.
+			if opts.AllowWasm {
+				if len(mounts) > 0 {
+					return nil, fmt.Errorf("mounts are not supported for wasm functions")
+				}
+				wFn, err := NewWasmFn(NewOciLoader(filepath.Join(os.TempDir(), "kpt-fn-wasm"), f.Image, registries.Keychain()))
+				if err != nil {
+					return nil, err
 				}
-			case f.Exec != "":
-				// If AllowWasm is true, we will use wasm runtime for exec field.
-				if opts.AllowWasm {
-					wFn, err := NewWasmFn(&FsLoader{Filename: f.Exec})
-					if err != nil {
-						return nil, err
-					}
-					fltr.Run = wFn.Run
-				} else {
-					var execArgs []string
-					// assuming exec here
-					s, err := shlex.Split(f.Exec)
-					if err != nil {
-						return nil, fmt.Errorf("exec command %q must be valid: %w", f.Exec, err)
-					}
-					execPath := f.Exec
-					if len(s) > 0 {
-						execPath = s[0]
-					}
-					if len(s) > 1 {
-						execArgs = s[1:]
-					}
-					eFn := &ExecFn{
-						Path:     execPath,
-						Args:     execArgs,
-						FnResult: fnResult,
-					}
-					fltr.Run = eFn.Run
+				if err := wFn.SetLimits(timeout, memory); err != nil {
+					return nil, err
 				}
-			default:
-				return nil, fmt.Errorf("must specify `exec` or `image` to execute a function")
+				wFn.SetFnResult(fnResult)
+				return wFn.Run, nil
+			}
+			cfn := &ContainerFn{
+				Image:           f.Image,
+				ImagePullPolicy: opts.ImagePullPolicy,
+				Perm: ContainerFnPermission{
+					AllowNetwork: opts.AllowNetwork,
+					AllowMount:   opts.AllowMount,
+				},
+				Timeout:       timeout,
+				Memory:        memory,
+				CPUs:          cpus,
+				StorageMounts: mounts,
+				Ctx:           ctx,
+				FnResult:      fnResult,
+				Registries:    registries,
+			}
+			// functions accessing the network or reading mounted files are
+			// not hermetic, and images always pulled by tag may have changed
+			// since the last run.
+			if opts.ResultCache != nil && !opts.AllowNetwork && len(mounts) == 0 &&
+				(opts.ImagePullPolicy != AlwaysPull || strings.Contains(f.Image, "@sha256:")) {
+				return (&cachedFn{
+					cache:    opts.ResultCache,
+					identity: cfn.cacheIdentity,
+					run:      cfn.Run,
+					fnResult: fnResult,
+				}).Run, nil
+			}
+			return cfn.Run, nil
+		case f.Exec != "":
+			// If AllowWasm is true, we will use wasm runtime for exec field.
+			if opts.AllowWasm {
+				wFn, err := NewWasmFn(&FsLoader{Filename: f.Exec})
+				if err != nil {
+					return nil, err
+				}
+				if err := wFn.SetLimits(timeout, memory); err != nil {
+					return nil, err
+				}
+				wFn.SetFnResult(fnResult)
+				return wFn.Run, nil
+			}
+			var execArgs []string
+			// assuming exec here
+			s, err := shlex.Split(f.Exec)
+			if err != nil {
+				return nil, fmt.Errorf("exec command %q must be valid: %w", f.Exec, err)
+			}
+			execPath := f.Exec
+			if len(s) > 0 {
+				execPath = s[0]
+			}
+			if len(s) > 1 {
+				execArgs = s[1:]
 			}
+			eFn := &ExecFn{
+				Path:         execPath,
+				Args:         execArgs,
+				Timeout:      timeout,
+				FnResult:     fnResult,
+				Sandbox:      opts.SandboxExec,
+				AllowNetwork: opts.AllowNetwork,
+			}
+			// like for containers, functions accessing the network are not
+			// hermetic.
+			if opts.ResultCache != nil && !opts.AllowNetwork {
+				return (&cachedFn{
+					cache:    opts.ResultCache,
+					identity: eFn.cacheIdentity,
+					run:      eFn.Run,
+					fnResult: fnResult,
+				}).Run, nil
+			}
+			return eFn.Run, nil
+		default:
+			return nil, fmt.Errorf("must specify `exec` or `image` to execute a function")
+		}
+	}
+	if isBuiltin {
+		fltr.Run = builtinRun
+	} else {
+		fltr.Run, err = RunWithRuntime(ctx, runtime, f, fnResult, builtin)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fr, err := NewFunctionRunner(ctx, fltr, pkgPath, fnResult, fnResults, opts)
+	if err != nil {
+		return nil, err
+	}
+	if f.When != "" {
+		fr.when, err = celutil.NewCondition(f.When)
+		if err != nil {
+			return nil, fmt.Errorf("invalid `when` expression %q: %w", f.When, err)
 		}
 	}
-	return NewFunctionRunner(ctx, fltr, pkgPath, fnResult, fnResults, opts)
+	return fr, nil
+}
+
+// pinImage returns the resolved image of a function with the digest it is
+// pinned to in the function lock. declared is the image as declared in the
+// pipeline, which is the key of the lock.
+func pinImage(declared, resolved string, opts RunnerOptions) (string, error) {
+	if builtins.IsBuiltin(resolved) || strings.Contains(resolved, "@") {
+		return resolved, nil
+	}
+	for _, l := range opts.FunctionLock {
+		if l.Image != declared {
+			continue
+		}
+		ref, err := name.ParseReference(resolved)
+		if err != nil {
+			return "", fmt.Errorf("cannot parse image name %q: %w", resolved, err)
+		}
+		return ref.Context().Digest(l.Digest).String(), nil
+	}
+	if opts.Locked {
+		return "", fmt.Errorf("function image %q is not pinned to a digest, run `kpt fn lock` to pin it", declared)
+	}
+	return resolved, nil
+}
+
+// functionLimits parses the resource limits declared on the function.
+// Zero values mean that the runtime defaults apply.
+func functionLimits(f *kptfilev1.Function) (timeout time.Duration, memory int64, cpus float64, err error) {
+	if f.Timeout != "" {
+		timeout, err = time.ParseDuration(f.Timeout)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid timeout %q: %w", f.Timeout, err)
+		}
+	}
+	if f.Memory != "" {
+		q, err := resource.ParseQuantity(f.Memory)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid memory %q: %w", f.Memory, err)
+		}
+		memory = q.Value()
+	}
+	if f.CPU != "" {
+		q, err := resource.ParseQuantity(f.CPU)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid cpu %q: %w", f.CPU, err)
+		}
+		cpus = q.AsApproximateFloat64()
+	}
+	return timeout, memory, cpus, nil
 }
 
 // NewFunctionRunner returns a FunctionRunner given a specification of a function
@@ -235,6 +446,8 @@ type FunctionRunner struct {
 	fnResult         *fnresult.Result
 	fnResults        *fnresult.ResultList
 	opts             RunnerOptions
+	// when is the condition for running the function, if any.
+	when *celutil.Condition
 }
 
 func (fr *FunctionRunner) Filter(input []*yaml.RNode) (output []*yaml.RNode, err error) {
@@ -271,6 +484,40 @@ func (fr *FunctionRunner) Filter(input []*yaml.RNode) (output []*yaml.RNode, err
 	return output, err
 }
 
+// Name returns the image or the executable of the function.
+func (fr *FunctionRunner) Name() string {
+	return fr.name
+}
+
+// Result returns the result of the last run of the function.
+func (fr *FunctionRunner) Result() *fnresult.Result {
+	return fr.fnResult
+}
+
+// ShouldRun evaluates the `when` condition of the function against the given
+// resources. It returns true if the function has no condition.
+func (fr *FunctionRunner) ShouldRun(resources []*yaml.RNode) (bool, error) {
+	if fr.when == nil {
+		return true, nil
+	}
+	ok, err := fr.when.Evaluate(resources)
+	if err != nil {
+		return false, fmt.Errorf("function %q: %w", fr.name, err)
+	}
+	return ok, nil
+}
+
+// Skip records that the function has been skipped because its `when`
+// condition evaluated to false.
+func (fr *FunctionRunner) Skip() {
+	if !fr.disableCLIOutput {
+		pr := printer.FromContextOrDie(fr.ctx)
+		pr.Printf("[SKIPPED] %q\n", fr.name)
+	}
+	fr.fnResult.Skipped = true
+	fr.fnResults.Items = append(fr.fnResults.Items, *fr.fnResult)
+}
+
 // SetFnConfig updates the functionConfig for the FunctionRunner instance.
 func (fr *FunctionRunner) SetFnConfig(conf *yaml.RNode) {
 	fr.filter.FunctionConfig = conf
@@ -317,6 +564,13 @@ func (fr *FunctionRunner) do(input []*yaml.RNode) (output []*yaml.RNode, err err
 			fnResult.ExitCode = execErr.ExitCode
 			fnResult.Stderr = execErr.Stderr
 		}
+		var limitErr *LimitError
+		if goerrors.As(err, &limitErr) {
+			fnResult.Results = append(fnResult.Results, &framework.Result{
+				Message:  limitErr.Error(),
+				Severity: framework.Error,
+			})
+		}
 		// accumulate the results
 		fr.fnResults.Items = append(fr.fnResults.Items, *fnResult)
 		return output, err
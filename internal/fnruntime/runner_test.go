@@ -19,17 +19,25 @@ package fnruntime
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"os"
+	"os/exec"
 	"path"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/GoogleContainerTools/kpt/internal/types"
+	fnregistry "github.com/GoogleContainerTools/kpt/pkg/api/fnregistry/v1alpha1"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/printer"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
 	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
@@ -646,3 +654,301 @@ func TestPrintFnStderr(t *testing.T) {
 		})
 	}
 }
+
+func TestFunctionLimits(t *testing.T) {
+	timeout, memory, cpus, err := functionLimits(&kptfilev1.Function{
+		Timeout: "90s",
+		Memory:  "64Mi",
+		CPU:     "500m",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Second, timeout)
+	assert.Equal(t, int64(64*1024*1024), memory)
+	assert.Equal(t, 0.5, cpus)
+
+	_, _, _, err = functionLimits(&kptfilev1.Function{Timeout: "forever"})
+	assert.Error(t, err)
+}
+
+func TestWasmFn_SetLimits(t *testing.T) {
+	nodejs := &WasmFn{runtimeType: Nodejs, nodejs: &WasmNodejsFn{NodeJsRunner: &ExecFn{}}}
+	assert.NoError(t, nodejs.SetLimits(time.Minute, 0))
+	assert.Equal(t, time.Minute, nodejs.nodejs.NodeJsRunner.Timeout)
+	assert.EqualError(t, nodejs.SetLimits(time.Minute, 1<<20),
+		"memory limits are not supported by the nodejs wasm runtime")
+
+	wasmtime := &WasmFn{runtimeType: Wasmtime, wasi: &WasiFn{}}
+	assert.NoError(t, wasmtime.SetLimits(time.Minute, 0))
+	assert.Equal(t, time.Minute, wasmtime.wasi.Timeout)
+	assert.EqualError(t, wasmtime.SetLimits(time.Minute, 1<<20),
+		"memory limits are not supported by the wasmtime wasm runtime")
+}
+
+func TestExecFnTimeout(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep is not available")
+	}
+	fn := &ExecFn{
+		Path:     "sleep",
+		Args:     []string{"10"},
+		Timeout:  100 * time.Millisecond,
+		FnResult: &fnresult.Result{},
+	}
+	err := fn.Run(strings.NewReader(""), &bytes.Buffer{})
+	var limitErr *LimitError
+	if assert.ErrorAs(t, err, &limitErr) {
+		assert.Equal(t, "timeout", limitErr.Limit)
+		assert.Equal(t, "100ms", limitErr.Value)
+	}
+}
+
+// TestNewRunner_execCache verifies that the output of exec functions is
+// replayed from the cache, unless they are allowed to access the network.
+func TestNewRunner_execCache(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	counter := filepath.Join(t.TempDir(), "runs")
+	cache, err := NewResultCache(t.TempDir())
+	require.NoError(t, err)
+	input, err := kio.FromBytes([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n"))
+	require.NoError(t, err)
+
+	runs := func(allowNetwork bool) int {
+		ctx := printer.WithContext(context.Background(), printer.New(nil, &bytes.Buffer{}))
+		for i := 0; i < 2; i++ {
+			fr, err := NewRunner(ctx, filesys.MakeFsInMemory(), &kptfilev1.Function{
+				Exec: fmt.Sprintf("sh -c 'echo run >> %s && cat'", counter),
+			}, "/", fnresult.NewResultList(), RunnerOptions{
+				ResultCache:  cache,
+				AllowNetwork: allowNetwork,
+			}, nil)
+			require.NoError(t, err)
+			_, err = fr.Filter(input)
+			require.NoError(t, err)
+		}
+		b, err := os.ReadFile(counter)
+		require.NoError(t, err)
+		require.NoError(t, os.Remove(counter))
+		return strings.Count(string(b), "run")
+	}
+	assert.Equal(t, 1, runs(false))
+	assert.Equal(t, 2, runs(true))
+}
+
+// TestNewRunner_builtinCatalog verifies that catalog images are only run by
+// their builtin when enabled, and recorded in the result.
+func TestNewRunner_builtinCatalog(t *testing.T) {
+	pkgPath, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(pkgPath, "data"), 0755))
+	mounts := []kptfilev1.Mount{{Src: "data", Dst: "/data"}}
+
+	testCases := map[string]struct {
+		function kptfilev1.Function
+		catalog  BuiltinCatalog
+		builtin  string
+		errMsg   string
+	}{
+		"off": {
+			function: kptfilev1.Function{Image: "gcr.io/kpt-fn/set-namespace:v0.4.1"},
+			catalog:  BuiltinCatalogOff,
+		},
+		"auto": {
+			function: kptfilev1.Function{Image: "gcr.io/kpt-fn/set-namespace:v0.4.1"},
+			catalog:  BuiltinCatalogAuto,
+			builtin:  "builtins/set-namespace",
+		},
+		"auto with another minor version": {
+			function: kptfilev1.Function{Image: "gcr.io/kpt-fn/set-namespace:v0.3.4"},
+			catalog:  BuiltinCatalogAuto,
+		},
+		"auto with mounts": {
+			function: kptfilev1.Function{Image: "gcr.io/kpt-fn/set-namespace:v0.4.1", Mounts: mounts},
+			catalog:  BuiltinCatalogAuto,
+		},
+		"builtin image with mounts": {
+			function: kptfilev1.Function{Image: "builtins/set-namespace", Mounts: mounts},
+			errMsg:   `mounts are not supported for builtin function "builtins/set-namespace"`,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			ctx := printer.WithContext(context.Background(), printer.New(nil, &bytes.Buffer{}))
+			fr, err := NewRunner(ctx, filesys.MakeFsInMemory(), &tc.function, types.UniquePath(pkgPath), fnresult.NewResultList(), RunnerOptions{
+				ResolveToImage: func(_ context.Context, image string) (string, error) { return image, nil },
+				BuiltinCatalog: tc.catalog,
+				AllowMount:     true,
+			}, nil)
+			if tc.errMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.function.Image, fr.fnResult.Image)
+			assert.Equal(t, tc.builtin, fr.fnResult.Builtin)
+		})
+	}
+}
+
+// TestNewRunner_mirrorPolicy verifies that the function policies apply to
+// the image pulled from the mirror rather than to the declared image.
+func TestNewRunner_mirrorPolicy(t *testing.T) {
+	p, err := LoadFunctionPolicy(writePolicy(t, t.TempDir(), `apiVersion: kpt.dev/v1alpha1
+kind: FunctionPolicy
+allowedImages:
+  - mirror.example.com/kpt-fn/**
+`))
+	require.NoError(t, err)
+	registries := &RegistryConfig{}
+	registries.Mirrors = []fnregistry.Mirror{{Source: "gcr.io/kpt-fn/*", Mirror: "mirror.example.com/kpt-fn/*"}}
+
+	testCases := map[string]struct {
+		image    string
+		expected string
+		errMsg   string
+	}{
+		"mirrored": {
+			image:    "gcr.io/kpt-fn/set-namespace:v0.4.1",
+			expected: "mirror.example.com/kpt-fn/set-namespace:v0.4.1",
+		},
+		"not mirrored": {
+			image:  "docker.io/example/set-namespace:v0.4.1",
+			errMsg: `function "docker.io/example/set-namespace:v0.4.1" is not allowed by the function policy`,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			ctx := printer.WithContext(context.Background(), printer.New(nil, &bytes.Buffer{}))
+			fr, err := NewRunner(ctx, filesys.MakeFsInMemory(), &kptfilev1.Function{Image: tc.image}, "/", fnresult.NewResultList(), RunnerOptions{
+				ResolveToImage: func(_ context.Context, image string) (string, error) { return image, nil },
+				Policies:       []*FunctionPolicy{p},
+				Registries:     registries,
+			}, nil)
+			if tc.errMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, fr.fnResult.Image)
+			assert.Equal(t, tc.image, fr.fnResult.OriginalImage)
+		})
+	}
+}
+
+func TestPinImage(t *testing.T) {
+	const digest = "sha256:8ae0ee1a1b2bb2b88f0a1e4bbf6bb0b1f0bc9e1a4b0e5e2df1a6d0f5c1d3e2f4"
+	lock := []kptfilev1.FunctionLock{{Image: "set-labels:v0.1", Digest: digest}}
+	testCases := map[string]struct {
+		declared    string
+		resolved    string
+		locked      bool
+		expected    string
+		expectedErr string
+	}{
+		"pinned": {
+			declared: "set-labels:v0.1",
+			resolved: "gcr.io/kpt-fn/set-labels:v0.1",
+			expected: "gcr.io/kpt-fn/set-labels@" + digest,
+		},
+		"not pinned": {
+			declared: "set-namespace:v0.1",
+			resolved: "gcr.io/kpt-fn/set-namespace:v0.1",
+			expected: "gcr.io/kpt-fn/set-namespace:v0.1",
+		},
+		"not pinned in locked mode": {
+			declared:    "set-namespace:v0.1",
+			resolved:    "gcr.io/kpt-fn/set-namespace:v0.1",
+			locked:      true,
+			expectedErr: "function image \"set-namespace:v0.1\" is not pinned to a digest, run `kpt fn lock` to pin it",
+		},
+		"digest in image": {
+			declared: "gcr.io/kpt-fn/set-namespace@" + digest,
+			resolved: "gcr.io/kpt-fn/set-namespace@" + digest,
+			locked:   true,
+			expected: "gcr.io/kpt-fn/set-namespace@" + digest,
+		},
+		"builtin": {
+			declared: FuncGenPkgContext,
+			resolved: FuncGenPkgContext,
+			locked:   true,
+			expected: FuncGenPkgContext,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			actual, err := pinImage(tc.declared, tc.resolved, RunnerOptions{FunctionLock: lock, Locked: tc.locked})
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestFunctionMounts(t *testing.T) {
+	outside := t.TempDir()
+	pkgPath, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(pkgPath, "data"), 0755))
+	require.NoError(t, os.Symlink(outside, filepath.Join(pkgPath, "escape")))
+	require.NoError(t, os.Symlink("data", filepath.Join(pkgPath, "alias")))
+
+	allowed := RunnerOptions{AllowMount: true}
+	testCases := map[string]struct {
+		mounts   []kptfilev1.Mount
+		opts     RunnerOptions
+		expected []runtimeutil.StorageMount
+		errMsg   string
+	}{
+		"no mounts": {},
+		"not allowed": {
+			mounts: []kptfilev1.Mount{{Src: "data", Dst: "/data"}},
+			errMsg: "must run with `--allow-mount` option",
+		},
+		"directory": {
+			mounts:   []kptfilev1.Mount{{Src: "data", Dst: "/data"}},
+			opts:     allowed,
+			expected: []runtimeutil.StorageMount{{MountType: "bind", Src: filepath.Join(pkgPath, "data"), DstPath: "/data"}},
+		},
+		"symlink inside the package": {
+			mounts:   []kptfilev1.Mount{{Src: "alias", Dst: "/data"}},
+			opts:     allowed,
+			expected: []runtimeutil.StorageMount{{MountType: "bind", Src: filepath.Join(pkgPath, "data"), DstPath: "/data"}},
+		},
+		"symlink outside the package": {
+			mounts: []kptfilev1.Mount{{Src: "escape", Dst: "/data"}},
+			opts:   allowed,
+			errMsg: `mount "escape" must not be outside the package`,
+		},
+		"missing": {
+			mounts: []kptfilev1.Mount{{Src: "missing", Dst: "/data"}},
+			opts:   allowed,
+			errMsg: `failed to resolve mount "missing"`,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			mounts, err := functionMounts(&kptfilev1.Function{Image: "fn", Mounts: tc.mounts}, types.UniquePath(pkgPath), tc.opts)
+			if tc.errMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, mounts)
+		})
+	}
+
+	// mounts are read-only
+	cfn := &ContainerFn{Image: "fn", StorageMounts: []runtimeutil.StorageMount{{MountType: "bind", Src: "/pkg/data", DstPath: "/data"}}}
+	assert.Contains(t, cfn.getCmd(context.Background(), "docker").Args, "type=bind,source=/pkg/data,target=/data,readonly")
+}
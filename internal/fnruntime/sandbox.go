@@ -0,0 +1,75 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// sandboxInitName is the name kpt is re-executed with to set up the
+	// sandbox of an exec function before running it.
+	sandboxInitName = "kpt-sandbox-init"
+
+	// sandboxInitExitCode is the exit code of the sandbox when it can't be
+	// set up.
+	sandboxInitExitCode = 125
+
+	// sandboxFnDir is the directory of the sandbox the executable of the
+	// function is mounted in.
+	sandboxFnDir = "/fn"
+
+	// sandboxTmpDir is the writable directory of the sandbox, which is
+	// also the working directory and the home of the function.
+	sandboxTmpDir = "/tmp"
+)
+
+// execSandbox is the configuration of the sandbox of an exec function. It is
+// passed to the sandbox init process as its argument.
+type execSandbox struct {
+	// Path is the absolute path of the executable on the host.
+	Path string
+	// Args are the arguments of the executable.
+	Args []string
+	// Env is the environment of the executable.
+	Env []string
+	// TempDir is the host directory mounted at /tmp in the sandbox.
+	TempDir string
+	// RootDir is the host directory the root of the sandbox is built in.
+	RootDir string
+	// AllowNetwork gives the executable access to the host network.
+	AllowNetwork bool
+}
+
+// sandboxEnv returns the environment of a sandboxed executable: a minimal
+// base environment and the given variables. The environment of kpt is not
+// passed to the executable.
+func sandboxEnv(env map[string]string) []string {
+	vars := map[string]string{
+		"PATH":   "/usr/local/bin:/usr/bin:/bin",
+		"HOME":   sandboxTmpDir,
+		"TMPDIR": sandboxTmpDir,
+	}
+	for k, v := range env {
+		vars[k] = v
+	}
+	var result []string
+	for k, v := range vars {
+		result = append(result, fmt.Sprintf("%v=%v", k, v))
+	}
+	sort.Strings(result)
+	return result
+}
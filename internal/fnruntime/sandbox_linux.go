@@ -0,0 +1,390 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"context"
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The sandbox of an exec function is set up by kpt itself, re-executed in
+// new user, mount, pid, ipc, uts and (unless the network is allowed) network
+// namespaces. The sandbox init process builds a read-only root containing
+// only the system directories, the executable and a writable /tmp, drops its
+// capabilities, installs a seccomp filter and then executes the function.
+
+func init() {
+	if len(os.Args) != 2 || os.Args[0] != sandboxInitName {
+		return
+	}
+	if err := runSandboxInit(os.Args[1]); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(sandboxInitExitCode)
+	}
+}
+
+var (
+	// sandboxSystemPaths are the host paths mounted read-only in the
+	// sandbox so that executables and their shared libraries can be loaded.
+	sandboxSystemPaths = []string{"/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32", "/usr", "/etc/ld.so.cache"}
+
+	// sandboxNetworkPaths are the host paths additionally mounted in the
+	// sandbox when the network is allowed, for name resolution and TLS.
+	sandboxNetworkPaths = []string{"/etc/resolv.conf", "/etc/hosts", "/etc/nsswitch.conf", "/etc/ssl", "/etc/pki", "/etc/ca-certificates"}
+
+	// sandboxDevices are the devices available in the sandbox.
+	sandboxDevices = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom"}
+)
+
+// sandboxCommand returns the command running the executable in a sandbox,
+// and a function removing the sandbox directories once the command is done.
+func (f *ExecFn) sandboxCommand(ctx context.Context) (*exec.Cmd, func(), error) {
+	path, err := exec.LookPath(f.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	dir, err := os.MkdirTemp("", "kpt-sandbox-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { _ = os.RemoveAll(dir) }
+	s := execSandbox{
+		Path:         path,
+		Args:         f.Args,
+		Env:          sandboxEnv(f.Env),
+		TempDir:      filepath.Join(dir, "tmp"),
+		RootDir:      filepath.Join(dir, "root"),
+		AllowNetwork: f.AllowNetwork,
+	}
+	for _, d := range []string{s.TempDir, s.RootDir} {
+		if err := os.Mkdir(d, 0700); err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+	}
+	config, err := json.Marshal(s)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "/proc/self/exe")
+	cmd.Args = []string{sandboxInitName, string(config)}
+	cmd.Env = []string{}
+	cmd.Dir = s.TempDir
+	flags := syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+	if !f.AllowNetwork {
+		flags |= syscall.CLONE_NEWNET
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: uintptr(flags),
+		// the init process is root in the user namespace so that it can
+		// set up the mounts, its capabilities are dropped before the
+		// function is executed.
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		Pdeathsig:   syscall.SIGKILL,
+	}
+	return cmd, cleanup, nil
+}
+
+// sandboxStartError returns the error of starting the sandbox init process.
+func sandboxStartError(err error) error {
+	if goerrors.Is(err, syscall.EPERM) || goerrors.Is(err, syscall.EINVAL) || goerrors.Is(err, syscall.ENOSPC) {
+		return fmt.Errorf("cannot create the sandbox of the exec function, unprivileged user namespaces may be disabled: %w", err)
+	}
+	return fmt.Errorf("cannot create the sandbox of the exec function: %w", err)
+}
+
+// runSandboxInit sets up the sandbox described by config in the current
+// namespaces and executes the function. It only returns on failure.
+func runSandboxInit(config string) error {
+	var s execSandbox
+	if err := json.Unmarshal([]byte(config), &s); err != nil {
+		return err
+	}
+	// capabilities, no_new_privs and seccomp filters are per thread, and
+	// the thread calling execve must be the one they are set on.
+	runtime.LockOSThread()
+
+	// the mounts of the sandbox must not propagate to the host.
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("cannot make mounts private: %w", err)
+	}
+	if err := unix.Mount("tmpfs", s.RootDir, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("cannot mount sandbox root: %w", err)
+	}
+	paths := sandboxSystemPaths
+	if s.AllowNetwork {
+		paths = append(paths, sandboxNetworkPaths...)
+	}
+	for _, p := range paths {
+		if err := sandboxMount(p, filepath.Join(s.RootDir, p), true); err != nil {
+			return err
+		}
+	}
+	for _, p := range sandboxDevices {
+		if err := sandboxMount(p, filepath.Join(s.RootDir, p), false); err != nil {
+			return err
+		}
+	}
+	fnPath := filepath.Join(sandboxFnDir, filepath.Base(s.Path))
+	if err := sandboxMount(s.Path, filepath.Join(s.RootDir, fnPath), true); err != nil {
+		return err
+	}
+	if err := sandboxMount(s.TempDir, filepath.Join(s.RootDir, sandboxTmpDir), false); err != nil {
+		return err
+	}
+	// a proc filesystem can't be mounted if the host proc is partially
+	// masked, e.g. when kpt itself runs in a container, so it is optional.
+	procDir := filepath.Join(s.RootDir, "proc")
+	if err := os.Mkdir(procDir, 0555); err != nil {
+		return err
+	}
+	_ = unix.Mount("proc", procDir, "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, "")
+
+	if err := sandboxPivotRoot(s.RootDir); err != nil {
+		return err
+	}
+	if err := unix.Sethostname([]byte("kpt-sandbox")); err != nil {
+		return err
+	}
+	if err := os.Chdir(sandboxTmpDir); err != nil {
+		return err
+	}
+	if err := dropCapabilities(); err != nil {
+		return err
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("cannot set no_new_privs: %w", err)
+	}
+	if err := installSeccompFilter(); err != nil {
+		return err
+	}
+	return syscall.Exec(fnPath, append([]string{fnPath}, s.Args...), s.Env)
+}
+
+// sandboxMount bind mounts the host path src at dst, read-only if readOnly is
+// true. Paths which don't exist on the host are skipped, and symbolic links
+// are recreated instead of being mounted.
+func sandboxMount(src, dst string, readOnly bool) error {
+	info, err := os.Lstat(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case info.IsDir():
+		if err := os.Mkdir(dst, 0755); err != nil {
+			return err
+		}
+	default:
+		if err := os.WriteFile(dst, nil, 0644); err != nil {
+			return err
+		}
+	}
+	if err := unix.Mount(src, dst, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("cannot mount %s: %w", src, err)
+	}
+	if !readOnly {
+		return nil
+	}
+	// the flags of the mount locked by the user namespace must be kept
+	// when it is remounted read-only.
+	var st unix.Statfs_t
+	if err := unix.Statfs(dst, &st); err != nil {
+		return err
+	}
+	flags := uintptr(unix.MS_BIND | unix.MS_REMOUNT | unix.MS_RDONLY)
+	for stFlag, msFlag := range map[int64]uintptr{
+		unix.ST_NOSUID:     unix.MS_NOSUID,
+		unix.ST_NODEV:      unix.MS_NODEV,
+		unix.ST_NOEXEC:     unix.MS_NOEXEC,
+		unix.ST_NOATIME:    unix.MS_NOATIME,
+		unix.ST_NODIRATIME: unix.MS_NODIRATIME,
+		unix.ST_RELATIME:   unix.MS_RELATIME,
+	} {
+		if int64(st.Flags)&stFlag != 0 {
+			flags |= msFlag
+		}
+	}
+	if err := unix.Mount("", dst, "", flags, ""); err != nil {
+		return fmt.Errorf("cannot mount %s read-only: %w", src, err)
+	}
+	return nil
+}
+
+// sandboxPivotRoot makes root the root of the mount namespace and detaches
+// the host root.
+func sandboxPivotRoot(root string) error {
+	oldRoot := filepath.Join(root, ".oldroot")
+	if err := os.Mkdir(oldRoot, 0700); err != nil {
+		return err
+	}
+	if err := unix.PivotRoot(root, oldRoot); err != nil {
+		return fmt.Errorf("cannot change sandbox root: %w", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return err
+	}
+	if err := unix.Unmount("/.oldroot", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("cannot unmount host root: %w", err)
+	}
+	if err := os.Remove("/.oldroot"); err != nil {
+		return err
+	}
+	return unix.Mount("", "/", "", unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY|unix.MS_NOSUID|unix.MS_NODEV, "")
+}
+
+// dropCapabilities drops the capabilities of the thread and its bounding set,
+// so the executed function has no capabilities even though it runs as root
+// in the user namespace.
+func dropCapabilities() error {
+	for c := 0; c < 64; c++ {
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0); err != nil {
+			if goerrors.Is(err, unix.EINVAL) {
+				break
+			}
+			return fmt.Errorf("cannot drop capability %d: %w", c, err)
+		}
+	}
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capset(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("cannot drop capabilities: %w", err)
+	}
+	return nil
+}
+
+const (
+	seccompRetKillProcess = 0x80000000
+	seccompRetErrno       = 0x00050000
+	seccompRetAllow       = 0x7fff0000
+
+	// x32SyscallBit is set in the numbers of the x32 syscalls on amd64,
+	// which would otherwise bypass the filter.
+	x32SyscallBit = 0x40000000
+)
+
+// seccompArchs are the audit architectures of the seccomp filter.
+var seccompArchs = map[string]uint32{
+	"amd64": unix.AUDIT_ARCH_X86_64,
+	"arm64": unix.AUDIT_ARCH_AARCH64,
+}
+
+// sandboxDeniedSyscalls are the syscalls which fail with EPERM in the
+// sandbox: changing mounts and namespaces, tracing other processes, loading
+// kernel code and changing the system configuration.
+var sandboxDeniedSyscalls = []uintptr{
+	unix.SYS_ACCT,
+	unix.SYS_ADD_KEY,
+	unix.SYS_BPF,
+	unix.SYS_CHROOT,
+	unix.SYS_CLOCK_SETTIME,
+	unix.SYS_DELETE_MODULE,
+	unix.SYS_FANOTIFY_INIT,
+	unix.SYS_FINIT_MODULE,
+	unix.SYS_FSMOUNT,
+	unix.SYS_FSOPEN,
+	unix.SYS_INIT_MODULE,
+	unix.SYS_KEXEC_LOAD,
+	unix.SYS_KEYCTL,
+	unix.SYS_MOUNT,
+	unix.SYS_MOVE_MOUNT,
+	unix.SYS_NAME_TO_HANDLE_AT,
+	unix.SYS_OPEN_BY_HANDLE_AT,
+	unix.SYS_OPEN_TREE,
+	unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_PIVOT_ROOT,
+	unix.SYS_PROCESS_VM_READV,
+	unix.SYS_PROCESS_VM_WRITEV,
+	unix.SYS_PTRACE,
+	unix.SYS_QUOTACTL,
+	unix.SYS_REBOOT,
+	unix.SYS_REQUEST_KEY,
+	unix.SYS_SETDOMAINNAME,
+	unix.SYS_SETHOSTNAME,
+	unix.SYS_SETNS,
+	unix.SYS_SETTIMEOFDAY,
+	unix.SYS_SWAPOFF,
+	unix.SYS_SWAPON,
+	unix.SYS_SYSLOG,
+	unix.SYS_UMOUNT2,
+	unix.SYS_UNSHARE,
+	unix.SYS_USERFAULTFD,
+}
+
+// installSeccompFilter installs the seccomp filter denying the
+// sandboxDeniedSyscalls. Syscalls of other architectures kill the process.
+func installSeccompFilter() error {
+	arch, found := seccompArchs[runtime.GOARCH]
+	if !found {
+		return fmt.Errorf("seccomp filters are not supported on %s", runtime.GOARCH)
+	}
+	deny := bpfStmt(unix.BPF_RET|unix.BPF_K, seccompRetErrno|uint32(unix.EPERM))
+	filter := []unix.SockFilter{
+		// seccomp_data.arch
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, 4),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, arch, 1, 0),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, seccompRetKillProcess),
+		// seccomp_data.nr
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, 0),
+		bpfJump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, x32SyscallBit, 0, 1),
+		deny,
+	}
+	for _, nr := range sandboxDeniedSyscalls {
+		filter = append(filter, bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, uint32(nr), 0, 1), deny)
+	}
+	filter = append(filter, bpfStmt(unix.BPF_RET|unix.BPF_K, seccompRetAllow))
+
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0); err != nil {
+		return fmt.Errorf("cannot install seccomp filter: %w", err)
+	}
+	return nil
+}
+
+func bpfStmt(code uint16, k uint32) unix.SockFilter {
+	return unix.SockFilter{Code: code, K: k}
+}
+
+func bpfJump(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+	return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
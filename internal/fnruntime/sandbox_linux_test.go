@@ -0,0 +1,114 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/printer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+const sandboxTestScript = `#!/bin/sh
+echo "home=$HOME foo=$FOO secret=$KPT_SANDBOX_TEST_SECRET pwd=$(pwd)"
+if cat "$1" >/dev/null 2>&1; then echo "host file readable"; fi
+if echo x > /fn/written 2>/dev/null; then echo "root writable"; fi
+if echo x > /tmp/written; then echo "tmp writable"; fi
+cat
+`
+
+func TestExecFn_sandbox(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "fn.sh")
+	if !assert.NoError(t, os.WriteFile(script, []byte(sandboxTestScript), 0700)) {
+		t.FailNow()
+	}
+	hostFile := filepath.Join(dir, "secret.txt")
+	if !assert.NoError(t, os.WriteFile(hostFile, []byte("secret"), 0600)) {
+		t.FailNow()
+	}
+	t.Setenv("KPT_SANDBOX_TEST_SECRET", "secret")
+
+	f := &ExecFn{
+		Path:     script,
+		Args:     []string{hostFile},
+		Env:      map[string]string{"FOO": "bar"},
+		FnResult: &fnresult.Result{},
+		Sandbox:  true,
+	}
+	var out bytes.Buffer
+	err := f.Run(strings.NewReader("input\n"), &out)
+	if err != nil && strings.Contains(err.Error(), "cannot create the sandbox") {
+		t.Skipf("sandboxes are not supported: %v", err)
+	}
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "home=/tmp foo=bar secret= pwd=/tmp\ntmp writable\ninput\n", out.String())
+}
+
+// TestNewRunner_sandboxCache verifies that the output of an exec function
+// run without the sandbox is not replayed when it runs in the sandbox.
+func TestNewRunner_sandboxCache(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "fn.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho \"$HOME\" >&2\ncat\n"), 0700))
+	t.Setenv("HOME", t.TempDir())
+	cache, err := NewResultCache(t.TempDir())
+	require.NoError(t, err)
+	input, err := kio.FromBytes([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n"))
+	require.NoError(t, err)
+
+	run := func(sandbox bool) (string, error) {
+		ctx := printer.WithContext(context.Background(), printer.New(nil, &bytes.Buffer{}))
+		fr, err := NewRunner(ctx, filesys.MakeFsInMemory(), &kptfilev1.Function{
+			Exec: script,
+		}, "/", fnresult.NewResultList(), RunnerOptions{
+			ResultCache: cache,
+			SandboxExec: sandbox,
+		}, nil)
+		require.NoError(t, err)
+		_, err = fr.Filter(input)
+		return fr.fnResult.Stderr, err
+	}
+	stderr, err := run(false)
+	require.NoError(t, err)
+	assert.Equal(t, os.Getenv("HOME")+"\n", stderr)
+
+	stderr, err = run(true)
+	if err != nil && strings.Contains(err.Error(), "cannot create the sandbox") {
+		t.Skipf("sandboxes are not supported: %v", err)
+	}
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp\n", stderr)
+}
+
+func TestSandboxEnv(t *testing.T) {
+	assert.Equal(t, []string{
+		"FOO=bar",
+		"HOME=/tmp",
+		"PATH=/opt/bin",
+		"TMPDIR=/tmp",
+	}, sandboxEnv(map[string]string{"FOO": "bar", "PATH": "/opt/bin"}))
+}
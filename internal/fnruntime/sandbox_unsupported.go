@@ -0,0 +1,34 @@
+//go:build !linux
+
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+// Stub functions for platforms without support for sandboxed exec functions.
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+func (f *ExecFn) sandboxCommand(_ context.Context) (*exec.Cmd, func(), error) {
+	return nil, nil, fmt.Errorf("sandboxed exec functions are only supported on linux, not on %s", runtime.GOOS)
+}
+
+func sandboxStartError(err error) error {
+	return err
+}
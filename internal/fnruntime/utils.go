@@ -32,26 +32,34 @@ import (
 const ResourceIDAnnotation = "internal.config.k8s.io/kpt-resource-id"
 
 // SaveResults saves results gathered from running the pipeline at specified dir in the input FileSystem.
-func SaveResults(fsys filesys.FileSystem, resultsDir string, fnResults *fnresult.ResultList) (string, error) {
+// The results are saved in each of the formats, `yaml` if none is given, and
+// the paths of the results files are returned. pkgPath is the directory of the
+// package the results refer to, it is used to resolve the line numbers of the
+// results in the SARIF and JUnit formats.
+func SaveResults(fsys filesys.FileSystem, resultsDir string, fnResults *fnresult.ResultList, formats []string, pkgPath string) ([]string, error) {
 	if resultsDir == "" {
-		return "", nil
+		return nil, nil
 	}
-	filePath := filepath.Join(resultsDir, "results.yaml")
-	out := &bytes.Buffer{}
-
-	// use kyaml encoder to ensure consistent indentation
-	e := yaml.NewEncoderWithOptions(out, &yaml.EncoderOptions{SeqIndent: yaml.WideSequenceStyle})
-	err := e.Encode(fnResults)
-	if err != nil {
-		return "", err
+	if len(formats) == 0 {
+		formats = []string{ResultsFormatYAML}
 	}
-
-	err = fsys.WriteFile(filePath, out.Bytes())
-	if err != nil {
-		return "", err
+	if err := ValidateResultsFormats(formats); err != nil {
+		return nil, err
 	}
-
-	return filePath, nil
+	locator := newResultLocator(fsys, pkgPath)
+	var paths []string
+	for _, format := range formats {
+		filePath := filepath.Join(resultsDir, resultsFileNames[format])
+		out := &bytes.Buffer{}
+		if err := writeResults(out, format, fnResults, locator); err != nil {
+			return nil, err
+		}
+		if err := fsys.WriteFile(filePath, out.Bytes()); err != nil {
+			return nil, err
+		}
+		paths = append(paths, filePath)
+	}
+	return paths, nil
 }
 
 // MergeWithInput merges the transformed output with input resources
@@ -0,0 +1,179 @@
+//go:build cgo
+
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/printer"
+	wasmtime "github.com/bytecodealliance/wasmtime-go"
+)
+
+// wasiExitStatus matches the error wasmtime returns when a WASI module
+// exits with proc_exit.
+var wasiExitStatus = regexp.MustCompile(`[Ee]xited with i32 exit status (-?[0-9]+)`)
+
+// WasiFn runs the wasm modules compiled for WASI preview1 with wasmtime.
+// The module reads the input ResourceList from stdin, writes the output
+// ResourceList to stdout and its logs to stderr.
+type WasiFn struct {
+	engine *wasmtime.Engine
+	module *wasmtime.Module
+
+	loader WasmLoader
+
+	// Timeout is the maximum duration of a function run. The function is
+	// interrupted once it elapses. The default value is 5 minutes.
+	Timeout time.Duration
+	// FnResult is used to store the stderr of the function.
+	FnResult *fnresult.Result
+}
+
+func NewWasiFn(loader WasmLoader) (*WasiFn, error) {
+	rc, err := loader.getReadCloser()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read wasm content from reader: %w", err)
+	}
+
+	config := wasmtime.NewConfig()
+	if err := config.CacheConfigLoadDefault(); err != nil {
+		return nil, fmt.Errorf("failed to config cache in wasmtime")
+	}
+	// epoch interruption is used to enforce the timeout of the function.
+	config.SetEpochInterruption(true)
+	engine := wasmtime.NewEngineWithConfig(config)
+	module, err := compileModule(engine, data)
+	if err != nil {
+		return nil, err
+	}
+	return &WasiFn{engine: engine, module: module, loader: loader}, nil
+}
+
+// Run runs the module, which reads the input from r and writes the output
+// to w.
+func (f *WasiFn) Run(r io.Reader, w io.Writer) error {
+	// wasmtime only connects the standard streams of WASI modules to files.
+	dir, err := os.MkdirTemp("", "kpt-fn-wasi-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	stdin, stdout, stderr := filepath.Join(dir, "stdin"), filepath.Join(dir, "stdout"), filepath.Join(dir, "stderr")
+	in, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(stdin, in, 0600); err != nil {
+		return err
+	}
+
+	wasi := wasmtime.NewWasiConfig()
+	wasi.SetArgv([]string{"kpt-fn-wasm-wasi"})
+	if err := wasi.SetStdinFile(stdin); err != nil {
+		return err
+	}
+	if err := wasi.SetStdoutFile(stdout); err != nil {
+		return err
+	}
+	if err := wasi.SetStderrFile(stderr); err != nil {
+		return err
+	}
+	store := wasmtime.NewStore(f.engine)
+	store.SetWasi(wasi)
+	// the engine epoch is only incremented once the timeout elapses.
+	store.SetEpochDeadline(1)
+	linker := wasmtime.NewLinker(f.engine)
+	if err := linker.DefineWasi(); err != nil {
+		return err
+	}
+	instance, err := linker.Instantiate(store, f.module)
+	if err != nil {
+		return err
+	}
+	start := instance.GetFunc(store, "_start")
+	if start == nil {
+		return fmt.Errorf("_start: missing export")
+	}
+
+	// Interrupt the function once the timeout elapses.
+	timeout := defaultLongTimeout
+	if f.Timeout != 0 {
+		timeout = f.Timeout
+	}
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(timeout, func() {
+		timedOut.Store(true)
+		f.engine.IncrementEpoch()
+	})
+	defer timer.Stop()
+
+	_, err = start.Call(store)
+	errOutput, _ := os.ReadFile(stderr)
+	if exitCode := wasiExitCode(err); exitCode != 0 {
+		err = &ExecError{
+			OriginalErr:    err,
+			ExitCode:       exitCode,
+			Stderr:         string(errOutput),
+			TruncateOutput: printer.TruncateOutput,
+		}
+		if timedOut.Load() {
+			return &LimitError{Limit: "timeout", Value: timeout.String(), Err: err}
+		}
+		return err
+	}
+	if len(errOutput) > 0 && f.FnResult != nil {
+		f.FnResult.Stderr = string(errOutput)
+	}
+
+	out, err := os.Open(stdout)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if _, err := io.Copy(w, out); err != nil {
+		return fmt.Errorf("unable to write the output resource list: %w", err)
+	}
+	return f.loader.cleanup()
+}
+
+// wasiExitCode returns the exit code of the module from the error returned
+// by its `_start` function. Errors other than an exit are reported with
+// the exit code 1.
+func wasiExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if m := wasiExitStatus.FindStringSubmatch(err.Error()); m != nil {
+		if code, err := strconv.Atoi(m[1]); err == nil {
+			return code
+		}
+	}
+	return 1
+}
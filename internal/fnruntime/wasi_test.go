@@ -0,0 +1,93 @@
+//go:build cgo
+
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	goerrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	wasmtime "github.com/bytecodealliance/wasmtime-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wasiEchoModule is a WASI module copying stdin to stdout, writing `log` to
+// stderr and exiting with the exit code.
+const wasiEchoModule = `(module
+  (import "wasi_snapshot_preview1" "fd_read" (func $fd_read (param i32 i32 i32 i32) (result i32)))
+  (import "wasi_snapshot_preview1" "fd_write" (func $fd_write (param i32 i32 i32 i32) (result i32)))
+  (import "wasi_snapshot_preview1" "proc_exit" (func $proc_exit (param i32)))
+  (memory (export "memory") 1)
+  (data (i32.const 2048) "log\n")
+  (func (export "_start")
+    (block $done
+      (loop $read
+        (i32.store (i32.const 0) (i32.const 4096))
+        (i32.store (i32.const 4) (i32.const 1024))
+        (drop (call $fd_read (i32.const 0) (i32.const 0) (i32.const 1) (i32.const 16)))
+        (br_if $done (i32.eqz (i32.load (i32.const 16))))
+        (i32.store (i32.const 4) (i32.load (i32.const 16)))
+        (drop (call $fd_write (i32.const 1) (i32.const 0) (i32.const 1) (i32.const 20)))
+        (br $read)))
+    (i32.store (i32.const 32) (i32.const 2048))
+    (i32.store (i32.const 36) (i32.const 4))
+    (drop (call $fd_write (i32.const 2) (i32.const 32) (i32.const 1) (i32.const 40)))
+    (call $proc_exit (i32.const %d))))
+`
+
+func TestWasiFn(t *testing.T) {
+	t.Setenv(FnCacheDirEnv, t.TempDir())
+	input := "apiVersion: config.kubernetes.io/v1\nkind: ResourceList\nitems:\n" +
+		strings.Repeat("- apiVersion: v1\n  kind: ConfigMap\n  metadata:\n    name: cm\n", 100)
+	module := func(t *testing.T, exitCode int) WasmLoader {
+		wasm, err := wasmtime.Wat2Wasm(fmt.Sprintf(wasiEchoModule, exitCode))
+		require.NoError(t, err)
+		path := filepath.Join(t.TempDir(), "fn.wasm")
+		require.NoError(t, os.WriteFile(path, wasm, 0644))
+		return &FsLoader{Filename: path}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		fn, err := NewWasmFn(module(t, 0))
+		require.NoError(t, err)
+		// the module type is detected from its imports.
+		require.NotNil(t, fn.wasi)
+		fnResult := &fnresult.Result{}
+		fn.SetFnResult(fnResult)
+		out := &bytes.Buffer{}
+		assert.NoError(t, fn.Run(strings.NewReader(input), out))
+		assert.Equal(t, input, out.String())
+		assert.Equal(t, "log\n", fnResult.Stderr)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		fn, err := NewWasmFn(module(t, 3))
+		require.NoError(t, err)
+		err = fn.Run(strings.NewReader(input), &bytes.Buffer{})
+		var execErr *ExecError
+		if assert.True(t, goerrors.As(err, &execErr)) {
+			assert.Equal(t, 3, execErr.ExitCode)
+			assert.Equal(t, "log\n", execErr.Stderr)
+		}
+	})
+}
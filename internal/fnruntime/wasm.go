@@ -20,8 +20,11 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/wasm"
+	"github.com/google/go-containerregistry/pkg/authn"
 )
 
 type WasmRuntime string
@@ -40,9 +43,29 @@ type WasmFn struct {
 
 	wasmtime *WasmtimeFn
 	nodejs   *WasmNodejsFn
+	// wasi runs the modules compiled for WASI preview1, which always run
+	// with wasmtime.
+	wasi *WasiFn
 }
 
 func NewWasmFn(loader WasmLoader) (*WasmFn, error) {
+	abi, err := loaderABI(loader)
+	if err != nil {
+		return nil, err
+	}
+	if abi == wasm.ABIWasip1 {
+		if runtime := os.Getenv(WasmRuntimeEnv); runtime != "" && runtime != string(Wasmtime) {
+			return nil, fmt.Errorf("wasm runtime %v doesn't support %v modules", runtime, abi)
+		}
+		wf, err := NewWasiFn(loader)
+		if err != nil {
+			return nil, err
+		}
+		return &WasmFn{
+			runtimeType: Wasmtime,
+			wasi:        wf,
+		}, nil
+	}
 	switch os.Getenv(WasmRuntimeEnv) {
 	case string(Nodejs):
 		nf, err := NewNodejsFn(loader)
@@ -69,7 +92,36 @@ func NewWasmFn(loader WasmLoader) (*WasmFn, error) {
 	}
 }
 
+// SetLimits sets the resource limits of the function. Only the timeout is
+// supported by the wasm runtimes, which can't cap the memory of a module.
+func (f *WasmFn) SetLimits(timeout time.Duration, memory int64) error {
+	if memory > 0 {
+		return fmt.Errorf("memory limits are not supported by the %v wasm runtime", f.runtimeType)
+	}
+	switch f.runtimeType {
+	case Nodejs:
+		f.nodejs.NodeJsRunner.Timeout = timeout
+	case Wasmtime:
+		if f.wasi != nil {
+			f.wasi.Timeout = timeout
+			return nil
+		}
+		f.wasmtime.Timeout = timeout
+	}
+	return nil
+}
+
+// SetFnResult sets the result the stderr of WASI modules is stored in.
+func (f *WasmFn) SetFnResult(fnResult *fnresult.Result) {
+	if f.wasi != nil {
+		f.wasi.FnResult = fnResult
+	}
+}
+
 func (f *WasmFn) Run(r io.Reader, w io.Writer) error {
+	if f.wasi != nil {
+		return f.wasi.Run(r, w)
+	}
 	switch f.runtimeType {
 	case Nodejs:
 		return f.nodejs.Run(r, w)
@@ -83,6 +135,9 @@ func (f *WasmFn) Run(r io.Reader, w io.Writer) error {
 type WasmLoader interface {
 	// getReadCloser returns an io.ReadCloser to read the wasm contents.
 	getReadCloser() (io.ReadCloser, error)
+	// getABI returns the ABI of the wasm module if the loader knows it,
+	// or an empty string.
+	getABI() wasm.ABI
 	// getFilePath returns a path to the wasm file.
 	getFilePath() (string, error)
 	// cleanup remove temporary directory or files.
@@ -92,15 +147,22 @@ type WasmLoader interface {
 type OciLoader struct {
 	cacheDir string
 	image    string
+	keychain authn.Keychain
 	tempDir  string
+	// abi is the ABI recorded in the image, once it is loaded.
+	abi wasm.ABI
 }
 
 var _ WasmLoader = &OciLoader{}
 
-func NewOciLoader(cacheDir, image string) *OciLoader {
+// NewOciLoader returns a loader of the wasm module of the image. keychain
+// provides the registry credentials, the default credentials are used if
+// it is nil.
+func NewOciLoader(cacheDir, image string, keychain authn.Keychain) *OciLoader {
 	return &OciLoader{
 		cacheDir: cacheDir,
 		image:    image,
+		keychain: keychain,
 	}
 }
 
@@ -109,13 +171,19 @@ func (o *OciLoader) getReadCloser() (io.ReadCloser, error) {
 	if err != nil {
 		return nil, fmt.Errorf("unable to create a storage client: %w", err)
 	}
-	rc, err := storage.LoadWasm(context.TODO(), o.image)
+	storage.Keychain = o.keychain
+	rc, abi, err := storage.LoadWasm(context.TODO(), o.image)
 	if err != nil {
 		return nil, fmt.Errorf("unable to load image from %v: %w", o.image, err)
 	}
+	o.abi = abi
 	return rc, nil
 }
 
+func (o *OciLoader) getABI() wasm.ABI {
+	return o.abi
+}
+
 func (o *OciLoader) getFilePath() (string, error) {
 	rc, err := o.getReadCloser()
 	if err != nil {
@@ -163,6 +231,28 @@ func (f *FsLoader) getReadCloser() (io.ReadCloser, error) {
 	return fi, nil
 }
 
+func (f *FsLoader) getABI() wasm.ABI {
+	return ""
+}
+
 func (f *FsLoader) cleanup() error {
 	return nil
 }
+
+// loaderABI returns the ABI of the wasm module of the loader. It is detected
+// from the imports of the module unless the loader knows it.
+func loaderABI(loader WasmLoader) (wasm.ABI, error) {
+	rc, err := loader.getReadCloser()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	if abi := loader.getABI(); abi != "" {
+		return abi, nil
+	}
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return "", fmt.Errorf("unable to read wasm content from reader: %w", err)
+	}
+	return wasm.DetectABI(data)
+}
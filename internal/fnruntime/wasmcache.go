@@ -0,0 +1,170 @@
+//go:build cgo
+
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	wasmtime "github.com/bytecodealliance/wasmtime-go"
+	"golang.org/x/sys/cpu"
+)
+
+const (
+	// wasmtimeModule is the path of the wasmtime go module.
+	wasmtimeModule = "github.com/bytecodealliance/wasmtime-go"
+	// compiledModulesDir is the directory in the function cache dir where
+	// the precompiled wasm modules are stored.
+	compiledModulesDir = "wasm-compiled"
+)
+
+// compileModule compiles the wasm module for the engine. The precompiled
+// artifact is cached in the function cache dir and reused by later runs.
+// Failing to use the cache never fails the compilation.
+func compileModule(engine *wasmtime.Engine, data []byte) (*wasmtime.Module, error) {
+	dir, err := GetFnCacheDir()
+	if err != nil {
+		return wasmtime.NewModule(engine, data)
+	}
+	return (&compiledModuleCache{dir: filepath.Join(dir, compiledModulesDir)}).compile(engine, data)
+}
+
+// compiledModuleCache caches the serialized precompiled wasm modules.
+// An artifact is addressed by the digest of the module, the wasmtime version
+// and the CPU features of the host, since wasmtime only loads artifacts
+// compiled by the same version for a compatible host. Each artifact is
+// prefixed with its own sha256 digest so that truncated or corrupted files
+// are never deserialized.
+type compiledModuleCache struct {
+	dir string
+}
+
+func (c *compiledModuleCache) compile(engine *wasmtime.Engine, data []byte) (*wasmtime.Module, error) {
+	path := filepath.Join(c.dir, compiledModuleKey(data)+".cwasm")
+	if module := c.load(engine, path); module != nil {
+		return module, nil
+	}
+	module, err := wasmtime.NewModule(engine, data)
+	if err != nil {
+		return nil, err
+	}
+	c.store(module, path)
+	return module, nil
+}
+
+// load returns the cached module, or nil if it is missing, corrupted or
+// incompatible with the engine.
+func (c *compiledModuleCache) load(engine *wasmtime.Engine, path string) *wasmtime.Module {
+	b, err := os.ReadFile(path)
+	if err != nil || len(b) < sha256.Size {
+		return nil
+	}
+	sum, artifact := b[:sha256.Size], b[sha256.Size:]
+	if digest := sha256.Sum256(artifact); !bytes.Equal(sum, digest[:]) {
+		return nil
+	}
+	// wasmtime checks the artifact was compiled with a compatible engine
+	// configuration.
+	module, err := wasmtime.NewModuleDeserialize(engine, artifact)
+	if err != nil {
+		return nil
+	}
+	return module
+}
+
+// store caches the precompiled module. The file is written atomically to
+// not expose partial artifacts to concurrent runs.
+func (c *compiledModuleCache) store(module *wasmtime.Module, path string) {
+	artifact, err := module.Serialize()
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return
+	}
+	f, err := os.CreateTemp(c.dir, "tmp-")
+	if err != nil {
+		return
+	}
+	defer os.Remove(f.Name())
+	digest := sha256.Sum256(artifact)
+	_, err = f.Write(append(digest[:], artifact...))
+	if closeErr := f.Close(); err != nil || closeErr != nil {
+		return
+	}
+	_ = os.Rename(f.Name(), path)
+}
+
+// compiledModuleKey returns the cache key of the precompiled module.
+func compiledModuleKey(data []byte) string {
+	h := sha256.New()
+	h.Write(data)
+	h.Write([]byte{0})
+	h.Write([]byte(wasmtimeVersion()))
+	h.Write([]byte{0})
+	h.Write([]byte(runtime.GOOS + "/" + runtime.GOARCH + ":" + cpuFeatures()))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// wasmtimeVersion returns the version of the wasmtime library compiled into
+// the binary.
+func wasmtimeVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == wasmtimeModule {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			return dep.Version + dep.Sum
+		}
+	}
+	return "unknown"
+}
+
+// cpuFeatures returns the list of the CPU features of the host.
+func cpuFeatures() string {
+	var features interface{}
+	switch runtime.GOARCH {
+	case "amd64", "386":
+		features = cpu.X86
+	case "arm64":
+		features = cpu.ARM64
+	case "s390x":
+		features = cpu.S390X
+	default:
+		return ""
+	}
+	var names []string
+	v := reflect.ValueOf(features)
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if strings.HasPrefix(name, "Has") && v.Field(i).Kind() == reflect.Bool && v.Field(i).Bool() {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
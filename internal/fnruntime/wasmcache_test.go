@@ -0,0 +1,92 @@
+//go:build cgo
+
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+
+	wasmtime "github.com/bytecodealliance/wasmtime-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompiledModuleCache(t *testing.T) {
+	data, err := wasmtime.Wat2Wasm(`(module (func (export "_start")))`)
+	require.NoError(t, err)
+	newEngine := func(epoch bool) *wasmtime.Engine {
+		config := wasmtime.NewConfig()
+		config.SetEpochInterruption(epoch)
+		return wasmtime.NewEngineWithConfig(config)
+	}
+	cache := &compiledModuleCache{dir: filepath.Join(t.TempDir(), compiledModulesDir)}
+	path := filepath.Join(cache.dir, compiledModuleKey(data)+".cwasm")
+	writeArtifact := func(t *testing.T, artifact []byte) {
+		digest := sha256.Sum256(artifact)
+		require.NoError(t, os.WriteFile(path, append(digest[:], artifact...), 0644))
+	}
+	compile := func(t *testing.T) {
+		module, err := cache.compile(newEngine(true), data)
+		require.NoError(t, err)
+		assert.NotNil(t, module.Exports())
+		// the cached artifact is always valid after a compilation.
+		assert.NotNil(t, cache.load(newEngine(true), path))
+	}
+
+	// the artifact is cached by the first compilation.
+	compile(t)
+	files, err := os.ReadDir(cache.dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	t.Run("cached artifact", func(t *testing.T) {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		compile(t)
+		after, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, info.ModTime(), after.ModTime())
+	})
+
+	t.Run("corrupted artifact", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("corrupted"), 0644))
+		assert.Nil(t, cache.load(newEngine(true), path))
+		compile(t)
+	})
+
+	t.Run("incompatible artifact", func(t *testing.T) {
+		module, err := wasmtime.NewModule(newEngine(false), data)
+		require.NoError(t, err)
+		artifact, err := module.Serialize()
+		require.NoError(t, err)
+		writeArtifact(t, artifact)
+		assert.Nil(t, cache.load(newEngine(true), path))
+		compile(t)
+	})
+
+	t.Run("invalid module", func(t *testing.T) {
+		_, err := cache.compile(newEngine(true), []byte("invalid"))
+		assert.Error(t, err)
+	})
+}
+
+func TestCompiledModuleKey(t *testing.T) {
+	assert.Equal(t, compiledModuleKey([]byte("a")), compiledModuleKey([]byte("a")))
+	assert.NotEqual(t, compiledModuleKey([]byte("a")), compiledModuleKey([]byte("b")))
+}
@@ -22,6 +22,8 @@ import (
 	"io"
 	"log"
 	"os"
+	"sync/atomic"
+	"time"
 
 	wasmtime "github.com/bytecodealliance/wasmtime-go"
 	"github.com/prep/wasmexec"
@@ -32,7 +34,8 @@ import (
 type WasmtimeFn struct {
 	wasmexec.Memory
 	*wasmtime.Instance
-	store *wasmtime.Store
+	engine *wasmtime.Engine
+	store  *wasmtime.Store
 
 	gomod *wasmexec.Module
 
@@ -40,6 +43,10 @@ type WasmtimeFn struct {
 	resumeFn *wasmtime.Func
 
 	loader WasmLoader
+
+	// Timeout is the maximum duration of a function run. The function is
+	// interrupted once it elapses. The default value is 5 minutes.
+	Timeout time.Duration
 }
 
 func NewWasmtimeFn(loader WasmLoader) (*WasmtimeFn, error) {
@@ -63,15 +70,19 @@ func NewWasmtimeFn(loader WasmLoader) (*WasmtimeFn, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to config cache in wasmtime")
 	}
-	engine := wasmtime.NewEngineWithConfig(config)
+	// epoch interruption is used to enforce the timeout of the function.
+	config.SetEpochInterruption(true)
+	f.engine = wasmtime.NewEngineWithConfig(config)
 
-	module, err := wasmtime.NewModule(engine, data)
+	module, err := compileModule(f.engine, data)
 	if err != nil {
 		return nil, err
 	}
-	f.store = wasmtime.NewStore(engine)
+	f.store = wasmtime.NewStore(f.engine)
+	// the engine epoch is only incremented once the timeout elapses.
+	f.store.SetEpochDeadline(1)
 
-	linker := wasmtime.NewLinker(engine)
+	linker := wasmtime.NewLinker(f.engine)
 	f.gomod, err = wasmtimexec.Import(f.store, linker, f)
 	if err != nil {
 		return nil, err
@@ -128,6 +139,18 @@ func (f *WasmtimeFn) Run(r io.Reader, w io.Writer) error {
 		return err
 	}
 
+	// Interrupt the function once the timeout elapses.
+	timeout := defaultLongTimeout
+	if f.Timeout != 0 {
+		timeout = f.Timeout
+	}
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(timeout, func() {
+		timedOut.Store(true)
+		f.engine.IncrementEpoch()
+	})
+	defer timer.Stop()
+
 	// Fetch the "run" function and call it. This starts the program.
 	runFn := f.GetFunc(f.store, "run")
 	if runFn == nil {
@@ -135,6 +158,9 @@ func (f *WasmtimeFn) Run(r io.Reader, w io.Writer) error {
 	}
 
 	if _, err = runFn.Call(f.store, argc, argv); err != nil {
+		if timedOut.Load() {
+			return &LimitError{Limit: "timeout", Value: timeout.String(), Err: err}
+		}
 		return err
 	}
 	resourceList, err := io.ReadAll(r)
@@ -143,9 +169,11 @@ func (f *WasmtimeFn) Run(r io.Reader, w io.Writer) error {
 	}
 	result, err := f.gomod.Call(jsEntrypointFunction, string(resourceList))
 	if err != nil {
+		if timedOut.Load() {
+			return &LimitError{Limit: "timeout", Value: timeout.String(), Err: err}
+		}
 		return fmt.Errorf("unable to invoke %v: %v", jsEntrypointFunction, err)
 	}
-
 	// We expect `result` to be a *wasmexec.jsString (which is not exportable) with
 	// the following definition: type jsString struct { data string }. It will look
 	// like `&{realPayload}`
@@ -22,6 +22,9 @@ package fnruntime
 import (
 	"fmt"
 	"io"
+	"time"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 )
 
 const (
@@ -29,6 +32,7 @@ const (
 )
 
 type WasmtimeFn struct {
+	Timeout time.Duration
 }
 
 func NewWasmtimeFn(loader WasmLoader) (*WasmtimeFn, error) {
@@ -38,3 +42,16 @@ func NewWasmtimeFn(loader WasmLoader) (*WasmtimeFn, error) {
 func (f *WasmtimeFn) Run(r io.Reader, w io.Writer) error {
 	return fmt.Errorf(msg)
 }
+
+type WasiFn struct {
+	Timeout  time.Duration
+	FnResult *fnresult.Result
+}
+
+func NewWasiFn(loader WasmLoader) (*WasiFn, error) {
+	return nil, fmt.Errorf(msg)
+}
+
+func (f *WasiFn) Run(r io.Reader, w io.Writer) error {
+	return fmt.Errorf(msg)
+}
@@ -0,0 +1,135 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+)
+
+// BackendEnv is the name of the environment variable that selects the
+// backend used for the upstream repos. It must be one of `go` and `exec`, and
+// defaults to `go`.
+const BackendEnv = "KPT_GIT_BACKEND"
+
+const (
+	// GoBackendName is the name of the GoBackend.
+	GoBackendName = "go"
+	// ExecBackendName is the name of the ExecBackend.
+	ExecBackendName = "exec"
+)
+
+// Backend performs the git operations on the repos caching the upstream
+// repos. Each repo has an `origin` remote, which is the upstream repo.
+//
+// The errors returned by a Backend wrap a GitExecError when they come from
+// git, so that they can be reported the same way for all the backends.
+type Backend interface {
+	// Init creates an empty repo in the directory, with the uri as its
+	// origin remote.
+	Init(ctx context.Context, dir, uri string) error
+
+	// ListRefs returns the branches and the tags of the origin remote, and
+	// the SHA of the object each of them is referencing. Note that this
+	// doesn't download any objects, only refs.
+	ListRefs(ctx context.Context, dir string) (heads map[string]string, tags map[string]string, err error)
+
+	// DefaultBranch returns the name of the branch pointed to by the HEAD
+	// symref of the origin remote.
+	DefaultBranch(ctx context.Context, dir string) (string, error)
+
+	// Fetch fetches refs and their objects from the origin remote.
+	Fetch(ctx context.Context, dir string, opts FetchOptions) error
+
+	// ResolveCommit returns the full SHA of the commit the revision, e.g. a
+	// ref or a short commit SHA, references in the repo.
+	ResolveCommit(ctx context.Context, dir, rev string) (string, error)
+
+	// CountCommits returns the number of commits reachable from the commit
+	// to and not from the commit from.
+	CountCommits(ctx context.Context, dir, from, to string) (int, error)
+
+	// Checkout resets the worktree of the repo to the commit, so that its
+	// directories can be read. Any change in the worktree is discarded.
+	Checkout(ctx context.Context, dir, commit string) error
+}
+
+// FetchOptions are the options for Backend.Fetch.
+type FetchOptions struct {
+	// Refs are the branches, tags and full commit SHAs to fetch. The
+	// branches and the tags must be qualified, e.g. `refs/heads/main`, since
+	// the GoBackend doesn't expand short names. If there are no refs, all
+	// the branches are fetched with the tags they reference.
+	Refs []string
+
+	// Depth limits the history fetched to the given number of commits from
+	// the tip of each ref. The history isn't limited if Depth is 0.
+	Depth int
+
+	// Unshallow fetches the whole history of the refs, including the
+	// commits missing from a repo previously fetched with a Depth.
+	Unshallow bool
+
+	// Verbose prints the progress of the fetch.
+	Verbose bool
+}
+
+// NewBackend returns the backend with the given name.
+func NewBackend(name string) (Backend, error) {
+	const op errors.Op = "gitutil.NewBackend"
+	switch name {
+	case GoBackendName:
+		// The remotes requiring credentials the GoBackend doesn't have
+		// are accessed with git, if it is installed.
+		b := &GoBackend{}
+		if fallback, err := NewExecBackend(); err == nil {
+			b.AuthFallback = fallback
+		}
+		return b, nil
+	case ExecBackendName:
+		return NewExecBackend()
+	default:
+		return nil, errors.E(op, errors.InvalidParam, fmt.Errorf(
+			"unknown git backend %q, must be one of: %s,%s", name, GoBackendName, ExecBackendName))
+	}
+}
+
+// DefaultBackend returns the backend selected by the environment variable
+// BackendEnv, or the GoBackend if it isn't set.
+func DefaultBackend() (Backend, error) {
+	name := os.Getenv(BackendEnv)
+	if name == "" {
+		name = GoBackendName
+	}
+	return NewBackend(name)
+}
+
+// LookupCommit looks up the sha of the current commit on the repo at the
+// provided path with the default backend.
+func LookupCommit(ctx context.Context, repoPath string) (string, error) {
+	const op errors.Op = "gitutil.LookupCommit"
+	b, err := DefaultBackend()
+	if err != nil {
+		return "", errors.E(op, err)
+	}
+	commit, err := b.ResolveCommit(ctx, repoPath, "HEAD")
+	if err != nil {
+		return "", errors.E(op, errors.Git, fmt.Errorf("unable to look up commit: %w", err))
+	}
+	return commit, nil
+}
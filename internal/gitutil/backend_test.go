@@ -0,0 +1,303 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	. "github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/GoogleContainerTools/kpt/pkg/printer/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBackend(t *testing.T) {
+	b, err := NewBackend(GoBackendName)
+	if assert.NoError(t, err) {
+		assert.IsType(t, &GoBackend{}, b)
+	}
+	b, err = NewBackend(ExecBackendName)
+	if assert.NoError(t, err) {
+		assert.IsType(t, &ExecBackend{}, b)
+	}
+	_, err = NewBackend("foo")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `unknown git backend "foo", must be one of: go,exec`)
+	}
+
+	t.Setenv(BackendEnv, ExecBackendName)
+	b, err = DefaultBackend()
+	if assert.NoError(t, err) {
+		assert.IsType(t, &ExecBackend{}, b)
+	}
+	t.Setenv(BackendEnv, "")
+	b, err = DefaultBackend()
+	if assert.NoError(t, err) {
+		assert.IsType(t, &GoBackend{}, b)
+	}
+}
+
+// TestBackends verifies that the backends resolve, fetch, count and check
+// out the same commits of a repo.
+func TestBackends(t *testing.T) {
+	for _, name := range []string{GoBackendName, ExecBackendName} {
+		t.Run(name, func(t *testing.T) {
+			backend, err := NewBackend(name)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			repoContent := map[string][]testutil.Content{
+				testutil.Upstream: {
+					{
+						Pkg: pkgbuilder.NewRootPkg().
+							WithResource(pkgbuilder.DeploymentResource),
+						Branch: "main",
+						Tag:    "v1",
+					},
+					{
+						Pkg: pkgbuilder.NewRootPkg().
+							WithResource(pkgbuilder.ConfigMapResource),
+						Tag: "v2",
+					},
+					{
+						Pkg: pkgbuilder.NewRootPkg().
+							WithResource(pkgbuilder.SecretResource),
+					},
+				},
+			}
+			g, clean := testutil.SetupRepos(t, repoContent)
+			defer clean()
+			if !assert.NoError(t, testutil.UpdateRepos(t, g, repoContent)) {
+				t.FailNow()
+			}
+			upstream := g[testutil.Upstream]
+			commits := upstream.Commits
+
+			ctx := fake.CtxWithDefaultPrinter()
+			gur, err := NewGitUpstreamRepo(ctx, upstream.RepoDirectory, WithBackend(backend))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, map[string]string{"main": commits[2]}, gur.Heads)
+			assert.Equal(t, map[string]string{"v1": commits[0], "v2": commits[1]}, gur.Tags)
+
+			branch, err := gur.GetDefaultBranch(ctx)
+			if assert.NoError(t, err) {
+				assert.Equal(t, "main", branch)
+			}
+
+			for ref, file := range map[string]string{
+				"main":         "secret.yaml",
+				"v1":           "deployment.yaml",
+				commits[1]:     "configmap.yaml",
+				commits[0][:7]: "deployment.yaml",
+			} {
+				dir, err := gur.GetRepo(ctx, []string{ref})
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				commit, found := gur.ResolveRef(ref)
+				if !found {
+					commit = ref
+				}
+				if !assert.NoError(t, gur.Checkout(ctx, commit)) {
+					t.FailNow()
+				}
+				_, err = os.Stat(filepath.Join(dir, file))
+				assert.NoError(t, err, "ref %s", ref)
+			}
+
+			count, err := gur.CommitsBehind(ctx, commits[0], commits[2])
+			if assert.NoError(t, err) {
+				assert.Equal(t, 2, count)
+			}
+			count, err = gur.CommitsBehind(ctx, commits[2], commits[1])
+			if assert.NoError(t, err) {
+				assert.Equal(t, 0, count)
+			}
+		})
+	}
+}
+
+// TestGoBackend_errors verifies that the errors of the GoBackend are
+// categorized like the errors of the git executable.
+func TestGoBackend_errors(t *testing.T) {
+	ctx := fake.CtxWithDefaultPrinter()
+
+	_, err := NewGitUpstreamRepo(ctx, t.TempDir(), WithBackend(&GoBackend{}))
+	var gitExecErr *GitExecError
+	if assert.True(t, errors.As(err, &gitExecErr)) {
+		assert.Equal(t, RepositoryNotFound, gitExecErr.Type)
+	}
+
+	g, clean := testutil.SetupRepos(t, map[string][]testutil.Content{
+		testutil.Upstream: {
+			{
+				Pkg: pkgbuilder.NewRootPkg().
+					WithResource(pkgbuilder.DeploymentResource),
+				Branch: "main",
+			},
+		},
+	})
+	defer clean()
+	gur, err := NewGitUpstreamRepo(ctx, g[testutil.Upstream].RepoDirectory, WithBackend(&GoBackend{}))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = gur.GetRepo(ctx, []string{"foo"})
+	if assert.True(t, errors.As(err, &gitExecErr)) {
+		assert.Equal(t, UnknownReference, gitExecErr.Type)
+		assert.Equal(t, "foo", gitExecErr.Ref)
+		assert.Equal(t, g[testutil.Upstream].RepoDirectory, gitExecErr.Repo)
+	}
+}
+
+// serveRepo serves the repo over http with git-http-backend. The requests
+// must use basic auth with the given credentials. It returns the url of the
+// repo.
+func serveRepo(t *testing.T, repo, user, password string) string {
+	out, err := exec.Command("git", "--exec-path").Output()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	backend := &cgi.Handler{
+		Path: filepath.Join(strings.TrimSpace(string(out)), "git-http-backend"),
+		Env: []string{
+			"GIT_PROJECT_ROOT=" + filepath.Dir(repo),
+			"GIT_HTTP_EXPORT_ALL=1",
+		},
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != user || p != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="kpt"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s.URL + "/" + filepath.Base(repo)
+}
+
+// upstreamRepo returns the directory of an upstream repo with a main branch.
+func upstreamRepo(t *testing.T) string {
+	g, clean := testutil.SetupRepos(t, map[string][]testutil.Content{
+		testutil.Upstream: {
+			{
+				Pkg: pkgbuilder.NewRootPkg().
+					WithResource(pkgbuilder.DeploymentResource),
+				Branch: "main",
+			},
+		},
+	})
+	t.Cleanup(clean)
+	return g[testutil.Upstream].RepoDirectory
+}
+
+// TestGoBackend_netrc verifies that the GoBackend reads the credentials of
+// the http remotes from the .netrc file.
+func TestGoBackend_netrc(t *testing.T) {
+	ctx := fake.CtxWithDefaultPrinter()
+	uri := serveRepo(t, upstreamRepo(t), "kpt", "secret")
+	netrc := filepath.Join(t.TempDir(), ".netrc")
+	t.Setenv("NETRC", netrc)
+
+	_, err := NewGitUpstreamRepo(ctx, uri, WithBackend(&GoBackend{}))
+	var gitExecErr *GitExecError
+	if assert.True(t, errors.As(err, &gitExecErr)) {
+		assert.Equal(t, HTTPSAuthRequired, gitExecErr.Type)
+	}
+
+	for _, content := range []string{
+		"machine 127.0.0.1 login kpt password secret\n",
+		"machine example.com\n  login foo\n  password bar\n" +
+			"macdef init\nmachine 127.0.0.1 login foo password bar\n\n" +
+			"default login kpt password secret\n" +
+			"machine 127.0.0.1 login foo password bar\n",
+	} {
+		if !assert.NoError(t, os.WriteFile(netrc, []byte(content), 0600)) {
+			t.FailNow()
+		}
+		gur, err := NewGitUpstreamRepo(ctx, uri, WithBackend(&GoBackend{}))
+		if !assert.NoError(t, err, content) {
+			continue
+		}
+		dir, err := gur.GetRepo(ctx, []string{"main"})
+		if assert.NoError(t, err) {
+			commit, _ := gur.ResolveRef("main")
+			assert.NoError(t, gur.Checkout(ctx, commit))
+			_, err = os.Stat(filepath.Join(dir, "deployment.yaml"))
+			assert.NoError(t, err)
+		}
+	}
+}
+
+// recordingBackend is a Backend which records the operations on the origin
+// remote.
+type recordingBackend struct {
+	Backend
+	ops []string
+}
+
+func (b *recordingBackend) ListRefs(ctx context.Context, dir string) (map[string]string, map[string]string, error) {
+	b.ops = append(b.ops, "ListRefs")
+	return b.Backend.ListRefs(ctx, dir)
+}
+
+func (b *recordingBackend) Fetch(ctx context.Context, dir string, opts FetchOptions) error {
+	b.ops = append(b.ops, "Fetch")
+	return b.Backend.Fetch(ctx, dir, opts)
+}
+
+// TestGoBackend_authFallback verifies that the operations on a remote
+// requiring credentials the GoBackend doesn't have are run by the
+// AuthFallback, here git with a credential helper.
+func TestGoBackend_authFallback(t *testing.T) {
+	ctx := fake.CtxWithDefaultPrinter()
+	t.Setenv("NETRC", filepath.Join(t.TempDir(), ".netrc"))
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "credential.helper")
+	t.Setenv("GIT_CONFIG_VALUE_0", "!f() { echo username=kpt; echo password=secret; }; f")
+	uri := serveRepo(t, upstreamRepo(t), "kpt", "secret")
+
+	exec, err := NewExecBackend()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	fallback := &recordingBackend{Backend: exec}
+	gur, err := NewGitUpstreamRepo(ctx, uri, WithBackend(&GoBackend{AuthFallback: fallback}))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	dir, err := gur.GetRepo(ctx, []string{"main"})
+	if assert.NoError(t, err) {
+		commit, _ := gur.ResolveRef("main")
+		assert.NoError(t, gur.Checkout(ctx, commit))
+		_, err = os.Stat(filepath.Join(dir, "deployment.yaml"))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"ListRefs", "Fetch"}, fallback.ops)
+}
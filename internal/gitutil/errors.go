@@ -45,8 +45,8 @@ const (
 )
 
 // GitExecError is an error type returned if kpt encounters an error while
-// executing a git command. It includes information about the command that
-// was executed and the output from git.
+// executing a git command, or its equivalent in the GoBackend. It includes
+// information about the command that was executed and the output from git.
 type GitExecError struct {
 	Type    GitExecErrorType
 	Args    []string
@@ -61,8 +61,11 @@ type GitExecError struct {
 func (e *GitExecError) Error() string {
 	b := new(strings.Builder)
 	b.WriteString(e.Err.Error())
-	b.WriteString(": ")
-	b.WriteString(e.StdErr)
+	// the errors of the GoBackend don't have any output
+	if e.StdErr != "" {
+		b.WriteString(": ")
+		b.WriteString(e.StdErr)
+	}
 	return b.String()
 }
 
@@ -0,0 +1,186 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+)
+
+// ExecBackend is a Backend running the git executable. It depends on the
+// version of git installed on the host, but uses its configuration and its
+// credential helpers.
+type ExecBackend struct{}
+
+// NewExecBackend returns a new ExecBackend, or an error if the git
+// executable isn't available.
+func NewExecBackend() (*ExecBackend, error) {
+	if _, err := NewLocalGitRunner(""); err != nil {
+		return nil, err
+	}
+	return &ExecBackend{}, nil
+}
+
+// Init creates the repo with `git init` and adds the origin remote.
+func (*ExecBackend) Init(ctx context.Context, dir, uri string) error {
+	gitRunner, err := NewLocalGitRunner(filepath.Dir(dir))
+	if err != nil {
+		return err
+	}
+	if _, err := gitRunner.Run(ctx, "init", filepath.Base(dir)); err != nil {
+		return fmt.Errorf("error running `git init`: %w", err)
+	}
+	gitRunner.Dir = dir
+	if _, err = gitRunner.Run(ctx, "remote", "add", "origin", uri); err != nil {
+		return fmt.Errorf("error adding origin remote: %w", err)
+	}
+	return nil
+}
+
+// ListRefs lists the refs with `git ls-remote`.
+func (*ExecBackend) ListRefs(ctx context.Context, dir string) (map[string]string, map[string]string, error) {
+	const op errors.Op = "gitutil.ExecBackend.ListRefs"
+	gitRunner, err := NewLocalGitRunner(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	rr, err := gitRunner.Run(ctx, "ls-remote", "--heads", "--tags", "--refs", "origin")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	heads := make(map[string]string)
+	tags := make(map[string]string)
+
+	re := regexp.MustCompile(`^([a-z0-9]+)\s+refs/(heads|tags)/(.+)$`)
+	scanner := bufio.NewScanner(bytes.NewBufferString(rr.Stdout))
+	for scanner.Scan() {
+		txt := scanner.Text()
+		res := re.FindStringSubmatch(txt)
+		if len(res) == 0 {
+			continue
+		}
+		switch res[2] {
+		case "heads":
+			heads[res[3]] = res[1]
+		case "tags":
+			tags[res[3]] = res[1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, errors.E(op, errors.Git,
+			fmt.Errorf("error parsing response from git: %w", err))
+	}
+	return heads, tags, nil
+}
+
+// DefaultBranch looks up the HEAD symref with `git ls-remote`.
+func (*ExecBackend) DefaultBranch(ctx context.Context, dir string) (string, error) {
+	const op errors.Op = "gitutil.ExecBackend.DefaultBranch"
+	gitRunner, err := NewLocalGitRunner(dir)
+	if err != nil {
+		return "", err
+	}
+	rr, err := gitRunner.Run(ctx, "ls-remote", "--symref", "origin", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	if rr.Stdout == "" {
+		return "", errors.E(op, fmt.Errorf("unable to detect default branch in repo"))
+	}
+
+	re := regexp.MustCompile(`ref: refs/heads/([^\s/]+)\s*HEAD`)
+	match := re.FindStringSubmatch(rr.Stdout)
+	if len(match) != 2 {
+		return "", errors.E(op, errors.Git,
+			fmt.Errorf("unexpected response from git when determining default branch: %s", rr.Stdout))
+	}
+	return match[1], nil
+}
+
+// Fetch fetches the refs with `git fetch`.
+func (*ExecBackend) Fetch(ctx context.Context, dir string, opts FetchOptions) error {
+	gitRunner, err := NewLocalGitRunner(dir)
+	if err != nil {
+		return err
+	}
+	args := []string{"origin"}
+	if opts.Depth > 0 {
+		args = append(args, fmt.Sprintf("--depth=%d", opts.Depth))
+	}
+	if opts.Unshallow {
+		rr, err := gitRunner.Run(ctx, "rev-parse", "--is-shallow-repository")
+		if err == nil && strings.TrimSpace(rr.Stdout) == "true" {
+			args = append(args, "--unshallow")
+		}
+	}
+	args = append(args, opts.Refs...)
+	if opts.Verbose {
+		_, err = gitRunner.RunVerbose(ctx, "fetch", args...)
+	} else {
+		_, err = gitRunner.Run(ctx, "fetch", args...)
+	}
+	return err
+}
+
+// ResolveCommit resolves the revision with `git rev-parse`.
+func (*ExecBackend) ResolveCommit(ctx context.Context, dir, rev string) (string, error) {
+	gitRunner, err := NewLocalGitRunner(dir)
+	if err != nil {
+		return "", err
+	}
+	rr, err := gitRunner.Run(ctx, "rev-parse", rev+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(rr.Stdout), nil
+}
+
+// CountCommits counts the commits with `git rev-list`.
+func (*ExecBackend) CountCommits(ctx context.Context, dir, from, to string) (int, error) {
+	const op errors.Op = "gitutil.ExecBackend.CountCommits"
+	gitRunner, err := NewLocalGitRunner(dir)
+	if err != nil {
+		return 0, err
+	}
+	rr, err := gitRunner.Run(ctx, "rev-list", "--count", from+".."+to)
+	if err != nil {
+		return 0, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(rr.Stdout))
+	if err != nil {
+		return 0, errors.E(op, errors.Git,
+			fmt.Errorf("unexpected response from git when counting commits: %s", rr.Stdout))
+	}
+	return count, nil
+}
+
+// Checkout resets the worktree with `git reset --hard`.
+func (*ExecBackend) Checkout(ctx context.Context, dir, commit string) error {
+	gitRunner, err := NewLocalGitRunner(dir)
+	if err != nil {
+		return err
+	}
+	_, err = gitRunner.Run(ctx, "reset", "--hard", commit)
+	return err
+}
@@ -15,7 +15,6 @@
 package gitutil
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"crypto/md5"
@@ -35,6 +34,9 @@ import (
 	"github.com/GoogleContainerTools/kpt/pkg/printer"
 )
 
+// fullSHARegexp matches the full SHA of a commit.
+var fullSHARegexp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
 // RepoCacheDirEnv is the name of the environment variable that controls the cache directory
 // for remote repos.  Defaults to UserHomeDir/.kpt/repos if unspecified.
 const RepoCacheDirEnv = "KPT_CACHE_DIR"
@@ -150,6 +152,14 @@ func WithFetchedRefs(a map[string]bool) NewGitUpstreamRepoOption {
 	}
 }
 
+// WithBackend sets the backend performing the git operations on the cache
+// repo. Defaults to the backend returned by DefaultBackend.
+func WithBackend(b Backend) NewGitUpstreamRepoOption {
+	return func(g *GitUpstreamRepo) {
+		g.backend = b
+	}
+}
+
 // NewGitUpstreamRepo returns a new GitUpstreamRepo for an upstream package.
 func NewGitUpstreamRepo(ctx context.Context, uri string, opts ...NewGitUpstreamRepoOption) (*GitUpstreamRepo, error) {
 	const op errors.Op = "gitutil.NewGitUpstreamRepo"
@@ -162,6 +172,13 @@ func NewGitUpstreamRepo(ctx context.Context, uri string, opts ...NewGitUpstreamR
 	if g.fetchedRefs == nil {
 		g.fetchedRefs = map[string]bool{}
 	}
+	if g.backend == nil {
+		b, err := DefaultBackend()
+		if err != nil {
+			return nil, errors.E(op, errors.Repo(uri), err)
+		}
+		g.backend = b
+	}
 	if err := g.updateRefs(ctx); err != nil {
 		return nil, errors.E(op, errors.Repo(uri), err)
 	}
@@ -182,6 +199,9 @@ type GitUpstreamRepo struct {
 
 	// fetchedRefs keeps track of refs already fetched from remote
 	fetchedRefs map[string]bool
+
+	// backend performs the git operations on the cache repo.
+	backend Backend
 }
 
 func (gur *GitUpstreamRepo) GetFetchedRefs() []string {
@@ -202,12 +222,7 @@ func (gur *GitUpstreamRepo) updateRefs(ctx context.Context) error {
 		return errors.E(op, errors.Repo(gur.URI), err)
 	}
 
-	gitRunner, err := NewLocalGitRunner(repoCacheDir)
-	if err != nil {
-		return errors.E(op, errors.Repo(gur.URI), err)
-	}
-
-	rr, err := gitRunner.Run(ctx, "ls-remote", "--heads", "--tags", "--refs", "origin")
+	heads, tags, err := gur.backend.ListRefs(ctx, repoCacheDir)
 	if err != nil {
 		AmendGitExecError(err, func(e *GitExecError) {
 			e.Repo = gur.URI
@@ -216,29 +231,6 @@ func (gur *GitUpstreamRepo) updateRefs(ctx context.Context) error {
 		// consider exposing the error message from git to the user here.
 		return errors.E(op, errors.Repo(gur.URI), err)
 	}
-
-	heads := make(map[string]string)
-	tags := make(map[string]string)
-
-	re := regexp.MustCompile(`^([a-z0-9]+)\s+refs/(heads|tags)/(.+)$`)
-	scanner := bufio.NewScanner(bytes.NewBufferString(rr.Stdout))
-	for scanner.Scan() {
-		txt := scanner.Text()
-		res := re.FindStringSubmatch(txt)
-		if len(res) == 0 {
-			continue
-		}
-		switch res[2] {
-		case "heads":
-			heads[res[3]] = res[1]
-		case "tags":
-			tags[res[3]] = res[1]
-		}
-	}
-	if err := scanner.Err(); err != nil {
-		return errors.E(op, errors.Repo(gur.URI), errors.Git,
-			fmt.Errorf("error parsing response from git: %w", err))
-	}
 	gur.Heads = heads
 	gur.Tags = tags
 	return nil
@@ -256,6 +248,42 @@ func (gur *GitUpstreamRepo) GetRepo(ctx context.Context, refs []string) (string,
 	return dir, nil
 }
 
+// CommitsBehind returns the number of commits reachable from the commit to
+// and not from the commit from, i.e. how many commits from is behind to. It
+// fetches the history of both commits to the cache repo.
+func (gur *GitUpstreamRepo) CommitsBehind(ctx context.Context, from, to string) (int, error) {
+	const op errors.Op = "gitutil.CommitsBehind"
+	if from == to {
+		return 0, nil
+	}
+	dir, err := gur.cacheRepo(ctx, gur.URI, []string{}, []string{})
+	if err != nil {
+		return 0, errors.E(op, errors.Repo(gur.URI), err)
+	}
+	// The refs are fetched without their history by default, so the cache
+	// repo needs to be unshallowed to count the commits.
+	if err := gur.backend.Fetch(ctx, dir, FetchOptions{
+		Refs:      []string{from, to},
+		Unshallow: true,
+		Verbose:   true,
+	}); err != nil {
+		AmendGitExecError(err, func(e *GitExecError) {
+			e.Repo = gur.URI
+			e.Command = "fetch"
+		})
+		return 0, errors.E(op, errors.Git, errors.Repo(gur.URI), err)
+	}
+
+	count, err := gur.backend.CountCommits(ctx, dir, from, to)
+	if err != nil {
+		AmendGitExecError(err, func(e *GitExecError) {
+			e.Repo = gur.URI
+		})
+		return 0, errors.E(op, errors.Git, errors.Repo(gur.URI), err)
+	}
+	return count, nil
+}
+
 // GetDefaultBranch returns the name of the branch pointed to by the
 // HEAD symref. This is the default branch of the repository.
 func (gur *GitUpstreamRepo) GetDefaultBranch(ctx context.Context) (string, error) {
@@ -265,30 +293,32 @@ func (gur *GitUpstreamRepo) GetDefaultBranch(ctx context.Context) (string, error
 		return "", errors.E(op, errors.Repo(gur.URI), err)
 	}
 
-	gitRunner, err := NewLocalGitRunner(cacheRepo)
+	branch, err := gur.backend.DefaultBranch(ctx, cacheRepo)
 	if err != nil {
+		AmendGitExecError(err, func(e *GitExecError) {
+			e.Repo = gur.URI
+		})
 		return "", errors.E(op, errors.Repo(gur.URI), err)
 	}
+	return branch, nil
+}
 
-	rr, err := gitRunner.Run(ctx, "ls-remote", "--symref", "origin", "HEAD")
+// Checkout resets the worktree of the cache repo to the commit, which must
+// have been fetched with GetRepo.
+func (gur *GitUpstreamRepo) Checkout(ctx context.Context, commit string) error {
+	const op errors.Op = "gitutil.Checkout"
+	dir, err := gur.cacheRepo(ctx, gur.URI, []string{}, []string{})
 	if err != nil {
+		return errors.E(op, errors.Repo(gur.URI), err)
+	}
+	if err := gur.backend.Checkout(ctx, dir, commit); err != nil {
 		AmendGitExecError(err, func(e *GitExecError) {
 			e.Repo = gur.URI
+			e.Ref = commit
 		})
-		return "", errors.E(op, errors.Repo(gur.URI), err)
-	}
-	if rr.Stdout == "" {
-		return "", errors.E(op, errors.Repo(gur.URI),
-			fmt.Errorf("unable to detect default branch in repo"))
+		return errors.E(op, errors.Git, errors.Repo(gur.URI), err)
 	}
-
-	re := regexp.MustCompile(`ref: refs/heads/([^\s/]+)\s*HEAD`)
-	match := re.FindStringSubmatch(rr.Stdout)
-	if len(match) != 2 {
-		return "", errors.E(op, errors.Repo(gur.URI), errors.Git,
-			fmt.Errorf("unexpected response from git when determining default branch: %s", rr.Stdout))
-	}
-	return match[1], nil
+	return nil
 }
 
 // ResolveBranch resolves the branch to a commit SHA. This happens based on the
@@ -327,6 +357,19 @@ func (gur *GitUpstreamRepo) ResolveRef(ref string) (string, bool) {
 	return gur.ResolveTag(ref)
 }
 
+// qualifiedRef returns the full name of the ref if it is a branch or a tag
+// in the upstream repo, or the ref itself otherwise. Branches take
+// precedence over tags, like in ResolveRef.
+func (gur *GitUpstreamRepo) qualifiedRef(ref string) string {
+	if _, found := gur.ResolveBranch(ref); found {
+		return "refs/heads/" + strings.TrimPrefix(ref, "refs/heads/")
+	}
+	if _, found := gur.ResolveTag(ref); found {
+		return "refs/tags/" + strings.TrimPrefix(ref, "refs/tags/")
+	}
+	return ref
+}
+
 // getRepoDir returns the cache directory name for a remote repo
 // This takes the md5 hash of the repo uri and then base32 (or hex for Windows to shorten dir)
 // encodes it to make sure it doesn't contain characters that isn't legal in directory names.
@@ -369,43 +412,22 @@ func (gur *GitUpstreamRepo) cacheRepo(ctx context.Context, uri string, requiredR
 	}
 
 	// create the repo directory if it doesn't exist yet
-	gitRunner, err := NewLocalGitRunner(kptCacheDir)
-	if err != nil {
-		return "", errors.E(op, errors.Repo(uri), err)
-	}
-	uriSha := gur.getRepoDir(uri)
-	repoCacheDir := filepath.Join(kptCacheDir, uriSha)
+	repoCacheDir := filepath.Join(kptCacheDir, gur.getRepoDir(uri))
 	if _, err := os.Stat(repoCacheDir); os.IsNotExist(err) {
-		if _, err := gitRunner.Run(ctx, "init", uriSha); err != nil {
-			AmendGitExecError(err, func(e *GitExecError) {
-				e.Repo = uri
-			})
-			return "", errors.E(op, errors.Git, fmt.Errorf("error running `git init`: %w", err))
-		}
-		gitRunner.Dir = repoCacheDir
-		if _, err = gitRunner.Run(ctx, "remote", "add", "origin", uri); err != nil {
+		if err := gur.backend.Init(ctx, repoCacheDir, uri); err != nil {
 			AmendGitExecError(err, func(e *GitExecError) {
 				e.Repo = uri
 			})
-			return "", errors.E(op, errors.Git, fmt.Errorf("error adding origin remote: %w", err))
+			return "", errors.E(op, errors.Git, err)
 		}
-	} else {
-		gitRunner.Dir = repoCacheDir
 	}
 
 loop:
 	for i := range requiredRefs {
 		s := requiredRefs[i]
-		// Check if we can verify the ref. This will output a full commit sha if
-		// either the ref (short commit, tag, branch) can be resolved to a full
-		// commit sha, or if the provided ref is already a valid full commit sha (note
-		// that this will happen even if the commit doesn't exist in the local repo).
-		// We ignore the error here since an error just means the ref didn't exist,
-		// which we detect by checking the output to stdout.
-		rr, _ := gitRunner.Run(ctx, "rev-parse", "--verify", "-q", s)
-		// If the output is the same as the ref, then the ref was already a full
-		// commit sha.
-		validFullSha := s == strings.TrimSpace(rr.Stdout)
+		// A full commit sha can be fetched directly, like a branch or a tag,
+		// even if the commit doesn't exist in the local repo.
+		validFullSha := fullSHARegexp.MatchString(s)
 		_, resolved := gur.ResolveRef(s)
 		// check if ref was previously fetched
 		// we use the ref s as the cache key
@@ -417,7 +439,11 @@ loop:
 		case resolved || validFullSha:
 			// If the ref references a branch or a tag, or is a valid commit
 			// sha and has not already been fetched, we can fetch just a single commit.
-			if _, err := gitRunner.RunVerbose(ctx, "fetch", "origin", "--depth=1", s); err != nil {
+			if err := gur.backend.Fetch(ctx, repoCacheDir, FetchOptions{
+				Refs:    []string{gur.qualifiedRef(s)},
+				Depth:   1,
+				Verbose: true,
+			}); err != nil {
 				AmendGitExecError(err, func(e *GitExecError) {
 					e.Repo = uri
 					e.Command = "fetch"
@@ -429,8 +455,9 @@ loop:
 			gur.fetchedRefs[s] = true
 		default:
 			// In other situations (like a short commit sha), we have to do
-			// a full fetch from the remote.
-			if _, err := gitRunner.RunVerbose(ctx, "fetch", "origin"); err != nil {
+			// a full fetch from the remote, including the history missing
+			// from the refs previously fetched with a depth.
+			if err := gur.backend.Fetch(ctx, repoCacheDir, FetchOptions{Unshallow: true, Verbose: true}); err != nil {
 				AmendGitExecError(err, func(e *GitExecError) {
 					e.Repo = uri
 					e.Command = "fetch"
@@ -438,7 +465,7 @@ loop:
 				return "", errors.E(op, errors.Git, fmt.Errorf(
 					"error running `git fetch` for origin: %w", err))
 			}
-			if _, err = gitRunner.Run(ctx, "show", s); err != nil {
+			if _, err := gur.backend.ResolveCommit(ctx, repoCacheDir, s); err != nil {
 				AmendGitExecError(err, func(e *GitExecError) {
 					e.Repo = uri
 					e.Ref = s
@@ -455,7 +482,9 @@ loop:
 
 	var found bool
 	for _, s := range optionalRefs {
-		if _, err := gitRunner.Run(ctx, "fetch", "origin", s); err == nil {
+		if err := gur.backend.Fetch(ctx, repoCacheDir, FetchOptions{
+			Refs: []string{gur.qualifiedRef(s)},
+		}); err == nil {
 			found = true
 		}
 	}
@@ -90,6 +90,7 @@ func TestLocalGitRunner(t *testing.T) {
 }
 
 func TestNewGitUpstreamRepo_noRepo(t *testing.T) {
+	testutil.UseExecBackend(t)
 	dir := t.TempDir()
 
 	_, err := NewGitUpstreamRepo(fake.CtxWithDefaultPrinter(), dir)
@@ -0,0 +1,400 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/pkg/printer"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+const (
+	originRemote = "origin"
+
+	// infiniteDepth is the depth git uses to fetch the whole history of a
+	// shallow repo.
+	infiniteDepth = 2147483647
+)
+
+// GoBackend is a Backend implemented in Go with go-git. It doesn't depend on
+// the version and the configuration of git on the host. Note that the repos
+// on the local filesystem are still served by git-upload-pack.
+//
+// The credentials of the http(s) remotes are read from the .netrc file, since
+// the credential helpers of git aren't available. The ssh remotes use the
+// ssh agent.
+type GoBackend struct {
+	// AuthFallback, if set, runs the operations on the origin remote which
+	// fail because the GoBackend doesn't have the credentials the remote
+	// requires, e.g. since they come from a git credential helper or the
+	// ssh configuration of the host.
+	AuthFallback Backend
+}
+
+// Init creates the repo and adds the origin remote.
+func (*GoBackend) Init(_ context.Context, dir, uri string) error {
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		return fmt.Errorf("error initializing repo: %w", goGitError("init", []string{dir}, err))
+	}
+	if _, err := r.CreateRemote(&config.RemoteConfig{
+		Name: originRemote,
+		URLs: []string{uri},
+	}); err != nil {
+		return fmt.Errorf("error adding origin remote: %w",
+			goGitError("remote", []string{"add", originRemote, uri}, err))
+	}
+	return nil
+}
+
+// ListRefs lists the refs advertised by the origin remote.
+func (b *GoBackend) ListRefs(ctx context.Context, dir string) (map[string]string, map[string]string, error) {
+	refs, err := b.listRemote(ctx, dir)
+	if err != nil {
+		if b.needsAuthFallback(ctx, dir, err) {
+			return b.AuthFallback.ListRefs(ctx, dir)
+		}
+		return nil, nil, goGitError("ls-remote", []string{"--heads", "--tags", "--refs", originRemote}, err)
+	}
+
+	heads := make(map[string]string)
+	tags := make(map[string]string)
+	for _, ref := range refs {
+		if ref.Type() != plumbing.HashReference {
+			continue
+		}
+		switch name := ref.Name(); {
+		case name.IsBranch():
+			heads[strings.TrimPrefix(name.String(), "refs/heads/")] = ref.Hash().String()
+		case name.IsTag():
+			tags[strings.TrimPrefix(name.String(), "refs/tags/")] = ref.Hash().String()
+		}
+	}
+	return heads, tags, nil
+}
+
+// DefaultBranch looks up the HEAD symref advertised by the origin remote.
+func (b *GoBackend) DefaultBranch(ctx context.Context, dir string) (string, error) {
+	const op errors.Op = "gitutil.GoBackend.DefaultBranch"
+	refs, err := b.listRemote(ctx, dir)
+	if err != nil {
+		if b.needsAuthFallback(ctx, dir, err) {
+			return b.AuthFallback.DefaultBranch(ctx, dir)
+		}
+		return "", goGitError("ls-remote", []string{"--symref", originRemote, "HEAD"}, err)
+	}
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference && ref.Target().IsBranch() {
+			return strings.TrimPrefix(ref.Target().String(), "refs/heads/"), nil
+		}
+	}
+	return "", errors.E(op, fmt.Errorf("unable to detect default branch in repo"))
+}
+
+// listRemote returns the refs advertised by the origin remote. An empty
+// remote repo doesn't advertise any refs.
+func (*GoBackend) listRemote(ctx context.Context, dir string) ([]*plumbing.Reference, error) {
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := r.Remote(originRemote)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := remote.ListContext(ctx, &git.ListOptions{
+		Auth: netrcAuth(remote.Config().URLs[0]),
+	})
+	if err != nil && !errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// Fetch fetches each of the refs. If the repo is shallow, fetching without
+// a depth fetches the whole history of the refs, like with
+// FetchOptions.Unshallow, since go-git only tells the remote which commits
+// are missing when fetching with a depth.
+func (b *GoBackend) Fetch(ctx context.Context, dir string, opts FetchOptions) error {
+	err := b.fetch(ctx, dir, opts)
+	if b.needsAuthFallback(ctx, dir, err) {
+		return b.AuthFallback.Fetch(ctx, dir, opts)
+	}
+	return err
+}
+
+// fetch fetches the refs without the AuthFallback.
+func (*GoBackend) fetch(ctx context.Context, dir string, opts FetchOptions) error {
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return goGitError("fetch", append([]string{originRemote}, opts.Refs...), err)
+	}
+	shallows, err := r.Storer.Shallow()
+	if err != nil {
+		return goGitError("fetch", append([]string{originRemote}, opts.Refs...), err)
+	}
+	remote, err := r.Remote(originRemote)
+	if err != nil {
+		return goGitError("fetch", append([]string{originRemote}, opts.Refs...), err)
+	}
+
+	fetchOpts := git.FetchOptions{
+		RemoteName: originRemote,
+		Depth:      opts.Depth,
+		Tags:       git.NoTags,
+		Force:      true,
+		Auth:       netrcAuth(remote.Config().URLs[0]),
+	}
+	if opts.Depth == 0 && len(shallows) > 0 {
+		fetchOpts.Depth = infiniteDepth
+	}
+	if opts.Verbose {
+		fetchOpts.Progress = printer.FromContextOrDie(ctx).ErrStream()
+	}
+
+	if len(opts.Refs) == 0 {
+		fetchOpts.RefSpecs = []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"}
+		fetchOpts.Tags = git.TagFollowing
+		if err := fetchRefSpecs(ctx, r, &fetchOpts); err != nil {
+			return goGitError("fetch", []string{originRemote}, err)
+		}
+		return removeFetchedShallows(r)
+	}
+
+	for _, ref := range opts.Refs {
+		o := fetchOpts
+		o.RefSpecs = []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:FETCH_HEAD", ref))}
+		err := fetchRefSpecs(ctx, r, &o)
+		if errors.Is(err, git.ErrExactSHA1NotSupported) {
+			// The remote only allows fetching the commits referenced by a
+			// branch or a tag, so we have to fetch all of them.
+			o.RefSpecs = []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"}
+			o.Tags = git.AllTags
+			o.Depth = 0
+			if len(shallows) > 0 {
+				o.Depth = infiniteDepth
+			}
+			err = fetchRefSpecs(ctx, r, &o)
+		}
+		if err != nil {
+			return goGitError("fetch", []string{originRemote, ref}, err)
+		}
+	}
+	return removeFetchedShallows(r)
+}
+
+// fetchRefSpecs fetches from the remote, ignoring the error if everything
+// was already fetched.
+func fetchRefSpecs(ctx context.Context, r *git.Repository, o *git.FetchOptions) error {
+	if err := r.FetchContext(ctx, o); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return err
+	}
+	return nil
+}
+
+// removeFetchedShallows removes the commits whose parents have been fetched
+// from the shallow commits of the repo.
+func removeFetchedShallows(r *git.Repository) error {
+	shallows, err := r.Storer.Shallow()
+	if err != nil || len(shallows) == 0 {
+		return err
+	}
+	var remaining []plumbing.Hash
+	for _, h := range shallows {
+		c, err := r.CommitObject(h)
+		if err != nil {
+			remaining = append(remaining, h)
+			continue
+		}
+		for _, p := range c.ParentHashes {
+			if r.Storer.HasEncodedObject(p) != nil {
+				remaining = append(remaining, h)
+				break
+			}
+		}
+	}
+	return r.Storer.SetShallow(remaining)
+}
+
+// needsAuthFallback returns true if the operation on the origin remote of
+// the repo failed with the error since the remote requires credentials, and
+// the AuthFallback can run it instead. go-git doesn't support all the ssh
+// configuration of the host, so any unknown error from an ssh remote is
+// retried.
+func (b *GoBackend) needsAuthFallback(ctx context.Context, dir string, err error) bool {
+	if err == nil || b.AuthFallback == nil || ctx.Err() != nil {
+		return false
+	}
+	typ := goGitErrorType(err)
+	var gitExecErr *GitExecError
+	if errors.As(err, &gitExecErr) {
+		typ = gitExecErr.Type
+	}
+	switch typ {
+	case HTTPSAuthRequired:
+		return true
+	case Unknown:
+		r, err := git.PlainOpen(dir)
+		if err != nil {
+			return false
+		}
+		remote, err := r.Remote(originRemote)
+		if err != nil {
+			return false
+		}
+		ep, err := transport.NewEndpoint(remote.Config().URLs[0])
+		return err == nil && ep.Protocol == "ssh"
+	}
+	return false
+}
+
+// ResolveCommit resolves the revision in the repo.
+func (*GoBackend) ResolveCommit(_ context.Context, dir, rev string) (string, error) {
+	r, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return "", goGitError("rev-parse", []string{rev}, err)
+	}
+	h, err := r.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return "", goGitError("rev-parse", []string{rev}, err)
+	}
+	return h.String(), nil
+}
+
+// CountCommits walks the history of both commits in the repo. The commits
+// missing from a shallow repo aren't counted.
+func (*GoBackend) CountCommits(_ context.Context, dir, from, to string) (int, error) {
+	args := []string{"--count", from + ".." + to}
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return 0, goGitError("rev-list", args, err)
+	}
+	var hashes []plumbing.Hash
+	for _, rev := range []string{from, to} {
+		h, err := r.ResolveRevision(plumbing.Revision(rev))
+		if err != nil {
+			return 0, goGitError("rev-list", args, err)
+		}
+		hashes = append(hashes, *h)
+	}
+	reachable, err := ancestors(r, hashes[0], nil)
+	if err != nil {
+		return 0, goGitError("rev-list", args, err)
+	}
+	behind, err := ancestors(r, hashes[1], reachable)
+	if err != nil {
+		return 0, goGitError("rev-list", args, err)
+	}
+	return len(behind), nil
+}
+
+// ancestors returns the commit and its ancestors in the repo, but the
+// excluded commits and their ancestors.
+func ancestors(r *git.Repository, h plumbing.Hash, excluded map[plumbing.Hash]bool) (map[plumbing.Hash]bool, error) {
+	commits := make(map[plumbing.Hash]bool)
+	queue := []plumbing.Hash{h}
+	for len(queue) > 0 {
+		h, queue = queue[0], queue[1:]
+		if commits[h] || excluded[h] {
+			continue
+		}
+		c, err := r.CommitObject(h)
+		if errors.Is(err, plumbing.ErrObjectNotFound) && len(commits) > 0 {
+			// the parents of the shallow commits are missing
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		commits[h] = true
+		queue = append(queue, c.ParentHashes...)
+	}
+	return commits, nil
+}
+
+// Checkout resets the worktree with a hard reset on a detached HEAD, which
+// doesn't create any local branch.
+func (*GoBackend) Checkout(_ context.Context, dir, commit string) error {
+	args := []string{"--hard", commit}
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return goGitError("reset", args, err)
+	}
+	h, err := r.ResolveRevision(plumbing.Revision(commit))
+	if err != nil {
+		return goGitError("reset", args, err)
+	}
+	// Detach HEAD first, since go-git can't reset a branch which doesn't
+	// exist yet, e.g. in a new repo.
+	if err := r.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, *h)); err != nil {
+		return goGitError("reset", args, err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return goGitError("reset", args, err)
+	}
+	if err := w.Reset(&git.ResetOptions{Commit: *h, Mode: git.HardReset}); err != nil {
+		return goGitError("reset", args, err)
+	}
+	return nil
+}
+
+// goGitError returns a GitExecError for an error returned by go-git when
+// running the equivalent of the git command, so that it is reported like
+// the errors of the ExecBackend.
+func goGitError(command string, args []string, err error) error {
+	const op errors.Op = "gitutil.goGitError"
+	return errors.E(op, errors.Git, &GitExecError{
+		Type:    goGitErrorType(err),
+		Args:    args,
+		Command: command,
+		Err:     err,
+	})
+}
+
+// goGitErrorType categorizes an error returned by go-git.
+func goGitErrorType(err error) GitExecErrorType {
+	var unexpectedErr *plumbing.UnexpectedError
+	if errors.As(err, &unexpectedErr) {
+		err = unexpectedErr.Err
+	}
+	var permanentErr *plumbing.PermanentError
+	if errors.As(err, &permanentErr) {
+		err = permanentErr.Err
+	}
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, plumbing.ErrReferenceNotFound),
+		errors.Is(err, plumbing.ErrObjectNotFound),
+		errors.Is(err, git.NoMatchingRefSpecError{}):
+		return UnknownReference
+	case errors.Is(err, transport.ErrAuthenticationRequired),
+		errors.Is(err, transport.ErrAuthorizationFailed):
+		return HTTPSAuthRequired
+	case errors.As(err, &dnsErr):
+		return RepositoryUnavailable
+	case errors.Is(err, transport.ErrRepositoryNotFound):
+		return RepositoryNotFound
+	}
+	return Unknown
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// netrcEnv is the name of the environment variable that overrides the path
+// of the .netrc file, like for curl and the go command.
+const netrcEnv = "NETRC"
+
+// netrcLine is a machine of a .netrc file.
+type netrcLine struct {
+	machine  string
+	login    string
+	password string
+}
+
+// netrcAuth returns the credentials of the .netrc file for the uri, or nil
+// if the uri isn't an http(s) url or the file doesn't have any credentials
+// for its host. The credentials in the uri take precedence.
+func netrcAuth(uri string) transport.AuthMethod {
+	ep, err := transport.NewEndpoint(uri)
+	if err != nil || (ep.Protocol != "http" && ep.Protocol != "https") || ep.User != "" {
+		return nil
+	}
+	path, err := netrcPath()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	for _, l := range parseNetrc(string(data)) {
+		// the default machine is always the last one
+		if l.machine == ep.Host || l.machine == "" {
+			return &http.BasicAuth{Username: l.login, Password: l.password}
+		}
+	}
+	return nil
+}
+
+// netrcPath returns the path of the .netrc file, which is named _netrc on
+// Windows.
+func netrcPath() (string, error) {
+	if path := os.Getenv(netrcEnv); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	name := ".netrc"
+	if runtime.GOOS == "windows" {
+		name = "_netrc"
+	}
+	return filepath.Join(home, name), nil
+}
+
+// parseNetrc parses the machines of a .netrc file. The default machine has
+// an empty name, and the machines after it are ignored. The macros are
+// skipped.
+func parseNetrc(data string) []netrcLine {
+	var tokens []string
+	inMacro := false
+	for _, line := range strings.Split(data, "\n") {
+		if inMacro {
+			// a macro ends with an empty line
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		f := strings.Fields(line)
+		for i, t := range f {
+			if t == "macdef" {
+				f, inMacro = f[:i], true
+				break
+			}
+		}
+		tokens = append(tokens, f...)
+	}
+
+	var lines []netrcLine
+	var l *netrcLine
+	for i := 0; i < len(tokens); i++ {
+		switch t := tokens[i]; t {
+		case "machine", "default":
+			if l != nil && l.machine == "" {
+				return lines
+			}
+			lines = append(lines, netrcLine{})
+			l = &lines[len(lines)-1]
+			if t == "machine" && i+1 < len(tokens) {
+				i++
+				l.machine = tokens[i]
+			}
+		case "login", "password":
+			if l == nil || i+1 >= len(tokens) {
+				continue
+			}
+			i++
+			if t == "login" {
+				l.login = tokens[i]
+			} else {
+				l.password = tokens[i]
+			}
+		}
+	}
+	return lines
+}
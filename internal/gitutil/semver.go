@@ -0,0 +1,341 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// versionConstraintRegexp matches refs that are version constraints, i.e.
+// an optional tag prefix followed by a comparison operator and a version.
+var versionConstraintRegexp = regexp.MustCompile(`^([^\s~^<>=|]*?)((?:[~^=]|[<>]=?)\s*v?[0-9xX*].*)$`)
+
+// IsVersionConstraint returns true if the ref is a version constraint
+// rather than a branch, tag or commit.
+func IsVersionConstraint(ref string) bool {
+	return versionConstraintRegexp.MatchString(ref)
+}
+
+// VersionConstraint is a semantic version constraint on the tags of a repo,
+// e.g. `~1.4`, `>=2.0.0 <3` or `package-a/v^1.2`.
+type VersionConstraint struct {
+	// Prefix is the prefix of the matching tags, before their version,
+	// e.g. `package-a/v`. If it is empty, the tags are the versions with
+	// an optional `v` prefix.
+	Prefix string
+
+	// ranges are the alternatives of the constraint, separated by `||`.
+	// A version matches a range if it matches all its comparators.
+	ranges [][]comparator
+
+	constraint string
+}
+
+// comparator compares versions to a canonical version with an operator
+// among =, <, <=, > and >=.
+type comparator struct {
+	op      string
+	version string
+}
+
+// ParseVersionConstraint parses the ref as a version constraint. The
+// constraint is made of ranges separated by `||`, each made of comparators
+// separated by spaces. The comparators are:
+//   - `=1.2.3`, `1.2` or `1.x`: the versions equal to the version, or matching
+//     its specified components.
+//   - `>`, `>=`, `<` and `<=`: the versions greater or lower than the version.
+//   - `~1.2.3`: the patch versions from 1.2.3, i.e. `>=1.2.3 <1.3.0`.
+//   - `^1.2.3`: the versions compatible with 1.2.3, i.e. `>=1.2.3 <2.0.0`.
+func ParseVersionConstraint(ref string) (*VersionConstraint, error) {
+	match := versionConstraintRegexp.FindStringSubmatch(ref)
+	if match == nil {
+		return nil, fmt.Errorf("%q is not a version constraint", ref)
+	}
+	c := &VersionConstraint{Prefix: match[1], constraint: match[2]}
+	for _, r := range strings.Split(match[2], "||") {
+		var comparators []comparator
+		fields := strings.Fields(strings.ReplaceAll(r, ",", " "))
+		for i := 0; i < len(fields); i++ {
+			f := fields[i]
+			// the operator may be separated from its version, e.g. `>= 1.2`
+			if strings.TrimLeft(f, "~^<>=") == "" && i+1 < len(fields) {
+				i++
+				f += fields[i]
+			}
+			cs, err := parseComparator(f)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version constraint %q: %w", ref, err)
+			}
+			comparators = append(comparators, cs...)
+		}
+		if len(comparators) == 0 {
+			return nil, fmt.Errorf("invalid version constraint %q: empty range", ref)
+		}
+		c.ranges = append(c.ranges, comparators)
+	}
+	return c, nil
+}
+
+// String returns the constraint without its prefix.
+func (c *VersionConstraint) String() string {
+	return c.constraint
+}
+
+// Match returns true if the version, with or without a `v` prefix, matches
+// the constraint. Pre-release versions only match constraints with
+// pre-release versions.
+func (c *VersionConstraint) Match(version string) bool {
+	v := canonicalSemver(version)
+	if !semver.IsValid(v) {
+		return false
+	}
+	for _, r := range c.ranges {
+		if matchRange(r, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRange returns true if the canonical version matches all the
+// comparators of the range.
+func matchRange(r []comparator, v string) bool {
+	prerelease := false
+	for _, cmp := range r {
+		if !cmp.match(v) {
+			return false
+		}
+		if semver.Prerelease(cmp.version) != "" {
+			prerelease = true
+		}
+	}
+	return semver.Prerelease(v) == "" || prerelease
+}
+
+func (c comparator) match(v string) bool {
+	n := semver.Compare(v, c.version)
+	switch c.op {
+	case "<":
+		return n < 0
+	case "<=":
+		return n <= 0
+	case ">":
+		return n > 0
+	case ">=":
+		return n >= 0
+	default:
+		return n == 0
+	}
+}
+
+// MatchTag returns the version of the tag if the tag has the prefix of the
+// constraint and a version matching the constraint.
+func (c *VersionConstraint) MatchTag(prefix, tag string) (string, bool) {
+	if !strings.HasPrefix(tag, prefix+c.Prefix) {
+		return "", false
+	}
+	version := strings.TrimPrefix(tag, prefix+c.Prefix)
+	if !c.Match(version) {
+		return "", false
+	}
+	return canonicalSemver(version), true
+}
+
+// ResolveVersion returns the tag with the highest version matching the
+// constraint, among the tags with the prefix followed by the prefix of the
+// constraint. If no tag matches the constraint, the last return value will
+// be false.
+func (gur *GitUpstreamRepo) ResolveVersion(prefix string, c *VersionConstraint) (string, bool) {
+	var tag, version string
+	for t := range gur.Tags {
+		v, found := c.MatchTag(prefix, t)
+		if !found {
+			continue
+		}
+		// pick the lowest tag between equal versions, e.g. `1.2` and
+		// `v1.2.0`, so that the resolution doesn't depend on the order of
+		// the map
+		if n := semver.Compare(v, version); tag == "" || n > 0 || (n == 0 && t < tag) {
+			tag, version = t, v
+		}
+	}
+	return tag, tag != ""
+}
+
+// anyVersion matches all the versions but the pre-release versions.
+var anyVersion = &VersionConstraint{ranges: [][]comparator{{{">=", "v0.0.0"}}}, constraint: ">=0.0.0"}
+
+// LatestVersion returns the tag with the highest version among the tags
+// with the prefix. If no tag with the prefix has a version, the last return
+// value will be false.
+func (gur *GitUpstreamRepo) LatestVersion(prefix string) (string, bool) {
+	return gur.ResolveVersion(prefix, anyVersion)
+}
+
+// versionTagRegexp matches tags ending with a version, e.g. `package-a/v1.2`.
+var versionTagRegexp = regexp.MustCompile(`^(.*?)(v?[0-9]+(?:\.[0-9]+){0,2}(?:-[0-9A-Za-z.-]+)?)$`)
+
+// SplitVersionTag splits the tag into its prefix and its canonical version,
+// e.g. `package-a/` and `v1.2.0` for `package-a/v1.2`. If the tag doesn't
+// end with a version, the last return value will be false.
+func SplitVersionTag(tag string) (string, string, bool) {
+	match := versionTagRegexp.FindStringSubmatch(tag)
+	if match == nil {
+		return "", "", false
+	}
+	version := canonicalSemver(match[2])
+	if version == "" {
+		return "", "", false
+	}
+	return match[1], version, true
+}
+
+// PackageTagPrefixes returns the prefixes of the tags specific to the
+// package in the directory of a repo, from the most specific, e.g.
+// `a/b/` and `a/` for the directory `/a/b`.
+func PackageTagPrefixes(directory string) []string {
+	var prefixes []string
+	ps := strings.Split(directory, "/")
+	for len(ps) != 0 {
+		if p := strings.TrimLeft(path.Join(ps...), "/"); p != "" {
+			prefixes = append(prefixes, p+"/")
+		}
+		ps = ps[:len(ps)-1]
+	}
+	return prefixes
+}
+
+// parseComparator parses a comparator of a range into comparators on
+// canonical versions.
+func parseComparator(s string) ([]comparator, error) {
+	op := s[:len(s)-len(strings.TrimLeft(s, "~^<>="))]
+	parts, prerelease, err := parsePartialVersion(strings.TrimSpace(s[len(op):]))
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		// a wildcard matches any version
+		if op == "<" || op == ">" {
+			return nil, fmt.Errorf("invalid comparator %q", s)
+		}
+		return []comparator{{">=", "v0.0.0"}}, nil
+	}
+	full := len(parts) == 3
+	lower := versionString(parts, prerelease)
+
+	switch op {
+	case "", "=":
+		if full {
+			return []comparator{{"=", lower}}, nil
+		}
+		return lowerAndUpper(lower, bump(parts, len(parts)-1)), nil
+	case ">=":
+		return []comparator{{">=", lower}}, nil
+	case ">":
+		if full {
+			return []comparator{{">", lower}}, nil
+		}
+		return []comparator{{">=", bump(parts, len(parts)-1)}}, nil
+	case "<":
+		return []comparator{{"<", lower}}, nil
+	case "<=":
+		if full {
+			return []comparator{{"<=", lower}}, nil
+		}
+		return []comparator{{"<", bump(parts, len(parts)-1)}}, nil
+	case "~":
+		if len(parts) == 1 {
+			return lowerAndUpper(lower, bump(parts, 0)), nil
+		}
+		return lowerAndUpper(lower, bump(parts, 1)), nil
+	case "^":
+		// the first non-zero component is the one that can't change
+		i := 0
+		for i < len(parts)-1 && parts[i] == 0 {
+			i++
+		}
+		return lowerAndUpper(lower, bump(parts, i)), nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
+
+func lowerAndUpper(lower, upper string) []comparator {
+	return []comparator{{">=", lower}, {"<", upper}}
+}
+
+// parsePartialVersion parses a version with up to 3 components, which may
+// end with a wildcard, and an optional pre-release.
+func parsePartialVersion(s string) ([]int, string, error) {
+	version := strings.TrimPrefix(s, "v")
+	prerelease := ""
+	if i := strings.Index(version, "-"); i != -1 {
+		version, prerelease = version[:i], version[i:]
+	}
+	var parts []int
+	for _, p := range strings.Split(version, ".") {
+		if p == "x" || p == "X" || p == "*" {
+			break
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, "", fmt.Errorf("invalid version %q", s)
+		}
+		parts = append(parts, n)
+	}
+	if len(parts) > 3 || (prerelease != "" && len(parts) != 3) {
+		return nil, "", fmt.Errorf("invalid version %q", s)
+	}
+	if len(parts) == 0 {
+		return nil, "", nil
+	}
+	if !semver.IsValid(versionString(parts, prerelease)) {
+		return nil, "", fmt.Errorf("invalid version %q", s)
+	}
+	return parts, prerelease, nil
+}
+
+// bump returns the lowest version greater than the versions matching the
+// components of the version up to i.
+func bump(parts []int, i int) string {
+	bumped := make([]int, i+1)
+	copy(bumped, parts[:i+1])
+	bumped[i]++
+	return versionString(bumped, "")
+}
+
+// versionString returns the canonical version of the components, padded with
+// zeros.
+func versionString(parts []int, prerelease string) string {
+	p := []string{"0", "0", "0"}
+	for i, n := range parts {
+		p[i] = strconv.Itoa(n)
+	}
+	return "v" + strings.Join(p, ".") + prerelease
+}
+
+// canonicalSemver returns the version with a `v` prefix as expected by the
+// semver package.
+func canonicalSemver(version string) string {
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	return semver.Canonical(version)
+}
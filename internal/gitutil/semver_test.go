@@ -0,0 +1,150 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil_test
+
+import (
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsVersionConstraint(t *testing.T) {
+	for ref, expected := range map[string]bool{
+		"main":                false,
+		"v1.2.3":              false,
+		"1.x":                 false,
+		"~1.4":                true,
+		">=2.0.0 <3":          true,
+		"package-a/v^1.2":     true,
+		"refs/tags/v1":        false,
+		"feature/new-version": false,
+	} {
+		assert.Equal(t, expected, IsVersionConstraint(ref), ref)
+	}
+}
+
+func TestVersionConstraint_Match(t *testing.T) {
+	testCases := map[string]struct {
+		matching    []string
+		notMatching []string
+	}{
+		"~1.4": {
+			matching:    []string{"1.4.0", "v1.4.9"},
+			notMatching: []string{"1.3.9", "1.5.0", "1.4.1-rc.1"},
+		},
+		"~1": {
+			matching:    []string{"1.0.0", "1.9.0"},
+			notMatching: []string{"2.0.0", "0.9.0"},
+		},
+		"^1.2.3": {
+			matching:    []string{"1.2.3", "1.9.0"},
+			notMatching: []string{"1.2.2", "2.0.0"},
+		},
+		"^0.2": {
+			matching:    []string{"0.2.0", "0.2.5"},
+			notMatching: []string{"0.3.0", "1.0.0"},
+		},
+		">=2.0.0 <3": {
+			matching:    []string{"2.0.0", "v2.9.9"},
+			notMatching: []string{"1.9.9", "3.0.0"},
+		},
+		">= 1.2, <= 1.3": {
+			matching:    []string{"1.2.0", "1.3.7"},
+			notMatching: []string{"1.1.0", "1.4.0"},
+		},
+		">1.2 || =1.0.0": {
+			matching:    []string{"1.0.0", "1.3.0"},
+			notMatching: []string{"1.0.1", "1.2.9"},
+		},
+		"=1.x": {
+			matching:    []string{"1.0.0", "1.9.1"},
+			notMatching: []string{"2.0.0"},
+		},
+		">=1.0.0-rc.1": {
+			matching:    []string{"1.0.0-rc.2", "1.0.0"},
+			notMatching: []string{"1.0.0-alpha", "not-a-version"},
+		},
+	}
+
+	for constraint, tc := range testCases {
+		t.Run(constraint, func(t *testing.T) {
+			c, err := ParseVersionConstraint(constraint)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			for _, v := range tc.matching {
+				assert.True(t, c.Match(v), v)
+			}
+			for _, v := range tc.notMatching {
+				assert.False(t, c.Match(v), v)
+			}
+		})
+	}
+}
+
+func TestParseVersionConstraint_invalid(t *testing.T) {
+	for _, ref := range []string{"main", "~1.2.3.4", "^1.a", ">=1 ||", "<*"} {
+		_, err := ParseVersionConstraint(ref)
+		assert.Error(t, err, ref)
+	}
+}
+
+func TestGitUpstreamRepo_ResolveVersion(t *testing.T) {
+	gur := &GitUpstreamRepo{
+		Tags: map[string]string{
+			"v1.4.0":               "a",
+			"v1.4.2":               "b",
+			"v1.5.0":               "c",
+			"package-a/v1.4.7":     "d",
+			"package-a/v2.0.0":     "e",
+			"package-a/v2.1.0-rc1": "f",
+		},
+	}
+	testCases := map[string]struct {
+		prefix   string
+		ref      string
+		expected string
+	}{
+		"highest patch version": {
+			ref:      "~1.4",
+			expected: "v1.4.2",
+		},
+		"tag prefix in the constraint": {
+			ref:      "package-a/v~1.4",
+			expected: "package-a/v1.4.7",
+		},
+		"package prefix": {
+			prefix:   "package-a/",
+			ref:      ">=1",
+			expected: "package-a/v2.0.0",
+		},
+		"no matching tag": {
+			ref: "^3",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			c, err := ParseVersionConstraint(tc.ref)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			tag, found := gur.ResolveVersion(tc.prefix, c)
+			assert.Equal(t, tc.expected != "", found)
+			assert.Equal(t, tc.expected, tag)
+		})
+	}
+}
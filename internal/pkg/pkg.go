@@ -26,6 +26,7 @@ import (
 
 	"github.com/GoogleContainerTools/kpt/internal/errors"
 	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/celutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/git"
 	"github.com/GoogleContainerTools/kpt/internal/util/pathutil"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
@@ -590,6 +591,13 @@ func (p *Pkg) ValidatePipeline() error {
 				Reason: "functionConfig must exist in the current package",
 			}
 		}
+		if err := validateWhen(fn.When); err != nil {
+			return &kptfilev1.ValidateError{
+				Field:  fmt.Sprintf("pipeline.%s[%d].when", "mutators", i),
+				Value:  fn.When,
+				Reason: err.Error(),
+			}
+		}
 	}
 	for i, fn := range pl.Validators {
 		if fn.ConfigPath != "" && !resourcesByPath.Has(filepath.Clean(fn.ConfigPath)) {
@@ -599,6 +607,24 @@ func (p *Pkg) ValidatePipeline() error {
 				Reason: "functionConfig must exist in the current package",
 			}
 		}
+		if err := validateWhen(fn.When); err != nil {
+			return &kptfilev1.ValidateError{
+				Field:  fmt.Sprintf("pipeline.%s[%d].when", "validators", i),
+				Value:  fn.When,
+				Reason: err.Error(),
+			}
+		}
+	}
+	return nil
+}
+
+// validateWhen checks that the `when` expression of a function compiles.
+func validateWhen(expr string) error {
+	if expr == "" {
+		return nil
+	}
+	if _, err := celutil.NewCondition(expr); err != nil {
+		return fmt.Errorf("invalid CEL expression: %w", err)
 	}
 	return nil
 }
@@ -535,3 +535,49 @@ func Chdir(t *testing.T, path string) func() {
 	}
 	return revertFunc
 }
+
+func TestValidatePipeline_When(t *testing.T) {
+	testCases := map[string]struct {
+		when        string
+		expectedErr string
+	}{
+		"valid expression": {
+			when: `resources.exists(r, r.kind == "ConfigMap")`,
+		},
+		"invalid expression": {
+			when:        `resources.exists(r, `,
+			expectedErr: "pipeline.mutators[0].when",
+		},
+		"non bool expression": {
+			when:        `size(resources)`,
+			expectedErr: "pipeline.mutators[0].when",
+		},
+	}
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			dir := t.TempDir()
+			kf := fmt.Sprintf(`apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: foo
+pipeline:
+  mutators:
+    - image: gcr.io/kpt-fn/set-labels:v0.1
+      when: '%s'
+`, tc.when)
+			err := os.WriteFile(filepath.Join(dir, kptfilev1.KptFileName), []byte(kf), 0600)
+			assert.NoError(t, err)
+			p, err := New(filesys.FileSystemOrOnDisk{}, dir)
+			assert.NoError(t, err)
+			err = p.ValidatePipeline()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.expectedErr)
+			}
+		})
+	}
+}
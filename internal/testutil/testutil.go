@@ -28,7 +28,6 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/gitutil"
 	"github.com/GoogleContainerTools/kpt/internal/pkg"
 	"github.com/GoogleContainerTools/kpt/internal/util/addmergecomment"
-	"github.com/GoogleContainerTools/kpt/internal/util/git"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
 	"github.com/GoogleContainerTools/kpt/pkg/printer/fake"
@@ -747,7 +746,7 @@ func commit(repo, message string) (string, error) {
 		return "", err
 	}
 
-	sha, err := git.LookupCommit(repo)
+	sha, err := gitutil.LookupCommit(fake.CtxWithDefaultPrinter(), repo)
 	if err != nil {
 		return "", err
 	}
@@ -878,6 +877,13 @@ func ConfigureTestKptCache(m *testing.M) int {
 	return m.Run()
 }
 
+// UseExecBackend makes the test run git with the ExecBackend, for the tests
+// asserting the error messages of the git executable rather than the ones of
+// the default GoBackend.
+func UseExecBackend(t *testing.T) {
+	t.Setenv(gitutil.BackendEnv, gitutil.ExecBackendName)
+}
+
 var EmptyReposInfo = &ReposInfo{}
 
 func ToReposInfo(repos map[string]*TestGitRepo) *ReposInfo {
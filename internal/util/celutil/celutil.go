@@ -0,0 +1,88 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package celutil evaluates the CEL expressions used to conditionally run
+// functions of a Kptfile pipeline.
+package celutil
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ResourcesVar is the name of the variable holding the resource list in
+// the expression.
+const ResourcesVar = "resources"
+
+// Condition is a compiled `when` expression of a pipeline function.
+type Condition struct {
+	expr    string
+	program cel.Program
+}
+
+// NewCondition compiles the given CEL expression. The expression has access
+// to the resources of the package through the `resources` variable and must
+// evaluate to a bool, e.g.
+//
+//	resources.exists(r, r.kind == "ConfigMap" && r.data.env == "prod")
+func NewCondition(expr string) (*Condition, error) {
+	env, err := cel.NewEnv(
+		cel.Variable(ResourcesVar, cel.ListType(cel.DynType)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	// the output type is dynamic if the expression ends with a field of
+	// a resource, in which case the type is checked on evaluation.
+	if t := ast.OutputType(); t != cel.DynType && !cel.BoolType.IsAssignableType(t) {
+		return nil, fmt.Errorf("expression must evaluate to a bool, not %s", t)
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	return &Condition{expr: expr, program: program}, nil
+}
+
+// Evaluate returns the value of the expression for the given resources.
+func (c *Condition) Evaluate(resources []*yaml.RNode) (bool, error) {
+	var list []interface{}
+	for _, r := range resources {
+		m, err := r.Map()
+		if err != nil {
+			return false, err
+		}
+		list = append(list, m)
+	}
+	if list == nil {
+		list = []interface{}{}
+	}
+	out, _, err := c.program.Eval(map[string]interface{}{
+		ResourcesVar: list,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate %q: %w", c.expr, err)
+	}
+	if out.Type() != types.BoolType {
+		return false, fmt.Errorf("expression %q evaluated to %s, expected a bool", c.expr, out.Type().TypeName())
+	}
+	return out.Value().(bool), nil
+}
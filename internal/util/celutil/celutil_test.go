@@ -0,0 +1,97 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package celutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestCondition(t *testing.T) {
+	resources := []*yaml.RNode{
+		yaml.MustParse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kptfile.kpt.dev
+data:
+  env: prod
+  enabled: true
+`),
+		yaml.MustParse(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+`),
+	}
+
+	testCases := map[string]struct {
+		expr       string
+		expected   bool
+		compileErr bool
+		evalErr    bool
+	}{
+		"matching condition": {
+			expr:     `resources.exists(r, r.kind == "ConfigMap" && r.data.env == "prod")`,
+			expected: true,
+		},
+		"non matching condition": {
+			expr:     `resources.exists(r, r.kind == "ConfigMap" && r.data.env == "dev")`,
+			expected: false,
+		},
+		"resource count": {
+			expr:     `size(resources) == 2`,
+			expected: true,
+		},
+		"dynamic bool": {
+			expr:     `resources[0].data.enabled`,
+			expected: true,
+		},
+		"syntax error": {
+			expr:       `resources.exists(r, `,
+			compileErr: true,
+		},
+		"not a bool": {
+			expr:       `size(resources)`,
+			compileErr: true,
+		},
+		"dynamic non bool": {
+			expr:    `resources[0].data.env`,
+			evalErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			c, err := NewCondition(tc.expr)
+			if tc.compileErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			got, err := c.Evaluate(resources)
+			if tc.evalErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
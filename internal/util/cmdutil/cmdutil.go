@@ -21,6 +21,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
 	"github.com/GoogleContainerTools/kpt/internal/util/function"
 	"github.com/GoogleContainerTools/kpt/internal/util/httputil"
 	"github.com/GoogleContainerTools/kpt/internal/util/porch"
@@ -164,7 +165,11 @@ func fetchCatalogFunctions() []v1alpha1.Function {
 	if err != nil {
 		return nil
 	}
-	return parseFunctions(content)
+	registries, err := fnruntime.DefaultRegistryConfig()
+	if err != nil {
+		return nil
+	}
+	return parseFunctions(content, registries.DefaultRegistry())
 }
 
 // fnName -> v<major>.<minor> -> catalogEntry
@@ -176,7 +181,8 @@ type catalogV2 map[string]map[string]struct {
 }
 
 // listImages returns the list of latest images from the input catalog content
-func parseFunctions(content string) []v1alpha1.Function {
+// in the registry.
+func parseFunctions(content, registry string) []v1alpha1.Function {
 	var jsonData catalogV2
 	err := json.Unmarshal([]byte(content), &jsonData)
 	if err != nil {
@@ -203,7 +209,7 @@ func parseFunctions(content string) []v1alpha1.Function {
 			}
 		}
 		fnName := fmt.Sprintf("%s:%s", fnName, latestVersion)
-		functions = append(functions, function.CatalogFunction(fnName, keywords, fnTypes))
+		functions = append(functions, function.CatalogFunction(registry, fnName, keywords, fnTypes))
 	}
 	return functions
 }
@@ -343,8 +343,14 @@ func TestListImages(t *testing.T) {
       }
     }
   }
-}`)
+}`, "registry.local/kpt-fn")
 	result := function.GetNames(functions)
 	sort.Strings(result)
 	assert.Equal(t, []string{"apply-setters:v0.1.1", "gatekeeper:v0.2.1"}, result)
+	var images []string
+	for _, f := range functions {
+		images = append(images, f.Spec.Image)
+	}
+	sort.Strings(images)
+	assert.Equal(t, []string{"registry.local/kpt-fn/apply-setters:v0.1.1", "registry.local/kpt-fn/gatekeeper:v0.2.1"}, images)
 }
@@ -31,6 +31,7 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/util/pkgutil"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/GoogleContainerTools/kpt/pkg/oci"
 	"sigs.k8s.io/kustomize/kyaml/errors"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
@@ -113,6 +114,9 @@ type Command struct {
 
 	// PkgGetter specifies packaging sourcing adapter
 	PkgGetter PkgGetter
+
+	// OciPkgGetter specifies packaging sourcing adapter for OCI upstreams
+	OciPkgGetter OciPkgGetter
 }
 
 func (c *Command) Run(ctx context.Context) error {
@@ -124,7 +128,7 @@ func (c *Command) Run(ctx context.Context) error {
 	}
 
 	// Return early if upstream is not set
-	if kptFile.Upstream == nil || kptFile.Upstream.Git == nil {
+	if kptFile.Upstream == nil || (kptFile.Upstream.Git == nil && kptFile.Upstream.Oci == nil) {
 		return errors.Errorf("package missing upstream in Kptfile at '%s'", c.Path)
 	}
 
@@ -140,10 +144,39 @@ func (c *Command) Run(ctx context.Context) error {
 		}
 	}()
 
+	// upstreamRef is the version of upstream the local package was fetched
+	// from, targetRef the version to compare against by default and getPkg
+	// stages upstream at the given version.
+	var upstreamRef, targetRef string
+	var getPkg func(targetDir, ref string) (string, error)
+	if o := kptFile.Upstream.Oci; o != nil {
+		targetRef, err = oci.ImageReference(o.Image)
+		if err != nil {
+			return err
+		}
+		// tags are mutable, so the fetched version is the locked digest
+		upstreamRef = targetRef
+		if kptFile.UpstreamLock != nil && kptFile.UpstreamLock.Oci != nil {
+			upstreamRef = kptFile.UpstreamLock.Oci.Digest
+		}
+		getPkg = func(targetDir, ref string) (string, error) {
+			image, err := oci.ReplaceReference(o.Image, ref)
+			if err != nil {
+				return "", err
+			}
+			return c.OciPkgGetter.GetOciPkg(ctx, stagingDirectory, targetDir, image, o.Directory)
+		}
+	} else {
+		g := kptFile.Upstream.Git
+		upstreamRef = g.Ref
+		getPkg = func(targetDir, ref string) (string, error) {
+			return c.PkgGetter.GetPkg(ctx, stagingDirectory, targetDir, g.Repo, g.Directory, ref)
+		}
+	}
+
 	// Stage current package
 	// This prevents prepareForDiff from modifying the local package
-	localPkgName := NameStagingDirectory(LocalPackageSource,
-		kptFile.Upstream.Git.Ref)
+	localPkgName := NameStagingDirectory(LocalPackageSource, upstreamRef)
 	currPkg, err := stageDirectory(stagingDirectory, localPkgName)
 	if err != nil {
 		return errors.Errorf("failed to create stage dir for current package: %v", err)
@@ -155,14 +188,8 @@ func (c *Command) Run(ctx context.Context) error {
 	}
 
 	// get the upstreamPkg at current version
-	upstreamPkgName := NameStagingDirectory(RemotePackageSource,
-		kptFile.Upstream.Git.Ref)
-	upstreamPkg, err := c.PkgGetter.GetPkg(ctx,
-		stagingDirectory,
-		upstreamPkgName,
-		kptFile.Upstream.Git.Repo,
-		kptFile.Upstream.Git.Directory,
-		kptFile.Upstream.Git.Ref)
+	upstreamPkgName := NameStagingDirectory(RemotePackageSource, upstreamRef)
+	upstreamPkg, err := getPkg(upstreamPkgName, upstreamRef)
 	if err != nil {
 		return err
 	}
@@ -170,13 +197,17 @@ func (c *Command) Run(ctx context.Context) error {
 	var upstreamTargetPkg string
 
 	if c.Ref == "" {
-		gur, err := gitutil.NewGitUpstreamRepo(ctx, kptFile.UpstreamLock.Git.Repo)
-		if err != nil {
-			return err
-		}
-		c.Ref, err = gur.GetDefaultBranch(ctx)
-		if err != nil {
-			return err
+		if targetRef != "" {
+			c.Ref = targetRef
+		} else {
+			gur, err := gitutil.NewGitUpstreamRepo(ctx, kptFile.UpstreamLock.Git.Repo)
+			if err != nil {
+				return err
+			}
+			c.Ref, err = gur.GetDefaultBranch(ctx)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
@@ -186,11 +217,7 @@ func (c *Command) Run(ctx context.Context) error {
 		// get the upstream pkg at the target version
 		upstreamTargetPkgName := NameStagingDirectory(TargetRemotePackageSource,
 			c.Ref)
-		upstreamTargetPkg, err = c.PkgGetter.GetPkg(ctx, stagingDirectory,
-			upstreamTargetPkgName,
-			kptFile.Upstream.Git.Repo,
-			kptFile.Upstream.Git.Directory,
-			c.Ref)
+		upstreamTargetPkg, err = getPkg(upstreamTargetPkgName, c.Ref)
 		if err != nil {
 			return err
 		}
@@ -239,6 +266,9 @@ func (c *Command) DefaultValues() {
 	if c.PkgGetter == nil {
 		c.PkgGetter = defaultPkgGetter{}
 	}
+	if c.OciPkgGetter == nil {
+		c.OciPkgGetter = defaultPkgGetter{}
+	}
 	if c.PkgDiffer == nil {
 		c.PkgDiffer = &defaultPkgDiffer{
 			DiffType:     c.DiffType,
@@ -332,7 +362,13 @@ type PkgGetter interface {
 	GetPkg(ctx context.Context, stagingDir, targetDir, repo, path, ref string) (dir string, err error)
 }
 
-// defaultPkgGetter uses fetch.Command abstraction to implement PkgGetter.
+// OciPkgGetter knows how to fetch a package given an OCI image and path.
+type OciPkgGetter interface {
+	GetOciPkg(ctx context.Context, stagingDir, targetDir, image, path string) (dir string, err error)
+}
+
+// defaultPkgGetter uses fetch.Command abstraction to implement PkgGetter
+// and OciPkgGetter.
 type defaultPkgGetter struct{}
 
 // GetPkg checks out a repository into a temporary directory for diffing
@@ -341,21 +377,41 @@ type defaultPkgGetter struct{}
 // path is the sub directory of the git repository that the package was cloned from
 // ref is the git ref the package was cloned from
 func (pg defaultPkgGetter) GetPkg(ctx context.Context, stagingDir, targetDir, repo, path, ref string) (string, error) {
-	dir, err := stageDirectory(stagingDir, targetDir)
-	if err != nil {
-		return dir, err
-	}
-
-	name := filepath.Base(dir)
-	kf := kptfileutil.DefaultKptfile(name)
-	kf.Upstream = &kptfilev1.Upstream{
+	return pg.getPkg(ctx, stagingDir, targetDir, &kptfilev1.Upstream{
 		Type: kptfilev1.GitOrigin,
 		Git: &kptfilev1.Git{
 			Repo:      repo,
 			Directory: path,
 			Ref:       ref,
 		},
+	})
+}
+
+// GetOciPkg pulls an image into a temporary directory for diffing and
+// returns the directory containing the package or an error.
+// image is the image the package was pulled from, including its tag or digest
+// path is the sub directory of the image that the package was pulled from
+func (pg defaultPkgGetter) GetOciPkg(ctx context.Context, stagingDir, targetDir, image, path string) (string, error) {
+	return pg.getPkg(ctx, stagingDir, targetDir, &kptfilev1.Upstream{
+		Type: kptfilev1.OciOrigin,
+		Oci: &kptfilev1.Oci{
+			Image:     image,
+			Directory: path,
+		},
+	})
+}
+
+// getPkg fetches the package from the given upstream into the target
+// directory of the staging directory.
+func (pg defaultPkgGetter) getPkg(ctx context.Context, stagingDir, targetDir string, upstream *kptfilev1.Upstream) (string, error) {
+	dir, err := stageDirectory(stagingDir, targetDir)
+	if err != nil {
+		return dir, err
 	}
+
+	name := filepath.Base(dir)
+	kf := kptfileutil.DefaultKptfile(name)
+	kf.Upstream = upstream
 	err = kptfileutil.WriteFile(dir, kf)
 	if err != nil {
 		return dir, err
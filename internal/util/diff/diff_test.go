@@ -353,6 +353,7 @@ locally changed: foo
 }
 
 func TestCommand_InvalidRef(t *testing.T) {
+	testutil.UseExecBackend(t)
 	reposChanges := map[string][]testutil.Content{
 		testutil.Upstream: {
 			{
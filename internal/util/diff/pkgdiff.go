@@ -111,6 +111,10 @@ func pkgSet(pkgPath string) (sets.String, error) {
 		if err != nil {
 			return err
 		}
+		// conflict reports are never copied between packages.
+		if info.Name() == pkgutil.ConflictReportFileName {
+			return nil
+		}
 		relPath, err := filepath.Rel(pkgPath, path)
 		if err != nil {
 			return err
@@ -18,7 +18,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
 
@@ -55,6 +54,13 @@ func (c Command) Run(ctx context.Context) error {
 		return errors.E(op, c.Pkg.UniquePath, err)
 	}
 
+	if o := kf.Upstream.Oci; o != nil {
+		if err := pullAndCopy(ctx, o, c.Pkg.UniquePath.String()); err != nil {
+			return errors.E(op, c.Pkg.UniquePath, err)
+		}
+		return nil
+	}
+
 	g := kf.Upstream.Git
 	repoSpec := &git.RepoSpec{
 		OrgRepo: g.Repo,
@@ -76,8 +82,18 @@ func (c Command) validate(kf *kptfilev1.KptFile) error {
 		return errors.E(op, errors.MissingParam, fmt.Errorf("kptfile doesn't contain upstream information"))
 	}
 
+	if o := kf.Upstream.Oci; o != nil {
+		if len(o.Image) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify image"))
+		}
+		if len(o.Directory) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify directory"))
+		}
+		return nil
+	}
+
 	if kf.Upstream.Git == nil {
-		return errors.E(op, errors.MissingParam, fmt.Errorf("kptfile upstream doesn't have git information"))
+		return errors.E(op, errors.MissingParam, fmt.Errorf("kptfile upstream doesn't have git or oci information"))
 	}
 
 	g := kf.Upstream.Git
@@ -156,12 +172,12 @@ func (c *Cloner) cloneAndCopy(ctx context.Context, dest string) error {
 	return nil
 }
 
-// ClonerUsingGitExec uses a local git install, as opposed
-// to say, some remote API, to obtain a local clone of
-// a remote repo. It looks for tags with the directory as a prefix to allow
-// for versioning multiple kpt packages in a single repo independently. It
-// relies on the private clonerUsingGitExec function to try fetching different
-// refs.
+// ClonerUsingGitExec uses git, through the backend selected by
+// gitutil.DefaultBackend, as opposed to say, some remote API, to obtain a
+// local clone of a remote repo. It looks for tags with the directory as a
+// prefix to allow for versioning multiple kpt packages in a single repo
+// independently. It relies on the private clonerUsingGitExec function to try
+// fetching different refs.
 func (c *Cloner) ClonerUsingGitExec(ctx context.Context) error {
 	const op errors.Op = "fetch.ClonerUsingGitExec"
 
@@ -178,47 +194,23 @@ func (c *Cloner) ClonerUsingGitExec(ctx context.Context) error {
 		c.cachedRepo[c.repoSpec.CloneSpec()] = upstreamRepo
 	}
 
-	// Check if we have a ref in the upstream that matches the package-specific
-	// reference. If we do, we use that reference.
-	ps := strings.Split(c.repoSpec.Path, "/")
-	for len(ps) != 0 {
-		p := path.Join(ps...)
-		packageRef := path.Join(strings.TrimLeft(p, "/"), c.repoSpec.Ref)
-		if _, found := upstreamRepo.ResolveTag(packageRef); found {
-			c.repoSpec.Ref = packageRef
-			break
-		}
-		ps = ps[:len(ps)-1]
-	}
-
-	// Pull the required ref into the repo git cache.
-	dir, err := upstreamRepo.GetRepo(ctx, []string{c.repoSpec.Ref})
+	// Resolve the ref to the commit it references.
+	commit, err := ResolveRef(ctx, upstreamRepo, c.repoSpec)
 	if err != nil {
 		return errors.E(op, errors.Git, errors.Repo(c.repoSpec.CloneSpec()), err)
 	}
 
-	gitRunner, err := gitutil.NewLocalGitRunner(dir)
+	// Pull the required ref into the repo git cache.
+	dir, err := upstreamRepo.GetRepo(ctx, []string{c.repoSpec.Ref})
 	if err != nil {
 		return errors.E(op, errors.Git, errors.Repo(c.repoSpec.CloneSpec()), err)
 	}
 
-	// Find the commit SHA for the ref that was just fetched. We need the SHA
-	// rather than the ref to be able to do a hard reset of the cache repo.
-	commit, found := upstreamRepo.ResolveRef(c.repoSpec.Ref)
-	if !found {
-		commit = c.repoSpec.Ref
-	}
-
 	// Reset the local repo to the commit we need. Doing a hard reset instead of
 	// a checkout means we don't create any local branches so we don't need to
 	// worry about fast-forwarding them with changes from upstream. It also makes
 	// sure that any changes in the local worktree are cleaned out.
-	_, err = gitRunner.Run(ctx, "reset", "--hard", commit)
-	if err != nil {
-		gitutil.AmendGitExecError(err, func(e *gitutil.GitExecError) {
-			e.Repo = c.repoSpec.CloneSpec()
-			e.Ref = commit
-		})
+	if err := upstreamRepo.Checkout(ctx, commit); err != nil {
 		return errors.E(op, errors.Git, errors.Repo(c.repoSpec.CloneSpec()), err)
 	}
 
@@ -272,6 +264,64 @@ func (c *Cloner) ClonerUsingGitExec(ctx context.Context) error {
 	return nil
 }
 
+// ResolveRef resolves the ref of the repoSpec with the refs of the upstream
+// repo, and returns the commit SHA it references. It sets the ref of the
+// repoSpec to the tag with the highest matching version if the ref is a
+// version constraint. Tags prefixed with the package directory take
+// precedence over the other refs. If the ref isn't a branch or a tag, e.g.
+// it is a commit, the ref itself is returned.
+func ResolveRef(ctx context.Context, upstreamRepo *gitutil.GitUpstreamRepo, repoSpec *git.RepoSpec) (string, error) {
+	resolved, err := resolveVersion(ctx, upstreamRepo, repoSpec)
+	if err != nil {
+		return "", err
+	}
+
+	// Check if we have a ref in the upstream that matches the package-specific
+	// reference. If we do, we use that reference.
+	if !resolved {
+		for _, prefix := range gitutil.PackageTagPrefixes(repoSpec.Path) {
+			packageRef := prefix + repoSpec.Ref
+			if _, found := upstreamRepo.ResolveTag(packageRef); found {
+				repoSpec.Ref = packageRef
+				break
+			}
+		}
+	}
+
+	// Find the commit SHA for the ref. We need the SHA rather than the ref
+	// to be able to do a hard reset of the cache repo.
+	if commit, found := upstreamRepo.ResolveRef(repoSpec.Ref); found {
+		return commit, nil
+	}
+	return repoSpec.Ref, nil
+}
+
+// resolveVersion sets the ref of the repoSpec to the tag with the highest
+// version matching it if the ref is a version constraint, and returns true
+// in that case.
+func resolveVersion(ctx context.Context, upstreamRepo *gitutil.GitUpstreamRepo, repoSpec *git.RepoSpec) (bool, error) {
+	ref := repoSpec.Ref
+	if !gitutil.IsVersionConstraint(ref) {
+		return false, nil
+	}
+	// an existing branch or tag with the same name takes precedence
+	if _, found := upstreamRepo.ResolveRef(ref); found {
+		return false, nil
+	}
+	constraint, err := gitutil.ParseVersionConstraint(ref)
+	if err != nil {
+		return false, err
+	}
+	for _, prefix := range append(gitutil.PackageTagPrefixes(repoSpec.Path), "") {
+		if tag, found := upstreamRepo.ResolveVersion(prefix, constraint); found {
+			printer.FromContextOrDie(ctx).Printf("Resolved %q to tag %q\n", ref, tag)
+			repoSpec.Ref = tag
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("no tag matches the version constraint %q", ref)
+}
+
 // copyDir copies a src directory to a dst directory.
 // copyDir skips copying the .git directory from the src and ignores symlinks.
 func copyDir(ctx context.Context, srcDir string, dstDir string) error {
@@ -110,7 +110,7 @@ func TestCommand_Run_failNoGit(t *testing.T) {
 	if !assert.Error(t, err) {
 		t.FailNow()
 	}
-	assert.Contains(t, err.Error(), "kptfile upstream doesn't have git information")
+	assert.Contains(t, err.Error(), "kptfile upstream doesn't have git or oci information")
 }
 
 // TestCommand_Run_failEmptyRepo verifies that Command fail if not repo is provided.
@@ -580,6 +580,7 @@ func TestCommand_Run_no_subdir_at_valid_tag(t *testing.T) {
 }
 
 func TestCommand_Run_no_subdir_at_invalid_tag(t *testing.T) {
+	testutil.UseExecBackend(t)
 	dir := "/java/expected"
 	nonexistentTag := "notjava/v2"
 	expectedName := "expected_dir_is_here"
@@ -625,6 +626,7 @@ func TestCommand_Run_no_subdir_at_invalid_tag(t *testing.T) {
 }
 
 func TestCommand_Run_failInvalidRepo(t *testing.T) {
+	testutil.UseExecBackend(t)
 	_, w, clean := setupWorkspace(t)
 	defer clean()
 
@@ -649,6 +651,7 @@ func TestCommand_Run_failInvalidRepo(t *testing.T) {
 }
 
 func TestCommand_Run_failInvalidBranch(t *testing.T) {
+	testutil.UseExecBackend(t)
 	g, w, clean := setupWorkspace(t)
 	defer clean()
 
@@ -676,6 +679,7 @@ func TestCommand_Run_failInvalidBranch(t *testing.T) {
 }
 
 func TestCommand_Run_failInvalidTag(t *testing.T) {
+	testutil.UseExecBackend(t)
 	g, w, clean := setupWorkspace(t)
 	defer clean()
 
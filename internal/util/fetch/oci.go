@@ -0,0 +1,126 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/pkgutil"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/GoogleContainerTools/kpt/pkg/oci"
+	"github.com/GoogleContainerTools/kpt/pkg/printer"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// OciPackage is a package pulled from an OCI registry into a local
+// directory.
+type OciPackage struct {
+	// Image is the image that was pulled.
+	Image string
+
+	// Directory is the sub directory of the image holding the package.
+	Directory string
+
+	// Digest is the digest of the image manifest that was pulled.
+	Digest string
+
+	// Dir is the local directory the image was extracted to.
+	Dir string
+}
+
+// AbsPath returns the absolute path to the package in the local directory.
+func (p *OciPackage) AbsPath() string {
+	return filepath.Join(p.Dir, p.Directory)
+}
+
+// Lock returns the resolved locator of the pulled package.
+func (p *OciPackage) Lock() *kptfilev1.OciLock {
+	return &kptfilev1.OciLock{
+		Image:     p.Image,
+		Directory: p.Directory,
+		Digest:    p.Digest,
+	}
+}
+
+// PullOciPackage pulls the given image into a temporary directory and
+// verifies that it contains the directory. The caller is responsible for
+// removing the Dir of the returned package.
+func PullOciPackage(ctx context.Context, image, directory string) (*OciPackage, error) {
+	const op errors.Op = "fetch.PullOciPackage"
+	dir, err := os.MkdirTemp("", "kpt-get-")
+	if err != nil {
+		return nil, errors.E(op, errors.Internal, fmt.Errorf("error creating temp directory: %w", err))
+	}
+	digest, err := oci.PullPackage(ctx, image, dir)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, errors.E(op, errors.OCI, err)
+	}
+	p := &OciPackage{
+		Image:     image,
+		Directory: directory,
+		Digest:    digest,
+		Dir:       dir,
+	}
+
+	// Verify that the requested directory exists in the image.
+	if _, err := os.Stat(p.AbsPath()); os.IsNotExist(err) {
+		_ = os.RemoveAll(dir)
+		return nil, errors.E(op, errors.Internal,
+			fmt.Errorf("path %q does not exist in image %q", directory, image))
+	}
+
+	// A Kptfile isn't required, but if one exists it must be valid.
+	if _, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, p.AbsPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		_ = os.RemoveAll(dir)
+		return nil, errors.E(op, fmt.Errorf("invalid Kptfile in image %q: %w", image, err))
+	}
+	return p, nil
+}
+
+// pullAndCopy pulls the package from the provided OCI locator and copies
+// the content into the directory specified by dest.
+func pullAndCopy(ctx context.Context, o *kptfilev1.Oci, dest string) error {
+	const op errors.Op = "fetch.pullAndCopy"
+	pr := printer.FromContextOrDie(ctx)
+
+	p, err := PullOciPackage(ctx, o.Image, o.Directory)
+	if err != nil {
+		return errors.E(op, errors.OCI, types.UniquePath(dest), err)
+	}
+	defer os.RemoveAll(p.Dir)
+
+	pr.Printf("Adding package %q.\n", strings.TrimPrefix(p.Directory, "/"))
+	if err := pkgutil.CopyPackage(p.AbsPath(), dest, true, pkg.All); err != nil {
+		return errors.E(op, types.UniquePath(dest), err)
+	}
+
+	if err := kptfileutil.UpdateKptfileWithoutOrigin(dest, p.AbsPath(), false); err != nil {
+		return errors.E(op, types.UniquePath(dest), err)
+	}
+
+	if err := kptfileutil.UpdateUpstreamLockFromOci(dest, p.Lock()); err != nil {
+		return errors.E(op, types.UniquePath(dest), err)
+	}
+	return nil
+}
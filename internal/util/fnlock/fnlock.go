@@ -0,0 +1,198 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fnlock contains libraries for pinning the images of the functions
+// of a package tree to digests.
+package fnlock
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/builtins"
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/GoogleContainerTools/kpt/pkg/printer"
+	"github.com/GoogleContainerTools/kpt/pkg/signature"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Command pins the images of the pipeline functions of a package and all its
+// subpackages to digests in the functionLock of their Kptfile.
+type Command struct {
+	// Pkg is the root package of the package tree.
+	Pkg *pkg.Pkg
+
+	// Update refreshes the digests of images that are already pinned.
+	// By default, only the images which aren't pinned yet are resolved.
+	Update bool
+
+	// ResolveToImage resolves a partial image to a fully-qualified one.
+	// Defaults to fnruntime.ResolveToImageForCLI.
+	ResolveToImage fnruntime.ImageResolveFunc
+
+	// Registries is the function registry config the images are resolved
+	// with. Defaults to fnruntime.DefaultRegistryConfig.
+	Registries *fnruntime.RegistryConfig
+
+	// Digest returns the digest the image currently resolves to.
+	// Defaults to the digest of the image in the mirror of its repository,
+	// if any, resolved with the credentials of the registry config.
+	Digest func(ctx context.Context, image string) (string, error)
+
+	// digests caches the digests of images shared by several packages.
+	digests map[string]string
+}
+
+// Run pins the function images of the package tree.
+func (c *Command) Run(ctx context.Context) error {
+	if c.ResolveToImage == nil {
+		c.ResolveToImage = fnruntime.ResolveToImageForCLI
+	}
+	if c.Registries == nil {
+		registries, err := fnruntime.DefaultRegistryConfig()
+		if err != nil {
+			return err
+		}
+		c.Registries = registries
+	}
+	if c.Digest == nil {
+		c.Digest = c.mirrorDigest
+	}
+	c.digests = map[string]string{}
+	return c.lockTree(ctx, c.Pkg)
+}
+
+// lockTree pins the function images of p and its subpackages.
+func (c *Command) lockTree(ctx context.Context, p *pkg.Pkg) error {
+	if err := c.lockPackage(ctx, p); err != nil {
+		return err
+	}
+	subPkgs, err := p.DirectSubpackages()
+	if err != nil {
+		return err
+	}
+	for _, subPkg := range subPkgs {
+		if err := c.lockTree(ctx, subPkg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockPackage pins the function images of the pipeline of p and writes the
+// Kptfile of p if its function lock changed.
+func (c *Command) lockPackage(ctx context.Context, p *pkg.Pkg) error {
+	const op errors.Op = "fnlock.lockPackage"
+	pr := printer.FromContextOrDie(ctx)
+	kf, err := p.Kptfile()
+	if err != nil {
+		return errors.E(op, p.UniquePath, err)
+	}
+
+	pinned := map[string]string{}
+	for _, l := range kf.FunctionLock {
+		pinned[l.Image] = l.Digest
+	}
+	var lock []kptfilev1.FunctionLock
+	for _, image := range images(kf.Pipeline) {
+		digest, found := pinned[image]
+		if !found || c.Update {
+			digest, err = c.digest(ctx, image)
+			if err != nil {
+				return errors.E(op, p.UniquePath, err)
+			}
+			if digest != pinned[image] {
+				pr.Printf("Package %q: pinned %q to %s\n", p.DisplayPath, image, digest)
+			}
+		}
+		lock = append(lock, kptfilev1.FunctionLock{Image: image, Digest: digest})
+	}
+
+	if equal(kf.FunctionLock, lock) {
+		return nil
+	}
+	kf.FunctionLock = lock
+	if err := kptfileutil.WriteFile(p.UniquePath.String(), kf); err != nil {
+		return errors.E(op, p.UniquePath, err)
+	}
+	return nil
+}
+
+// mirrorDigest returns the digest the image currently resolves to in the
+// mirror of its repository, which is the image pulled by render.
+func (c *Command) mirrorDigest(ctx context.Context, image string) (string, error) {
+	ref, err := signature.ResolveDigest(ctx, c.Registries.Mirror(image),
+		remote.WithAuthFromKeychain(c.Registries.Keychain()))
+	if err != nil {
+		return "", err
+	}
+	return ref.DigestStr(), nil
+}
+
+// digest returns the digest the image currently resolves to.
+func (c *Command) digest(ctx context.Context, image string) (string, error) {
+	resolved, err := c.ResolveToImage(ctx, image)
+	if err != nil {
+		return "", err
+	}
+	if d, found := c.digests[resolved]; found {
+		return d, nil
+	}
+	d, err := c.Digest(ctx, resolved)
+	if err != nil {
+		return "", err
+	}
+	c.digests[resolved] = d
+	return d, nil
+}
+
+// images returns the sorted images of the pipeline functions which can be
+// pinned. Images which already include a digest and builtin functions are
+// not returned.
+func images(pl *kptfilev1.Pipeline) []string {
+	if pl == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var result []string
+	for _, fns := range [][]kptfilev1.Function{pl.Mutators, pl.Validators} {
+		for _, f := range fns {
+			if f.Image == "" || builtins.IsBuiltin(f.Image) ||
+				strings.Contains(f.Image, "@") || seen[f.Image] {
+				continue
+			}
+			seen[f.Image] = true
+			result = append(result, f.Image)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func equal(a, b []kptfilev1.FunctionLock) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
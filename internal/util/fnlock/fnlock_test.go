@@ -0,0 +1,162 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnlock
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	fnregistry "github.com/GoogleContainerTools/kpt/pkg/api/fnregistry/v1alpha1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/printer"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const rootKptfile = `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: root
+pipeline:
+  mutators:
+    - image: set-labels:v0.1
+    - image: gcr.io/kpt-fn/set-namespace@sha256:0000000000000000000000000000000000000000000000000000000000000000
+    - image: builtins/gen-pkg-context
+    - exec: ./fn
+  validators:
+    - image: kubeval:v0.3
+    - image: set-labels:v0.1
+`
+
+const subKptfile = `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: sub
+pipeline:
+  mutators:
+    - image: set-labels:v0.1
+functionLock:
+  - image: removed:v1
+    digest: sha256:1111111111111111111111111111111111111111111111111111111111111111
+`
+
+func TestCommand_Run(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, kptfilev1.KptFileName), []byte(rootKptfile), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "sub", kptfilev1.KptFileName), []byte(subKptfile), 0644))
+
+	digests := map[string]string{
+		"gcr.io/kpt-fn/set-labels:v0.1": "sha256:aaaa",
+		"gcr.io/kpt-fn/kubeval:v0.3":    "sha256:bbbb",
+	}
+	var resolved []string
+	digest := func(_ context.Context, image string) (string, error) {
+		resolved = append(resolved, image)
+		return digests[image], nil
+	}
+	run := func(update bool) {
+		p, err := pkg.New(filesys.FileSystemOrOnDisk{}, dir)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		var out bytes.Buffer
+		ctx := printer.WithContext(context.Background(), printer.New(&out, &out))
+		cmd := &Command{Pkg: p, Update: update, Digest: digest}
+		assert.NoError(t, cmd.Run(ctx))
+	}
+	lockOf := func(path string) []kptfilev1.FunctionLock {
+		kf, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, filepath.Join(dir, path))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return kf.FunctionLock
+	}
+
+	run(false)
+	assert.Equal(t, []kptfilev1.FunctionLock{
+		{Image: "kubeval:v0.3", Digest: "sha256:bbbb"},
+		{Image: "set-labels:v0.1", Digest: "sha256:aaaa"},
+	}, lockOf("."))
+	assert.Equal(t, []kptfilev1.FunctionLock{
+		{Image: "set-labels:v0.1", Digest: "sha256:aaaa"},
+	}, lockOf("sub"))
+	// images shared by packages are resolved once
+	assert.Equal(t, []string{"gcr.io/kpt-fn/kubeval:v0.3", "gcr.io/kpt-fn/set-labels:v0.1"}, resolved)
+
+	// pinned images are not resolved again unless updating
+	digests["gcr.io/kpt-fn/set-labels:v0.1"] = "sha256:cccc"
+	resolved = nil
+	run(false)
+	assert.Empty(t, resolved)
+	assert.Equal(t, "sha256:aaaa", lockOf("sub")[0].Digest)
+
+	run(true)
+	assert.Equal(t, []kptfilev1.FunctionLock{
+		{Image: "kubeval:v0.3", Digest: "sha256:bbbb"},
+		{Image: "set-labels:v0.1", Digest: "sha256:cccc"},
+	}, lockOf("."))
+	assert.Equal(t, []kptfilev1.FunctionLock{
+		{Image: "set-labels:v0.1", Digest: "sha256:cccc"},
+	}, lockOf("sub"))
+}
+
+// TestCommand_Run_mirror verifies that the images are resolved in the mirror
+// of their repository.
+func TestCommand_Run_mirror(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	tag, err := name.NewTag(host + "/kpt-fn/set-labels:v0.1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(tag, img))
+	h, err := img.Digest()
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, kptfilev1.KptFileName), []byte(`apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: root
+pipeline:
+  mutators:
+    - image: gcr.io/kpt-fn/set-labels:v0.1
+`), 0644))
+	p, err := pkg.New(filesys.FileSystemOrOnDisk{}, dir)
+	require.NoError(t, err)
+	registries := &fnruntime.RegistryConfig{}
+	registries.Mirrors = []fnregistry.Mirror{{Source: "gcr.io/kpt-fn/*", Mirror: host + "/kpt-fn/*"}}
+
+	ctx := printer.WithContext(context.Background(), printer.New(&bytes.Buffer{}, &bytes.Buffer{}))
+	require.NoError(t, (&Command{Pkg: p, Registries: registries}).Run(ctx))
+
+	kf, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, dir)
+	require.NoError(t, err)
+	assert.Equal(t, []kptfilev1.FunctionLock{{Image: "gcr.io/kpt-fn/set-labels:v0.1", Digest: h.String()}}, kf.FunctionLock)
+}
@@ -26,7 +26,8 @@ import (
 const CatalogV2 = "__catalog_v2"
 
 // CatalogFunction converts catalog function into the porch v1alpha1.function struct.
-func CatalogFunction(name string, keywords []string, fnTypes []v1alpha1.FunctionType) v1alpha1.Function {
+// registry is the registry the catalog functions are pulled from, e.g. gcr.io/kpt-fn.
+func CatalogFunction(registry, name string, keywords []string, fnTypes []v1alpha1.FunctionType) v1alpha1.Function {
 	return v1alpha1.Function{
 		TypeMeta: metav1.TypeMeta{},
 		ObjectMeta: metav1.ObjectMeta{
@@ -34,7 +35,7 @@ func CatalogFunction(name string, keywords []string, fnTypes []v1alpha1.Function
 			Namespace: CatalogV2,
 		},
 		Spec: v1alpha1.FunctionSpec{
-			Image:         fmt.Sprintf("gcr.io/kpt-fn/%s", name),
+			Image:         fmt.Sprintf("%s/%s", registry, name),
 			FunctionTypes: fnTypes,
 			Keywords:      keywords,
 		},
@@ -40,12 +40,16 @@ import (
 	"sigs.k8s.io/kustomize/kyaml/kio"
 )
 
-// Command fetches a package from a git repository, copies it to a local
-// directory, and expands any remote subpackages.
+// Command fetches a package from a git repository or an OCI registry,
+// copies it to a local directory, and expands any remote subpackages.
 type Command struct {
 	// Git contains information about the git repo to fetch
 	Git *kptfilev1.Git
 
+	// Oci contains information about the OCI image to fetch. Only one of
+	// Git and Oci can be set.
+	Oci *kptfilev1.Oci
+
 	// Destination is the output directory to clone the package to.  Defaults to the name of the package --
 	// either the base repo name, or the base subdirectory name.
 	Destination string
@@ -79,20 +83,28 @@ func (c Command) Run(ctx context.Context) error {
 		return errors.E(op, errors.IO, types.UniquePath(c.Destination), err)
 	}
 
-	// normalize path to a filepath
-	repoDir := c.Git.Directory
-	if !strings.HasSuffix(repoDir, "file://") {
-		// Convert from separator to slash and back.
-		// This ensures all separators are compatible with the local OS.
-		repoDir = filepath.FromSlash(filepath.ToSlash(repoDir))
-	}
-	c.Git.Directory = repoDir
-
 	kf := kptfileutil.DefaultKptfile(c.Name)
-	kf.Upstream = &kptfilev1.Upstream{
-		Type:           kptfilev1.GitOrigin,
-		Git:            c.Git,
-		UpdateStrategy: c.UpdateStrategy,
+	if c.Oci != nil {
+		kf.Upstream = &kptfilev1.Upstream{
+			Type:           kptfilev1.OciOrigin,
+			Oci:            c.Oci,
+			UpdateStrategy: c.UpdateStrategy,
+		}
+	} else {
+		// normalize path to a filepath
+		repoDir := c.Git.Directory
+		if !strings.HasSuffix(repoDir, "file://") {
+			// Convert from separator to slash and back.
+			// This ensures all separators are compatible with the local OS.
+			repoDir = filepath.FromSlash(filepath.ToSlash(repoDir))
+		}
+		c.Git.Directory = repoDir
+
+		kf.Upstream = &kptfilev1.Upstream{
+			Type:           kptfilev1.GitOrigin,
+			Git:            c.Git,
+			UpdateStrategy: c.UpdateStrategy,
+		}
 	}
 
 	err = kptfileutil.WriteFile(c.Destination, kf)
@@ -129,6 +141,10 @@ func (c Command) Run(ctx context.Context) error {
 		hookCmd := hook.Executor{}
 		hookCmd.RunnerOptions.InitDefaults()
 		hookCmd.PkgPath = c.Destination
+		hookCmd.RunnerOptions.Policies, err = fnruntime.FindFunctionPolicies("", c.Destination)
+		if err != nil {
+			return err
+		}
 
 		builtinHooks := []kptfilev1.Function{
 			{
@@ -166,7 +182,11 @@ func (c Command) fetchPackages(ctx context.Context, rootPkg *pkg.Pkg) error {
 		if kf.Upstream != nil && kf.UpstreamLock == nil {
 			packageCount++
 			pr.PrintPackage(p, !(p == rootPkg))
-			pr.Printf("Fetching %s@%s\n", kf.Upstream.Git.Repo, kf.Upstream.Git.Ref)
+			if o := kf.Upstream.Oci; o != nil {
+				pr.Printf("Fetching %s\n", o.Image)
+			} else if g := kf.Upstream.Git; g != nil {
+				pr.Printf("Fetching %s@%s\n", g.Repo, g.Ref)
+			}
 			err := (&fetch.Command{
 				Pkg: p,
 			}).Run(ctx)
@@ -190,22 +210,33 @@ func (c Command) fetchPackages(ctx context.Context, rootPkg *pkg.Pkg) error {
 // DefaultValues sets values to the default values if they were unspecified
 func (c *Command) DefaultValues() error {
 	const op errors.Op = "get.DefaultValues"
-	if c.Git == nil {
-		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify git repo information"))
-	}
-	g := c.Git
-	if len(g.Repo) == 0 {
-		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify repo"))
-	}
-	if len(g.Ref) == 0 {
-		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify ref"))
+	switch {
+	case c.Git != nil && c.Oci != nil:
+		return errors.E(op, errors.InvalidParam, fmt.Errorf("only one of git repo and oci image information can be specified"))
+	case c.Oci != nil:
+		if len(c.Oci.Image) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify image"))
+		}
+		if len(c.Oci.Directory) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify directory"))
+		}
+	case c.Git != nil:
+		g := c.Git
+		if len(g.Repo) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify repo"))
+		}
+		if len(g.Ref) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify ref"))
+		}
+		if len(g.Directory) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify directory"))
+		}
+	default:
+		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify git repo or oci image information"))
 	}
 	if len(c.Destination) == 0 {
 		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify destination"))
 	}
-	if len(g.Directory) == 0 {
-		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify directory"))
-	}
 
 	if !filepath.IsAbs(c.Destination) {
 		return errors.E(op, errors.InvalidParam, fmt.Errorf("destination must be an absolute path"))
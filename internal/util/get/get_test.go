@@ -16,10 +16,14 @@ package get_test
 
 import (
 	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/GoogleContainerTools/kpt/internal/errors/resolver"
 	"github.com/GoogleContainerTools/kpt/internal/testutil"
 	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
 	. "github.com/GoogleContainerTools/kpt/internal/util/get"
@@ -48,7 +52,7 @@ func TestCommand_Run_failEmptyRepo(t *testing.T) {
 	if !assert.Error(t, err) {
 		t.FailNow()
 	}
-	assert.Contains(t, err.Error(), "must specify git repo information")
+	assert.Contains(t, err.Error(), "must specify git repo or oci image information")
 }
 
 // TestCommand_Run_failEmptyRepo verifies that Command fail if not repo is provided.
@@ -544,6 +548,78 @@ func TestCommand_Run_tag(t *testing.T) {
 	})
 }
 
+// TestCommand_Run_versionConstraint verifies the package is fetched from the
+// tag with the highest version matching a version constraint, and the tag
+// is recorded in the upstream lock.
+func TestCommand_Run_versionConstraint(t *testing.T) {
+	g, w, clean := testutil.SetupRepoAndWorkspace(t, testutil.Content{
+		Data:   testutil.Dataset1,
+		Branch: "master",
+	})
+	defer clean()
+
+	defer testutil.Chdir(t, w.WorkspaceDirectory)()
+
+	// tag dataset1 v1.0.0, dataset2 v1.1.0 and dataset3 v2.0.0
+	assert.NoError(t, g.Tag("v1.0.0"))
+	assert.NoError(t, g.ReplaceData(testutil.Dataset2))
+	_, err := g.Commit("new-data for v1.1.0")
+	assert.NoError(t, err)
+	commit, err := g.GetCommit()
+	assert.NoError(t, err)
+	assert.NoError(t, g.Tag("v1.1.0"))
+	assert.NoError(t, g.ReplaceData(testutil.Dataset3))
+	_, err = g.Commit("new-data for v2.0.0")
+	assert.NoError(t, err)
+	assert.NoError(t, g.Tag("v2.0.0"))
+
+	absPath := filepath.Join(w.WorkspaceDirectory, g.RepoName)
+	err = Command{
+		Git: &kptfilev1.Git{
+			Repo:      g.RepoDirectory,
+			Ref:       "^1.0",
+			Directory: "/",
+		},
+		Destination: absPath,
+	}.Run(fake.CtxWithDefaultPrinter())
+	assert.NoError(t, err)
+
+	// verify the cloned contents matches the repository
+	g.AssertEqual(t, filepath.Join(g.DatasetDirectory, testutil.Dataset2), absPath, true)
+
+	// verify the KptFile contains the expected values
+	g.AssertKptfile(t, absPath, kptfilev1.KptFile{
+		ResourceMeta: yaml.ResourceMeta{
+			ObjectMeta: yaml.ObjectMeta{
+				NameMeta: yaml.NameMeta{
+					Name: g.RepoName,
+				},
+			},
+			TypeMeta: yaml.TypeMeta{
+				APIVersion: kptfilev1.TypeMeta.APIVersion,
+				Kind:       kptfilev1.TypeMeta.Kind},
+		},
+		UpstreamLock: &kptfilev1.UpstreamLock{
+			Type: kptfilev1.GitOrigin,
+			Git: &kptfilev1.GitLock{
+				Directory: "/",
+				Repo:      g.RepoDirectory,
+				Ref:       "v1.1.0",
+				Commit:    commit,
+			},
+		},
+		Upstream: &kptfilev1.Upstream{
+			Type: kptfilev1.GitOrigin,
+			Git: &kptfilev1.Git{
+				Directory: "/",
+				Repo:      g.RepoDirectory,
+				Ref:       "^1.0",
+			},
+			UpdateStrategy: kptfilev1.ResourceMerge,
+		},
+	})
+}
+
 func TestCommand_Run_ref(t *testing.T) {
 	testCases := map[string]struct {
 		reposContent map[string][]testutil.Content
@@ -792,6 +868,7 @@ func TestCommand_Run_nonexistingParentDir(t *testing.T) {
 }
 
 func TestCommand_Run_failInvalidRepo(t *testing.T) {
+	testutil.UseExecBackend(t)
 	_, w, clean := testutil.SetupRepoAndWorkspace(t, testutil.Content{
 		Data:   testutil.Dataset1,
 		Branch: "master",
@@ -822,6 +899,7 @@ func TestCommand_Run_failInvalidRepo(t *testing.T) {
 }
 
 func TestCommand_Run_failInvalidBranch(t *testing.T) {
+	testutil.UseExecBackend(t)
 	g, w, clean := testutil.SetupRepoAndWorkspace(t, testutil.Content{
 		Data:   testutil.Dataset1,
 		Branch: "master",
@@ -855,6 +933,7 @@ func TestCommand_Run_failInvalidBranch(t *testing.T) {
 }
 
 func TestCommand_Run_failInvalidTag(t *testing.T) {
+	testutil.UseExecBackend(t)
 	g, w, clean := testutil.SetupRepoAndWorkspace(t, testutil.Content{
 		Data:   testutil.Dataset1,
 		Branch: "master",
@@ -887,6 +966,79 @@ func TestCommand_Run_failInvalidTag(t *testing.T) {
 	}
 }
 
+// TestCommand_Run_failGoBackend verifies the error messages when the
+// default GoBackend fails to get the package.
+func TestCommand_Run_failGoBackend(t *testing.T) {
+	t.Setenv("NETRC", filepath.Join(t.TempDir(), ".netrc"))
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(t.TempDir(), ".gitconfig"))
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="kpt"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer authServer.Close()
+
+	g, w, clean := testutil.SetupRepoAndWorkspace(t, testutil.Content{
+		Data:   testutil.Dataset1,
+		Branch: "master",
+	})
+	defer clean()
+	notRepo := t.TempDir()
+
+	testCases := map[string]struct {
+		repo        string
+		ref         string
+		expectedMsg string
+	}{
+		"invalid repo": {
+			repo:        notRepo,
+			ref:         "refs/heads/master",
+			expectedMsg: fmt.Sprintf("Error: Repository %q not found.", notRepo),
+		},
+		"invalid branch": {
+			repo: g.RepoDirectory,
+			ref:  "refs/heads/foo",
+			expectedMsg: fmt.Sprintf("Error: Unknown ref %q. Please verify that the reference exists in upstream repo %q.",
+				"refs/heads/foo", g.RepoDirectory),
+		},
+		"invalid tag": {
+			repo: g.RepoDirectory,
+			ref:  "refs/tags/foo",
+			expectedMsg: fmt.Sprintf("Error: Unknown ref %q. Please verify that the reference exists in upstream repo %q.",
+				"refs/tags/foo", g.RepoDirectory),
+		},
+		"auth required": {
+			repo:        authServer.URL + "/foo.git",
+			ref:         "refs/heads/master",
+			expectedMsg: fmt.Sprintf("Error: Repository %q requires authentication.", authServer.URL+"/foo.git"),
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			absPath := filepath.Join(w.WorkspaceDirectory, "foo")
+			err := Command{
+				Git: &kptfilev1.Git{
+					Repo:      tc.repo,
+					Directory: "/",
+					Ref:       tc.ref,
+				},
+				Destination: absPath,
+			}.Run(fake.CtxWithDefaultPrinter())
+			if !assert.Error(t, err) {
+				t.FailNow()
+			}
+			res, ok := resolver.ResolveError(err)
+			if assert.True(t, ok) {
+				assert.Contains(t, res.Message, tc.expectedMsg)
+			}
+
+			// Confirm destination directory no longer exists.
+			_, err = os.Stat(absPath)
+			assert.Error(t, err)
+		})
+	}
+}
+
 func TestCommand_Run_subpackages(t *testing.T) {
 	testCases := map[string]struct {
 		directory      string
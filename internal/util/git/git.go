@@ -17,13 +17,9 @@ package git
 
 import (
 	"fmt"
-	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"strings"
-
-	"github.com/GoogleContainerTools/kpt/internal/errors"
 )
 
 // RepoSpec specifies a git repository and a branch and path therein.
@@ -80,22 +76,6 @@ func isAWSHost(host string) bool {
 	return strings.Contains(host, "amazonaws.com")
 }
 
-// lookupCommit looks up the sha of the current commit on the repo at the
-// provided path.
-func LookupCommit(repoPath string) (string, error) {
-	const op errors.Op = "git.LookupCommit"
-	cmd := exec.Command("git", "rev-parse", "--verify", "HEAD")
-	cmd.Dir = repoPath
-	cmd.Env = os.Environ()
-	cmd.Stderr = os.Stderr
-	b, err := cmd.Output()
-	if err != nil {
-		return "", errors.E(op, errors.Git, fmt.Errorf("unable to look up commit: %w", err))
-	}
-	commit := strings.TrimSpace(string(b))
-	return commit, nil
-}
-
 func (rs *RepoSpec) RepoRef() string {
 	repoPath := path.Join(rs.CloneSpec(), rs.Path)
 	if rs.Ref != "" {
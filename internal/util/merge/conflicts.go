@@ -0,0 +1,293 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+	"sigs.k8s.io/kustomize/kyaml/yaml/merge3"
+	"sigs.k8s.io/kustomize/kyaml/yaml/walk"
+)
+
+// Conflict is a field of a resource changed to different values in the
+// local and the upstream packages since the origin package. The local
+// value of the field is kept by the merge. A conflict without a resource
+// is a text file with conflict markers around the conflicting lines.
+type Conflict struct {
+	// File is the path of the file of the resource, relative to the
+	// package.
+	File string `yaml:"file" json:"file"`
+
+	// Resource identifies the local resource.
+	Resource yaml.ResourceIdentifier `yaml:"resource,omitempty" json:"resource,omitempty"`
+
+	// Path is the path of the field in the resource, in the format of
+	// yaml.PathGetter. List elements are selected by their name or merge
+	// key, e.g. `[name=nginx]`, or else by their index.
+	Path []string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// Local is the value of the field in the local package, nil if the
+	// field is missing.
+	Local interface{} `yaml:"local,omitempty" json:"local,omitempty"`
+
+	// Upstream is the value of the field in the upstream package, nil if
+	// the field is missing.
+	Upstream interface{} `yaml:"upstream,omitempty" json:"upstream,omitempty"`
+
+	// Origin is the value of the field in the origin package, nil if the
+	// field is missing.
+	Origin interface{} `yaml:"origin,omitempty" json:"origin,omitempty"`
+}
+
+// internalAnnotationsPrefix is the prefix of the annotations set by kyaml
+// when reading resources.
+const internalAnnotationsPrefix = "internal.config.kubernetes.io/"
+
+// listMergeKeys are the fields identifying the elements of lists, in order
+// of preference, when the elements don't have a name.
+var listMergeKeys = []string{"name", "containerPort", "mountPath", "devicePath", "ip", "key"}
+
+// mergeKeepingConflicts merges the changes between the original and the
+// updated resource into the dest resource in place, like merge3.Merge, but
+// keeps the dest value of the fields with conflicting changes and returns
+// these fields.
+func mergeKeepingConflicts(dest, original, updated *yaml.RNode) ([]Conflict, error) {
+	v := &conflictVisitor{paths: map[*yaml.Node][]string{}}
+	collectPaths(dest.YNode(), nil, v.paths)
+	collectPaths(updated.YNode(), nil, v.paths)
+	_, err := walk.Walker{
+		Visitor:            v,
+		VisitKeysAsScalars: true,
+		Sources:            []*yaml.RNode{dest, original, updated},
+	}.Walk()
+	if err != nil {
+		return nil, err
+	}
+	if len(v.conflicts) == 0 {
+		return nil, nil
+	}
+
+	meta, err := dest.GetMeta()
+	if err != nil {
+		return nil, err
+	}
+	id := yaml.ResourceIdentifier{
+		TypeMeta: yaml.TypeMeta{APIVersion: meta.APIVersion, Kind: meta.Kind},
+		NameMeta: yaml.NameMeta{Name: meta.Name, Namespace: meta.Namespace},
+	}
+	for i := range v.conflicts {
+		v.conflicts[i].File = meta.Annotations[kioutil.PathAnnotation]
+		v.conflicts[i].Resource = id
+	}
+	return v.conflicts, nil
+}
+
+// conflictVisitor is a merge3.Visitor which keeps the dest value of
+// conflicting fields instead of the updated value, and records them.
+type conflictVisitor struct {
+	merge3.Visitor
+
+	// paths are the paths of the dest and updated nodes.
+	paths map[*yaml.Node][]string
+
+	conflicts []Conflict
+}
+
+func (v *conflictVisitor) VisitMap(nodes walk.Sources, s *openapi.ResourceSchema) (*yaml.RNode, error) {
+	if keep, err := v.keepDeletedOrModified(nodes); err != nil || keep {
+		return nodes.Dest(), err
+	}
+	return v.Visitor.VisitMap(nodes, s)
+}
+
+func (v *conflictVisitor) VisitList(nodes walk.Sources, s *openapi.ResourceSchema, kind walk.ListKind) (*yaml.RNode, error) {
+	if kind == walk.AssociativeList {
+		if keep, err := v.keepDeletedOrModified(nodes); err != nil || keep {
+			return nodes.Dest(), err
+		}
+		return v.Visitor.VisitList(nodes, s, kind)
+	}
+	// non-associative lists are merged as a whole
+	if conflict, err := v.conflict(nodes); err != nil || conflict {
+		return nodes.Dest(), err
+	}
+	return v.Visitor.VisitList(nodes, s, kind)
+}
+
+func (v *conflictVisitor) VisitScalar(nodes walk.Sources, s *openapi.ResourceSchema) (*yaml.RNode, error) {
+	if conflict, err := v.conflict(nodes); err != nil || conflict {
+		return nodes.Dest(), err
+	}
+	return v.Visitor.VisitScalar(nodes, s)
+}
+
+// keepDeletedOrModified records a conflict and returns true if a map or an
+// associative list has been deleted on one side and modified on the other.
+// The deleted dest is kept by returning a nil dest, and the modified dest
+// by merging it with itself.
+func (v *conflictVisitor) keepDeletedOrModified(nodes walk.Sources) (bool, error) {
+	if yaml.IsMissingOrNull(nodes.Origin()) || nodes.Dest().IsTaggedNull() || nodes.Updated().IsTaggedNull() ||
+		yaml.IsMissingOrNull(nodes.Dest()) == yaml.IsMissingOrNull(nodes.Updated()) {
+		return false, nil
+	}
+	conflict, err := v.conflict(nodes)
+	if err != nil || !conflict {
+		return false, err
+	}
+	if !yaml.IsMissingOrNull(nodes.Dest()) {
+		// the fields of dest are merged with the same fields
+		nodes[walk.UpdatedIndex] = nodes.Dest()
+	}
+	return true, nil
+}
+
+// conflict records a conflict and returns true if the field has different
+// values in dest, original and updated. Missing fields have their own value.
+func (v *conflictVisitor) conflict(nodes walk.Sources) (bool, error) {
+	if nodes.Dest().IsTaggedNull() || nodes.Updated().IsTaggedNull() {
+		// explicitly cleared
+		return false, nil
+	}
+	path := v.path(nodes)
+	if path == nil || isInternalAnnotation(path) {
+		// map keys and the annotations of the merge
+		return false, nil
+	}
+	dest, err := fieldValue(nodes.Dest())
+	if err != nil {
+		return false, err
+	}
+	origin, err := fieldValue(nodes.Origin())
+	if err != nil {
+		return false, err
+	}
+	updated, err := fieldValue(nodes.Updated())
+	if err != nil {
+		return false, err
+	}
+	if reflect.DeepEqual(dest, origin) || reflect.DeepEqual(updated, origin) || reflect.DeepEqual(dest, updated) {
+		return false, nil
+	}
+	v.conflicts = append(v.conflicts, Conflict{
+		Path:     path,
+		Local:    valueOrNil(dest),
+		Upstream: valueOrNil(updated),
+		Origin:   valueOrNil(origin),
+	})
+	return true, nil
+}
+
+// path returns the path of the field of the nodes, or nil if it is unknown.
+func (v *conflictVisitor) path(nodes walk.Sources) []string {
+	for _, n := range []*yaml.RNode{nodes.Dest(), nodes.Updated()} {
+		if n == nil {
+			continue
+		}
+		if p, found := v.paths[n.YNode()]; found {
+			return p
+		}
+	}
+	return nil
+}
+
+// missingField is the value of missing fields.
+type missingField struct{}
+
+// fieldValue returns the value of the node, ignoring its style and
+// comments, or missingField if the node is missing.
+func fieldValue(n *yaml.RNode) (interface{}, error) {
+	if yaml.IsMissingOrNull(n) {
+		return missingField{}, nil
+	}
+	var value interface{}
+	if err := n.YNode().Decode(&value); err != nil {
+		return nil, fmt.Errorf("failed to decode field: %w", err)
+	}
+	return value, nil
+}
+
+func valueOrNil(value interface{}) interface{} {
+	if _, missing := value.(missingField); missing {
+		return nil
+	}
+	return value
+}
+
+// collectPaths records the path of node and of all its descendants.
+func collectPaths(node *yaml.Node, path []string, paths map[*yaml.Node][]string) {
+	if node == nil {
+		return
+	}
+	if path == nil {
+		path = []string{}
+	}
+	paths[node] = path
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			collectPaths(node.Content[i+1], appendPath(path, node.Content[i].Value), paths)
+		}
+	case yaml.SequenceNode:
+		for i, elem := range node.Content {
+			collectPaths(elem, appendPath(path, elementSelector(elem, i)), paths)
+		}
+	}
+}
+
+// appendPath returns a copy of path with the element appended.
+func appendPath(path []string, elem string) []string {
+	return append(append([]string{}, path...), elem)
+}
+
+// elementSelector returns the yaml.PathGetter selector of the list element
+// at index i.
+func elementSelector(elem *yaml.Node, i int) string {
+	switch elem.Kind {
+	case yaml.MappingNode:
+		for _, key := range listMergeKeys {
+			for j := 0; j+1 < len(elem.Content); j += 2 {
+				if elem.Content[j].Value == key && elem.Content[j+1].Kind == yaml.ScalarNode {
+					return fmt.Sprintf("[%s=%s]", key, elem.Content[j+1].Value)
+				}
+			}
+		}
+	case yaml.ScalarNode:
+		return fmt.Sprintf("[=%s]", elem.Value)
+	}
+	return strconv.Itoa(i)
+}
+
+// isInternalAnnotation returns true if the path is the path of one of the
+// annotations set by kpt to read and merge packages.
+func isInternalAnnotation(path []string) bool {
+	if len(path) != 3 || path[0] != yaml.MetadataField || path[1] != yaml.AnnotationsField {
+		return false
+	}
+	if strings.HasPrefix(path[2], internalAnnotationsPrefix) {
+		return true
+	}
+	for _, a := range internalAnnotations {
+		if path[2] == a {
+			return true
+		}
+	}
+	return false
+}
@@ -48,6 +48,11 @@ type Merge3 struct {
 	MatchFilesGlob     []string
 	MergeOnPath        bool
 	IncludeSubPackages bool
+
+	// Conflicts, if set, makes the merge keep the local value of the fields
+	// changed to different values in the local and the updated packages,
+	// instead of the updated value, and collects these fields.
+	Conflicts *[]Conflict
 }
 
 func (m Merge3) Merge() error {
@@ -104,7 +109,7 @@ func (m Merge3) Merge() error {
 	})
 
 	rmMatcher := ResourceMergeMatcher{MergeOnPath: m.MergeOnPath}
-	resourceHandler := resourceHandler{}
+	resourceHandler := resourceHandler{conflicts: m.Conflicts}
 	kyamlMerge := filters.Merge3{
 		Matcher: &rmMatcher,
 		Handler: &resourceHandler,
@@ -297,6 +302,10 @@ func metadataComment(node *yaml.RNode) string {
 // there is no diff between origin and local.
 type resourceHandler struct {
 	keptResources []*yaml.RNode
+
+	// conflicts, if set, collects the conflicting fields of the merged
+	// resources, whose local value is kept.
+	conflicts *[]Conflict
 }
 
 func (r *resourceHandler) Handle(origin, upstream, local *yaml.RNode) (filters.ResourceMergeStrategy, error) {
@@ -326,6 +335,14 @@ func (r *resourceHandler) Handle(origin, upstream, local *yaml.RNode) (filters.R
 	// Do not re-add if deleted from local.
 	case origin != nil && local == nil:
 		strategy = filters.Skip
+	case r.conflicts != nil:
+		conflicts, err := mergeKeepingConflicts(local, origin, upstream)
+		if err != nil {
+			return strategy, err
+		}
+		*r.conflicts = append(*r.conflicts, conflicts...)
+		// local has been merged in place
+		strategy = filters.KeepDest
 	default:
 		strategy = filters.Merge
 	}
@@ -356,10 +373,14 @@ func (*resourceHandler) equals(r1, r2 *yaml.RNode) (bool, error) {
 	return r1Clone.MustString() == r2Clone.MustString(), nil
 }
 
+// internalAnnotations are the annotations set on the resources to read and
+// merge them.
+var internalAnnotations = []string{mergeSourceAnnotation, kioutil.PathAnnotation, kioutil.IndexAnnotation,
+	kioutil.LegacyPathAnnotation, kioutil.LegacyIndexAnnotation, // nolint:staticcheck
+	kioutil.InternalAnnotationsMigrationResourceIDAnnotation, attribution.CNRMMetricsAnnotation}
+
 func stripKyamlAnnos(n *yaml.RNode) error {
-	for _, a := range []string{mergeSourceAnnotation, kioutil.PathAnnotation, kioutil.IndexAnnotation,
-		kioutil.LegacyPathAnnotation, kioutil.LegacyIndexAnnotation, // nolint:staticcheck
-		kioutil.InternalAnnotationsMigrationResourceIDAnnotation, attribution.CNRMMetricsAnnotation} {
+	for _, a := range internalAnnotations {
 		err := n.PipeE(yaml.ClearAnnotation(a))
 		if err != nil {
 			return err
@@ -633,3 +633,297 @@ spec:
 		})
 	}
 }
+
+func TestMerge3_Conflicts(t *testing.T) {
+	testCases := map[string]struct {
+		origin    string
+		update    string
+		local     string
+		expected  string
+		conflicts []merge.Conflict
+	}{
+		`Changes on both sides to different fields are merged`: {
+			origin: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 3`,
+			update: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  labels:
+    app: nginx
+spec:
+  replicas: 3`,
+			local: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 4
+`,
+			expected: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  labels:
+    app: nginx
+spec:
+  replicas: 4
+`},
+
+		`Same change on both sides is not a conflict`: {
+			origin: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 3`,
+			update: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 5`,
+			local: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 5
+`,
+			expected: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 5
+`},
+
+		`Different changes to a field keep the local value`: {
+			origin: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.14
+        args: [a]`,
+			update: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 4
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.16
+        args: [a, b]`,
+			local: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.15
+        args: [c]
+`,
+			expected: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.15
+        args: [c]
+`,
+			conflicts: []merge.Conflict{
+				{
+					Path:     []string{"spec", "replicas"},
+					Local:    5,
+					Upstream: 4,
+					Origin:   3,
+				},
+				{
+					Path:     []string{"spec", "template", "spec", "containers", "[name=nginx]", "args"},
+					Local:    []interface{}{"c"},
+					Upstream: []interface{}{"a", "b"},
+					Origin:   []interface{}{"a"},
+				},
+				{
+					Path:     []string{"spec", "template", "spec", "containers", "[name=nginx]", "image"},
+					Local:    "nginx:1.15",
+					Upstream: "nginx:1.16",
+					Origin:   "nginx:1.14",
+				},
+			},
+		},
+
+		`Field removed locally and changed upstream stays removed`: {
+			origin: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  foo: bar
+  baz: qux`,
+			update: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  foo: bar2
+  baz: qux`,
+			local: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  baz: qux
+`,
+			expected: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  baz: qux
+`,
+			conflicts: []merge.Conflict{
+				{
+					Path:     []string{"data", "foo"},
+					Upstream: "bar2",
+					Origin:   "bar",
+				},
+			},
+		},
+
+		`Map removed upstream and changed locally is kept`: {
+			origin: `
+apiVersion: v1
+kind: Service
+metadata:
+  name: svc
+spec:
+  selector:
+    app: foo
+  type: ClusterIP`,
+			update: `
+apiVersion: v1
+kind: Service
+metadata:
+  name: svc
+spec:
+  type: ClusterIP`,
+			local: `
+apiVersion: v1
+kind: Service
+metadata:
+  name: svc
+spec:
+  selector:
+    app: bar
+  type: ClusterIP
+`,
+			expected: `
+apiVersion: v1
+kind: Service
+metadata:
+  name: svc
+spec:
+  selector:
+    app: bar
+  type: ClusterIP
+`,
+			conflicts: []merge.Conflict{
+				{
+					Path:   []string{"spec", "selector"},
+					Local:  map[string]interface{}{"app": "bar"},
+					Origin: map[string]interface{}{"app": "foo"},
+				},
+			},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range map[string]string{
+				"originalDir": tc.origin,
+				"updatedDir":  tc.update,
+				"localDir":    tc.local,
+			} {
+				err := os.MkdirAll(filepath.Join(dir, name), 0700)
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				err = os.WriteFile(filepath.Join(dir, name, "f1.yaml"), []byte(strings.TrimSpace(content)), 0700)
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+			}
+
+			var conflicts []merge.Conflict
+			err := merge.Merge3{
+				OriginalPath: filepath.Join(dir, "originalDir"),
+				UpdatedPath:  filepath.Join(dir, "updatedDir"),
+				DestPath:     filepath.Join(dir, "localDir"),
+				MergeOnPath:  true,
+				Conflicts:    &conflicts,
+			}.Merge()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			b, err := os.ReadFile(filepath.Join(dir, "localDir", "f1.yaml"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, strings.TrimSpace(tc.expected), strings.TrimSpace(string(b)))
+
+			for i := range tc.conflicts {
+				tc.conflicts[i].File = "f1.yaml"
+				meta, err := yaml.MustParse(tc.local).GetMeta()
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				tc.conflicts[i].Resource = yaml.ResourceIdentifier{
+					TypeMeta: meta.TypeMeta,
+					NameMeta: meta.NameMeta,
+				}
+			}
+			assert.Equal(t, tc.conflicts, conflicts)
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const (
+	// ConflictMarkerLocal starts the local lines of a conflict in a merged
+	// text file.
+	ConflictMarkerLocal = "<<<<<<< local"
+	// ConflictMarkerSeparator separates the local and the updated lines of
+	// a conflict.
+	ConflictMarkerSeparator = "======="
+	// ConflictMarkerUpdated ends the updated lines of a conflict.
+	ConflictMarkerUpdated = ">>>>>>> upstream"
+)
+
+// IsBinary returns true if the content looks like the content of a binary
+// file, i.e. it has a NUL byte in its first 8000 bytes like git checks.
+func IsBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) != -1
+}
+
+// MergeText performs a 3-way merge of the lines of text files. The lines
+// changed between original and updated are merged into dest. The lines
+// changed differently on both sides are written between conflict markers,
+// with the dest lines first, in which case MergeText returns true.
+func MergeText(dest, original, updated []byte) ([]byte, bool) {
+	d, o, u := splitLines(dest), splitLines(original), splitLines(updated)
+
+	var out []string
+	conflict := false
+	di, oi, ui := 0, 0, 0
+	for _, r := range syncRegions(d, o, u) {
+		dChunk, oChunk, uChunk := d[di:r.dStart], o[oi:r.oStart], u[ui:r.uStart]
+		switch {
+		case equalLines(dChunk, uChunk), equalLines(oChunk, uChunk):
+			out = append(out, dChunk...)
+		case equalLines(oChunk, dChunk):
+			out = append(out, uChunk...)
+		default:
+			conflict = true
+			out = append(out, ConflictMarkerLocal+"\n")
+			out = append(out, terminated(dChunk)...)
+			out = append(out, ConflictMarkerSeparator+"\n")
+			out = append(out, terminated(uChunk)...)
+			out = append(out, ConflictMarkerUpdated+"\n")
+		}
+		out = append(out, o[r.oStart:r.oEnd]...)
+		di, oi, ui = r.dEnd, r.oEnd, r.uEnd
+	}
+	return []byte(strings.Join(out, "")), conflict
+}
+
+// ResolveTextConflicts replaces the conflicts written by MergeText in the
+// content with their dest lines if ours is true, or else with their updated
+// lines.
+func ResolveTextConflicts(content []byte, ours bool) []byte {
+	var out []string
+	// the section of the conflict: none, dest or updated
+	section := ""
+	for _, line := range splitLines(content) {
+		switch strings.TrimRight(line, "\r\n") {
+		case ConflictMarkerLocal:
+			section = "dest"
+			continue
+		case ConflictMarkerSeparator:
+			if section == "dest" {
+				section = "updated"
+				continue
+			}
+		case ConflictMarkerUpdated:
+			if section == "updated" {
+				section = ""
+				continue
+			}
+		}
+		if section == "" || (section == "dest") == ours {
+			out = append(out, line)
+		}
+	}
+	return []byte(strings.Join(out, ""))
+}
+
+// syncRegion is a region of lines unchanged in dest and updated since
+// original.
+type syncRegion struct {
+	dStart, dEnd int
+	oStart, oEnd int
+	uStart, uEnd int
+}
+
+// syncRegions returns the regions of lines unchanged on both sides, ending
+// with an empty region at the end of the lines.
+func syncRegions(d, o, u []string) []syncRegion {
+	dBlocks := matchingBlocks(o, d)
+	uBlocks := matchingBlocks(o, u)
+
+	var regions []syncRegion
+	for i, j := 0, 0; i < len(dBlocks) && j < len(uBlocks); {
+		db, ub := dBlocks[i], uBlocks[j]
+		start := max(db.A, ub.A)
+		end := min(db.A+db.Size, ub.A+ub.Size)
+		if start < end {
+			dStart := db.B + start - db.A
+			uStart := ub.B + start - ub.A
+			regions = append(regions, syncRegion{
+				dStart: dStart, dEnd: dStart + end - start,
+				oStart: start, oEnd: end,
+				uStart: uStart, uEnd: uStart + end - start,
+			})
+		}
+		if db.A+db.Size < ub.A+ub.Size {
+			i++
+		} else {
+			j++
+		}
+	}
+	return append(regions, syncRegion{
+		dStart: len(d), dEnd: len(d),
+		oStart: len(o), oEnd: len(o),
+		uStart: len(u), uEnd: len(u),
+	})
+}
+
+// matchingBlocks returns the blocks of equal lines in a and b.
+func matchingBlocks(a, b []string) []difflib.Match {
+	// junk heuristics would treat frequent lines, e.g. blank lines, as
+	// changed
+	return difflib.NewMatcherWithJunk(a, b, false, nil).GetMatchingBlocks()
+}
+
+// splitLines splits the content into lines, keeping their line endings.
+func splitLines(content []byte) []string {
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// terminated returns the lines with a line ending on the last line, so that
+// a conflict marker can follow them.
+func terminated(lines []string) []string {
+	if len(lines) == 0 || strings.HasSuffix(lines[len(lines)-1], "\n") {
+		return lines
+	}
+	lines = append([]string{}, lines...)
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
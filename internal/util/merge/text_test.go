@@ -0,0 +1,109 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge_test
+
+import (
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeText(t *testing.T) {
+	testCases := map[string]struct {
+		dest     string
+		original string
+		updated  string
+		expected string
+		conflict bool
+	}{
+		"unchanged": {
+			dest:     "a\nb\n",
+			original: "a\nb\n",
+			updated:  "a\nb\n",
+			expected: "a\nb\n",
+		},
+		"changed in dest": {
+			dest:     "a\nB\n",
+			original: "a\nb\n",
+			updated:  "a\nb\n",
+			expected: "a\nB\n",
+		},
+		"changed in updated": {
+			dest:     "a\nb\n",
+			original: "a\nb\n",
+			updated:  "a\nb\nc\n",
+			expected: "a\nb\nc\n",
+		},
+		"different lines changed on both sides": {
+			dest:     "# Title\n\nlocal intro\n\nusage\n",
+			original: "# Title\n\nintro\n\nusage\n",
+			updated:  "# Title\n\nintro\n\nnew usage\n\nmore\n",
+			expected: "# Title\n\nlocal intro\n\nnew usage\n\nmore\n",
+		},
+		"same change on both sides": {
+			dest:     "a\nc\n",
+			original: "a\nb\n",
+			updated:  "a\nc\n",
+			expected: "a\nc\n",
+		},
+		"line deleted in updated next to a line changed in dest": {
+			dest:     "a\nb\nC\n",
+			original: "a\nb\nc\n",
+			updated:  "a\nc\n",
+			expected: "a\n<<<<<<< local\nb\nC\n=======\nc\n>>>>>>> upstream\n",
+			conflict: true,
+		},
+		"line deleted in updated": {
+			dest:     "a\nb\nc\nd\n",
+			original: "a\nb\nc\nD\n",
+			updated:  "a\nc\nD\n",
+			expected: "a\nc\nd\n",
+		},
+		"same line changed on both sides": {
+			dest:     "a\nlocal\nc\n",
+			original: "a\nb\nc\n",
+			updated:  "a\nupstream\nc\n",
+			expected: "a\n<<<<<<< local\nlocal\n=======\nupstream\n>>>>>>> upstream\nc\n",
+			conflict: true,
+		},
+		"added on both sides": {
+			dest:     "local",
+			original: "",
+			updated:  "upstream",
+			expected: "<<<<<<< local\nlocal\n=======\nupstream\n>>>>>>> upstream\n",
+			conflict: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			merged, conflict := merge.MergeText([]byte(tc.dest), []byte(tc.original), []byte(tc.updated))
+			assert.Equal(t, tc.expected, string(merged))
+			assert.Equal(t, tc.conflict, conflict)
+		})
+	}
+}
+
+func TestResolveTextConflicts(t *testing.T) {
+	merged, _ := merge.MergeText([]byte("a\nlocal\nc\n"), []byte("a\nb\nc\n"), []byte("a\nupstream\nc\n"))
+	assert.Equal(t, "a\nlocal\nc\n", string(merge.ResolveTextConflicts(merged, true)))
+	assert.Equal(t, "a\nupstream\nc\n", string(merge.ResolveTextConflicts(merged, false)))
+}
+
+func TestIsBinary(t *testing.T) {
+	assert.False(t, merge.IsBinary([]byte("text\n")))
+	assert.True(t, merge.IsBinary([]byte{0x89, 'P', 'N', 'G', 0x00}))
+}
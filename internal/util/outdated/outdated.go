@@ -0,0 +1,392 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package outdated contains libraries for reporting the packages that are
+// behind their upstream.
+package outdated
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/addmergecomment"
+	"github.com/GoogleContainerTools/kpt/internal/util/fetch"
+	"github.com/GoogleContainerTools/kpt/internal/util/git"
+	"github.com/GoogleContainerTools/kpt/internal/util/update"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/oci"
+	"github.com/google/go-containerregistry/pkg/name"
+	"golang.org/x/mod/semver"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+)
+
+// Command reports the packages and subpackages of a package that are behind
+// their git or OCI upstream.
+type Command struct {
+	// Pkg is the root package of the packages to check.
+	Pkg *pkg.Pkg
+
+	// cachedUpstreamRepos is an upstream repo already fetched for a given repoSpec CloneRef
+	cachedUpstreamRepos map[string]*gitutil.GitUpstreamRepo
+
+	// cachedDigests is the digest an upstream image already resolved to
+	cachedDigests map[string]string
+}
+
+// Status is the status of a package with regard to its upstream.
+type Status struct {
+	// Package is the path of the package, relative to the root package.
+	Package string `json:"package"`
+
+	// Repo is the upstream repo of the package, or the repository of the
+	// upstream image.
+	Repo string `json:"repo"`
+
+	// Directory is the directory of the package in the upstream repo or
+	// image.
+	Directory string `json:"directory"`
+
+	// Ref is the upstream ref of the package, which can be a version
+	// constraint, or the tag or digest of the upstream image.
+	Ref string `json:"ref"`
+
+	// CurrentRef is the ref the package was last fetched from.
+	CurrentRef string `json:"currentRef,omitempty"`
+
+	// CurrentCommit is the commit the package was last fetched from.
+	CurrentCommit string `json:"currentCommit,omitempty"`
+
+	// LatestCommit is the commit the upstream ref references now.
+	LatestCommit string `json:"latestCommit"`
+
+	// CurrentDigest is the digest of the image the package was last
+	// fetched from.
+	CurrentDigest string `json:"currentDigest,omitempty"`
+
+	// LatestDigest is the digest the upstream image resolves to now.
+	LatestDigest string `json:"latestDigest,omitempty"`
+
+	// LatestTag is the tag with the highest version in the upstream repo,
+	// among the tags with the same prefix as the ref.
+	LatestTag string `json:"latestTag,omitempty"`
+
+	// CommitsBehind is the number of commits between the current commit
+	// and the latest commit.
+	CommitsBehind int `json:"commitsBehind"`
+
+	// LocalChanges is true if the package has been changed locally since
+	// it was fetched.
+	LocalChanges bool `json:"localChanges"`
+
+	// Outdated is true if the upstream ref references a different commit
+	// than the current commit, if a tag has a higher version than the
+	// current ref, or if the upstream image resolves to a different digest
+	// than the current digest.
+	Outdated bool `json:"outdated"`
+}
+
+// Run returns the status of the package and of all its subpackages with a
+// git or OCI upstream, ordered by path. Each upstream repo or image is
+// queried once.
+func (c *Command) Run(ctx context.Context) ([]Status, error) {
+	const op errors.Op = "outdated.Run"
+	if c.Pkg == nil {
+		return nil, errors.E(op, errors.MissingParam, "pkg must be provided")
+	}
+	if c.cachedUpstreamRepos == nil {
+		c.cachedUpstreamRepos = make(map[string]*gitutil.GitUpstreamRepo)
+	}
+	if c.cachedDigests == nil {
+		c.cachedDigests = make(map[string]string)
+	}
+
+	root := c.Pkg.UniquePath.String()
+	paths, err := pathutil.DirsWithFile(root, kptfilev1.KptFileName, true)
+	if err != nil {
+		return nil, errors.E(op, c.Pkg.UniquePath, err)
+	}
+	sort.Strings(paths)
+
+	var statuses []Status
+	for _, p := range paths {
+		status, err := c.packageStatus(ctx, root, p)
+		if err != nil {
+			return nil, errors.E(op, types.UniquePath(p), err)
+		}
+		if status != nil {
+			statuses = append(statuses, *status)
+		}
+	}
+	return statuses, nil
+}
+
+// packageStatus returns the status of the package at the path, or nil if
+// the package doesn't have an upstream.
+func (c *Command) packageStatus(ctx context.Context, root, path string) (*Status, error) {
+	p, err := pkg.New(filesys.FileSystemOrOnDisk{}, path)
+	if err != nil {
+		return nil, err
+	}
+	kf, err := p.Kptfile()
+	if err != nil {
+		return nil, err
+	}
+	if kf.Upstream == nil || (kf.Upstream.Git == nil && kf.Upstream.Oci == nil) {
+		return nil, nil
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return nil, err
+	}
+	if kf.Upstream.Oci != nil {
+		return c.ociPackageStatus(ctx, filepath.ToSlash(rel), path, kf)
+	}
+	g := kf.Upstream.Git
+	status := &Status{
+		Package:   filepath.ToSlash(rel),
+		Repo:      g.Repo,
+		Directory: g.Directory,
+		Ref:       g.Ref,
+	}
+
+	repoSpec := &git.RepoSpec{OrgRepo: g.Repo, Path: g.Directory, Ref: g.Ref}
+	upstreamRepo, err := c.upstreamRepo(ctx, repoSpec.CloneSpec())
+	if err != nil {
+		return nil, err
+	}
+	status.LatestCommit, err = fetch.ResolveRef(ctx, upstreamRepo, repoSpec)
+	if err != nil {
+		return nil, err
+	}
+	status.LatestTag = latestTag(upstreamRepo, g)
+
+	lock := kf.UpstreamLock
+	if lock == nil || lock.Git == nil {
+		// the package has never been fetched
+		status.Outdated = true
+		return status, nil
+	}
+	status.CurrentRef = lock.Git.Ref
+	status.CurrentCommit = lock.Git.Commit
+
+	// the ref can be an abbreviated commit
+	if !strings.HasPrefix(status.CurrentCommit, status.LatestCommit) {
+		status.Outdated = true
+		status.CommitsBehind, err = upstreamRepo.CommitsBehind(ctx, status.CurrentCommit, status.LatestCommit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, current, found := gitutil.SplitVersionTag(status.CurrentRef); found && status.LatestTag != "" {
+		_, latest, _ := gitutil.SplitVersionTag(status.LatestTag)
+		if semver.Compare(latest, current) > 0 {
+			status.Outdated = true
+		}
+	}
+
+	status.LocalChanges, err = c.hasLocalChanges(ctx, path, lock.Git)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// ociPackageStatus returns the status of the package at the path with an
+// OCI upstream. Images have no history, so the package is outdated if the
+// upstream image resolves to a different digest than the one it was
+// fetched from.
+func (c *Command) ociPackageStatus(ctx context.Context, rel, path string, kf *kptfilev1.KptFile) (*Status, error) {
+	o := kf.Upstream.Oci
+	ref, err := name.ParseReference(o.Image)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse image name %q: %w", o.Image, err)
+	}
+	status := &Status{
+		Package:   rel,
+		Repo:      ref.Context().String(),
+		Directory: o.Directory,
+		Ref:       ref.Identifier(),
+	}
+	status.LatestDigest, err = c.imageDigest(ctx, o.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := kf.UpstreamLock
+	if lock == nil || lock.Oci == nil {
+		// the package has never been fetched
+		status.Outdated = true
+		return status, nil
+	}
+	status.CurrentRef, err = oci.ImageReference(lock.Oci.Image)
+	if err != nil {
+		return nil, err
+	}
+	status.CurrentDigest = lock.Oci.Digest
+	status.Outdated = status.CurrentDigest != status.LatestDigest
+
+	status.LocalChanges, err = hasOciLocalChanges(ctx, path, lock.Oci)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// imageDigest returns the digest the image resolves to, which is resolved
+// once and then cached.
+func (c *Command) imageDigest(ctx context.Context, image string) (string, error) {
+	if digest, found := c.cachedDigests[image]; found {
+		return digest, nil
+	}
+	digest, err := oci.ImageDigest(ctx, image)
+	if err != nil {
+		return "", err
+	}
+	c.cachedDigests[image] = digest
+	return digest, nil
+}
+
+// upstreamRepo returns the upstream repo for the clone spec, which is
+// queried once and then cached.
+func (c *Command) upstreamRepo(ctx context.Context, cloneSpec string) (*gitutil.GitUpstreamRepo, error) {
+	if upstreamRepo, found := c.cachedUpstreamRepos[cloneSpec]; found {
+		return upstreamRepo, nil
+	}
+	upstreamRepo, err := gitutil.NewGitUpstreamRepo(ctx, cloneSpec)
+	if err != nil {
+		return nil, err
+	}
+	c.cachedUpstreamRepos[cloneSpec] = upstreamRepo
+	return upstreamRepo, nil
+}
+
+// latestTag returns the tag with the highest version among the tags with the
+// same prefix as the ref, the tags prefixed with the package directory
+// taking precedence.
+func latestTag(upstreamRepo *gitutil.GitUpstreamRepo, g *kptfilev1.Git) string {
+	prefix := ""
+	if gitutil.IsVersionConstraint(g.Ref) {
+		if constraint, err := gitutil.ParseVersionConstraint(g.Ref); err == nil {
+			prefix = constraint.Prefix
+		}
+	} else if p, _, found := gitutil.SplitVersionTag(g.Ref); found {
+		prefix = p
+	}
+	for _, packagePrefix := range append(gitutil.PackageTagPrefixes(g.Directory), "") {
+		if tag, found := upstreamRepo.LatestVersion(packagePrefix + prefix); found {
+			return tag
+		}
+	}
+	return ""
+}
+
+// hasLocalChanges returns true if the package differs from the upstream
+// package it was fetched from.
+func (c *Command) hasLocalChanges(ctx context.Context, path string, lock *kptfilev1.GitLock) (bool, error) {
+	origin := &git.RepoSpec{OrgRepo: lock.Repo, Path: lock.Directory, Ref: lock.Commit}
+	if err := fetch.NewCloner(origin, fetch.WithCachedRepo(c.cachedUpstreamRepos)).ClonerUsingGitExec(ctx); err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(origin.Dir)
+	// the merge comments were added to the local package when it was fetched
+	if err := addmergecomment.Process(origin.AbsPath()); err != nil {
+		return false, err
+	}
+	changes, err := update.LocalChanges(path, origin.AbsPath())
+	if err != nil {
+		return false, err
+	}
+	return len(changes) > 0, nil
+}
+
+// hasOciLocalChanges returns true if the package differs from the image it
+// was pulled from, which is pulled again by digest.
+func hasOciLocalChanges(ctx context.Context, path string, lock *kptfilev1.OciLock) (bool, error) {
+	image, err := oci.ReplaceReference(lock.Image, lock.Digest)
+	if err != nil {
+		return false, err
+	}
+	origin, err := fetch.PullOciPackage(ctx, image, lock.Directory)
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(origin.Dir)
+	// the merge comments were added to the local package when it was fetched
+	if err := addmergecomment.Process(origin.AbsPath()); err != nil {
+		return false, err
+	}
+	changes, err := update.LocalChanges(path, origin.AbsPath())
+	if err != nil {
+		return false, err
+	}
+	return len(changes) > 0, nil
+}
+
+// PrintStatuses prints the statuses of the packages as a table.
+func PrintStatuses(w io.Writer, statuses []Status) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PACKAGE\tREF\tCURRENT\tLATEST TAG\tBEHIND\tLOCAL CHANGES\tSTATUS")
+	for _, s := range statuses {
+		current := s.CurrentRef
+		if current == "" {
+			current = "-"
+		} else if s.CurrentCommit != "" && current != s.CurrentCommit {
+			current += "@" + shortCommit(s.CurrentCommit)
+		} else if s.CurrentDigest != "" && current != s.CurrentDigest {
+			current += "@" + shortDigest(s.CurrentDigest)
+		}
+		latestTag := s.LatestTag
+		if latestTag == "" {
+			latestTag = "-"
+		}
+		localChanges := "no"
+		if s.LocalChanges {
+			localChanges = "yes"
+		}
+		state := "up to date"
+		if s.Outdated {
+			state = "outdated"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", s.Package, s.Ref, current,
+			latestTag, s.CommitsBehind, localChanges, state)
+	}
+	return tw.Flush()
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
+
+func shortDigest(digest string) string {
+	algorithm, hex, found := strings.Cut(digest, ":")
+	if !found {
+		return shortCommit(digest)
+	}
+	return algorithm + ":" + shortCommit(hex)
+}
@@ -0,0 +1,244 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outdated_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pkgtest "github.com/GoogleContainerTools/kpt/internal/pkg/testing"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/get"
+	. "github.com/GoogleContainerTools/kpt/internal/util/outdated"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/oci"
+	"github.com/GoogleContainerTools/kpt/pkg/printer/fake"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.ConfigureTestKptCache(m))
+}
+
+// TestCommand_Run verifies that a package fetched from an older commit of
+// its upstream is reported as outdated, with the latest tag and the number
+// of commits it is behind.
+func TestCommand_Run(t *testing.T) {
+	g := &testutil.TestSetupManager{
+		T: t,
+		ReposChanges: map[string][]testutil.Content{
+			testutil.Upstream: {
+				{Data: testutil.Dataset1, Branch: "master", Tag: "v1.0.0"},
+				{Data: testutil.Dataset2, Tag: "v1.1.0"},
+				{Data: testutil.Dataset3},
+			},
+		},
+	}
+	defer g.Clean()
+	if !g.Init() {
+		return
+	}
+	upstreamRepo := g.Repos[testutil.Upstream]
+
+	statuses, err := (&Command{
+		Pkg: pkgtest.CreatePkgOrFail(t, g.LocalWorkspace.FullPackagePath()),
+	}).Run(fake.CtxWithDefaultPrinter())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []Status{
+		{
+			Package:       ".",
+			Repo:          upstreamRepo.RepoDirectory,
+			Directory:     "/",
+			Ref:           "master",
+			CurrentRef:    "master",
+			CurrentCommit: upstreamRepo.Commits[0],
+			LatestCommit:  upstreamRepo.Commits[2],
+			LatestTag:     "v1.1.0",
+			CommitsBehind: 2,
+			Outdated:      true,
+		},
+	}, statuses)
+
+	// change the package locally
+	f := filepath.Join(g.LocalWorkspace.FullPackagePath(), "java", "java-service.resource.yaml")
+	if !assert.NoError(t, os.WriteFile(f, []byte("kind: Service\n"), 0600)) {
+		t.FailNow()
+	}
+	statuses, err = (&Command{
+		Pkg: pkgtest.CreatePkgOrFail(t, g.LocalWorkspace.FullPackagePath()),
+	}).Run(fake.CtxWithDefaultPrinter())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if assert.Len(t, statuses, 1) {
+		assert.True(t, statuses[0].LocalChanges)
+	}
+}
+
+// TestCommand_Run_upToDate verifies that a package fetched from the latest
+// commit of its upstream isn't reported as outdated.
+func TestCommand_Run_upToDate(t *testing.T) {
+	g := &testutil.TestSetupManager{
+		T: t,
+		ReposChanges: map[string][]testutil.Content{
+			testutil.Upstream: {
+				{Data: testutil.Dataset1, Branch: "master"},
+			},
+		},
+	}
+	defer g.Clean()
+	if !g.Init() {
+		return
+	}
+	upstreamRepo := g.Repos[testutil.Upstream]
+
+	statuses, err := (&Command{
+		Pkg: pkgtest.CreatePkgOrFail(t, g.LocalWorkspace.FullPackagePath()),
+	}).Run(fake.CtxWithDefaultPrinter())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []Status{
+		{
+			Package:       ".",
+			Repo:          upstreamRepo.RepoDirectory,
+			Directory:     "/",
+			Ref:           "master",
+			CurrentRef:    "master",
+			CurrentCommit: upstreamRepo.Commits[0],
+			LatestCommit:  upstreamRepo.Commits[0],
+		},
+	}, statuses)
+}
+
+// TestCommand_Run_oci verifies that a package fetched from an OCI image is
+// reported as outdated once its tag is pushed again with other content.
+func TestCommand_Run_oci(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	image := u.Host + "/blueprints/datasets"
+
+	testDataDir, err := testutil.GetTestDataPath()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	ctx := fake.CtxWithDefaultPrinter()
+	v1Digest, err := oci.PushPackage(ctx, filepath.Join(testDataDir, testutil.Dataset1), image+":main")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	dest := filepath.Join(t.TempDir(), "java")
+	err = get.Command{
+		Oci: &kptfilev1.Oci{
+			Image:     image + ":main",
+			Directory: "java",
+		},
+		Destination: dest,
+	}.Run(ctx)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	statuses, err := (&Command{Pkg: pkgtest.CreatePkgOrFail(t, dest)}).Run(ctx)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []Status{
+		{
+			Package:       ".",
+			Repo:          image,
+			Directory:     "java",
+			Ref:           "main",
+			CurrentRef:    "main",
+			CurrentDigest: v1Digest,
+			LatestDigest:  v1Digest,
+		},
+	}, statuses)
+
+	v2Digest, err := oci.PushPackage(ctx, filepath.Join(testDataDir, testutil.Dataset2), image+":main")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// change the package locally
+	f := filepath.Join(dest, "java-service.resource.yaml")
+	if !assert.NoError(t, os.WriteFile(f, []byte("kind: Service\n"), 0600)) {
+		t.FailNow()
+	}
+	statuses, err = (&Command{Pkg: pkgtest.CreatePkgOrFail(t, dest)}).Run(ctx)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []Status{
+		{
+			Package:       ".",
+			Repo:          image,
+			Directory:     "java",
+			Ref:           "main",
+			CurrentRef:    "main",
+			CurrentDigest: v1Digest,
+			LatestDigest:  v2Digest,
+			LocalChanges:  true,
+			Outdated:      true,
+		},
+	}, statuses)
+}
+
+func TestPrintStatuses(t *testing.T) {
+	b := &bytes.Buffer{}
+	err := PrintStatuses(b, []Status{
+		{
+			Package:       ".",
+			Ref:           "main",
+			CurrentRef:    "main",
+			CurrentCommit: "0123456789abcdef",
+			LatestTag:     "v1.1.0",
+			CommitsBehind: 2,
+			LocalChanges:  true,
+			Outdated:      true,
+		},
+		{
+			Package:       "sub",
+			Ref:           "v1.1.0",
+			CurrentRef:    "v1.1.0",
+			CurrentCommit: "fedcba9876543210",
+			LatestTag:     "v1.1.0",
+		},
+		{
+			Package:       "oci",
+			Ref:           "main",
+			CurrentRef:    "main",
+			CurrentDigest: "sha256:0123456789abcdef",
+			LatestDigest:  "sha256:fedcba9876543210",
+			Outdated:      true,
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `PACKAGE  REF     CURRENT              LATEST TAG  BEHIND  LOCAL CHANGES  STATUS
+.        main    main@0123456         v1.1.0      2       yes            outdated
+sub      v1.1.0  v1.1.0@fedcba9       v1.1.0      0       no             up to date
+oci      main    main@sha256:0123456  -           0       no             outdated
+`, b.String())
+}
@@ -25,6 +25,7 @@ import (
 
 	"github.com/GoogleContainerTools/kpt/internal/gitutil"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/google/go-containerregistry/pkg/name"
 	"sigs.k8s.io/kustomize/kyaml/errors"
 )
 
@@ -35,6 +36,45 @@ type Target struct {
 	Destination string
 }
 
+// OciPrefix is the prefix of the package arguments referring to an image
+// in an OCI registry.
+const OciPrefix = "oci://"
+
+// OciTarget is the OCI locator and local destination of a package.
+type OciTarget struct {
+	kptfilev1.Oci
+	Destination string
+}
+
+// IsOciArg returns true if the package argument refers to an image in an
+// OCI registry.
+func IsOciArg(arg string) bool {
+	return strings.HasPrefix(arg, OciPrefix)
+}
+
+// OciParseArgs parses the package argument `oci://IMAGE[//DIRECTORY]` and
+// the destination into an OciTarget. The image defaults to the `latest`
+// tag and the directory to the root of the image.
+func OciParseArgs(args []string) (OciTarget, error) {
+	t := OciTarget{}
+	image, dir, _ := strings.Cut(strings.TrimPrefix(args[0], OciPrefix), "//")
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return t, errors.Errorf("invalid image %q: %v", image, err)
+	}
+	if dir == "" {
+		dir = "/"
+	}
+	destination, err := getDest(args[1], ref.Context().RepositoryStr(), dir)
+	if err != nil {
+		return t, err
+	}
+	t.Image = ref.Name()
+	t.Directory = path.Clean(dir)
+	t.Destination = filepath.Clean(destination)
+	return t, nil
+}
+
 func GitParseArgs(ctx context.Context, args []string) (Target, error) {
 	g := Target{}
 	if args[0] == "-" {
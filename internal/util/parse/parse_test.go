@@ -267,3 +267,44 @@ func Test_GitParseArgs(t *testing.T) {
 		})
 	}
 }
+
+func Test_OciParseArgs(t *testing.T) {
+	tests := map[string]struct {
+		arg      string
+		expected OciTarget
+	}{
+		"image with tag": {
+			arg: "oci://us-docker.pkg.dev/my-project/blueprints/cockroachdb:v1",
+			expected: OciTarget{Oci: v1.Oci{
+				Image:     "us-docker.pkg.dev/my-project/blueprints/cockroachdb:v1",
+				Directory: "/",
+			},
+				Destination: "cockroachdb"},
+		},
+		"image without tag": {
+			arg: "oci://us-docker.pkg.dev/my-project/blueprints/cockroachdb",
+			expected: OciTarget{Oci: v1.Oci{
+				Image:     "us-docker.pkg.dev/my-project/blueprints/cockroachdb:latest",
+				Directory: "/",
+			},
+				Destination: "cockroachdb"},
+		},
+		"image with directory": {
+			arg: "oci://us-docker.pkg.dev/my-project/blueprints:v1//staging/cockroachdb",
+			expected: OciTarget{Oci: v1.Oci{
+				Image:     "us-docker.pkg.dev/my-project/blueprints:v1",
+				Directory: "staging/cockroachdb",
+			},
+				Destination: "cockroachdb"},
+		},
+	}
+	for name, test := range tests {
+		test := test // capture range variable
+		t.Run(name, func(t *testing.T) {
+			assert.True(t, IsOciArg(test.arg))
+			actual, err := OciParseArgs([]string{test.arg, ""})
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
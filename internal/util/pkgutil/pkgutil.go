@@ -30,6 +30,11 @@ import (
 	"sigs.k8s.io/kustomize/kyaml/kio/filters"
 )
 
+// ConflictReportFileName is the name of the file in the root package where
+// `kpt pkg update` records the conflicts it could not merge. The report is
+// local to a package, so it is never copied between packages.
+const ConflictReportFileName = ".kptconflicts"
+
 // WalkPackage walks the package defined at src and provides a callback for
 // every folder and file. Any subpackages and the .git folder are excluded.
 func WalkPackage(src string, c func(string, os.FileInfo, error) error) error {
@@ -67,7 +72,8 @@ func WalkPackage(src string, c func(string, os.FileInfo, error) error) error {
 }
 
 // CopyPackage copies the content of a single package from src to dst. If includeSubpackages
-// is true, it will copy resources belonging to any subpackages.
+// is true, it will copy resources belonging to any subpackages. Conflict reports are not
+// copied.
 func CopyPackage(src, dst string, copyRootKptfile bool, matcher pkg.SubpackageMatcher) error {
 	subpackagesToCopy, err := pkg.Subpackages(filesys.FileSystemOrOnDisk{}, src, matcher, true)
 	if err != nil {
@@ -97,6 +103,9 @@ func CopyPackage(src, dst string, copyRootKptfile bool, matcher pkg.SubpackageMa
 		if info.IsDir() {
 			return os.MkdirAll(filepath.Join(dst, copyTo), info.Mode())
 		}
+		if info.Name() == ConflictReportFileName {
+			return nil
+		}
 
 		if path == filepath.Join(src, kptfilev1.KptFileName) && !copyRootKptfile {
 			return nil
@@ -138,6 +147,9 @@ func CopyPackage(src, dst string, copyRootKptfile bool, matcher pkg.SubpackageMa
 			if info.IsDir() {
 				return os.MkdirAll(filepath.Join(dst, copyTo), info.Mode())
 			}
+			if info.Name() == ConflictReportFileName {
+				return nil
+			}
 
 			if copyTo == "/Kptfile" {
 				_, err := os.Stat(filepath.Join(dst, copyTo))
@@ -16,17 +16,18 @@ package printerutil
 
 import (
 	"context"
+	"strings"
 
 	"github.com/GoogleContainerTools/kpt/pkg/printer"
 )
 
-// PrintFnResultInfo displays information about the function results file.
-func PrintFnResultInfo(ctx context.Context, resultsFile string, withNewLine bool) {
+// PrintFnResultInfo displays information about the function results files.
+func PrintFnResultInfo(ctx context.Context, resultsFiles []string, withNewLine bool) {
 	pr := printer.FromContextOrDie(ctx)
-	if resultsFile != "" {
+	if len(resultsFiles) > 0 {
 		if withNewLine {
 			pr.Printf("\n")
 		}
-		pr.Printf("For complete results, see %s\n", resultsFile)
+		pr.Printf("For complete results, see %s\n", strings.Join(resultsFiles, ", "))
 	}
 }
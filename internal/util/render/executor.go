@@ -15,12 +15,15 @@
 package render
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/GoogleContainerTools/kpt/internal/errors"
 	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
@@ -52,6 +55,10 @@ type Renderer struct {
 	// ResultsDirPath is absolute path to the directory to write results
 	ResultsDirPath string
 
+	// ResultsFormats are the formats to write the results in. Defaults to
+	// `yaml`.
+	ResultsFormats []string
+
 	// fnResultsList is the list of results from the pipeline execution
 	fnResultsList *fnresult.ResultList
 
@@ -61,8 +68,36 @@ type Renderer struct {
 	// RunnerOptions contains options controlling function execution.
 	RunnerOptions fnruntime.RunnerOptions
 
-	// FileSystem is the input filesystem to operate on
+	// FileSystem is the input filesystem to operate on. With a
+	// RunnerOptions.Parallelism greater than 1, it is read concurrently by
+	// the sibling subpackages, and only written to once they are hydrated.
 	FileSystem filesys.FileSystem
+
+	// TraceDir is the path to the directory to write the trace of the
+	// pipeline steps to. Tracing is disabled if it is empty.
+	TraceDir string
+
+	// keepOutputs keeps the output of every package after a render so that
+	// the next render can reuse the packages which are not affected by the
+	// changes since. It is set by Watcher.
+	keepOutputs bool
+
+	// previous are the packages hydrated by the last render, keyed by their
+	// unique paths, minus the ones affected by the changes since.
+	previous map[types.UniquePath]*pkgNode
+
+	// summary summarizes the last render.
+	summary renderSummary
+}
+
+// renderSummary summarizes a render.
+type renderSummary struct {
+	// rendered is the number of packages whose pipeline was run.
+	rendered int
+	// reused is the number of packages whose previous output was reused.
+	reused int
+	// functions is the number of functions executed.
+	functions int
 }
 
 // Execute runs a pipeline.
@@ -84,15 +119,45 @@ func (e *Renderer) Execute(ctx context.Context) (*fnresult.ResultList, error) {
 		runnerOptions: e.RunnerOptions,
 		fileSystem:    e.FileSystem,
 		runtime:       e.Runtime,
+		keepOutputs:   e.keepOutputs,
+		previous:      e.previous,
 	}
+	// packages are only reused if the render succeeds.
+	e.previous = nil
+	if e.RunnerOptions.Parallelism > 1 {
+		hctx.sem = make(chan struct{}, e.RunnerOptions.Parallelism)
+	}
+	if e.TraceDir != "" {
+		hctx.tracer = &tracer{fsys: e.FileSystem, dir: e.TraceDir}
+	}
+	// results of the root package subtree are gathered directly in the
+	// hydration context.
+	root.fnResults = hctx.fnResults
 
-	if _, err = hydrate(ctx, root, hctx); err != nil {
+	_, err = hydrate(ctx, root, hctx)
+	if hctx.tracer != nil {
+		// the trace of a failed render is written too, ignoring the error
+		// to avoid masking the hydration error.
+		if flushErr := hctx.tracer.flush(); err == nil && flushErr != nil {
+			return nil, errors.E(op, root.pkg.UniquePath, flushErr)
+		}
+	}
+	if err != nil {
 		// Note(droot): ignore the error in function result saving
 		// to avoid masking the hydration error.
 		// don't disable the CLI output in case of error
 		_ = e.saveFnResults(ctx, hctx.fnResults)
 		return hctx.fnResults, errors.E(op, root.pkg.UniquePath, err)
 	}
+	e.summary = renderSummary{
+		rendered:  len(hctx.pkgs) - hctx.reusedRootCnt,
+		reused:    hctx.reusedPkgCnt,
+		functions: hctx.executedFunctionCnt,
+	}
+	if e.keepOutputs {
+		e.previous = map[types.UniquePath]*pkgNode{}
+		root.walk(func(pn *pkgNode) { e.previous[pn.pkg.UniquePath] = pn })
+	}
 
 	err = adjustRelResourcesPath(hctx.root.resources, hctx.root.pkg, hctx.root.pkg)
 	if err != nil {
@@ -153,12 +218,12 @@ func (e *Renderer) Execute(ctx context.Context) (*fnresult.ResultList, error) {
 
 func (e *Renderer) saveFnResults(ctx context.Context, fnResults *fnresult.ResultList) error {
 	e.fnResultsList = fnResults
-	resultsFile, err := fnruntime.SaveResults(e.FileSystem, e.ResultsDirPath, fnResults)
+	resultsFiles, err := fnruntime.SaveResults(e.FileSystem, e.ResultsDirPath, fnResults, e.ResultsFormats, e.PkgPath)
 	if err != nil {
 		return fmt.Errorf("failed to save function results: %w", err)
 	}
 
-	printerutil.PrintFnResultInfo(ctx, resultsFile, false)
+	printerutil.PrintFnResultInfo(ctx, resultsFiles, false)
 	return nil
 }
 
@@ -195,6 +260,39 @@ type hydrationContext struct {
 
 	// function runtime
 	runtime fn.FunctionRuntime
+
+	// tracer records the steps of the pipelines, if tracing is enabled.
+	tracer *tracer
+
+	// sem bounds the number of package pipelines running concurrently.
+	// It is nil when sibling subpackages are hydrated sequentially.
+	sem chan struct{}
+
+	// keepOutputs keeps a copy of the output of every package, see
+	// Renderer.keepOutputs.
+	keepOutputs bool
+
+	// previous are the packages hydrated by a previous render whose output
+	// can be reused, see Renderer.previous.
+	previous map[types.UniquePath]*pkgNode
+
+	// reusedRootCnt is the number of reused packages whose parent was
+	// hydrated, and reusedPkgCnt is the number of reused packages including
+	// their subpackages.
+	reusedRootCnt int
+	reusedPkgCnt  int
+
+	// mu guards pkgs, inputFiles, executedFunctionCnt and the reused
+	// package counters which are shared by sibling subpackages hydrated
+	// concurrently.
+	mu sync.Mutex
+}
+
+// incExecutedFunctionCnt increments the counter of executed functions.
+func (hctx *hydrationContext) incExecutedFunctionCnt() {
+	hctx.mu.Lock()
+	defer hctx.mu.Unlock()
+	hctx.executedFunctionCnt++
 }
 
 // pkgNode represents a package being hydrated. Think of it as a node in the hydration DAG.
@@ -207,6 +305,33 @@ type pkgNode struct {
 	// KRM resources that we have gathered post hydration for this package.
 	// These inludes resources at this pkg as well all it's children.
 	resources []*yaml.RNode
+
+	// fnResults stores the function results of this package and all
+	// it's children, in hydration order.
+	fnResults *fnresult.ResultList
+
+	// trace records the steps of the pipeline of this package, if
+	// tracing is enabled.
+	trace *pkgTrace
+
+	// inputFiles are the files of the local resources of the package,
+	// relative to the root package.
+	inputFiles []string
+
+	// subpkgs are the hydrated direct subpackages of the package.
+	subpkgs []*pkgNode
+
+	// output is a copy of the resources of the package post hydration,
+	// kept to be reused by a later render. See Renderer.keepOutputs.
+	output []*yaml.RNode
+}
+
+// walk calls f for the package and all its hydrated subpackages.
+func (pn *pkgNode) walk(f func(*pkgNode)) {
+	f(pn)
+	for _, sub := range pn.subpkgs {
+		sub.walk(f)
+	}
 }
 
 // newPkgNode returns a pkgNode instance given a path or pkg.
@@ -235,8 +360,9 @@ func newPkgNode(fsys filesys.FileSystem, path string, p *pkg.Pkg) (pn *pkgNode,
 	}
 
 	pn = &pkgNode{
-		pkg:   p,
-		state: Dry, // package starts in dry state
+		pkg:       p,
+		state:     Dry, // package starts in dry state
+		fnResults: fnresult.NewResultList(),
 	}
 	return pn, nil
 }
@@ -259,26 +385,46 @@ func (s hydrationState) String() string {
 func hydrate(ctx context.Context, pn *pkgNode, hctx *hydrationContext) (output []*yaml.RNode, err error) {
 	const op errors.Op = "pkg.render"
 
+	hctx.mu.Lock()
 	curr, found := hctx.pkgs[pn.pkg.UniquePath]
 	if found {
-		switch curr.state {
+		state, resources := curr.state, curr.resources
+		hctx.mu.Unlock()
+		switch state {
 		case Hydrating:
 			// we detected a cycle
 			err = fmt.Errorf("cycle detected in pkg dependencies")
 			return output, errors.E(op, curr.pkg.UniquePath, err)
 		case Wet:
-			output = curr.resources
+			output = resources
 			return output, nil
 		default:
 			return output, errors.E(op, curr.pkg.UniquePath,
-				fmt.Errorf("package found in invalid state %v", curr.state))
+				fmt.Errorf("package found in invalid state %v", state))
 		}
 	}
 	// add it to the discovered package list
 	hctx.pkgs[pn.pkg.UniquePath] = pn
+	if prev, found := hctx.previous[pn.pkg.UniquePath]; found {
+		// none of the files of the package tree changed since the previous
+		// render, so its previous output is reused.
+		pn.state, pn.fnResults, pn.inputFiles, pn.subpkgs, pn.output = Wet, prev.fnResults, prev.inputFiles, prev.subpkgs, prev.output
+		pn.resources = cloneResources(prev.output)
+		if hctx.inputFiles == nil {
+			hctx.inputFiles = sets.String{}
+		}
+		pn.walk(func(p *pkgNode) {
+			hctx.inputFiles.Insert(p.inputFiles...)
+			hctx.reusedPkgCnt++
+		})
+		hctx.reusedRootCnt++
+		hctx.mu.Unlock()
+		return pn.resources, nil
+	}
 	curr = pn
 	// mark the pkg in hydrating
 	curr.state = Hydrating
+	hctx.mu.Unlock()
 
 	relPath, err := curr.pkg.RelativePathTo(hctx.root.pkg)
 	if err != nil {
@@ -293,26 +439,11 @@ func hydrate(ctx context.Context, pn *pkgNode, hctx *hydrationContext) (output [
 		return output, errors.E(op, curr.pkg.UniquePath, err)
 	}
 	// hydrate recursively and gather hydated transitive resources.
-	for _, subpkg := range subpkgs {
-		var transitiveResources []*yaml.RNode
-		var subPkgNode *pkgNode
-
-		if subPkgNode, err = newPkgNode(hctx.fileSystem, "", subpkg); err != nil {
-			return output, errors.E(op, subpkg.UniquePath, err)
-		}
-
-		transitiveResources, err = hydrate(ctx, subPkgNode, hctx)
-		if err != nil {
-			return output, errors.E(op, subpkg.UniquePath, err)
-		}
-
-		err = adjustRelResourcesPath(transitiveResources, curr.pkg, subpkg)
-		if err != nil {
-			return output, errors.E(op, subpkg.UniquePath, err)
-		}
-
-		input = append(input, transitiveResources...)
+	transitiveResources, err := hydrateSubpackages(ctx, curr, subpkgs, hctx)
+	if err != nil {
+		return output, err
 	}
+	input = append(input, transitiveResources...)
 
 	// gather resources present at the current package
 	currPkgResources, err := curr.pkg.LocalResources()
@@ -320,7 +451,7 @@ func hydrate(ctx context.Context, pn *pkgNode, hctx *hydrationContext) (output [
 		return output, errors.E(op, curr.pkg.UniquePath, err)
 	}
 
-	err = trackInputFiles(hctx, relPath, currPkgResources)
+	curr.inputFiles, err = trackInputFiles(hctx, relPath, currPkgResources)
 	if err != nil {
 		return nil, err
 	}
@@ -328,18 +459,127 @@ func hydrate(ctx context.Context, pn *pkgNode, hctx *hydrationContext) (output [
 	// include current package's resources in the input resource list
 	input = append(input, currPkgResources...)
 
+	if hctx.sem != nil {
+		hctx.sem <- struct{}{}
+	}
 	output, err = curr.runPipeline(ctx, hctx, input)
+	if hctx.sem != nil {
+		<-hctx.sem
+	}
 	if err != nil {
 		return output, errors.E(op, curr.pkg.UniquePath, err)
 	}
 
 	// pkg is hydrated, mark the pkg as wet and update the resources
+	hctx.mu.Lock()
 	curr.state = Wet
 	curr.resources = output
+	if hctx.keepOutputs {
+		curr.output = cloneResources(output)
+	}
+	hctx.mu.Unlock()
 
 	return output, err
 }
 
+// hydrateSubpackages hydrates the given direct subpackages of the current
+// package and returns their resources adjusted relative to the current package.
+// Subpackages are hydrated one after another unless parallelism is enabled,
+// in which case they are hydrated concurrently. In both cases, the resources,
+// function results and CLI output are gathered in the order of the subpackages
+// so the outcome is the same as a sequential hydration. When hydrated
+// concurrently, the subpackages after a failed one have run as well, so the
+// CLI output and function results of all of them are kept before the first
+// error is returned.
+func hydrateSubpackages(ctx context.Context, curr *pkgNode, subpkgs []*pkg.Pkg, hctx *hydrationContext) ([]*yaml.RNode, error) {
+	const op errors.Op = "pkg.render"
+
+	var input []*yaml.RNode
+	if hctx.sem == nil || len(subpkgs) < 2 {
+		for _, subpkg := range subpkgs {
+			subPkgNode, err := newPkgNode(hctx.fileSystem, "", subpkg)
+			if err != nil {
+				return nil, errors.E(op, subpkg.UniquePath, err)
+			}
+
+			transitiveResources, err := hydrate(ctx, subPkgNode, hctx)
+			mergeFnResults(curr.fnResults, subPkgNode.fnResults)
+			curr.subpkgs = append(curr.subpkgs, subPkgNode)
+			if err != nil {
+				return nil, errors.E(op, subpkg.UniquePath, err)
+			}
+
+			err = adjustRelResourcesPath(transitiveResources, curr.pkg, subpkg)
+			if err != nil {
+				return nil, errors.E(op, subpkg.UniquePath, err)
+			}
+
+			input = append(input, transitiveResources...)
+		}
+		return input, nil
+	}
+
+	var subPkgNodes []*pkgNode
+	for _, subpkg := range subpkgs {
+		subPkgNode, err := newPkgNode(hctx.fileSystem, "", subpkg)
+		if err != nil {
+			return nil, errors.E(op, subpkg.UniquePath, err)
+		}
+		subPkgNodes = append(subPkgNodes, subPkgNode)
+	}
+
+	pr := printer.FromContextOrDie(ctx)
+	outputs := make([][]*yaml.RNode, len(subPkgNodes))
+	errs := make([]error, len(subPkgNodes))
+	logs := make([]bytes.Buffer, len(subPkgNodes))
+	var wg sync.WaitGroup
+	for i := range subPkgNodes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// buffer the CLI output of every subpackage so that it can be
+			// displayed in order once all of them are hydrated.
+			subCtx := printer.WithContext(ctx, printer.New(pr.OutStream(), &logs[i]))
+			outputs[i], errs[i] = hydrate(subCtx, subPkgNodes[i], hctx)
+		}(i)
+	}
+	wg.Wait()
+
+	var firstErr error
+	for i, subPkgNode := range subPkgNodes {
+		_, _ = pr.ErrStream().Write(logs[i].Bytes())
+		mergeFnResults(curr.fnResults, subPkgNode.fnResults)
+		curr.subpkgs = append(curr.subpkgs, subPkgNode)
+		if firstErr != nil {
+			continue
+		}
+		if errs[i] != nil {
+			firstErr = errors.E(op, subPkgNode.pkg.UniquePath, errs[i])
+			continue
+		}
+
+		err := adjustRelResourcesPath(outputs[i], curr.pkg, subPkgNode.pkg)
+		if err != nil {
+			firstErr = errors.E(op, subPkgNode.pkg.UniquePath, err)
+			continue
+		}
+
+		input = append(input, outputs[i]...)
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return input, nil
+}
+
+// mergeFnResults appends the function results in src to dst.
+func mergeFnResults(dst, src *fnresult.ResultList) {
+	dst.Items = append(dst.Items, src.Items...)
+	if src.ExitCode != 0 {
+		dst.ExitCode = src.ExitCode
+	}
+}
+
 // runPipeline runs the pipeline defined at current pkgNode on given input resources.
 func (pn *pkgNode) runPipeline(ctx context.Context, hctx *hydrationContext, input []*yaml.RNode) ([]*yaml.RNode, error) {
 	const op errors.Op = "pipeline.run"
@@ -366,6 +606,16 @@ func (pn *pkgNode) runPipeline(ctx context.Context, hctx *hydrationContext, inpu
 		return nil, err
 	}
 
+	if hctx.tracer != nil {
+		relPath, err := pn.pkg.RelativePathTo(hctx.root.pkg)
+		if err != nil {
+			return nil, err
+		}
+		if pn.trace, err = hctx.tracer.newPkgTrace(relPath, input); err != nil {
+			return nil, err
+		}
+	}
+
 	mutatedResources, err := pn.runMutators(ctx, hctx, input)
 	if err != nil {
 		return nil, errors.E(op, pn.pkg.UniquePath, err)
@@ -390,12 +640,26 @@ func (pn *pkgNode) runMutators(ctx context.Context, hctx *hydrationContext, inpu
 		return input, nil
 	}
 
-	mutators, err := fnChain(ctx, hctx, pn.pkg.UniquePath, pl.Mutators)
+	mutators, err := fnChain(ctx, hctx, pn, pl.Mutators)
 	if err != nil {
 		return nil, err
 	}
 
 	for i, mutator := range mutators {
+		// the `when` condition is evaluated against all the resources,
+		// not only the selected ones.
+		run, err := mutator.ShouldRun(input)
+		if err != nil {
+			return nil, err
+		}
+		if !run {
+			mutator.Skip()
+			if err := pn.trace.record("mutator", &pl.Mutators[i], mutator, StepSkipped, 0, nil); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
 		if pl.Mutators[i].ConfigPath != "" {
 			// kpt v1.0.0-beta15+ onwards, functionConfigs are included in the
 			// function inputs during `render` and as a result, they can be
@@ -443,11 +707,15 @@ func (pn *pkgNode) runMutators(ctx context.Context, hctx *hydrationContext, inpu
 			Filters: []kio.Filter{mutator},
 			Outputs: []kio.Writer{output},
 		}
+		t0 := time.Now()
 		err = mutation.Execute()
 		if err != nil {
+			// don't mask the function error with a tracing error.
+			_ = pn.trace.record("mutator", &pl.Mutators[i], mutator, StepFailed, time.Since(t0), nil)
 			return nil, err
 		}
-		hctx.executedFunctionCnt++
+		d := time.Since(t0)
+		hctx.incExecutedFunctionCnt()
 
 		if len(selectors) > 0 || len(exclusions) > 0 {
 			// merge the output resources with input resources
@@ -460,6 +728,9 @@ func (pn *pkgNode) runMutators(ctx context.Context, hctx *hydrationContext, inpu
 		} else {
 			input = output.Nodes
 		}
+		if err := pn.trace.record("mutator", &pl.Mutators[i], mutator, StepPassed, d, input); err != nil {
+			return nil, err
+		}
 	}
 	return input, nil
 }
@@ -477,6 +748,10 @@ func (pn *pkgNode) runValidators(ctx context.Context, hctx *hydrationContext, in
 	if len(pl.Validators) == 0 {
 		return nil
 	}
+	kf, err := pn.pkg.Kptfile()
+	if err != nil {
+		return err
+	}
 
 	for i := range pl.Validators {
 		function := pl.Validators[i]
@@ -486,7 +761,6 @@ func (pn *pkgNode) runValidators(ctx context.Context, hctx *hydrationContext, in
 		if err != nil {
 			return err
 		}
-		var validator kio.Filter
 		displayResourceCount := false
 		if len(function.Selectors) > 0 || len(function.Exclusions) > 0 {
 			displayResourceCount = true
@@ -497,14 +771,32 @@ func (pn *pkgNode) runValidators(ctx context.Context, hctx *hydrationContext, in
 		opts := hctx.runnerOptions
 		opts.SetPkgPathAnnotation = true
 		opts.DisplayResourceCount = displayResourceCount
-		validator, err = fnruntime.NewRunner(ctx, hctx.fileSystem, &function, pn.pkg.UniquePath, hctx.fnResults, opts, hctx.runtime)
+		opts.FunctionLock = kf.FunctionLock
+		validator, err := fnruntime.NewRunner(ctx, hctx.fileSystem, &function, pn.pkg.UniquePath, pn.fnResults, opts, hctx.runtime)
+		if err != nil {
+			return err
+		}
+		run, err := validator.ShouldRun(input)
 		if err != nil {
 			return err
 		}
+		if !run {
+			validator.Skip()
+			if err := pn.trace.record("validator", &function, validator, StepSkipped, 0, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		t0 := time.Now()
 		if _, err = validator.Filter(cloneResources(selectedResources)); err != nil {
+			// don't mask the function error with a tracing error.
+			_ = pn.trace.record("validator", &function, validator, StepFailed, time.Since(t0), nil)
+			return err
+		}
+		hctx.incExecutedFunctionCnt()
+		if err := pn.trace.record("validator", &function, validator, StepPassed, time.Since(t0), nil); err != nil {
 			return err
 		}
-		hctx.executedFunctionCnt++
 	}
 	return nil
 }
@@ -584,8 +876,12 @@ func pathRelToRoot(rootPkgPath, subPkgPath, resourcePath string) (relativePath s
 }
 
 // fnChain returns a slice of function runners given a list of functions defined in pipeline.
-func fnChain(ctx context.Context, hctx *hydrationContext, pkgPath types.UniquePath, fns []kptfilev1.Function) ([]*fnruntime.FunctionRunner, error) {
+func fnChain(ctx context.Context, hctx *hydrationContext, pn *pkgNode, fns []kptfilev1.Function) ([]*fnruntime.FunctionRunner, error) {
 	var runners []*fnruntime.FunctionRunner
+	kf, err := pn.pkg.Kptfile()
+	if err != nil {
+		return nil, err
+	}
 	for i := range fns {
 		var err error
 		var runner *fnruntime.FunctionRunner
@@ -600,7 +896,8 @@ func fnChain(ctx context.Context, hctx *hydrationContext, pkgPath types.UniquePa
 		opts := hctx.runnerOptions
 		opts.SetPkgPathAnnotation = true
 		opts.DisplayResourceCount = displayResourceCount
-		runner, err = fnruntime.NewRunner(ctx, hctx.fileSystem, &function, pkgPath, hctx.fnResults, opts, hctx.runtime)
+		opts.FunctionLock = kf.FunctionLock
+		runner, err = fnruntime.NewRunner(ctx, hctx.fileSystem, &function, pn.pkg.UniquePath, pn.fnResults, opts, hctx.runtime)
 		if err != nil {
 			return nil, err
 		}
@@ -609,20 +906,25 @@ func fnChain(ctx context.Context, hctx *hydrationContext, pkgPath types.UniquePa
 	return runners, nil
 }
 
-// trackInputFiles records file paths of input resources in the hydration context.
-func trackInputFiles(hctx *hydrationContext, relPath string, input []*yaml.RNode) error {
+// trackInputFiles records file paths of input resources in the hydration
+// context and returns them.
+func trackInputFiles(hctx *hydrationContext, relPath string, input []*yaml.RNode) ([]string, error) {
+	hctx.mu.Lock()
+	defer hctx.mu.Unlock()
 	if hctx.inputFiles == nil {
 		hctx.inputFiles = sets.String{}
 	}
+	var paths []string
 	for _, r := range input {
 		path, _, err := kioutil.GetFileAnnotations(r)
 		if err != nil {
-			return fmt.Errorf("path annotation missing: %w", err)
+			return nil, fmt.Errorf("path annotation missing: %w", err)
 		}
 		path = filepath.Join(relPath, filepath.Clean(path))
 		hctx.inputFiles.Insert(path)
+		paths = append(paths, path)
 	}
-	return nil
+	return paths, nil
 }
 
 // trackOutputFiles records the file paths of output resources in the hydration
@@ -15,12 +15,24 @@
 package render
 
 import (
+	"bytes"
+	"context"
+	goerrors "errors"
 	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+	"github.com/GoogleContainerTools/kpt/pkg/printer"
 	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
 func TestPathRelToRoot(t *testing.T) {
@@ -227,3 +239,384 @@ metadata:
 		})
 	}
 }
+
+// fakeRuntime returns runners that annotate the resources with the image of
+// the function after an image specific delay, or fail for the images in
+// failures.
+type fakeRuntime struct {
+	delays   map[string]time.Duration
+	failures map[string]bool
+}
+
+func (r *fakeRuntime) GetRunner(_ context.Context, f *kptfilev1.Function) (fn.FunctionRunner, error) {
+	return &fakeRunner{image: f.Image, delay: r.delays[f.Image], fail: r.failures[f.Image]}, nil
+}
+
+type fakeRunner struct {
+	image string
+	delay time.Duration
+	fail  bool
+}
+
+func (r *fakeRunner) Run(in io.Reader, out io.Writer) error {
+	time.Sleep(r.delay)
+	if r.fail {
+		return fmt.Errorf("%s failed", r.image)
+	}
+	rw := &kio.ByteReadWriter{Reader: in, Writer: out, KeepReaderAnnotations: true}
+	nodes, err := rw.Read()
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		if err := n.PipeE(yaml.SetAnnotation("rendered-by-"+r.image, "true")); err != nil {
+			return err
+		}
+	}
+	return rw.Write(nodes)
+}
+
+func TestRenderer_Parallelism(t *testing.T) {
+	kptfile := func(name, image string) string {
+		return fmt.Sprintf(`apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: %s
+pipeline:
+  mutators:
+    - image: %s
+`, name, image)
+	}
+	configMap := func(name string) string {
+		return fmt.Sprintf(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s
+`, name)
+	}
+	files := map[string]string{
+		"Kptfile":     kptfile("root", "root-fn"),
+		"cm.yaml":     configMap("root"),
+		"a/Kptfile":   kptfile("a", "a-fn"),
+		"a/cm.yaml":   configMap("a"),
+		"a/c/Kptfile": kptfile("c", "c-fn"),
+		"a/c/cm.yaml": configMap("c"),
+		"b/Kptfile":   kptfile("b", "b-fn"),
+		"b/cm.yaml":   configMap("b"),
+		"d/Kptfile":   kptfile("d", "d-fn"),
+		"d/cm.yaml":   configMap("d"),
+	}
+	// make the first subpackages the slowest ones to finish
+	runtime := &fakeRuntime{delays: map[string]time.Duration{
+		"a-fn": 30 * time.Millisecond,
+		"c-fn": 20 * time.Millisecond,
+		"b-fn": 10 * time.Millisecond,
+	}}
+
+	render := func(parallelism int) (string, string, string, []string) {
+		pkgPath := "/root"
+		traceDir := "/trace"
+		fsys := filesys.MakeFsInMemory()
+		for name, content := range files {
+			assert.NoError(t, fsys.MkdirAll(filepath.Dir(filepath.Join(pkgPath, name))))
+			assert.NoError(t, fsys.WriteFile(filepath.Join(pkgPath, name), []byte(content)))
+		}
+		var cliOutput, output bytes.Buffer
+		ctx := printer.WithContext(context.Background(), printer.New(nil, &cliOutput))
+		r := &Renderer{
+			PkgPath:    pkgPath,
+			Runtime:    runtime,
+			Output:     &output,
+			FileSystem: fsys,
+			TraceDir:   traceDir,
+			RunnerOptions: fnruntime.RunnerOptions{
+				ResolveToImage: func(_ context.Context, image string) (string, error) { return image, nil },
+				Parallelism:    parallelism,
+			},
+		}
+		results, err := r.Execute(ctx)
+		assert.NoError(t, err)
+		resultsOutput, err := yaml.Marshal(results)
+		assert.NoError(t, err)
+		steps, err := ReadTrace(fsys, traceDir)
+		assert.NoError(t, err)
+		var trace []string
+		for _, s := range steps {
+			trace = append(trace, fmt.Sprintf("%s %d %s", s.Package, s.Index, s.Function))
+		}
+		// function execution times vary between runs
+		durations := regexp.MustCompile(`" in [^\n]+`)
+		return output.String(), string(resultsOutput), durations.ReplaceAllString(cliOutput.String(), `"`), trace
+	}
+
+	expectedOutput, expectedResults, expectedCLIOutput, expectedTrace := render(1)
+	assert.Len(t, expectedTrace, 5)
+	for _, parallelism := range []int{2, 4} {
+		output, results, cliOutput, trace := render(parallelism)
+		assert.Equal(t, expectedOutput, output)
+		assert.Equal(t, expectedResults, results)
+		assert.Equal(t, expectedCLIOutput, cliOutput)
+		assert.Equal(t, expectedTrace, trace)
+	}
+}
+
+// TestRenderer_ParallelismFailure verifies that when a subpackage fails, the
+// CLI output and function results of its siblings hydrated concurrently are
+// kept, and the error of the failed subpackage is returned.
+func TestRenderer_ParallelismFailure(t *testing.T) {
+	pkgPath := "/root"
+	fsys := filesys.MakeFsInMemory()
+	for name, image := range map[string]string{"a": "a-fn", "b": "b-fn"} {
+		dir := filepath.Join(pkgPath, name)
+		assert.NoError(t, fsys.MkdirAll(dir))
+		assert.NoError(t, fsys.WriteFile(filepath.Join(dir, "Kptfile"), []byte(fmt.Sprintf(`apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: %s
+pipeline:
+  mutators:
+    - image: %s
+`, name, image))))
+		assert.NoError(t, fsys.WriteFile(filepath.Join(dir, "cm.yaml"), []byte(fmt.Sprintf(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s
+`, name))))
+	}
+	assert.NoError(t, fsys.WriteFile(filepath.Join(pkgPath, "Kptfile"), []byte(`apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: root
+`)))
+
+	var cliOutput bytes.Buffer
+	ctx := printer.WithContext(context.Background(), printer.New(nil, &cliOutput))
+	r := &Renderer{
+		PkgPath: pkgPath,
+		// the failed subpackage finishes first
+		Runtime: &fakeRuntime{
+			delays:   map[string]time.Duration{"b-fn": 20 * time.Millisecond},
+			failures: map[string]bool{"a-fn": true},
+		},
+		Output:     io.Discard,
+		FileSystem: fsys,
+		RunnerOptions: fnruntime.RunnerOptions{
+			ResolveToImage: func(_ context.Context, image string) (string, error) { return image, nil },
+			Parallelism:    2,
+		},
+	}
+	results, err := r.Execute(ctx)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "a-fn failed")
+	}
+	var images []string
+	for _, item := range results.Items {
+		images = append(images, item.Image)
+	}
+	assert.Equal(t, []string{"a-fn", "b-fn"}, images)
+	assert.Contains(t, cliOutput.String(), `[FAIL] "a-fn"`)
+	assert.Contains(t, cliOutput.String(), `[PASS] "b-fn"`)
+}
+
+func TestRenderer_When(t *testing.T) {
+	pkgPath := "/root"
+	fsys := filesys.MakeFsInMemory()
+	assert.NoError(t, fsys.MkdirAll(pkgPath))
+	assert.NoError(t, fsys.WriteFile(filepath.Join(pkgPath, "Kptfile"), []byte(`apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: root
+pipeline:
+  mutators:
+    - image: prod-fn
+      when: resources.exists(r, r.kind == "ConfigMap" && r.data.env == "prod")
+    - image: dev-fn
+      when: resources.exists(r, r.kind == "ConfigMap" && r.data.env == "dev")
+  validators:
+    - image: validate-fn
+      when: "!resources.exists(r, r.kind == \"ConfigMap\")"
+`)))
+	assert.NoError(t, fsys.WriteFile(filepath.Join(pkgPath, "cm.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: env
+data:
+  env: prod
+`)))
+
+	var cliOutput, output bytes.Buffer
+	ctx := printer.WithContext(context.Background(), printer.New(nil, &cliOutput))
+	r := &Renderer{
+		PkgPath:    pkgPath,
+		Runtime:    &fakeRuntime{},
+		Output:     &output,
+		FileSystem: fsys,
+		RunnerOptions: fnruntime.RunnerOptions{
+			ResolveToImage: func(_ context.Context, image string) (string, error) { return image, nil },
+		},
+	}
+	results, err := r.Execute(ctx)
+	assert.NoError(t, err)
+
+	assert.Contains(t, output.String(), "rendered-by-prod-fn")
+	assert.NotContains(t, output.String(), "rendered-by-dev-fn")
+	assert.Contains(t, cliOutput.String(), `[SKIPPED] "dev-fn"`)
+	assert.Contains(t, cliOutput.String(), `[SKIPPED] "validate-fn"`)
+	if assert.Len(t, results.Items, 3) {
+		assert.False(t, results.Items[0].Skipped)
+		assert.True(t, results.Items[1].Skipped)
+		assert.True(t, results.Items[2].Skipped)
+	}
+}
+
+func TestRenderer_Trace(t *testing.T) {
+	pkgPath := "/root"
+	traceDir := "/trace"
+	fsys := filesys.MakeFsInMemory()
+	assert.NoError(t, fsys.MkdirAll(filepath.Join(pkgPath, "db")))
+	assert.NoError(t, fsys.WriteFile(filepath.Join(pkgPath, "Kptfile"), []byte(`apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: root
+pipeline:
+  mutators:
+    - image: gcr.io/kpt-fn/first:v1
+    - image: gcr.io/kpt-fn/skipped:v1
+      when: "false"
+  validators:
+    - image: gcr.io/kpt-fn/check:v1
+`)))
+	assert.NoError(t, fsys.WriteFile(filepath.Join(pkgPath, "cm.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: root
+`)))
+	assert.NoError(t, fsys.WriteFile(filepath.Join(pkgPath, "db", "Kptfile"), []byte(`apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: db
+pipeline:
+  mutators:
+    - image: gcr.io/kpt-fn/db:v1
+`)))
+
+	var cliOutput bytes.Buffer
+	ctx := printer.WithContext(context.Background(), printer.New(nil, &cliOutput))
+	r := &Renderer{
+		PkgPath:    pkgPath,
+		Runtime:    &fakeRuntime{},
+		Output:     &bytes.Buffer{},
+		FileSystem: fsys,
+		TraceDir:   traceDir,
+		RunnerOptions: fnruntime.RunnerOptions{
+			ResolveToImage: func(_ context.Context, image string) (string, error) { return image, nil },
+		},
+	}
+	_, err := r.Execute(ctx)
+	assert.NoError(t, err)
+
+	steps, err := ReadTrace(fsys, traceDir)
+	assert.NoError(t, err)
+	var got []string
+	for _, s := range steps {
+		got = append(got, fmt.Sprintf("%s %d %s %s %s", s.Package, s.Index, s.Stage, s.Function, s.Status))
+	}
+	assert.Equal(t, []string{
+		". 1 mutator gcr.io/kpt-fn/first:v1 pass",
+		". 2 mutator gcr.io/kpt-fn/skipped:v1 skipped",
+		". 3 validator gcr.io/kpt-fn/check:v1 pass",
+		"db 1 mutator gcr.io/kpt-fn/db:v1 pass",
+	}, got)
+
+	assert.True(t, fsys.Exists(filepath.Join(traceDir, TraceInputFile)))
+	assert.True(t, fsys.Exists(filepath.Join(traceDir, "db", TraceInputFile)))
+	assert.Equal(t, filepath.Join(traceDir, "step-01-mutator-first-v1"), steps[0].Dir)
+	assert.False(t, fsys.Exists(filepath.Join(steps[1].Dir, TraceDiffFile)))
+	assert.False(t, fsys.Exists(filepath.Join(steps[2].Dir, TraceResourcesFile)))
+
+	resources, err := fsys.ReadFile(filepath.Join(steps[0].Dir, TraceResourcesFile))
+	assert.NoError(t, err)
+	assert.Contains(t, string(resources), "rendered-by-gcr.io/kpt-fn/first:v1")
+	diff, err := fsys.ReadFile(filepath.Join(steps[0].Dir, TraceDiffFile))
+	assert.NoError(t, err)
+	assert.Contains(t, string(diff), "+    rendered-by-gcr.io/kpt-fn/first:v1: 'true'")
+	added, removed := DiffStat(string(diff))
+	// the root resources, the Kptfiles and the subpackage resources are annotated
+	assert.Equal(t, 3, added)
+	assert.Equal(t, 0, removed)
+}
+
+func TestRenderer_FunctionLock(t *testing.T) {
+	const digest = "sha256:8ae0ee1a1b2bb2b88f0a1e4bbf6bb0b1f0bc9e1a4b0e5e2df1a6d0f5c1d3e2f4"
+	pkgPath := "/root"
+	fsys := filesys.MakeFsInMemory()
+	assert.NoError(t, fsys.MkdirAll(filepath.Join(pkgPath, "db")))
+	assert.NoError(t, fsys.WriteFile(filepath.Join(pkgPath, "Kptfile"), []byte(`apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: root
+pipeline:
+  mutators:
+    - image: gcr.io/kpt-fn/set-labels:v0.1
+functionLock:
+  - image: gcr.io/kpt-fn/set-labels:v0.1
+    digest: `+digest+`
+`)))
+	assert.NoError(t, fsys.WriteFile(filepath.Join(pkgPath, "cm.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: root
+`)))
+	assert.NoError(t, fsys.WriteFile(filepath.Join(pkgPath, "db", "Kptfile"), []byte(`apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: db
+pipeline:
+  mutators:
+    - image: gcr.io/kpt-fn/set-namespace:v0.1
+`)))
+
+	render := func(locked bool) (string, error) {
+		var output bytes.Buffer
+		ctx := printer.WithContext(context.Background(), printer.New(nil, &bytes.Buffer{}))
+		r := &Renderer{
+			PkgPath:    pkgPath,
+			Runtime:    &fakeRuntime{},
+			Output:     &output,
+			FileSystem: fsys,
+			RunnerOptions: fnruntime.RunnerOptions{
+				ResolveToImage: func(_ context.Context, image string) (string, error) { return image, nil },
+				Locked:         locked,
+			},
+		}
+		_, err := r.Execute(ctx)
+		return output.String(), err
+	}
+
+	output, err := render(false)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "rendered-by-gcr.io/kpt-fn/set-labels@"+digest)
+	assert.Contains(t, output, "rendered-by-gcr.io/kpt-fn/set-namespace:v0.1")
+
+	_, err = render(true)
+	assert.ErrorContains(t, err, `function image "gcr.io/kpt-fn/set-namespace:v0.1" is not pinned to a digest`)
+
+	// function policies are checked against the pinned images
+	policy := &fnruntime.FunctionPolicy{Path: "fn-policy.yaml"}
+	policy.RequireDigest = true
+	r := &Renderer{
+		PkgPath:    pkgPath,
+		Runtime:    &fakeRuntime{},
+		Output:     &bytes.Buffer{},
+		FileSystem: fsys,
+		RunnerOptions: fnruntime.RunnerOptions{
+			ResolveToImage: func(_ context.Context, image string) (string, error) { return image, nil },
+			Policies:       []*fnruntime.FunctionPolicy{policy},
+		},
+	}
+	_, err = r.Execute(printer.WithContext(context.Background(), printer.New(nil, &bytes.Buffer{})))
+	var violation *fnruntime.PolicyViolationError
+	if assert.True(t, goerrors.As(err, &violation)) {
+		assert.Equal(t, "gcr.io/kpt-fn/set-namespace:v0.1", violation.Function)
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Names of the files of a render trace.
+const (
+	// TraceInputFile contains the input resources of a package pipeline.
+	TraceInputFile = "input.yaml"
+	// TraceStepFile contains the metadata of a step.
+	TraceStepFile = "step.yaml"
+	// TraceResourcesFile contains the resources after a mutator step.
+	TraceResourcesFile = "resources.yaml"
+	// TraceDiffFile contains the unified diff of the resources between
+	// a mutator step and the previous one.
+	TraceDiffFile = "diff.patch"
+	// TraceStderrFile contains the stderr of the function of a step.
+	TraceStderrFile = "stderr.txt"
+)
+
+// Status of a step in a render trace.
+const (
+	StepPassed  = "pass"
+	StepFailed  = "fail"
+	StepSkipped = "skipped"
+)
+
+// TraceStep describes the run of a pipeline function recorded in a render
+// trace. A step is stored in the directory
+// <TRACE_DIR>/<PKG>/step-<INDEX>-<STAGE>-<FUNCTION>.
+type TraceStep struct {
+	// Package is the path of the package relative to the root package.
+	Package string `yaml:"package"`
+	// Index is the position of the step in the pipeline of the package,
+	// starting at 1. Validators come after the mutators.
+	Index int `yaml:"index"`
+	// Stage is either `mutator` or `validator`.
+	Stage string `yaml:"stage"`
+	// Function is the image or the executable of the function.
+	Function string `yaml:"function"`
+	// Name is the name of the function in the pipeline, if any.
+	Name string `yaml:"name,omitempty"`
+	// Status is one of `pass`, `fail` or `skipped`.
+	Status string `yaml:"status"`
+	// Duration is the time spent running the function.
+	Duration string `yaml:"duration,omitempty"`
+	// ExitCode is the exit code of the function.
+	ExitCode int `yaml:"exitCode"`
+
+	// Dir is the directory of the step in the trace.
+	Dir string `yaml:"-"`
+}
+
+// tracer records the steps of the package pipelines in a trace directory.
+// Packages are hydrated concurrently with parallelism, and the FileSystem
+// must not be written to while other packages read it, so the files are
+// buffered until flush writes them once the packages are hydrated.
+type tracer struct {
+	fsys filesys.FileSystem
+	dir  string
+
+	// mu guards files.
+	mu sync.Mutex
+	// files maps the paths of the files of the trace to their content.
+	files map[string][]byte
+}
+
+// writeFile buffers a file of the trace.
+func (t *tracer) writeFile(path string, data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.files == nil {
+		t.files = map[string][]byte{}
+	}
+	t.files[path] = data
+}
+
+// flush writes the buffered files of the trace to the FileSystem.
+func (t *tracer) flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	paths := make([]string, 0, len(t.files))
+	for p := range t.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if err := t.fsys.MkdirAll(filepath.Dir(p)); err != nil {
+			return fmt.Errorf("failed to write trace: %w", err)
+		}
+		if err := t.fsys.WriteFile(p, t.files[p]); err != nil {
+			return fmt.Errorf("failed to write trace: %w", err)
+		}
+	}
+	t.files = nil
+	return nil
+}
+
+// pkgDir returns the directory of the package trace.
+func (t *tracer) pkgDir(relPath string) string {
+	return filepath.Join(t.dir, relPath)
+}
+
+// recordInput records the input resources of the package pipeline and
+// returns them serialized, to diff the first step against.
+func (t *tracer) recordInput(relPath string, resources []*yaml.RNode) (string, error) {
+	content, err := serializeResources(resources)
+	if err != nil {
+		return "", err
+	}
+	t.writeFile(filepath.Join(t.pkgDir(relPath), TraceInputFile), []byte(content))
+	return content, nil
+}
+
+// recordStep records the metadata and stderr of a step. For mutators, the
+// resulting resources are recorded along with the diff against the previous
+// step, and the serialized resources are returned.
+func (t *tracer) recordStep(step *TraceStep, stderr, prev string, resources []*yaml.RNode) (string, error) {
+	stepDir := filepath.Join(t.pkgDir(step.Package), stepDirName(step))
+	b, err := yaml.Marshal(step)
+	if err != nil {
+		return "", err
+	}
+	t.writeFile(filepath.Join(stepDir, TraceStepFile), b)
+	if stderr != "" {
+		t.writeFile(filepath.Join(stepDir, TraceStderrFile), []byte(stderr))
+	}
+	if step.Stage != "mutator" || step.Status != StepPassed {
+		return prev, nil
+	}
+
+	content, err := serializeResources(resources)
+	if err != nil {
+		return "", err
+	}
+	t.writeFile(filepath.Join(stepDir, TraceResourcesFile), []byte(content))
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(prev),
+		B:        difflib.SplitLines(content),
+		FromFile: "before",
+		ToFile:   "after",
+		Context:  3,
+	})
+	if err != nil {
+		return "", err
+	}
+	t.writeFile(filepath.Join(stepDir, TraceDiffFile), []byte(diff))
+	return content, nil
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// stepDirName returns the name of the directory of the step.
+func stepDirName(step *TraceStep) string {
+	fnName := unsafeNameChars.ReplaceAllString(path.Base(filepath.ToSlash(step.Function)), "-")
+	return fmt.Sprintf("step-%02d-%s-%s", step.Index, step.Stage, fnName)
+}
+
+// serializeResources returns the resources as a stream of YAML documents.
+func serializeResources(resources []*yaml.RNode) (string, error) {
+	var out bytes.Buffer
+	w := kio.ByteWriter{Writer: &out}
+	if err := w.Write(cloneResources(resources)); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// pkgTrace records the steps of the pipeline of a package.
+// A nil pkgTrace records nothing, i.e. tracing is disabled.
+type pkgTrace struct {
+	tracer  *tracer
+	relPath string
+	// index is the index of the last recorded step.
+	index int
+	// prev holds the serialized resources of the last mutator step.
+	prev string
+}
+
+// newPkgTrace records the input resources of the package pipeline and
+// returns the trace of the package.
+func (t *tracer) newPkgTrace(relPath string, input []*yaml.RNode) (*pkgTrace, error) {
+	prev, err := t.recordInput(relPath, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record trace of package %q: %w", relPath, err)
+	}
+	return &pkgTrace{tracer: t, relPath: relPath, prev: prev}, nil
+}
+
+// record records the outcome of running the given function. resources are
+// the resources of the package after a mutator step.
+func (pt *pkgTrace) record(stage string, f *kptfilev1.Function, runner *fnruntime.FunctionRunner,
+	status string, d time.Duration, resources []*yaml.RNode) error {
+	if pt == nil {
+		return nil
+	}
+	pt.index++
+	step := &TraceStep{
+		Package:  filepath.ToSlash(pt.relPath),
+		Index:    pt.index,
+		Stage:    stage,
+		Function: runner.Name(),
+		Name:     f.Name,
+		Status:   status,
+		ExitCode: runner.Result().ExitCode,
+	}
+	if status != StepSkipped {
+		step.Duration = d.Truncate(time.Millisecond).String()
+	}
+	prev, err := pt.tracer.recordStep(step, runner.Result().Stderr, pt.prev, resources)
+	if err != nil {
+		return fmt.Errorf("failed to record trace of function %q: %w", runner.Name(), err)
+	}
+	pt.prev = prev
+	return nil
+}
+
+// ReadTrace returns the steps of the render trace stored in dir, ordered
+// by package and by index.
+func ReadTrace(fsys filesys.FileSystem, dir string) ([]*TraceStep, error) {
+	if !fsys.Exists(dir) {
+		return nil, fmt.Errorf("trace directory %q does not exist", dir)
+	}
+	var steps []*TraceStep
+	err := fsys.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.Name() != TraceStepFile {
+			return nil
+		}
+		b, err := fsys.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		var step TraceStep
+		if err := yaml.Unmarshal(b, &step); err != nil {
+			return fmt.Errorf("failed to read trace step %q: %w", p, err)
+		}
+		step.Dir = filepath.Dir(p)
+		steps = append(steps, &step)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(steps, func(i, j int) bool {
+		if steps[i].Package != steps[j].Package {
+			return steps[i].Package < steps[j].Package
+		}
+		return steps[i].Index < steps[j].Index
+	})
+	return steps, nil
+}
+
+// DiffStat returns the number of added and removed lines of a unified diff.
+func DiffStat(diff string) (added, removed int) {
+	inHunk := false
+	for _, l := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(l, "@@"):
+			// lines before the first hunk are the file headers
+			inHunk = true
+		case !inHunk:
+		case strings.HasPrefix(l, "+"):
+			added++
+		case strings.HasPrefix(l, "-"):
+			removed++
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,280 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/pkg/printer"
+	"github.com/fsnotify/fsnotify"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// defaultWatchDelay is the default time to wait for more file events before
+// rendering.
+const defaultWatchDelay = 200 * time.Millisecond
+
+// Watcher renders a package every time a file of the package tree changes.
+// Only the packages containing the changed files and their ancestors are
+// rendered again, the output of the other packages is reused from the
+// previous render.
+type Watcher struct {
+	// Renderer renders the package. Its output must be nil, the package is
+	// rendered in-place.
+	Renderer *Renderer
+
+	// Delay is the time to wait for more file events after a change before
+	// rendering, so that a burst of events triggers a single render.
+	// Defaults to 200ms.
+	Delay time.Duration
+
+	// rendered is called after every render, for tests.
+	rendered func(error)
+
+	// fs records the content of the files written by render.
+	fs *recordingFS
+
+	watcher *fsnotify.Watcher
+}
+
+// Run renders the package, then renders it again on every change until ctx
+// is done. Render failures are reported and don't stop the watch.
+func (w *Watcher) Run(ctx context.Context) error {
+	pr := printer.FromContextOrDie(ctx)
+	if w.Delay == 0 {
+		w.Delay = defaultWatchDelay
+	}
+	var err error
+	w.watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch package %q: %w", w.Renderer.PkgPath, err)
+	}
+	defer w.watcher.Close()
+
+	w.fs = &recordingFS{FileSystem: w.Renderer.FileSystem, hashes: map[string]string{}}
+	if w.fs.FileSystem == nil {
+		w.fs.FileSystem = filesys.MakeFsOnDisk()
+	}
+	w.Renderer.FileSystem = w.fs
+	w.Renderer.keepOutputs = true
+	if err := w.watchTree(w.Renderer.PkgPath); err != nil {
+		return err
+	}
+
+	w.render(ctx, nil)
+	pr.Printf("Watching %q for changes, press Ctrl+C to stop.\n", w.Renderer.PkgPath)
+
+	changed := map[string]bool{}
+	timer := time.NewTimer(w.Delay)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-w.watcher.Errors:
+			return fmt.Errorf("failed to watch package %q: %w", w.Renderer.PkgPath, err)
+		case event := <-w.watcher.Events:
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					// files created along with the directory don't have
+					// their own events.
+					if err := w.watchTree(event.Name); err != nil {
+						return err
+					}
+				}
+			}
+			if event.Op != fsnotify.Chmod {
+				changed[event.Name] = true
+				timer.Reset(w.Delay)
+			}
+		case <-timer.C:
+			paths := w.fs.changedFiles(changed)
+			changed = map[string]bool{}
+			if len(paths) == 0 {
+				// only the writes of render itself
+				continue
+			}
+			w.render(ctx, paths)
+		}
+	}
+}
+
+// render renders the package after the files at paths changed. The output
+// of render is only displayed if it fails, a summary is printed otherwise.
+func (w *Watcher) render(ctx context.Context, paths []string) {
+	pr := printer.FromContextOrDie(ctx)
+	if len(paths) > 0 {
+		var rel []string
+		for _, p := range paths {
+			if r, err := filepath.Rel(w.Renderer.PkgPath, p); err == nil {
+				p = r
+			}
+			rel = append(rel, p)
+		}
+		pr.Printf("%s Changed %s\n", time.Now().Format(time.TimeOnly), strings.Join(rel, ", "))
+	}
+	w.Renderer.invalidate(paths)
+
+	var out bytes.Buffer
+	renderCtx := printer.WithContext(ctx, printer.New(&out, &out))
+	start := time.Now()
+	_, err := w.Renderer.Execute(renderCtx)
+	d := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		_, _ = pr.ErrStream().Write(out.Bytes())
+		pr.Printf("%s Render failed after %s: %v\n", time.Now().Format(time.TimeOnly), d, err)
+	} else {
+		s := w.Renderer.summary
+		pr.Printf("%s Rendered %d package(s) and reused %d in %s, executed %d function(s).\n",
+			time.Now().Format(time.TimeOnly), s.rendered, s.reused, d, s.functions)
+	}
+	if w.rendered != nil {
+		w.rendered(err)
+	}
+}
+
+// watchTree watches the directory at path and all its subdirectories.
+func (w *Watcher) watchTree(path string) error {
+	return filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			// the directory may have been removed since.
+			return nil
+		}
+		if !info.IsDir() {
+			w.fs.record(p)
+			return nil
+		}
+		if info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if err := w.watcher.Add(p); err != nil {
+			return fmt.Errorf("failed to watch directory %q: %w", p, err)
+		}
+		return nil
+	})
+}
+
+// invalidate drops the packages affected by the changed files at paths from
+// the packages reused by the next render. These are all the packages whose
+// directory contains one of the files, that is the package of the file and
+// its ancestors. All the packages are rendered again if paths is empty.
+func (e *Renderer) invalidate(paths []string) {
+	if len(paths) == 0 {
+		e.previous = nil
+		return
+	}
+	for pkgPath := range e.previous {
+		for _, p := range paths {
+			if isWithin(types.UniquePath(p), pkgPath) {
+				delete(e.previous, pkgPath)
+				break
+			}
+		}
+	}
+}
+
+// isWithin returns true if path is dir or is within dir.
+func isWithin(path, dir types.UniquePath) bool {
+	return path == dir || strings.HasPrefix(string(path), string(dir)+string(filepath.Separator))
+}
+
+// recordingFS is a file system recording the hash of the content of the
+// files written through it, so that the file events triggered by render
+// itself can be told apart from the changes made by the user.
+type recordingFS struct {
+	filesys.FileSystem
+
+	mu sync.Mutex
+	// hashes are the last known hashes of the content of the files, keyed
+	// by path. Missing files have an empty hash.
+	hashes map[string]string
+}
+
+// WriteFile implements filesys.FileSystem.
+func (fs *recordingFS) WriteFile(path string, data []byte) error {
+	if err := fs.FileSystem.WriteFile(path, data); err != nil {
+		return err
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.hashes[path] = hash(data)
+	return nil
+}
+
+// RemoveAll implements filesys.FileSystem.
+func (fs *recordingFS) RemoveAll(path string) error {
+	if err := fs.FileSystem.RemoveAll(path); err != nil {
+		return err
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for p := range fs.hashes {
+		if isWithin(types.UniquePath(p), types.UniquePath(path)) {
+			fs.hashes[p] = ""
+		}
+	}
+	return nil
+}
+
+// record records the current content of the file at path.
+func (fs *recordingFS) record(path string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.hashes[path] = fs.current(path)
+}
+
+// changedFiles returns the sorted paths of the files whose content differs
+// from the content last known, and records their current content.
+func (fs *recordingFS) changedFiles(paths map[string]bool) []string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	var changed []string
+	for p := range paths {
+		if fs.IsDir(p) {
+			continue
+		}
+		h := fs.current(p)
+		if h == fs.hashes[p] {
+			continue
+		}
+		fs.hashes[p] = h
+		changed = append(changed, p)
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// current returns the hash of the current content of the file at path.
+func (fs *recordingFS) current(path string) string {
+	data, err := fs.FileSystem.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return hash(data)
+}
+
+func hash(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
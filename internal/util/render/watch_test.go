@@ -0,0 +1,137 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/pkg/printer"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"Kptfile":      "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: root\npipeline:\n  mutators:\n    - image: root-fn\n",
+		"a/Kptfile":    "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: a\npipeline:\n  mutators:\n    - image: a-fn\n",
+		"a/cm.yaml":    "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n",
+		"b/Kptfile":    "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: b\npipeline:\n  mutators:\n    - image: b-fn\n",
+		"b/cm.yaml":    "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n",
+		"b/c/Kptfile":  "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: c\n",
+		"b/c/cm.yaml":  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n",
+		"root-cm.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: root\n",
+	}
+	for path, content := range files {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), 0644))
+	}
+
+	type result struct {
+		summary renderSummary
+		err     error
+	}
+	results := make(chan result, 10)
+	w := &Watcher{
+		Renderer: &Renderer{
+			PkgPath:    dir,
+			Runtime:    &fakeRuntime{},
+			FileSystem: filesys.MakeFsOnDisk(),
+			RunnerOptions: fnruntime.RunnerOptions{
+				ResolveToImage: func(_ context.Context, image string) (string, error) { return image, nil },
+			},
+		},
+		Delay: 50 * time.Millisecond,
+	}
+	w.rendered = func(err error) { results <- result{w.Renderer.summary, err} }
+
+	ctx, cancel := context.WithCancel(printer.WithContext(context.Background(), printer.New(&bytes.Buffer{}, &bytes.Buffer{})))
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+	next := func() result {
+		select {
+		case r := <-results:
+			return r
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for a render")
+		}
+		return result{}
+	}
+	// waits long enough for the events of the writes of render to be
+	// handled, these must not trigger a render.
+	assertIdle := func() {
+		select {
+		case r := <-results:
+			t.Errorf("unexpected render: %+v", r)
+		case <-time.After(10 * w.Delay):
+		}
+	}
+
+	r := next()
+	assert.NoError(t, r.err)
+	assert.Equal(t, renderSummary{rendered: 4, functions: 3}, r.summary)
+	assertIdle()
+
+	// only the package of the changed file and its ancestors are rendered
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b", "c", "cm.yaml"),
+		[]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\ndata:\n  foo: bar\n"), 0644))
+	r = next()
+	assert.NoError(t, r.err)
+	assert.Equal(t, renderSummary{rendered: 3, reused: 1, functions: 2}, r.summary)
+	content, err := os.ReadFile(filepath.Join(dir, "b", "c", "cm.yaml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "foo: bar")
+	assert.Contains(t, string(content), "rendered-by-b-fn")
+	assert.Contains(t, string(content), "rendered-by-root-fn")
+	assertIdle()
+
+	// failures don't stop the watch
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a", "Kptfile"), []byte("kind: Unknown\n"), 0644))
+	r = next()
+	assert.Error(t, r.err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a", "Kptfile"), []byte(files["a/Kptfile"]), 0644))
+	r = next()
+	assert.NoError(t, r.err)
+	assert.Equal(t, renderSummary{rendered: 4, functions: 3}, r.summary)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestRenderer_invalidate(t *testing.T) {
+	e := &Renderer{previous: map[types.UniquePath]*pkgNode{
+		"/root":       {},
+		"/root/a":     {},
+		"/root/a/b":   {},
+		"/root/ab":    {},
+		"/root/other": {},
+	}}
+	e.invalidate([]string{"/root/a/b/cm.yaml"})
+	var remaining []string
+	for p := range e.previous {
+		remaining = append(remaining, string(p))
+	}
+	assert.ElementsMatch(t, []string{"/root/ab", "/root/other"}, remaining)
+
+	e.invalidate(nil)
+	assert.Empty(t, e.previous)
+}
@@ -0,0 +1,380 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
+	"github.com/GoogleContainerTools/kpt/internal/util/pkgutil"
+	"github.com/GoogleContainerTools/kpt/pkg/printer"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// ConflictReportFileName is the name of the file in the root package
+	// where update records the conflicts it could not merge.
+	ConflictReportFileName = pkgutil.ConflictReportFileName
+
+	ConflictReportAPIVersion = "kpt.dev/v1alpha1"
+	ConflictReportKind       = "ConflictReport"
+)
+
+// ConflictReport is the list of the fields of the resources of a package
+// changed to different values in the local package and in upstream, whose
+// local value has been kept by update, and of the text files merged with
+// conflict markers.
+type ConflictReport struct {
+	yaml.ResourceMeta `yaml:",inline"`
+
+	// Conflicts are the conflicting fields. The file paths are relative to
+	// the root package.
+	Conflicts []merge.Conflict `yaml:"conflicts"`
+}
+
+// Resolution is a way of resolving the recorded conflicts of a package.
+type Resolution string
+
+const (
+	// ResolveOurs keeps the local values of the fields.
+	ResolveOurs Resolution = "ours"
+	// ResolveTheirs sets the fields to their upstream values, or removes
+	// them if they have been removed from upstream.
+	ResolveTheirs Resolution = "theirs"
+	// ResolveInteractive prompts for the resolution of each field.
+	ResolveInteractive Resolution = "interactive"
+)
+
+// Resolutions returns the resolutions as strings.
+func Resolutions() []string {
+	return []string{string(ResolveOurs), string(ResolveTheirs), string(ResolveInteractive)}
+}
+
+// ReadConflictReport reads the conflict report of the package at pkgPath.
+// It returns nil if the package has no conflict report.
+func ReadConflictReport(pkgPath string) (*ConflictReport, error) {
+	b, err := os.ReadFile(filepath.Join(pkgPath, ConflictReportFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	report := &ConflictReport{}
+	if err := yaml.Unmarshal(b, report); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ConflictReportFileName, err)
+	}
+	if report.APIVersion != ConflictReportAPIVersion || report.Kind != ConflictReportKind {
+		return nil, fmt.Errorf("%s is not a %s %s", ConflictReportFileName, ConflictReportAPIVersion, ConflictReportKind)
+	}
+	return report, nil
+}
+
+// WriteConflictReport writes the conflicts to the conflict report of the
+// package at pkgPath, or removes the report if there are no conflicts.
+func WriteConflictReport(pkgPath string, conflicts []merge.Conflict) error {
+	path := filepath.Join(pkgPath, ConflictReportFileName)
+	if len(conflicts) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	b, err := yaml.Marshal(ConflictReport{
+		ResourceMeta: yaml.ResourceMeta{
+			TypeMeta: yaml.TypeMeta{
+				APIVersion: ConflictReportAPIVersion,
+				Kind:       ConflictReportKind,
+			},
+		},
+		Conflicts: conflicts,
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
+
+// resolveConflicts resolves the conflicts recorded in the conflict report
+// of the package. The conflicts skipped in interactive mode stay in the
+// report.
+func (u Command) resolveConflicts(ctx context.Context) error {
+	const op errors.Op = "update.resolveConflicts"
+	pr := printer.FromContextOrDie(ctx)
+	root := u.Pkg.UniquePath.String()
+
+	report, err := ReadConflictReport(root)
+	if err != nil {
+		return errors.E(op, u.Pkg.UniquePath, err)
+	}
+	if report == nil || len(report.Conflicts) == 0 {
+		return errors.E(op, u.Pkg.UniquePath, fmt.Errorf("package has no conflicts to resolve"))
+	}
+
+	var in *bufio.Reader
+	if u.Resolve == ResolveInteractive {
+		r := u.Input
+		if r == nil {
+			r = os.Stdin
+		}
+		in = bufio.NewReader(r)
+	}
+
+	var unresolved []merge.Conflict
+	theirs := map[string][]merge.Conflict{}
+	var files []string
+	for _, c := range report.Conflicts {
+		resolution := u.Resolve
+		if resolution == ResolveInteractive {
+			resolution, err = promptResolution(pr, in, c)
+			if err != nil {
+				return errors.E(op, u.Pkg.UniquePath, err)
+			}
+		}
+		switch {
+		case resolution != "" && isTextConflict(c):
+			if err := resolveTextConflicts(filepath.Join(root, c.File), resolution == ResolveOurs); err != nil {
+				return errors.E(op, types.UniquePath(filepath.Join(root, c.File)), err)
+			}
+		case resolution == ResolveOurs:
+			// the local value is already in place
+		case resolution == ResolveTheirs:
+			if _, found := theirs[c.File]; !found {
+				files = append(files, c.File)
+			}
+			theirs[c.File] = append(theirs[c.File], c)
+		default:
+			unresolved = append(unresolved, c)
+		}
+	}
+
+	for _, file := range files {
+		if err := setUpstreamValues(filepath.Join(root, file), theirs[file]); err != nil {
+			return errors.E(op, types.UniquePath(filepath.Join(root, file)), err)
+		}
+	}
+	if err := WriteConflictReport(root, unresolved); err != nil {
+		return errors.E(op, u.Pkg.UniquePath, err)
+	}
+
+	pr.Printf("Resolved %d conflict(s).\n", len(report.Conflicts)-len(unresolved))
+	if len(unresolved) > 0 {
+		pr.Printf("%d conflict(s) left in %s.\n", len(unresolved), ConflictReportFileName)
+	}
+	return nil
+}
+
+// isTextConflict returns true if the conflict is a text file with conflict
+// markers.
+func isTextConflict(c merge.Conflict) bool {
+	return len(c.Path) == 0
+}
+
+// resolveTextConflicts replaces the conflicts in the text file with their
+// local or upstream lines.
+func resolveTextConflicts(path string, ours bool) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, merge.ResolveTextConflicts(b, ours), info.Mode())
+}
+
+// promptResolution asks how to resolve the conflict until it reads a valid
+// answer. An empty resolution means that the conflict is skipped.
+func promptResolution(pr printer.Printer, in *bufio.Reader, c merge.Conflict) (Resolution, error) {
+	if isTextConflict(c) {
+		pr.Printf("\n%s: conflicting lines between conflict markers\n", c.File)
+	} else {
+		pr.Printf("\n%s: %s %s %s\n", c.File, c.Resource.Kind, c.Resource.Name, strings.Join(c.Path, "."))
+		for _, v := range []struct {
+			name  string
+			value interface{}
+		}{{"origin", c.Origin}, {"local", c.Local}, {"upstream", c.Upstream}} {
+			pr.Printf("  %s: %s\n", v.name, formatValue(v.value))
+		}
+	}
+	for {
+		pr.Printf("Keep [o]urs, take [t]heirs or [s]kip? ")
+		answer, err := in.ReadString('\n')
+		if err != nil && (err != io.EOF || answer == "") {
+			return "", fmt.Errorf("failed to read the resolution: %w", err)
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "o", "ours":
+			return ResolveOurs, nil
+		case "t", "theirs":
+			return ResolveTheirs, nil
+		case "s", "skip":
+			return "", nil
+		}
+	}
+}
+
+// formatValue formats the value of a conflicting field on one line.
+func formatValue(value interface{}) string {
+	if value == nil {
+		return "<missing>"
+	}
+	n, err := yaml.FromMap(map[string]interface{}{"v": value})
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	v := n.Field("v").Value
+	v.YNode().Style = yaml.FlowStyle
+	s, err := v.String()
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return strings.TrimSpace(s)
+}
+
+// setUpstreamValues sets the conflicting fields of the resources in the
+// file to their upstream values.
+func setUpstreamValues(path string, conflicts []merge.Conflict) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	rw := &kio.ByteReadWriter{
+		Reader:            bytes.NewReader(b),
+		Writer:            &out,
+		PreserveSeqIndent: true,
+		WrapBareSeqNode:   true,
+	}
+	err = kio.Pipeline{
+		Inputs: []kio.Reader{rw},
+		Filters: []kio.Filter{kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+			for _, c := range conflicts {
+				node, err := findResource(nodes, c.Resource)
+				if err != nil {
+					return nil, err
+				}
+				if err := setField(node, c.Path, c.Upstream); err != nil {
+					return nil, fmt.Errorf("failed to set %s of %s %s: %w",
+						strings.Join(c.Path, "."), c.Resource.Kind, c.Resource.Name, err)
+				}
+			}
+			return nodes, nil
+		})},
+		Outputs: []kio.Writer{rw},
+	}.Execute()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out.Bytes(), 0600)
+}
+
+// findResource returns the resource with the identifier.
+func findResource(nodes []*yaml.RNode, id yaml.ResourceIdentifier) (*yaml.RNode, error) {
+	for _, node := range nodes {
+		meta, err := node.GetMeta()
+		if err != nil {
+			continue
+		}
+		if meta.APIVersion == id.APIVersion && meta.Kind == id.Kind &&
+			meta.Name == id.Name && meta.Namespace == id.Namespace {
+			return node, nil
+		}
+	}
+	return nil, fmt.Errorf("resource %s %s not found", id.Kind, id.Name)
+}
+
+// setField sets the field at the path to the value, or removes it if the
+// value is nil.
+func setField(node *yaml.RNode, path []string, value interface{}) error {
+	if len(path) == 0 {
+		return fmt.Errorf("empty field path")
+	}
+	parentPath, field := path[:len(path)-1], path[len(path)-1]
+	if value == nil {
+		parent, err := node.Pipe(yaml.Lookup(parentPath...))
+		if err != nil || parent == nil {
+			return err
+		}
+		return removeField(parent, field)
+	}
+
+	v, err := yaml.FromMap(map[string]interface{}{"v": value})
+	if err != nil {
+		return err
+	}
+	valueNode := v.Field("v").Value
+
+	kind := yaml.MappingNode
+	if yaml.IsListIndex(field) || yaml.IsIdxNumber(field) {
+		kind = yaml.SequenceNode
+	}
+	parent, err := node.Pipe(yaml.LookupCreate(kind, parentPath...))
+	if err != nil {
+		return err
+	}
+	switch {
+	case yaml.IsListIndex(field):
+		key, keyValue, err := yaml.SplitIndexNameValue(field)
+		if err != nil {
+			return err
+		}
+		_, err = parent.Pipe(yaml.ElementSetter{Element: valueNode.YNode(), Keys: []string{key}, Values: []string{keyValue}})
+		return err
+	case yaml.IsIdxNumber(field):
+		i, _ := strconv.Atoi(field)
+		if i < 0 || i >= len(parent.YNode().Content) {
+			return fmt.Errorf("index %d out of range", i)
+		}
+		parent.YNode().Content[i] = valueNode.YNode()
+		return nil
+	default:
+		return parent.PipeE(yaml.SetField(field, valueNode))
+	}
+}
+
+// removeField removes the field or list element from the parent node.
+func removeField(parent *yaml.RNode, field string) error {
+	switch {
+	case yaml.IsListIndex(field):
+		key, keyValue, err := yaml.SplitIndexNameValue(field)
+		if err != nil {
+			return err
+		}
+		_, err = parent.Pipe(yaml.ElementSetter{Keys: []string{key}, Values: []string{keyValue}})
+		return err
+	case yaml.IsIdxNumber(field):
+		i, _ := strconv.Atoi(field)
+		content := parent.YNode().Content
+		if i >= 0 && i < len(content) {
+			parent.YNode().Content = append(content[:i], content[i+1:]...)
+		}
+		return nil
+	default:
+		return parent.PipeE(yaml.Clear(field))
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pkgtest "github.com/GoogleContainerTools/kpt/internal/pkg/testing"
+	. "github.com/GoogleContainerTools/kpt/internal/util/update"
+	"github.com/GoogleContainerTools/kpt/pkg/printer/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+const conflictsDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.15
+      - name: sidecar
+        image: sidecar:1.0
+`
+
+const conflictsReport = `apiVersion: kpt.dev/v1alpha1
+kind: ConflictReport
+conflicts:
+- file: deployment.yaml
+  resource:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: nginx
+  path:
+  - spec
+  - replicas
+  local: 5
+  upstream: 4
+  origin: 3
+- file: deployment.yaml
+  resource:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: nginx
+  path:
+  - spec
+  - template
+  - spec
+  - containers
+  - '[name=sidecar]'
+  local:
+    image: sidecar:1.0
+    name: sidecar
+  origin:
+    image: sidecar:0.9
+    name: sidecar
+`
+
+func TestCommand_Run_resolve(t *testing.T) {
+	testCases := map[string]struct {
+		resolve            Resolution
+		input              string
+		expectedDeployment string
+		expectedReport     string
+	}{
+		"ours": {
+			resolve:            ResolveOurs,
+			expectedDeployment: conflictsDeployment,
+		},
+		"theirs": {
+			resolve: ResolveTheirs,
+			expectedDeployment: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  replicas: 4
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.15
+`,
+		},
+		"interactive": {
+			resolve: ResolveInteractive,
+			input:   "x\nt\ns\n",
+			expectedDeployment: strings.Replace(conflictsDeployment,
+				"replicas: 5", "replicas: 4", 1),
+			expectedReport: conflictsReport[:strings.Index(conflictsReport, "conflicts:\n")+len("conflicts:\n")] +
+				conflictsReport[strings.LastIndex(conflictsReport, "- file:"):],
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			dir := t.TempDir()
+			err := os.WriteFile(filepath.Join(dir, "deployment.yaml"), []byte(conflictsDeployment), 0600)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			err = os.WriteFile(filepath.Join(dir, ConflictReportFileName), []byte(conflictsReport), 0600)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			err = (&Command{
+				Pkg:     pkgtest.CreatePkgOrFail(t, dir),
+				Resolve: tc.resolve,
+				Input:   strings.NewReader(tc.input),
+			}).Run(fake.CtxWithDefaultPrinter())
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			b, err := os.ReadFile(filepath.Join(dir, "deployment.yaml"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expectedDeployment, string(b))
+
+			b, err = os.ReadFile(filepath.Join(dir, ConflictReportFileName))
+			if tc.expectedReport == "" {
+				assert.True(t, os.IsNotExist(err))
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedReport, string(b))
+			}
+		})
+	}
+}
+
+func TestCommand_Run_unresolvedConflicts(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, ConflictReportFileName), []byte(conflictsReport), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	err = (&Command{
+		Pkg: pkgtest.CreatePkgOrFail(t, dir),
+	}).Run(fake.CtxWithDefaultPrinter())
+	if !assert.Error(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, err.Error(), "package has 2 unresolved conflict(s)")
+}
+
+func TestCommand_Run_resolveText(t *testing.T) {
+	for resolve, expected := range map[Resolution]string{
+		ResolveOurs:   "a\nlocal\nc\n",
+		ResolveTheirs: "a\nupstream\nc\n",
+	} {
+		t.Run(string(resolve), func(t *testing.T) {
+			dir := t.TempDir()
+			err := os.WriteFile(filepath.Join(dir, "README.md"),
+				[]byte("a\n<<<<<<< local\nlocal\n=======\nupstream\n>>>>>>> upstream\nc\n"), 0600)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			err = os.WriteFile(filepath.Join(dir, ConflictReportFileName),
+				[]byte("apiVersion: kpt.dev/v1alpha1\nkind: ConflictReport\nconflicts:\n- file: README.md\n"), 0600)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			err = (&Command{
+				Pkg:     pkgtest.CreatePkgOrFail(t, dir),
+				Resolve: resolve,
+			}).Run(fake.CtxWithDefaultPrinter())
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			b, err := os.ReadFile(filepath.Join(dir, "README.md"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, expected, string(b))
+			_, err = os.Stat(filepath.Join(dir, ConflictReportFileName))
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
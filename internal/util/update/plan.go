@@ -0,0 +1,379 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/sets"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ChangeAction is the change of a resource or a file by an update.
+type ChangeAction string
+
+const (
+	Added    ChangeAction = "added"
+	Modified ChangeAction = "modified"
+	Removed  ChangeAction = "removed"
+)
+
+// Plan is the list of the changes an update would make to a package.
+type Plan struct {
+	// AddedPackages are the paths of the subpackages that would be added,
+	// relative to the package.
+	AddedPackages []string `json:"addedPackages,omitempty"`
+
+	// RemovedPackages are the paths of the subpackages that would be
+	// removed, relative to the package.
+	RemovedPackages []string `json:"removedPackages,omitempty"`
+
+	// Resources are the resources that would change, including the
+	// Kptfiles.
+	Resources []ResourceChange `json:"resources,omitempty"`
+
+	// Files are the non-KRM files that would change.
+	Files []FileChange `json:"files,omitempty"`
+
+	// Conflicts are the conflicts the update would record.
+	Conflicts []merge.Conflict `json:"conflicts,omitempty"`
+}
+
+// ResourceChange is a resource that would change.
+type ResourceChange struct {
+	// File is the path of the file of the resource, relative to the package.
+	File string `json:"file"`
+
+	// Resource identifies the resource.
+	Resource yaml.ResourceIdentifier `json:"resource"`
+
+	// Action is the change of the resource.
+	Action ChangeAction `json:"action"`
+}
+
+// FileChange is a non-KRM file that would change.
+type FileChange struct {
+	// File is the path of the file, relative to the package.
+	File string `json:"file"`
+
+	// Action is the change of the file.
+	Action ChangeAction `json:"action"`
+}
+
+// IsEmpty returns true if the update would not change the package.
+func (p *Plan) IsEmpty() bool {
+	return len(p.AddedPackages) == 0 && len(p.RemovedPackages) == 0 &&
+		len(p.Resources) == 0 && len(p.Files) == 0 && len(p.Conflicts) == 0
+}
+
+// Print prints the plan in a human-readable format.
+func (p *Plan) Print(w io.Writer) error {
+	if p.IsEmpty() {
+		_, err := fmt.Fprintln(w, "No changes.")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	section := func(title string, rows [][]string) {
+		if len(rows) == 0 {
+			return
+		}
+		fmt.Fprintf(tw, "%s:\n", title)
+		for _, row := range rows {
+			fmt.Fprintf(tw, "  %s\n", strings.Join(row, "\t"))
+		}
+	}
+
+	var rows [][]string
+	for _, path := range p.AddedPackages {
+		rows = append(rows, []string{string(Added), path})
+	}
+	for _, path := range p.RemovedPackages {
+		rows = append(rows, []string{string(Removed), path})
+	}
+	section("Subpackages", rows)
+
+	rows = nil
+	for _, r := range p.Resources {
+		rows = append(rows, []string{string(r.Action), r.File, resourceString(r.Resource)})
+	}
+	section("Resources", rows)
+
+	rows = nil
+	for _, f := range p.Files {
+		rows = append(rows, []string{string(f.Action), f.File})
+	}
+	section("Files", rows)
+
+	rows = nil
+	for _, c := range p.Conflicts {
+		if isTextConflict(c) {
+			rows = append(rows, []string{c.File, "conflicting lines"})
+			continue
+		}
+		rows = append(rows, []string{c.File, resourceString(c.Resource), strings.Join(c.Path, "."),
+			fmt.Sprintf("local: %s, upstream: %s, origin: %s",
+				formatValue(c.Local), formatValue(c.Upstream), formatValue(c.Origin))})
+	}
+	section("Conflicts", rows)
+	return tw.Flush()
+}
+
+func resourceString(id yaml.ResourceIdentifier) string {
+	name := id.Name
+	if id.Namespace != "" {
+		name = id.Namespace + "/" + name
+	}
+	return fmt.Sprintf("%s %s %s", id.APIVersion, id.Kind, name)
+}
+
+// DryRun runs the update on a copy of the package, and returns the changes
+// it would make to the package.
+func (u *Command) DryRun(ctx context.Context) (*Plan, error) {
+	const op errors.Op = "update.DryRun"
+	if u.Pkg == nil {
+		return nil, errors.E(op, errors.MissingParam, "pkg must be provided")
+	}
+	if u.Resolve != "" {
+		return nil, errors.E(op, errors.InvalidParam, "conflicts can't be resolved in a dry run")
+	}
+	root := u.Pkg.UniquePath.String()
+
+	dir, err := os.MkdirTemp("", "kpt-update-")
+	if err != nil {
+		return nil, errors.E(op, errors.IO, fmt.Errorf("error creating a temporary directory: %w", err))
+	}
+	defer os.RemoveAll(dir)
+	scratch := filepath.Join(dir, filepath.Base(root))
+	if err := copyutil.CopyDir(filesys.MakeFsOnDisk(), root, scratch); err != nil {
+		return nil, errors.E(op, u.Pkg.UniquePath, err)
+	}
+	p, err := pkg.New(filesys.FileSystemOrOnDisk{}, scratch)
+	if err != nil {
+		return nil, errors.E(op, u.Pkg.UniquePath, err)
+	}
+	p.DisplayPath = u.Pkg.DisplayPath
+
+	c := *u
+	c.Pkg = p
+	c.dryRun = true
+	if err := c.Run(ctx); err != nil {
+		return nil, errors.E(op, u.Pkg.UniquePath, err)
+	}
+	u.cachedUpstreamRepos = c.cachedUpstreamRepos
+
+	plan, err := newPlan(root, scratch)
+	if err != nil {
+		return nil, errors.E(op, u.Pkg.UniquePath, err)
+	}
+	return plan, nil
+}
+
+// newPlan returns the changes between the package and its updated copy.
+func newPlan(pkgPath, updatedPath string) (*Plan, error) {
+	plan := &Plan{}
+
+	pkgs, err := subpackages(pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	updatedPkgs, err := subpackages(updatedPath)
+	if err != nil {
+		return nil, err
+	}
+	plan.AddedPackages = updatedPkgs.Difference(pkgs).List()
+	plan.RemovedPackages = pkgs.Difference(updatedPkgs).List()
+
+	if plan.Resources, err = resourceChanges(pkgPath, updatedPath); err != nil {
+		return nil, err
+	}
+	if plan.Files, err = fileChanges(pkgPath, updatedPath); err != nil {
+		return nil, err
+	}
+
+	report, err := ReadConflictReport(updatedPath)
+	if err != nil {
+		return nil, err
+	}
+	if report != nil {
+		plan.Conflicts = report.Conflicts
+	}
+	return plan, nil
+}
+
+// subpackages returns the paths of the subpackages of the package.
+func subpackages(pkgPath string) (sets.String, error) {
+	paths, err := pathutil.DirsWithFile(pkgPath, kptfilev1.KptFileName, true)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := sets.String{}
+	for _, p := range paths {
+		rel, err := filepath.Rel(pkgPath, p)
+		if err != nil {
+			return nil, err
+		}
+		if rel != "." {
+			pkgs.Insert(filepath.ToSlash(rel))
+		}
+	}
+	return pkgs, nil
+}
+
+// resourceChanges returns the resources that differ between the package
+// and its updated copy.
+func resourceChanges(pkgPath, updatedPath string) ([]ResourceChange, error) {
+	resources, keys, err := readPlanResources(pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	updatedResources, updatedKeys, err := readPlanResources(updatedPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []ResourceChange
+	for _, key := range keys {
+		r := resources[key]
+		updated, found := updatedResources[key]
+		switch {
+		case !found:
+			r.change.Action = Removed
+		case updated.content != r.content:
+			r.change.Action = Modified
+		default:
+			continue
+		}
+		changes = append(changes, r.change)
+	}
+	for _, key := range updatedKeys {
+		if _, found := resources[key]; !found {
+			r := updatedResources[key]
+			r.change.Action = Added
+			changes = append(changes, r.change)
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].File < changes[j].File
+	})
+	return changes, nil
+}
+
+type planResource struct {
+	change  ResourceChange
+	content string
+}
+
+// readPlanResources reads the resources of the package and its subpackages,
+// keyed by file and identity, and returns their keys in order.
+func readPlanResources(pkgPath string) (map[string]planResource, []string, error) {
+	nodes, err := (&kio.LocalPackageReader{
+		PackagePath:        pkgPath,
+		MatchFilesGlob:     krmFilesGlob,
+		IncludeSubpackages: true,
+		PackageFileName:    kptfilev1.KptFileName,
+		PreserveSeqIndent:  true,
+		WrapBareSeqNode:    true,
+	}).Read()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resources := map[string]planResource{}
+	var keys []string
+	for _, node := range nodes {
+		meta, err := node.GetMeta()
+		if err != nil {
+			return nil, nil, err
+		}
+		file := meta.Annotations[kioutil.PathAnnotation]
+		id := yaml.ResourceIdentifier{
+			TypeMeta: yaml.TypeMeta{APIVersion: meta.APIVersion, Kind: meta.Kind},
+			NameMeta: yaml.NameMeta{Name: meta.Name, Namespace: meta.Namespace},
+		}
+		// the index of the resource in its file doesn't change its content
+		for _, a := range []string{kioutil.IndexAnnotation, kioutil.LegacyIndexAnnotation} { // nolint:staticcheck
+			if err := node.PipeE(yaml.ClearAnnotation(a)); err != nil {
+				return nil, nil, err
+			}
+		}
+		key := fmt.Sprintf("%s|%s|%s|%s/%s", file, id.APIVersion, id.Kind, id.Namespace, id.Name)
+		if _, found := resources[key]; !found {
+			keys = append(keys, key)
+		}
+		resources[key] = planResource{
+			change:  ResourceChange{File: file, Resource: id},
+			content: node.MustString(),
+		}
+	}
+	return resources, keys, nil
+}
+
+// fileChanges returns the non-KRM files that differ between the package
+// and its updated copy.
+func fileChanges(pkgPath, updatedPath string) ([]FileChange, error) {
+	_, files, err := getSubDirsAndNonKrmFiles(pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	_, updatedFiles, err := getSubDirsAndNonKrmFiles(updatedPath)
+	if err != nil {
+		return nil, err
+	}
+
+	all := sets.String{}
+	all.Insert(files.List()...)
+	all.Insert(updatedFiles.List()...)
+	var changes []FileChange
+	for _, file := range all.List() {
+		name := strings.TrimPrefix(filepath.ToSlash(file), "/")
+		if name == ConflictReportFileName {
+			continue
+		}
+		var action ChangeAction
+		switch {
+		case !updatedFiles.Has(file):
+			action = Removed
+		case !files.Has(file):
+			action = Added
+		default:
+			same, err := compareFiles(filepath.Join(pkgPath, file), filepath.Join(updatedPath, file))
+			if err != nil {
+				return nil, err
+			}
+			if same {
+				continue
+			}
+			action = Modified
+		}
+		changes = append(changes, FileChange{File: name, Action: action})
+	}
+	return changes, nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pkgtest "github.com/GoogleContainerTools/kpt/internal/pkg/testing"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
+	. "github.com/GoogleContainerTools/kpt/internal/util/update"
+	"github.com/GoogleContainerTools/kpt/pkg/printer/fake"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// TestCommand_DryRun verifies that a dry run returns the changes of the
+// update without changing the local package.
+func TestCommand_DryRun(t *testing.T) {
+	g := &testutil.TestSetupManager{
+		T: t,
+		ReposChanges: map[string][]testutil.Content{
+			testutil.Upstream: {
+				{
+					Pkg: pkgbuilder.NewRootPkg().
+						WithKptfile(pkgbuilder.NewKptfile()).
+						WithResource(pkgbuilder.DeploymentResource).
+						WithSubPackages(pkgbuilder.NewSubPkg("a").
+							WithKptfile(pkgbuilder.NewKptfile()).
+							WithResource(pkgbuilder.ConfigMapResource),
+						),
+					Branch: masterBranch,
+				},
+				{
+					Pkg: pkgbuilder.NewRootPkg().
+						WithKptfile(pkgbuilder.NewKptfile()).
+						WithResource(pkgbuilder.DeploymentResource,
+							pkgbuilder.SetFieldPath("42", "spec", "replicas")).
+						WithSubPackages(pkgbuilder.NewSubPkg("b").
+							WithKptfile(pkgbuilder.NewKptfile()).
+							WithResource(pkgbuilder.ConfigMapResource),
+						),
+					Branch: "exp", CreateBranch: true,
+				},
+			},
+		},
+		LocalChanges: []testutil.Content{
+			{
+				Pkg: pkgbuilder.NewRootPkg().
+					WithKptfile(pkgbuilder.NewKptfile().
+						WithUpstreamRef(testutil.Upstream, "/", masterBranch, "resource-merge").
+						WithUpstreamLockRef(testutil.Upstream, "/", masterBranch, 0)).
+					WithResource(pkgbuilder.DeploymentResource,
+						pkgbuilder.SetFieldPath("7", "spec", "replicas")).
+					WithSubPackages(pkgbuilder.NewSubPkg("a").
+						WithKptfile(pkgbuilder.NewKptfile()).
+						WithResource(pkgbuilder.ConfigMapResource),
+					),
+			},
+		},
+	}
+	defer g.Clean()
+	if !g.Init() {
+		return
+	}
+	localPath := g.LocalWorkspace.FullPackagePath()
+	kptfile, err := os.ReadFile(filepath.Join(localPath, "Kptfile"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	plan, err := (&Command{
+		Pkg: pkgtest.CreatePkgOrFail(t, localPath),
+		Ref: "exp",
+	}).DryRun(fake.CtxWithDefaultPrinter())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	deployment := yaml.ResourceIdentifier{
+		TypeMeta: yaml.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		NameMeta: yaml.NameMeta{Name: "mysql-deployment", Namespace: "myspace"},
+	}
+	configMap := yaml.ResourceIdentifier{
+		TypeMeta: yaml.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		NameMeta: yaml.NameMeta{Name: "configmap"},
+	}
+	kptfileID := func(name string) yaml.ResourceIdentifier {
+		return yaml.ResourceIdentifier{
+			TypeMeta: yaml.TypeMeta{APIVersion: "kpt.dev/v1", Kind: "Kptfile"},
+			NameMeta: yaml.NameMeta{Name: name},
+		}
+	}
+	assert.Equal(t, &Plan{
+		AddedPackages:   []string{"b"},
+		RemovedPackages: []string{"a"},
+		Resources: []ResourceChange{
+			{File: "Kptfile", Resource: kptfileID(g.LocalWorkspace.PackageDir), Action: Modified},
+			{File: "a/Kptfile", Resource: kptfileID("a"), Action: Removed},
+			{File: "a/configmap.yaml", Resource: configMap, Action: Removed},
+			{File: "b/Kptfile", Resource: kptfileID("b"), Action: Added},
+			{File: "b/configmap.yaml", Resource: configMap, Action: Added},
+			{File: "deployment.yaml", Resource: deployment, Action: Modified},
+		},
+		Conflicts: []merge.Conflict{
+			{
+				File:     "deployment.yaml",
+				Resource: deployment,
+				Path:     []string{"spec", "replicas"},
+				Local:    7,
+				Upstream: 42,
+				Origin:   3,
+			},
+		},
+	}, plan)
+
+	// the local package is unchanged
+	b, err := os.ReadFile(filepath.Join(localPath, "Kptfile"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, string(kptfile), string(b))
+	_, err = os.Stat(filepath.Join(localPath, "b"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(localPath, ConflictReportFileName))
+	assert.True(t, os.IsNotExist(err))
+
+	var out bytes.Buffer
+	if !assert.NoError(t, plan.Print(&out)) {
+		t.FailNow()
+	}
+	assert.Equal(t, `Subpackages:
+  added    b
+  removed  a
+Resources:
+  modified  Kptfile           kpt.dev/v1 Kptfile `+g.LocalWorkspace.PackageDir+`
+  removed   a/Kptfile         kpt.dev/v1 Kptfile a
+  removed   a/configmap.yaml  v1 ConfigMap configmap
+  added     b/Kptfile         kpt.dev/v1 Kptfile b
+  added     b/configmap.yaml  v1 ConfigMap configmap
+  modified  deployment.yaml   apps/v1 Deployment myspace/mysql-deployment
+Conflicts:
+  deployment.yaml  apps/v1 Deployment myspace/mysql-deployment  spec.replicas  local: 7, upstream: 42, origin: 3
+`, out.String())
+}
+
+func TestPlan_Print_empty(t *testing.T) {
+	var out bytes.Buffer
+	if !assert.NoError(t, (&Plan{}).Print(&out)) {
+		t.FailNow()
+	}
+	assert.Equal(t, "No changes.\n", out.String())
+}
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -30,13 +31,21 @@ import (
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
 	"sigs.k8s.io/kustomize/kyaml/copyutil"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/kustomize/kyaml/sets"
 )
 
 // ResourceMergeUpdater updates a package by fetching the original and updated source
 // packages, and performing a 3-way merge of the Resources.
-type ResourceMergeUpdater struct{}
+type ResourceMergeUpdater struct {
+	// conflicts collects the conflicts of the update, if set.
+	conflicts *[]merge.Conflict
+
+	// nonKRMFiles are the update strategies of the non KRM files of the
+	// package.
+	nonKRMFiles []kptfilev1.NonKRMFile
+}
 
 func (u ResourceMergeUpdater) Update(options Options) error {
 	const op errors.Op = "update.Update"
@@ -63,6 +72,15 @@ func (u ResourceMergeUpdater) Update(options Options) error {
 		return errors.E(op, types.UniquePath(options.LocalPath), err)
 	}
 
+	kf, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, options.LocalPath)
+	if err != nil {
+		return errors.E(op, types.UniquePath(options.LocalPath), err)
+	}
+	if kf.Upstream != nil {
+		u.nonKRMFiles = kf.Upstream.NonKRMFiles
+	}
+	u.conflicts = options.Conflicts
+
 	// Update each package and subpackage. Parent package is updated before
 	// subpackages to make sure auto-setters can work correctly.
 	for _, subPkgPath := range append([]string{"."}, subPkgPaths...) {
@@ -143,13 +161,21 @@ func (u ResourceMergeUpdater) updatePackage(subPkgPath, localPath, updatedPath,
 }
 
 // mergePackage merge a package. It does a 3-way merge by using the provided
-// paths to the local, updated and original versions of the package.
-func (u ResourceMergeUpdater) mergePackage(localPath, updatedPath, originalPath, _ string, isRootPkg bool) error {
+// paths to the local, updated and original versions of the package. If
+// the updater collects conflicts, the local values of the conflicting
+// fields are kept.
+func (u ResourceMergeUpdater) mergePackage(localPath, updatedPath, originalPath, relPath string, isRootPkg bool) error {
 	const op errors.Op = "update.mergePackage"
 	if err := kptfileutil.UpdateKptfile(localPath, updatedPath, originalPath, !isRootPkg); err != nil {
 		return errors.E(op, types.UniquePath(localPath), err)
 	}
 
+	var pkgConflicts []merge.Conflict
+	var mergeConflicts *[]merge.Conflict
+	if u.conflicts != nil {
+		mergeConflicts = &pkgConflicts
+	}
+
 	// merge the Resources: original + updated + dest => dest
 	err := merge.Merge3{
 		OriginalPath: originalPath,
@@ -158,20 +184,48 @@ func (u ResourceMergeUpdater) mergePackage(localPath, updatedPath, originalPath,
 		// TODO: Write a test to ensure this is set
 		MergeOnPath:        true,
 		IncludeSubPackages: false,
+		Conflicts:          mergeConflicts,
 	}.Merge()
 	if err != nil {
 		return errors.E(op, types.UniquePath(localPath), err)
 	}
+	for _, c := range pkgConflicts {
+		c.File = filepath.Join(localPath, c.File)
+		*u.conflicts = append(*u.conflicts, c)
+	}
 
-	if err := ReplaceNonKRMFiles(updatedPath, originalPath, localPath); err != nil {
+	if err := ReplaceNonKRMFiles(updatedPath, originalPath, localPath, NonKRMFilesOptions{
+		Strategies: u.nonKRMFiles,
+		RelPath:    relPath,
+		Conflicts:  u.conflicts,
+	}); err != nil {
 		return errors.E(op, types.UniquePath(localPath), err)
 	}
 	return nil
 }
 
-// replaceNonKRMFiles replaces the non KRM files in localDir with the corresponding files in updatedDir,
-// it also deletes non KRM files and sub dirs which are present in localDir and not in updatedDir
-func ReplaceNonKRMFiles(updatedDir, originalDir, localDir string) error {
+// NonKRMFilesOptions configures how ReplaceNonKRMFiles updates the non-KRM
+// files.
+type NonKRMFilesOptions struct {
+	// Strategies are the update strategies of the files by path glob.
+	Strategies []kptfilev1.NonKRMFile
+
+	// RelPath is the path of the updated directory relative to the package
+	// of the strategies.
+	RelPath string
+
+	// Conflicts, if set, collects the text files merged with conflicts,
+	// with absolute paths.
+	Conflicts *[]merge.Conflict
+}
+
+// ReplaceNonKRMFiles updates the non KRM files in localDir with the changes
+// between the corresponding files in originalDir and updatedDir. Files
+// changed on one side only take the changed version, and files deleted from
+// updatedDir are deleted from localDir unless they have been modified
+// locally. Text files changed on both sides are merged line by line by
+// default, with conflict markers around the conflicting lines.
+func ReplaceNonKRMFiles(updatedDir, originalDir, localDir string, opts NonKRMFilesOptions) error {
 	const op errors.Op = "update.ReplaceNonKRMFiles"
 	updatedSubDirs, updatedFiles, err := getSubDirsAndNonKrmFiles(updatedDir)
 	if err != nil {
@@ -188,32 +242,6 @@ func ReplaceNonKRMFiles(updatedDir, originalDir, localDir string) error {
 		return errors.E(op, types.UniquePath(localDir), err)
 	}
 
-	// identify all non KRM files modified locally, to leave them untouched
-	locallyModifiedFiles := sets.String{}
-	for _, file := range localFiles.List() {
-		if !originalFiles.Has(file) {
-			// new local file has been added
-			locallyModifiedFiles.Insert(file)
-			continue
-		}
-		same, err := compareFiles(filepath.Join(originalDir, file), filepath.Join(localDir, file))
-		if err != nil {
-			return errors.E(op, types.UniquePath(localDir), err)
-		}
-		if !same {
-			// local file has been modified
-			locallyModifiedFiles.Insert(file)
-			continue
-		}
-
-		// remove the file from local if it is not modified and is deleted from updated upstream
-		if !updatedFiles.Has(file) {
-			if err = os.Remove(filepath.Join(localDir, file)); err != nil {
-				return errors.E(op, types.UniquePath(localDir), err)
-			}
-		}
-	}
-
 	// make sure local has all sub-dirs present in updated
 	for _, dir := range updatedSubDirs.List() {
 		if err = os.MkdirAll(filepath.Join(localDir, dir), 0700); err != nil {
@@ -221,14 +249,20 @@ func ReplaceNonKRMFiles(updatedDir, originalDir, localDir string) error {
 		}
 	}
 
-	// replace all non KRM files in local with the ones in updated
-	for _, file := range updatedFiles.List() {
-		if locallyModifiedFiles.Has(file) {
-			// skip syncing locally modified files
-			continue
+	files := sets.String{}
+	files.Insert(localFiles.List()...)
+	files.Insert(updatedFiles.List()...)
+	for _, file := range files.List() {
+		f := nonKRMFile{
+			name:     file,
+			local:    filepath.Join(localDir, file),
+			original: filepath.Join(originalDir, file),
+			updated:  filepath.Join(updatedDir, file),
+			inLocal:  localFiles.Has(file),
+			inOrig:   originalFiles.Has(file),
+			inUpd:    updatedFiles.Has(file),
 		}
-		err = copyutil.SyncFile(filepath.Join(updatedDir, file), filepath.Join(localDir, file))
-		if err != nil {
+		if err := f.update(opts); err != nil {
 			return errors.E(op, types.UniquePath(localDir), err)
 		}
 	}
@@ -244,6 +278,119 @@ func ReplaceNonKRMFiles(updatedDir, originalDir, localDir string) error {
 	return nil
 }
 
+// nonKRMFile is a non KRM file in the local, original and updated
+// directories.
+type nonKRMFile struct {
+	// name is the path of the file relative to the directories
+	name                     string
+	local, original, updated string
+	inLocal, inOrig, inUpd   bool
+}
+
+// update updates the local file with the changes in the updated file.
+func (f nonKRMFile) update(opts NonKRMFilesOptions) error {
+	switch {
+	// Deleted from local, or added in updated. Deleted files are
+	// restored from updated.
+	case !f.inLocal:
+		return copyutil.SyncFile(f.updated, f.local)
+	// Added locally
+	case !f.inUpd && !f.inOrig:
+		return nil
+	}
+
+	localChanged := true
+	if f.inOrig {
+		same, err := compareFiles(f.original, f.local)
+		if err != nil {
+			return err
+		}
+		localChanged = !same
+	}
+	strategy := opts.strategy(f.name)
+
+	// Deleted from updated, unless local has changes
+	if !f.inUpd {
+		if localChanged && strategy != kptfilev1.NonKRMFileTheirs {
+			return nil
+		}
+		return os.Remove(f.local)
+	}
+
+	if f.inOrig {
+		same, err := compareFiles(f.original, f.updated)
+		if err != nil {
+			return err
+		}
+		if same {
+			// no changes in updated
+			return nil
+		}
+	}
+	if !localChanged {
+		return copyutil.SyncFile(f.updated, f.local)
+	}
+	if same, err := compareFiles(f.local, f.updated); err != nil || same {
+		return err
+	}
+
+	// Changed on both sides, or added on both sides
+	switch strategy {
+	case kptfilev1.NonKRMFileTheirs:
+		return copyutil.SyncFile(f.updated, f.local)
+	case kptfilev1.NonKRMFileOurs:
+		return nil
+	}
+	return f.merge(opts.Conflicts)
+}
+
+// merge merges the changes to the text file on both sides line by line.
+// Binary files are kept.
+func (f nonKRMFile) merge(conflicts *[]merge.Conflict) error {
+	local, err := os.ReadFile(f.local)
+	if err != nil {
+		return err
+	}
+	updated, err := os.ReadFile(f.updated)
+	if err != nil {
+		return err
+	}
+	var original []byte
+	if f.inOrig {
+		if original, err = os.ReadFile(f.original); err != nil {
+			return err
+		}
+	}
+	if merge.IsBinary(local) || merge.IsBinary(updated) || merge.IsBinary(original) {
+		return nil
+	}
+
+	merged, conflict := merge.MergeText(local, original, updated)
+	info, err := os.Stat(f.local)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(f.local, merged, info.Mode()); err != nil {
+		return err
+	}
+	if conflict && conflicts != nil {
+		*conflicts = append(*conflicts, merge.Conflict{File: f.local})
+	}
+	return nil
+}
+
+// strategy returns the update strategy of the file.
+func (o NonKRMFilesOptions) strategy(file string) kptfilev1.NonKRMFileStrategyType {
+	relPath := path.Join(filepath.ToSlash(o.RelPath), filepath.ToSlash(file))
+	relPath = strings.TrimPrefix(relPath, "/")
+	for _, s := range o.Strategies {
+		if s.Matches(relPath) {
+			return s.Strategy
+		}
+	}
+	return kptfilev1.NonKRMFileMerge
+}
+
 // getSubDirsAndNonKrmFiles returns the list of all non git sub dirs and, non git+non KRM files
 // in the root directory
 func getSubDirsAndNonKrmFiles(root string) (sets.String, sets.String, error) {
@@ -262,6 +409,11 @@ func getSubDirsAndNonKrmFiles(root string) (sets.String, sets.String, error) {
 			}
 			return nil
 		}
+		// the conflict report belongs to the local package, it is never
+		// merged with upstream.
+		if info.Name() == ConflictReportFileName {
+			return nil
+		}
 		isKrm, err := isKrmFile(path)
 		if err != nil {
 			return errors.E(op, err)
@@ -18,6 +18,7 @@ package update
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
@@ -30,10 +31,12 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/util/addmergecomment"
 	"github.com/GoogleContainerTools/kpt/internal/util/fetch"
 	"github.com/GoogleContainerTools/kpt/internal/util/git"
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
 	"github.com/GoogleContainerTools/kpt/internal/util/pkgutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/stack"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/GoogleContainerTools/kpt/pkg/oci"
 	"github.com/GoogleContainerTools/kpt/pkg/printer"
 	"sigs.k8s.io/kustomize/kyaml/copyutil"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
@@ -79,6 +82,12 @@ type Options struct {
 	// updated and origin were fetched based on the information in the
 	// Kptfile from this package.
 	IsRoot bool
+
+	// Conflicts, if set, collects the fields changed to different values in
+	// the local package and in upstream, whose local values are kept. The
+	// file paths of the conflicts are absolute. Updaters which can't keep
+	// the local values ignore it.
+	Conflicts *[]merge.Conflict
 }
 
 // Updater updates a local package
@@ -103,6 +112,21 @@ type Command struct {
 	// Strategy is the update strategy to use
 	Strategy kptfilev1.UpdateStrategyType
 
+	// Resolve, if set, makes the command resolve the conflicts recorded
+	// by a previous update instead of updating the package.
+	Resolve Resolution
+
+	// Input is where the resolutions are read from in interactive mode.
+	// It defaults to stdin.
+	Input io.Reader
+
+	// conflicts collects the conflicts of the update.
+	conflicts *[]merge.Conflict
+
+	// dryRun is set when the command updates a copy of the package for
+	// DryRun, in which case it doesn't print the summary of the update.
+	dryRun bool
+
 	// cachedUpstreamRepos is an upstream repo already fetched for a given repoSpec CloneRef
 	cachedUpstreamRepos map[string]*gitutil.GitUpstreamRepo
 }
@@ -116,18 +140,38 @@ func (u *Command) Run(ctx context.Context) error {
 		return errors.E(op, errors.MissingParam, "pkg must be provided")
 	}
 
+	if u.Resolve != "" {
+		return u.resolveConflicts(ctx)
+	}
+	report, err := ReadConflictReport(u.Pkg.UniquePath.String())
+	if err != nil {
+		return errors.E(op, u.Pkg.UniquePath, err)
+	}
+	if report != nil {
+		return errors.E(op, u.Pkg.UniquePath,
+			fmt.Errorf("package has %d unresolved conflict(s) from a previous update in %s; "+
+				"run `kpt pkg update --resolve=ours|theirs|interactive` first",
+				len(report.Conflicts), ConflictReportFileName))
+	}
+	u.conflicts = &[]merge.Conflict{}
+
 	rootKf, err := u.Pkg.Kptfile()
 	if err != nil {
 		return errors.E(op, u.Pkg.UniquePath, err)
 	}
 
-	if rootKf.Upstream == nil || rootKf.Upstream.Git == nil {
+	if rootKf.Upstream == nil || (rootKf.Upstream.Git == nil && rootKf.Upstream.Oci == nil) {
 		return errors.E(op, u.Pkg.UniquePath,
 			fmt.Errorf("package must have an upstream reference"))
 	}
-	originalRootKfRef := rootKf.Upstream.Git.Ref
+	var originalRootKfRef string
+	if rootKf.Upstream.Git != nil {
+		originalRootKfRef = rootKf.Upstream.Git.Ref
+	}
 	if u.Ref != "" {
-		rootKf.Upstream.Git.Ref = u.Ref
+		if err := updateUpstreamRef(rootKf.Upstream, u.Ref); err != nil {
+			return errors.E(op, u.Pkg.UniquePath, err)
+		}
 	}
 	if u.Strategy != "" {
 		rootKf.Upstream.UpdateStrategy = u.Strategy
@@ -164,11 +208,13 @@ func (u *Command) Run(ctx context.Context) error {
 				return errors.E(op, p.UniquePath, err)
 			}
 
-			if subKf.Upstream != nil && subKf.Upstream.Git != nil {
+			if subKf.Upstream != nil && (subKf.Upstream.Git != nil || subKf.Upstream.Oci != nil) {
 				// update subpackage kf ref/strategy if current pkg is a subpkg of root pkg or is root pkg
 				// and if original root pkg ref matches the subpkg ref
 				if shouldUpdateSubPkgRef(subKf, rootKf, originalRootKfRef) {
-					updateSubKf(subKf, u.Ref, u.Strategy)
+					if err := updateSubKf(subKf, u.Ref, u.Strategy); err != nil {
+						return errors.E(op, subPkg.UniquePath, err)
+					}
 					err = kptfileutil.WriteFile(subPkg.UniquePath.String(), subKf)
 					if err != nil {
 						return errors.E(op, subPkg.UniquePath, err)
@@ -178,12 +224,43 @@ func (u *Command) Run(ctx context.Context) error {
 			}
 		}
 	}
-	pr.Printf("\nUpdated %d package(s).\n", packageCount)
+	if !u.dryRun {
+		pr.Printf("\nUpdated %d package(s).\n", packageCount)
+	}
 
 	// finally, make sure that the merge comments are added to all resources in the updated package
 	if err := addmergecomment.Process(string(u.Pkg.UniquePath)); err != nil {
 		return errors.E(op, u.Pkg.UniquePath, err)
 	}
+
+	if err := u.writeConflicts(ctx); err != nil {
+		return errors.E(op, u.Pkg.UniquePath, err)
+	}
+	return nil
+}
+
+// writeConflicts writes the conflicts of the update to the conflict report
+// of the root package, with paths relative to the root package. A stale
+// report is removed if there are no conflicts.
+func (u Command) writeConflicts(ctx context.Context) error {
+	pr := printer.FromContextOrDie(ctx)
+	conflicts := *u.conflicts
+	for i := range conflicts {
+		rel, err := filepath.Rel(u.Pkg.UniquePath.String(), conflicts[i].File)
+		if err != nil {
+			return err
+		}
+		conflicts[i].File = filepath.ToSlash(rel)
+	}
+	if err := WriteConflictReport(u.Pkg.UniquePath.String(), conflicts); err != nil {
+		return err
+	}
+	if u.dryRun || len(conflicts) == 0 {
+		return nil
+	}
+	pr.Printf("Kept the local values of %d conflicting field(s), recorded in %s.\n",
+		len(conflicts), ConflictReportFileName)
+	pr.Printf("Run `kpt pkg update --resolve=ours|theirs|interactive` to resolve them.\n")
 	return nil
 }
 
@@ -193,19 +270,41 @@ func (u Command) GetCachedUpstreamRepos() map[string]*gitutil.GitUpstreamRepo {
 }
 
 // updateSubKf updates subpackage with given ref and update strategy
-func updateSubKf(subKf *kptfilev1.KptFile, ref string, strategy kptfilev1.UpdateStrategyType) {
+func updateSubKf(subKf *kptfilev1.KptFile, ref string, strategy kptfilev1.UpdateStrategyType) error {
 	// check if explicit ref provided
 	if ref != "" {
-		subKf.Upstream.Git.Ref = ref
+		if err := updateUpstreamRef(subKf.Upstream, ref); err != nil {
+			return err
+		}
 	}
 	if strategy != "" {
 		subKf.Upstream.UpdateStrategy = strategy
 	}
+	return nil
+}
+
+// updateUpstreamRef sets the ref of the upstream. For OCI upstreams, the
+// ref replaces the tag or digest of the image.
+func updateUpstreamRef(upstream *kptfilev1.Upstream, ref string) error {
+	if upstream.Oci != nil {
+		image, err := oci.ReplaceReference(upstream.Oci.Image, ref)
+		if err != nil {
+			return err
+		}
+		upstream.Oci.Image = image
+		return nil
+	}
+	upstream.Git.Ref = ref
+	return nil
 }
 
 // shouldUpdateSubPkgRef checks if subpkg ref should be updated.
 // This is true if pkg has the same upstream repo, upstream directory is within or equal to root pkg directory and original root pkg ref matches the subpkg ref.
+// Only git upstreams share refs between packages.
 func shouldUpdateSubPkgRef(subKf, rootKf *kptfilev1.KptFile, originalRootKfRef string) bool {
+	if subKf.Upstream.Git == nil || rootKf.Upstream.Git == nil {
+		return false
+	}
 	return subKf.Upstream.Git.Repo == rootKf.Upstream.Git.Repo &&
 		subKf.Upstream.Git.Ref == originalRootKfRef &&
 		strings.HasPrefix(path.Clean(subKf.Upstream.Git.Directory), path.Clean(rootKf.Upstream.Git.Directory))
@@ -257,31 +356,45 @@ func (u Command) updateRootPackage(ctx context.Context, p *pkg.Pkg) error {
 	pr := printer.FromContextOrDie(ctx)
 	pr.PrintPackage(p, !(p == u.Pkg))
 
-	g := kf.Upstream.Git
-	updated := &git.RepoSpec{OrgRepo: g.Repo, Path: g.Directory, Ref: g.Ref}
-	pr.Printf("Fetching upstream from %s@%s\n", kf.Upstream.Git.Repo, kf.Upstream.Git.Ref)
-	cloner := fetch.NewCloner(updated, fetch.WithCachedRepo(u.cachedUpstreamRepos))
-	if err := cloner.ClonerUsingGitExec(ctx); err != nil {
-		return errors.E(op, p.UniquePath, err)
-	}
-	defer os.RemoveAll(updated.AbsPath())
-
-	var origin repoClone
-	if kf.UpstreamLock != nil {
-		gLock := kf.UpstreamLock.Git
-		originRepoSpec := &git.RepoSpec{OrgRepo: gLock.Repo, Path: gLock.Directory, Ref: gLock.Commit}
-		pr.Printf("Fetching origin from %s@%s\n", kf.Upstream.Git.Repo, kf.Upstream.Git.Ref)
-		if err := fetch.NewCloner(originRepoSpec, fetch.WithCachedRepo(u.cachedUpstreamRepos)).ClonerUsingGitExec(ctx); err != nil {
+	var updated, origin repoClone
+	var updateLock func() error
+	if kf.Upstream.Oci != nil {
+		updatedPkg, originPkg, err := pullOciUpstream(ctx, kf)
+		if err != nil {
 			return errors.E(op, p.UniquePath, err)
 		}
-		origin = originRepoSpec
+		defer os.RemoveAll(updatedPkg.Dir)
+		updated = updatedPkg
+		if originPkg != nil {
+			defer os.RemoveAll(originPkg.Dir)
+			origin = originPkg
+		}
+		updateLock = func() error {
+			return kptfileutil.UpdateUpstreamLockFromOci(p.UniquePath.String(), updatedPkg.Lock())
+		}
 	} else {
-		origin, err = newNilRepoClone()
+		updatedSpec, originSpec, err := u.cloneGitUpstream(ctx, kf)
+		if err != nil {
+			return errors.E(op, p.UniquePath, err)
+		}
+		defer os.RemoveAll(updatedSpec.AbsPath())
+		updated = updatedSpec
+		if originSpec != nil {
+			defer os.RemoveAll(originSpec.AbsPath())
+			origin = originSpec
+		}
+		updateLock = func() error {
+			return kptfileutil.UpdateUpstreamLockFromGit(p.UniquePath.String(), updatedSpec)
+		}
+	}
+	if origin == nil {
+		nrc, err := newNilRepoClone()
 		if err != nil {
 			return errors.E(op, p.UniquePath, err)
 		}
+		defer os.RemoveAll(nrc.AbsPath())
+		origin = nrc
 	}
-	defer os.RemoveAll(origin.AbsPath())
 
 	s := stack.New()
 	s.Push(".")
@@ -311,12 +424,73 @@ func (u Command) updateRootPackage(ctx context.Context, p *pkg.Pkg) error {
 		}
 	}
 
-	if err := kptfileutil.UpdateUpstreamLockFromGit(p.UniquePath.String(), updated); err != nil {
+	if err := updateLock(); err != nil {
 		return errors.E(op, p.UniquePath, err)
 	}
 	return nil
 }
 
+// cloneGitUpstream clones the updated version of the package from the git
+// upstream in the Kptfile, and the origin version if the package has an
+// upstream lock.
+func (u Command) cloneGitUpstream(ctx context.Context, kf *kptfilev1.KptFile) (*git.RepoSpec, *git.RepoSpec, error) {
+	const op errors.Op = "update.cloneGitUpstream"
+	pr := printer.FromContextOrDie(ctx)
+
+	g := kf.Upstream.Git
+	updated := &git.RepoSpec{OrgRepo: g.Repo, Path: g.Directory, Ref: g.Ref}
+	pr.Printf("Fetching upstream from %s@%s\n", kf.Upstream.Git.Repo, kf.Upstream.Git.Ref)
+	cloner := fetch.NewCloner(updated, fetch.WithCachedRepo(u.cachedUpstreamRepos))
+	if err := cloner.ClonerUsingGitExec(ctx); err != nil {
+		return nil, nil, errors.E(op, err)
+	}
+
+	if kf.UpstreamLock == nil || kf.UpstreamLock.Git == nil {
+		return updated, nil, nil
+	}
+	gLock := kf.UpstreamLock.Git
+	origin := &git.RepoSpec{OrgRepo: gLock.Repo, Path: gLock.Directory, Ref: gLock.Commit}
+	pr.Printf("Fetching origin from %s@%s\n", kf.Upstream.Git.Repo, kf.Upstream.Git.Ref)
+	if err := fetch.NewCloner(origin, fetch.WithCachedRepo(u.cachedUpstreamRepos)).ClonerUsingGitExec(ctx); err != nil {
+		_ = os.RemoveAll(updated.AbsPath())
+		return nil, nil, errors.E(op, err)
+	}
+	return updated, origin, nil
+}
+
+// pullOciUpstream pulls the updated version of the package from the OCI
+// upstream in the Kptfile, and the origin version if the package has an
+// upstream lock. The origin is pulled by the digest recorded in the lock,
+// since tags are mutable.
+func pullOciUpstream(ctx context.Context, kf *kptfilev1.KptFile) (*fetch.OciPackage, *fetch.OciPackage, error) {
+	const op errors.Op = "update.pullOciUpstream"
+	pr := printer.FromContextOrDie(ctx)
+
+	o := kf.Upstream.Oci
+	pr.Printf("Fetching upstream from %s\n", o.Image)
+	updated, err := fetch.PullOciPackage(ctx, o.Image, o.Directory)
+	if err != nil {
+		return nil, nil, errors.E(op, err)
+	}
+
+	if kf.UpstreamLock == nil || kf.UpstreamLock.Oci == nil {
+		return updated, nil, nil
+	}
+	oLock := kf.UpstreamLock.Oci
+	originImage, err := oci.ReplaceReference(oLock.Image, oLock.Digest)
+	if err != nil {
+		_ = os.RemoveAll(updated.Dir)
+		return nil, nil, errors.E(op, err)
+	}
+	pr.Printf("Fetching origin from %s\n", originImage)
+	origin, err := fetch.PullOciPackage(ctx, originImage, oLock.Directory)
+	if err != nil {
+		_ = os.RemoveAll(updated.Dir)
+		return nil, nil, errors.E(op, err)
+	}
+	return updated, origin, nil
+}
+
 // updatePackage takes care of updating a single package. The absolute paths to
 // the local, updated and origin packages are provided, as well as the path to the
 // package relative to the root.
@@ -470,6 +644,7 @@ func (u Command) mergePackage(ctx context.Context, localPath, updatedPath, origi
 		UpdatedPath:    updatedPath,
 		OriginPath:     originPath,
 		IsRoot:         isRootPkg,
+		Conflicts:      u.conflicts,
 	}); err != nil {
 		return errors.E(op, types.UniquePath(localPath), err)
 	}
@@ -16,6 +16,8 @@ package update_test
 
 import (
 	"fmt"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
@@ -26,10 +28,14 @@ import (
 	pkgtest "github.com/GoogleContainerTools/kpt/internal/pkg/testing"
 	"github.com/GoogleContainerTools/kpt/internal/testutil"
 	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/GoogleContainerTools/kpt/internal/util/get"
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
 	. "github.com/GoogleContainerTools/kpt/internal/util/update"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/GoogleContainerTools/kpt/pkg/oci"
 	"github.com/GoogleContainerTools/kpt/pkg/printer/fake"
+	"github.com/google/go-containerregistry/pkg/registry"
 	"github.com/stretchr/testify/assert"
 	"sigs.k8s.io/kustomize/kyaml/copyutil"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
@@ -222,6 +228,23 @@ func TestCommand_Run_noChanges(t *testing.T) {
 	}
 }
 
+const replicasConflictReport = `apiVersion: kpt.dev/v1alpha1
+kind: ConflictReport
+conflicts:
+- file: deployment.yaml
+  resource:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: mysql-deployment
+    namespace: myspace
+  path:
+  - spec
+  - replicas
+  local: 21
+  upstream: 42
+  origin: 3
+`
+
 func TestCommand_Run_localPackageChanges(t *testing.T) {
 	testCases := map[string]struct {
 		strategy        kptfilev1.UpdateStrategyType
@@ -315,7 +338,8 @@ func TestCommand_Run_localPackageChanges(t *testing.T) {
 			expectedLocal: testutil.Content{
 				Pkg: pkgbuilder.NewRootPkg().
 					WithResource(pkgbuilder.DeploymentResource,
-						pkgbuilder.SetFieldPath("42", "spec", "replicas")),
+						pkgbuilder.SetFieldPath("21", "spec", "replicas")).
+					WithFile(ConflictReportFileName, replicasConflictReport),
 			},
 			expectedCommit: func(writer *testutil.TestSetupManager) (string, error) {
 				return writer.Repos[testutil.Upstream].GetCommit()
@@ -884,6 +908,63 @@ func TestCommand_Run_toTagRef(t *testing.T) {
 	}
 }
 
+// TestCommand_Run_toVersionConstraint verifies the package contents are set
+// to the contents of the tag with the highest version matching the constraint.
+func TestCommand_Run_toVersionConstraint(t *testing.T) {
+	g := &testutil.TestSetupManager{
+		T: t,
+		ReposChanges: map[string][]testutil.Content{
+			testutil.Upstream: {
+				{
+					Data:   testutil.Dataset1,
+					Branch: masterBranch,
+				},
+				{
+					Data: testutil.Dataset2,
+					Tag:  "release/v1.0.3",
+				},
+				{
+					Data: testutil.Dataset3,
+					Tag:  "release/v1.1.0",
+				},
+			},
+		},
+	}
+	defer g.Clean()
+	if !g.Init() {
+		return
+	}
+	upstreamRepo := g.Repos[testutil.Upstream]
+
+	// Update the local package
+	if !assert.NoError(t, (&Command{
+		Pkg: pkgtest.CreatePkgOrFail(t, g.LocalWorkspace.FullPackagePath()),
+		Ref: "release/v~1.0",
+	}).Run(fake.CtxWithDefaultPrinter())) {
+		return
+	}
+
+	// Expect the local package to have Dataset2
+	if !g.AssertLocalDataEquals(testutil.Dataset2, true) {
+		return
+	}
+
+	if !assert.NoError(t, upstreamRepo.CheckoutBranch("release/v1.0.3", false)) {
+		return
+	}
+	commit, err := upstreamRepo.GetCommit()
+	if !assert.NoError(t, err) {
+		return
+	}
+	kf, err := pkgtest.CreatePkgOrFail(t, g.LocalWorkspace.FullPackagePath()).Kptfile()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "release/v~1.0", kf.Upstream.Git.Ref)
+	assert.Equal(t, "release/v1.0.3", kf.UpstreamLock.Git.Ref)
+	assert.Equal(t, commit, kf.UpstreamLock.Git.Commit)
+}
+
 // TestCommand_ResourceMerge_NonKRMUpdates tests if the local non KRM files are updated
 func TestCommand_ResourceMerge_NonKRMUpdates(t *testing.T) {
 	strategies := []kptfilev1.UpdateStrategyType{kptfilev1.ResourceMerge}
@@ -981,6 +1062,7 @@ func TestCommand_Run_failInvalidPath(t *testing.T) {
 }
 
 func TestCommand_Run_badUpstreamLock(t *testing.T) {
+	testutil.UseExecBackend(t)
 	testCases := map[string]struct {
 		commit           string
 		repo             string
@@ -1047,6 +1129,7 @@ func TestCommand_Run_badUpstreamLock(t *testing.T) {
 
 // TestCommand_Run_failInvalidRef verifies Run fails if the ref is invalid
 func TestCommand_Run_failInvalidRef(t *testing.T) {
+	testutil.UseExecBackend(t)
 	for i := range kptfilev1.UpdateStrategies {
 		strategy := kptfilev1.UpdateStrategies[i]
 		t.Run(string(strategy), func(t *testing.T) {
@@ -1931,6 +2014,42 @@ func TestCommand_Run_local_subpackages(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "conflict report in upstream is not copied",
+			reposChanges: map[string][]testutil.Content{
+				testutil.Upstream: {
+					{
+						Pkg: pkgbuilder.NewRootPkg().
+							WithKptfile(pkgbuilder.NewKptfile()).
+							WithFile("data.txt", "initial content").
+							WithFile(ConflictReportFileName, "apiVersion: kpt.dev/v1alpha1\nkind: ConflictReport\nconflicts:\n- file: data.txt\n"),
+						Branch: masterBranch,
+					},
+					{
+						Pkg: pkgbuilder.NewRootPkg().
+							WithKptfile(pkgbuilder.NewKptfile()).
+							WithFile("data.txt", "updated content").
+							WithFile(ConflictReportFileName, "apiVersion: kpt.dev/v1alpha1\nkind: ConflictReport\nconflicts:\n- file: data.txt\n"),
+					},
+				},
+			},
+			expectedResults: []resultForStrategy{
+				{
+					strategies: []kptfilev1.UpdateStrategyType{
+						kptfilev1.ResourceMerge,
+						kptfilev1.FastForward,
+						kptfilev1.ForceDeleteReplace,
+					},
+					expectedLocal: pkgbuilder.NewRootPkg().
+						WithKptfile(
+							pkgbuilder.NewKptfile().
+								WithUpstreamRef("upstream", "/", masterBranch, "PLACEHOLDER").
+								WithUpstreamLockRef("upstream", "/", masterBranch, 1),
+						).
+						WithFile("data.txt", "updated content"),
+				},
+			},
+		},
 		{
 			name: "non-krm files updated in both upstream and local",
 			reposChanges: map[string][]testutil.Content{
@@ -1964,7 +2083,8 @@ func TestCommand_Run_local_subpackages(t *testing.T) {
 								WithUpstreamRef("upstream", "/", masterBranch, "PLACEHOLDER").
 								WithUpstreamLockRef("upstream", "/", masterBranch, 1),
 						).
-						WithFile("data.txt", "local content"),
+						WithFile("data.txt", "<<<<<<< local\nlocal content\n=======\nupdated content\n>>>>>>> upstream\n").
+						WithFile(ConflictReportFileName, "apiVersion: kpt.dev/v1alpha1\nkind: ConflictReport\nconflicts:\n- file: data.txt\n"),
 				},
 				{
 					strategies: []kptfilev1.UpdateStrategyType{
@@ -3869,10 +3989,197 @@ func TestReplaceNonKRMFiles(t *testing.T) {
 			// expectedLocal.
 			err = os.WriteFile(filepath.Join(updated, "new.yaml"), []byte("a: b"), 0600)
 			assert.NoError(t, err)
-			err = ReplaceNonKRMFiles(updated, original, local)
+			err = ReplaceNonKRMFiles(updated, original, local, NonKRMFilesOptions{})
 			assert.NoError(t, err)
 			tg := testutil.TestGitRepo{}
 			tg.AssertEqual(t, local, expectedLocal, false)
 		})
 	}
 }
+
+// TestReplaceNonKRMFiles_strategies tests the update strategies of non KRM
+// files changed both locally and in upstream
+func TestReplaceNonKRMFiles_strategies(t *testing.T) {
+	const (
+		original = "# Title\n\nIntro.\n\nUsage.\n"
+		updated  = "# Title\n\nIntro.\n\nNew usage.\n"
+	)
+	testCases := map[string]struct {
+		local              string
+		strategies         []kptfilev1.NonKRMFile
+		expected           string
+		expectedConflicted bool
+	}{
+		"merge": {
+			local:    "# Local title\n\nIntro.\n\nUsage.\n",
+			expected: "# Local title\n\nIntro.\n\nNew usage.\n",
+		},
+		"merge with conflicts": {
+			local:              "# Title\n\nIntro.\n\nLocal usage.\n",
+			expected:           "# Title\n\nIntro.\n\n<<<<<<< local\nLocal usage.\n=======\nNew usage.\n>>>>>>> upstream\n",
+			expectedConflicted: true,
+		},
+		"ours": {
+			local:      "# Title\n\nIntro.\n\nLocal usage.\n",
+			strategies: []kptfilev1.NonKRMFile{{Path: "docs/*.md", Strategy: kptfilev1.NonKRMFileOurs}},
+			expected:   "# Title\n\nIntro.\n\nLocal usage.\n",
+		},
+		"theirs": {
+			local: "# Title\n\nIntro.\n\nLocal usage.\n",
+			strategies: []kptfilev1.NonKRMFile{
+				{Path: "*.md", Strategy: kptfilev1.NonKRMFileTheirs},
+				{Path: "docs/*.md", Strategy: kptfilev1.NonKRMFileOurs},
+			},
+			expected: updated,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			dirs := map[string]string{}
+			for name, content := range map[string]string{
+				"original": original,
+				"updated":  updated,
+				"local":    tc.local,
+			} {
+				dirs[name] = t.TempDir()
+				err := os.MkdirAll(filepath.Join(dirs[name], "docs"), 0700)
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				err = os.WriteFile(filepath.Join(dirs[name], "docs", "README.md"), []byte(content), 0600)
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+			}
+
+			var conflicts []merge.Conflict
+			err := ReplaceNonKRMFiles(dirs["updated"], dirs["original"], dirs["local"], NonKRMFilesOptions{
+				Strategies: tc.strategies,
+				Conflicts:  &conflicts,
+			})
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			b, err := os.ReadFile(filepath.Join(dirs["local"], "docs", "README.md"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expected, string(b))
+			if tc.expectedConflicted {
+				assert.Equal(t, []merge.Conflict{{File: filepath.Join(dirs["local"], "docs", "README.md")}}, conflicts)
+			} else {
+				assert.Empty(t, conflicts)
+			}
+		})
+	}
+}
+
+// TestReplaceNonKRMFiles_binary tests that binary files changed both locally
+// and in upstream are kept
+func TestReplaceNonKRMFiles_binary(t *testing.T) {
+	dirs := map[string]string{}
+	for name, content := range map[string][]byte{
+		"original": {0x89, 'P', 'N', 'G', 0x00, 1},
+		"updated":  {0x89, 'P', 'N', 'G', 0x00, 2},
+		"local":    {0x89, 'P', 'N', 'G', 0x00, 3},
+	} {
+		dirs[name] = t.TempDir()
+		err := os.WriteFile(filepath.Join(dirs[name], "logo.png"), content, 0600)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+
+	err := ReplaceNonKRMFiles(dirs["updated"], dirs["original"], dirs["local"], NonKRMFilesOptions{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	b, err := os.ReadFile(filepath.Join(dirs["local"], "logo.png"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []byte{0x89, 'P', 'N', 'G', 0x00, 3}, b)
+}
+
+// TestCommand_Run_oci updates a package fetched from an OCI registry.
+// - Push Dataset1 and Dataset2 as the v1 and v2 tags of an image
+// - Get the java package from the v1 image
+// - Update the local package to the v2 image
+func TestCommand_Run_oci(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	image := u.Host + "/blueprints/datasets"
+
+	testDataDir, err := testutil.GetTestDataPath()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	ctx := fake.CtxWithDefaultPrinter()
+	_, err = oci.PushPackage(ctx, filepath.Join(testDataDir, testutil.Dataset1), image+":v1")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	v2Digest, err := oci.PushPackage(ctx, filepath.Join(testDataDir, testutil.Dataset2), image+":v2")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	for i := range kptfilev1.UpdateStrategies {
+		strategy := kptfilev1.UpdateStrategies[i]
+		t.Run(string(strategy), func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "java")
+			err := get.Command{
+				Oci: &kptfilev1.Oci{
+					Image:     image + ":v1",
+					Directory: "java",
+				},
+				Destination: dest,
+			}.Run(ctx)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			err = (&Command{
+				Pkg:      pkgtest.CreatePkgOrFail(t, dest),
+				Ref:      "v2",
+				Strategy: strategy,
+			}).Run(ctx)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			b, err := os.ReadFile(filepath.Join(dest, "java-deployment.resource.yaml"))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Contains(t, string(b), "containerPort: 80\n")
+
+			kf, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, dest)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, &kptfilev1.Upstream{
+				Type: kptfilev1.OciOrigin,
+				Oci: &kptfilev1.Oci{
+					Image:     image + ":v2",
+					Directory: "java",
+				},
+				UpdateStrategy: strategy,
+			}, kf.Upstream)
+			assert.Equal(t, &kptfilev1.UpstreamLock{
+				Type: kptfilev1.OciOrigin,
+				Oci: &kptfilev1.OciLock{
+					Image:     image + ":v2",
+					Directory: "java",
+					Digest:    v2Digest,
+				},
+			}, kf.UpstreamLock)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package defines FunctionPolicy schema.
+// Version: v1alpha1
+// swagger:meta
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// FunctionPolicyGVK is the GroupVersionKind of FunctionPolicy objects
+func FunctionPolicyGVK() schema.GroupVersionKind {
+	return schema.GroupVersionKind{
+		Group:   "kpt.dev",
+		Version: "v1alpha1",
+		Kind:    "FunctionPolicy",
+	}
+}
+
+// FunctionPolicy restricts the functions kpt is allowed to run.
+// Image patterns are globs where `*` matches any sequence of characters
+// except `/` and `**` matches any sequence of characters.
+// swagger:model functionpolicy
+type FunctionPolicy struct {
+	yaml.ResourceMeta `yaml:",inline" json:",inline"`
+
+	// AllowedImages are the patterns of the image repositories functions
+	// can be run from. Images from all repositories are allowed if empty.
+	// e.g. 'us-docker.pkg.dev/my-project/kpt-fn/**'
+	AllowedImages []string `yaml:"allowedImages,omitempty" json:"allowedImages,omitempty"`
+
+	// DeniedImages are the patterns of the image repositories, or of the
+	// images including their tag, that are never run, even if allowed.
+	// e.g. 'us-docker.pkg.dev/my-project/kpt-fn/starlark:*'
+	DeniedImages []string `yaml:"deniedImages,omitempty" json:"deniedImages,omitempty"`
+
+	// RequireDigest requires function images to be pinned to a digest,
+	// either in the image or in the functionLock of the Kptfile.
+	RequireDigest bool `yaml:"requireDigest,omitempty" json:"requireDigest,omitempty"`
+
+	// MinVersions are the minimum semantic versions of the images of
+	// repositories matching a pattern. The tag of the image is its version.
+	MinVersions []MinVersion `yaml:"minVersions,omitempty" json:"minVersions,omitempty"`
+
+	// AllowExec allows executable functions to be run. Executables are
+	// denied by a policy by default, since they can't be restricted
+	// by image.
+	AllowExec bool `yaml:"allowExec,omitempty" json:"allowExec,omitempty"`
+
+	// Signatures are the signatures required for the images of repositories
+	// matching a pattern. An image must have a valid signature matching one
+	// of the requirements whose pattern matches its repository.
+	Signatures []SignatureRequirement `yaml:"signatures,omitempty" json:"signatures,omitempty"`
+}
+
+// MinVersion is the minimum version of the images of repositories
+// matching a pattern.
+type SignatureRequirement struct {
+	// Image is the pattern of the image repositories.
+	// e.g. 'us-docker.pkg.dev/my-project/kpt-fn/**'
+	Image string `yaml:"image,omitempty" json:"image,omitempty"`
+
+	// Key is the path of the PEM encoded public key the images must be
+	// signed with, relative to the policy file. e.g. 'cosign.pub'
+	Key string `yaml:"key,omitempty" json:"key,omitempty"`
+
+	// Keyless requires the images to be signed with a short-lived
+	// certificate issued to an identity. Key and Keyless are mutually
+	// exclusive.
+	Keyless *KeylessSignature `yaml:"keyless,omitempty" json:"keyless,omitempty"`
+}
+
+type KeylessSignature struct {
+	// Bundle is the path of the sigstore trusted root bundle holding the
+	// certificate authorities and the transparency log keys, relative to
+	// the policy file. e.g. 'trusted_root.json'
+	Bundle string `yaml:"bundle,omitempty" json:"bundle,omitempty"`
+
+	// Identity is the email address or the URI the signing certificate
+	// must be issued to. e.g. 'release@example.com'
+	Identity string `yaml:"identity,omitempty" json:"identity,omitempty"`
+
+	// Issuer is the OIDC issuer which must have authenticated the
+	// identity. Any issuer is accepted if empty.
+	// e.g. 'https://accounts.google.com'
+	Issuer string `yaml:"issuer,omitempty" json:"issuer,omitempty"`
+}
+
+type MinVersion struct {
+	// Image is the pattern of the image repositories.
+	// e.g. 'us-docker.pkg.dev/my-project/kpt-fn/set-labels'
+	Image string `yaml:"image,omitempty" json:"image,omitempty"`
+
+	// Version is the minimum semantic version of the images.
+	// e.g. 'v0.2.0'
+	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+}
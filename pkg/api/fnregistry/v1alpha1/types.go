@@ -0,0 +1,89 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package defines FunctionRegistryConfig schema.
+// Version: v1alpha1
+// swagger:meta
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// FunctionRegistryConfigGVK is the GroupVersionKind of FunctionRegistryConfig
+// objects
+func FunctionRegistryConfigGVK() schema.GroupVersionKind {
+	return schema.GroupVersionKind{
+		Group:   "kpt.dev",
+		Version: "v1alpha1",
+		Kind:    "FunctionRegistryConfig",
+	}
+}
+
+// FunctionRegistryConfig configures the registries function images are
+// pulled from.
+// swagger:model functionregistryconfig
+type FunctionRegistryConfig struct {
+	yaml.ResourceMeta `yaml:",inline" json:",inline"`
+
+	// DefaultRegistry is the registry, including the repository path, the
+	// short names of functions are resolved in. Defaults to 'gcr.io/kpt-fn'.
+	// e.g. with 'registry.local/kpt-fn', 'set-labels:v0.1' resolves to
+	// 'registry.local/kpt-fn/set-labels:v0.1'
+	DefaultRegistry string `yaml:"defaultRegistry,omitempty" json:"defaultRegistry,omitempty"`
+
+	// Mirrors are the repositories images are pulled from instead of the
+	// repositories they are declared with. The first matching mirror is
+	// used.
+	Mirrors []Mirror `yaml:"mirrors,omitempty" json:"mirrors,omitempty"`
+
+	// Credentials are the credentials used to pull images from registries.
+	// Registries without credentials use the credentials of the docker
+	// config and of the credential helpers, if any.
+	Credentials []Credential `yaml:"credentials,omitempty" json:"credentials,omitempty"`
+}
+
+// Mirror is a repository images of other repositories are pulled from.
+type Mirror struct {
+	// Source is the repository of the declared images. A source ending with
+	// '/*' matches all the repositories under its prefix.
+	// e.g. 'gcr.io/kpt-fn/*'
+	Source string `yaml:"source,omitempty" json:"source,omitempty"`
+
+	// Mirror is the repository the images are pulled from. If the source
+	// ends with '/*', the mirror must too, and the repository path matched
+	// by the source replaces the '*' of the mirror.
+	// e.g. 'registry.local/kpt-fn/*'
+	Mirror string `yaml:"mirror,omitempty" json:"mirror,omitempty"`
+}
+
+// Credential is the username and password of a registry.
+type Credential struct {
+	// Registry is the hostname of the registry, including its port if any.
+	// e.g. 'registry.local:5000'
+	Registry string `yaml:"registry,omitempty" json:"registry,omitempty"`
+
+	// Username is the username of the registry.
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+
+	// PasswordEnv is the environment variable holding the password or the
+	// token of the registry.
+	PasswordEnv string `yaml:"passwordEnv,omitempty" json:"passwordEnv,omitempty"`
+
+	// PasswordFile is the path of the file holding the password or the
+	// token of the registry, relative to the config file. PasswordEnv and
+	// PasswordFile are mutually exclusive.
+	PasswordFile string `yaml:"passwordFile,omitempty" json:"passwordFile,omitempty"`
+}
@@ -25,22 +25,52 @@ type Result struct {
 	// Image is the full name of the image that generates this result
 	// Image and Exec are mutually exclusive
 	Image string `yaml:"image,omitempty"`
+	// OriginalImage is the image the function was declared with, if the
+	// image was pulled from a mirror of its repository. Image is then the
+	// image in the mirror.
+	OriginalImage string `yaml:"originalImage,omitempty"`
+	// Builtin is the builtin function kpt ran in-process instead of Image,
+	// if Image is a catalog function implemented by kpt and
+	// `--builtin-catalog=auto` is set.
+	Builtin string `yaml:"builtin,omitempty"`
 	// ExecPath is the the absolute os-specific path to the executable file
 	// If user provides an executable file with commands, ExecPath should
 	// contain the entire input string.
 	ExecPath string `yaml:"exec,omitempty"`
 	// TODO(droot): This is required for making structured results subpackage aware.
-	// Enable this once test harness supports filepath based assertions.
-	// Pkg is OS specific Absolute path to the package.
-	// Pkg string `yaml:"pkg,omitempty"`
+	// Enable this for render once test harness supports filepath based assertions.
+	// Pkg is the path of the package the function was run on. It is only set
+	// when `kpt fn eval` runs the function on several packages, in which
+	// case the file paths of the results are relative to Pkg.
+	Pkg string `yaml:"pkg,omitempty"`
 	// Stderr is the content in function stderr
 	Stderr string `yaml:"stderr,omitempty"`
 	// ExitCode is the exit code from running the function
 	ExitCode int `yaml:"exitCode"`
+	// Skipped is true if the function was not executed because its
+	// `when` condition evaluated to false
+	Skipped bool `yaml:"skipped,omitempty"`
+	// Signature is the verified signature of the function image, if a
+	// function policy requires the image to be signed
+	Signature *SignatureVerification `yaml:"signature,omitempty"`
 	// Results is the list of results for the function
 	Results framework.Results `yaml:"results,omitempty"`
 }
 
+// SignatureVerification is the result of the verification of the signature
+// of a function image
+type SignatureVerification struct {
+	// Digest is the verified digest of the image, which is the digest run
+	Digest string `yaml:"digest"`
+	// Signer is the identity of the signer, i.e. the sha256 digest of the
+	// public key or the identity of the signing certificate
+	Signer string `yaml:"signer"`
+	// Issuer is the OIDC issuer of the identity of the signing certificate
+	Issuer string `yaml:"issuer,omitempty"`
+	// Policy is the path of the function policy requiring the signature
+	Policy string `yaml:"policy,omitempty"`
+}
+
 const (
 	// Deprecated: prefer ResultListGVK
 	ResultListKind = "FunctionResultList"
@@ -19,6 +19,8 @@ package v1
 
 import (
 	"fmt"
+	"path"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
@@ -77,6 +79,10 @@ type KptFile struct {
 	// Pipeline declares the pipeline of functions.
 	Pipeline *Pipeline `yaml:"pipeline,omitempty" json:"pipeline,omitempty"`
 
+	// FunctionLock pins the images of the pipeline functions to digests.
+	// This is set by `kpt fn lock`.
+	FunctionLock []FunctionLock `yaml:"functionLock,omitempty" json:"functionLock,omitempty"`
+
 	// Inventory contains parameters for the inventory object used in apply.
 	Inventory *Inventory `yaml:"inventory,omitempty" json:"inventory,omitempty"`
 
@@ -89,6 +95,9 @@ type OriginType string
 const (
 	// GitOrigin specifies a package as having been cloned from a git repository.
 	GitOrigin OriginType = "git"
+
+	// OciOrigin specifies a package as having been pulled from an OCI registry.
+	OciOrigin OriginType = "oci"
 )
 
 // UpdateStrategyType defines the strategy for updating a package from upstream.
@@ -145,8 +154,63 @@ type Upstream struct {
 	// Git is the locator for a package stored on Git.
 	Git *Git `yaml:"git,omitempty" json:"git,omitempty"`
 
+	// Oci is the locator for a package stored in an OCI registry.
+	Oci *Oci `yaml:"oci,omitempty" json:"oci,omitempty"`
+
 	// UpdateStrategy declares how a package will be updated from upstream.
 	UpdateStrategy UpdateStrategyType `yaml:"updateStrategy,omitempty" json:"updateStrategy,omitempty"`
+
+	// NonKRMFiles declares how the resource-merge strategy updates the
+	// non-KRM files of the package, by path glob. The first matching glob
+	// applies. Text files are merged by default.
+	NonKRMFiles []NonKRMFile `yaml:"nonKRMFiles,omitempty" json:"nonKRMFiles,omitempty"`
+}
+
+// NonKRMFileStrategyType defines how the changes to a non-KRM file in
+// upstream and in the local package are merged.
+type NonKRMFileStrategyType string
+
+const (
+	// NonKRMFileMerge merges text files line by line, and writes
+	// conflict markers around the lines changed on both sides. Binary
+	// files are handled like NonKRMFileOurs.
+	NonKRMFileMerge NonKRMFileStrategyType = "merge"
+	// NonKRMFileOurs keeps the local file if it has been changed on both
+	// sides.
+	NonKRMFileOurs NonKRMFileStrategyType = "ours"
+	// NonKRMFileTheirs replaces the local file with the upstream file if
+	// it has been changed on both sides.
+	NonKRMFileTheirs NonKRMFileStrategyType = "theirs"
+)
+
+// NonKRMFileStrategies is a slice with all the supported strategies for
+// non-KRM files.
+var NonKRMFileStrategies = []NonKRMFileStrategyType{
+	NonKRMFileMerge,
+	NonKRMFileOurs,
+	NonKRMFileTheirs,
+}
+
+// NonKRMFile declares the update strategy of the non-KRM files matching a
+// path glob.
+type NonKRMFile struct {
+	// Path is a glob of the slash-separated paths of the files relative
+	// to the package, e.g. 'docs/*.md'. A glob without a slash matches the
+	// name of the files in any directory, e.g. '*.sh'.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// Strategy is the update strategy of the files.
+	Strategy NonKRMFileStrategyType `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+}
+
+// Matches returns true if the slash-separated path relative to the package
+// matches the glob of the file.
+func (f NonKRMFile) Matches(relPath string) bool {
+	if !strings.Contains(f.Path, "/") {
+		relPath = path.Base(relPath)
+	}
+	match, err := path.Match(f.Path, relPath)
+	return err == nil && match
 }
 
 // Git is the user-specified locator for a package on Git.
@@ -160,9 +224,24 @@ type Git struct {
 	Directory string `yaml:"directory,omitempty" json:"directory,omitempty"`
 
 	// Ref can be a Git branch, tag, or a commit SHA-1.
+	// It can also be a semantic version constraint, optionally prefixed by
+	// the prefix of the tags, which resolves to the tag with the highest
+	// matching version.
+	// e.g. '~1.4', '>=2.0.0 <3' or 'package-a/v^1.2'
 	Ref string `yaml:"ref,omitempty" json:"ref,omitempty"`
 }
 
+// Oci is the user-specified locator for a package in an OCI registry.
+type Oci struct {
+	// Image is the image of the package, including its tag or digest.
+	// e.g. 'us-docker.pkg.dev/my-project/blueprints/cockroachdb:v1'
+	Image string `yaml:"image,omitempty" json:"image,omitempty"`
+
+	// Directory is the sub directory of the image.
+	// e.g. 'staging/cockroachdb'
+	Directory string `yaml:"directory,omitempty" json:"directory,omitempty"`
+}
+
 // UpstreamLock is a resolved locator for the last fetch of the package.
 type UpstreamLock struct {
 	// Type is the type of origin.
@@ -170,6 +249,9 @@ type UpstreamLock struct {
 
 	// Git is the resolved locator for a package on Git.
 	Git *GitLock `yaml:"git,omitempty" json:"git,omitempty"`
+
+	// Oci is the resolved locator for a package in an OCI registry.
+	Oci *OciLock `yaml:"oci,omitempty" json:"oci,omitempty"`
 }
 
 // GitLock is the resolved locator for a package on Git.
@@ -191,6 +273,21 @@ type GitLock struct {
 	Commit string `yaml:"commit,omitempty" json:"commit,omitempty"`
 }
 
+// OciLock is the resolved locator for a package in an OCI registry.
+type OciLock struct {
+	// Image is the image that was pulled.
+	// e.g. 'us-docker.pkg.dev/my-project/blueprints/cockroachdb:v1'
+	Image string `yaml:"image,omitempty" json:"image,omitempty"`
+
+	// Directory is the sub directory of the image that was pulled.
+	// e.g. 'staging/cockroachdb'
+	Directory string `yaml:"directory,omitempty" json:"directory,omitempty"`
+
+	// Digest is the digest of the image manifest for the last fetch of
+	// the package. This is set by kpt for bookkeeping purposes.
+	Digest string `yaml:"digest,omitempty" json:"digest,omitempty"`
+}
+
 // PackageInfo contains optional information about the package such as license, documentation, etc.
 // These fields are not consumed by any functionality in kpt and are simply passed through.
 // Note that like any other KRM resource, humans and automation can also use `metadata.labels` and
@@ -327,6 +424,46 @@ type Function struct {
 	// `Exclude` are used to specify resources on which the function should NOT be executed.
 	// If not specified, all resources selected by `Selectors` are selected.
 	Exclusions []Selector `yaml:"exclude,omitempty" json:"exclude,omitempty"`
+
+	// `When` is a CEL expression evaluated against the current resources of the
+	// package, available as the `resources` list. The function is skipped if the
+	// expression evaluates to false, e.g.
+	// `resources.exists(r, r.kind == "ConfigMap" && r.data.env == "prod")`.
+	// If not specified, the function is always executed.
+	When string `yaml:"when,omitempty" json:"when,omitempty"`
+
+	// `Timeout` is the maximum duration the function is allowed to run, e.g. `30s`.
+	// If not specified, the function is killed after 5 minutes.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// `Memory` is the maximum amount of memory the function is allowed to use,
+	// e.g. `512Mi`. It applies to container functions only, and is rejected
+	// for wasm functions, whose memory can't be capped.
+	// If not specified, the memory is not limited.
+	Memory string `yaml:"memory,omitempty" json:"memory,omitempty"`
+
+	// `CPU` is the maximum number of CPUs the function is allowed to use,
+	// e.g. `500m` or `2`. It applies to container functions only.
+	// If not specified, the CPU is not limited.
+	CPU string `yaml:"cpu,omitempty" json:"cpu,omitempty"`
+
+	// `Mounts` are the files and directories of the package mounted read-only
+	// into the container of the function, e.g. data files read by a generator.
+	// Mounts are only supported for container functions and require the
+	// `--allow-mount` flag of `kpt fn render`.
+	Mounts []Mount `yaml:"mounts,omitempty" json:"mounts,omitempty"`
+}
+
+// Mount is a file or directory of the package mounted read-only into the
+// container of a function.
+type Mount struct {
+	// `Src` is the slash-delimited path of the file or directory to mount,
+	// relative to the directory of the package. It must be inside the package.
+	Src string `yaml:"src,omitempty" json:"src,omitempty"`
+
+	// `Dst` is the absolute path the file or directory is mounted at in the
+	// container.
+	Dst string `yaml:"dst,omitempty" json:"dst,omitempty"`
 }
 
 // Selector specifies the selection criteria
@@ -357,6 +494,17 @@ func (s Selector) IsEmpty() bool {
 		len(s.Annotations) == 0
 }
 
+// FunctionLock pins the image of pipeline functions to a digest.
+type FunctionLock struct {
+	// Image is the image of the functions as declared in the pipeline.
+	// e.g. 'set-labels:v0.1'
+	Image string `yaml:"image,omitempty" json:"image,omitempty"`
+
+	// Digest is the digest of the image manifest the image resolved to.
+	// e.g. 'sha256:8ae0ee1a1b2bb2b88f0a1e4bbf6bb0b1f0bc9e1a4b0e5e2df1a6d0f5c1d3e2f4'
+	Digest string `yaml:"digest,omitempty" json:"digest,omitempty"`
+}
+
 // Inventory encapsulates the parameters for the inventory resource applied to a cluster.
 // All of the the parameters are required if any are set.
 type Inventory struct {
@@ -16,11 +16,14 @@ package v1
 
 import (
 	"fmt"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/GoogleContainerTools/kpt/internal/types"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"sigs.k8s.io/kustomize/api/konfig"
 	kustomizetypes "sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
@@ -38,10 +41,41 @@ func (kf *KptFile) Validate(fsys filesys.FileSystem, pkgPath types.UniquePath) e
 	if err := kf.Pipeline.validate(fsys, pkgPath); err != nil {
 		return fmt.Errorf("invalid pipeline: %w", err)
 	}
+	if err := kf.Upstream.validate(); err != nil {
+		return err
+	}
 	// TODO: validate other fields
 	return nil
 }
 
+// validate validates the update strategies of the non-KRM files.
+func (u *Upstream) validate() error {
+	if u == nil {
+		return nil
+	}
+	for i, f := range u.NonKRMFiles {
+		if _, err := path.Match(f.Path, ""); err != nil || f.Path == "" {
+			return &ValidateError{
+				Field:  fmt.Sprintf("upstream.nonKRMFiles[%d].path", i),
+				Value:  f.Path,
+				Reason: "path must be a valid glob",
+			}
+		}
+		valid := false
+		for _, s := range NonKRMFileStrategies {
+			valid = valid || f.Strategy == s
+		}
+		if !valid {
+			return &ValidateError{
+				Field:  fmt.Sprintf("upstream.nonKRMFiles[%d].strategy", i),
+				Value:  string(f.Strategy),
+				Reason: fmt.Sprintf("strategy must be one of %v", NonKRMFileStrategies),
+			}
+		}
+	}
+	return nil
+}
+
 // validate will validate all fields in the Pipeline
 // 'mutators' and 'validators' share same schema and
 // they are valid if all functions in them are ALL valid.
@@ -98,6 +132,67 @@ func (f *Function) validate(fsys filesys.FileSystem, fnType string, idx int, pkg
 		}
 	}
 
+	if f.Timeout != "" {
+		if d, err := time.ParseDuration(f.Timeout); err != nil || d <= 0 {
+			return &ValidateError{
+				Field:  fmt.Sprintf("pipeline.%s[%d].timeout", fnType, idx),
+				Value:  f.Timeout,
+				Reason: "timeout must be a positive duration, e.g. `30s`",
+			}
+		}
+	}
+
+	if f.Memory != "" {
+		if q, err := resource.ParseQuantity(f.Memory); err != nil || q.Sign() <= 0 {
+			return &ValidateError{
+				Field:  fmt.Sprintf("pipeline.%s[%d].memory", fnType, idx),
+				Value:  f.Memory,
+				Reason: "memory must be a positive quantity, e.g. `512Mi`",
+			}
+		}
+	}
+
+	if f.CPU != "" {
+		if q, err := resource.ParseQuantity(f.CPU); err != nil || q.Sign() <= 0 {
+			return &ValidateError{
+				Field:  fmt.Sprintf("pipeline.%s[%d].cpu", fnType, idx),
+				Value:  f.CPU,
+				Reason: "cpu must be a positive quantity, e.g. `500m`",
+			}
+		}
+	}
+
+	for i, m := range f.Mounts {
+		field := fmt.Sprintf("pipeline.%s[%d].mounts[%d]", fnType, idx, i)
+		if f.Exec != "" {
+			return &ValidateError{
+				Field:  field,
+				Reason: "mounts are only supported for container functions (`image`)",
+			}
+		}
+		if err := validateMountSrcSyntax(m.Src); err != nil {
+			return &ValidateError{
+				Field:  field + ".src",
+				Value:  m.Src,
+				Reason: err.Error(),
+			}
+		}
+		if !path.IsAbs(m.Dst) {
+			return &ValidateError{
+				Field:  field + ".dst",
+				Value:  m.Dst,
+				Reason: "path must be an absolute path in the container",
+			}
+		}
+		if !fsys.Exists(filepath.Join(string(pkgPath), filepath.FromSlash(m.Src))) {
+			return &ValidateError{
+				Field:  field + ".src",
+				Value:  m.Src,
+				Reason: "path does not exist in the package",
+			}
+		}
+	}
+
 	if f.ConfigPath != "" {
 		if err := validateFnConfigPathSyntax(f.ConfigPath); err != nil {
 			return &ValidateError{
@@ -150,6 +245,23 @@ func ValidateFunctionImageURL(name string) error {
 	return nil
 }
 
+// validateMountSrcSyntax validates syntactic correctness of the source path
+// of a mount and returns an error if it's invalid.
+func validateMountSrcSyntax(p string) error {
+	if strings.TrimSpace(p) == "" {
+		return fmt.Errorf("path must not be empty")
+	}
+	if path.IsAbs(p) || filepath.IsAbs(p) {
+		return fmt.Errorf("path must be relative")
+	}
+	if p = path.Clean(p); p == ".." || strings.HasPrefix(p, "../") {
+		// mounting files outside the package would give functions
+		// access to any file on the machine of the package consumer.
+		return fmt.Errorf("path must not be outside the package")
+	}
+	return nil
+}
+
 // validateFnConfigPathSyntax validates syntactic correctness of given functionConfig path
 // and return an error if it's invalid.
 func validateFnConfigPathSyntax(p string) error {
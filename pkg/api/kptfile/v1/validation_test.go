@@ -149,6 +149,178 @@ func TestKptfileValidate(t *testing.T) {
 			},
 			valid: false,
 		},
+		{
+			name: "pipeline: valid resource limits",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image:   "image",
+							Timeout: "30s",
+							Memory:  "512Mi",
+							CPU:     "500m",
+						},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "pipeline: invalid timeout",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image:   "image",
+							Timeout: "30",
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: negative timeout",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Validators: []Function{
+						{
+							Image:   "image",
+							Timeout: "-1m",
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: invalid memory",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image:  "image",
+							Memory: "lots",
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: zero cpu",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image: "image",
+							CPU:   "0",
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: mount",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image:  "image",
+							Mounts: []Mount{{Src: ".", Dst: "/data"}},
+						},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "pipeline: mount outside the package",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image:  "image",
+							Mounts: []Mount{{Src: "../data", Dst: "/data"}},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: mount with relative destination",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image:  "image",
+							Mounts: []Mount{{Src: ".", Dst: "data"}},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: mount of missing file",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image:  "image",
+							Mounts: []Mount{{Src: "missing", Dst: "/data"}},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: mount for exec function",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Exec:   "fn",
+							Mounts: []Mount{{Src: ".", Dst: "/data"}},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "upstream: non-KRM file strategies",
+			kptfile: KptFile{
+				Upstream: &Upstream{
+					NonKRMFiles: []NonKRMFile{
+						{Path: "docs/*.md", Strategy: NonKRMFileMerge},
+						{Path: "*.sh", Strategy: NonKRMFileTheirs},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "upstream: invalid non-KRM file glob",
+			kptfile: KptFile{
+				Upstream: &Upstream{
+					NonKRMFiles: []NonKRMFile{{Path: "[docs", Strategy: NonKRMFileOurs}},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "upstream: unknown non-KRM file strategy",
+			kptfile: KptFile{
+				Upstream: &Upstream{
+					NonKRMFiles: []NonKRMFile{{Path: "*.md", Strategy: "replace"}},
+				},
+			},
+			valid: false,
+		},
 	}
 
 	for _, c := range cases {
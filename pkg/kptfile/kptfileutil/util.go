@@ -224,6 +224,28 @@ func UpdateUpstreamLockFromGit(path string, spec *git.RepoSpec) error {
 	return nil
 }
 
+// UpdateUpstreamLockFromOci updates the upstreamLock of the package specified
+// by path with the given OCI lock, which records the digest of the image
+// that was pulled.
+func UpdateUpstreamLockFromOci(path string, lock *kptfilev1.OciLock) error {
+	const op errors.Op = "kptfileutil.UpdateUpstreamLockFromOci"
+	// read KptFile pulled with the package if it exists
+	kpgfile, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, path)
+	if err != nil {
+		return errors.E(op, types.UniquePath(path), err)
+	}
+
+	kpgfile.UpstreamLock = &kptfilev1.UpstreamLock{
+		Type: kptfilev1.OciOrigin,
+		Oci:  lock,
+	}
+	err = WriteFile(path, kpgfile)
+	if err != nil {
+		return errors.E(op, types.UniquePath(path), err)
+	}
+	return nil
+}
+
 // merge merges the Kptfiles from various sources and updates localKf with output
 // please refer to https://github.com/GoogleContainerTools/kpt/blob/main/docs/design-docs/03-pipeline-merge.md
 // for related design
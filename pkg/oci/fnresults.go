@@ -0,0 +1,139 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// fnResultKeyMatcher matches valid keys of cached function invocations.
+var fnResultKeyMatcher = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+// FnResultEntry describes a function invocation stored in the cache.
+type FnResultEntry struct {
+	// Key is the content address of the function invocation.
+	Key string
+	// Size is the size of the cached output in bytes.
+	Size int64
+	// LastUsed is the last time the cached output was stored or replayed.
+	LastUsed time.Time
+}
+
+func (r *Storage) fnResultsDir() string {
+	return filepath.Join(r.cacheDir, "fn-results")
+}
+
+func (r *Storage) fnResultPath(key string) (string, error) {
+	if !fnResultKeyMatcher.MatchString(key) {
+		return "", fmt.Errorf("invalid function result cache key %q", key)
+	}
+	return filepath.Join(r.fnResultsDir(), key), nil
+}
+
+// LoadFnResult returns the cached output of the function invocation
+// identified by key. The returned error wraps fs.ErrNotExist if the
+// invocation is not cached.
+func (r *Storage) LoadFnResult(key string) ([]byte, error) {
+	p, err := r.fnResultPath(key)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	// record the usage so that prune keeps recently used entries.
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+	return b, nil
+}
+
+// StoreFnResult stores the output of the function invocation identified by key.
+func (r *Storage) StoreFnResult(key string, data []byte) error {
+	p, err := r.fnResultPath(key)
+	if err != nil {
+		return err
+	}
+	f, err := WithCacheFile(p, func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// ListFnResults returns the cached function invocations, most recently used first.
+func (r *Storage) ListFnResults() ([]FnResultEntry, error) {
+	dirEntries, err := os.ReadDir(r.fnResultsDir())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading function result cache %q: %w", r.fnResultsDir(), err)
+	}
+	var entries []FnResultEntry
+	for _, de := range dirEntries {
+		if de.IsDir() || !fnResultKeyMatcher.MatchString(de.Name()) {
+			// skip temp files of in-flight writes
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		entries = append(entries, FnResultEntry{
+			Key:      de.Name(),
+			Size:     info.Size(),
+			LastUsed: info.ModTime(),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.After(entries[j].LastUsed)
+	})
+	return entries, nil
+}
+
+// PruneFnResults removes the cached function invocations that have not been
+// used since the given time and returns the removed entries.
+func (r *Storage) PruneFnResults(before time.Time) ([]FnResultEntry, error) {
+	entries, err := r.ListFnResults()
+	if err != nil {
+		return nil, err
+	}
+	var pruned []FnResultEntry
+	for _, e := range entries {
+		if !e.LastUsed.Before(before) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(r.fnResultsDir(), e.Key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return pruned, fmt.Errorf("failed to remove cached function result %q: %w", e.Key, err)
+		}
+		pruned = append(pruned, e)
+	}
+	return pruned, nil
+}
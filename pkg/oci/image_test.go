@@ -0,0 +1,52 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageDigest(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	image := u.Host + "/kpt-fn/set-labels:v0.1"
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644))
+	ctx := context.Background()
+	pushed, err := PushPackage(ctx, dir, image)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	digest, err := ImageDigest(ctx, image)
+	assert.NoError(t, err)
+	assert.Equal(t, pushed, digest)
+
+	_, err = ImageDigest(ctx, u.Host+"/kpt-fn/set-labels:missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,223 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/gcrane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// PullPackage pulls the package image and extracts its content into dir.
+// It returns the digest of the image manifest.
+func PullPackage(ctx context.Context, image string, dir string) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse image name %q: %w", image, err)
+	}
+	img, err := remote.Image(ref, remoteOptions(ctx)...)
+	if err != nil {
+		return "", fmt.Errorf("failed to pull image %q: %w", image, err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return "", fmt.Errorf("failed to get digest of image %q: %w", image, err)
+	}
+
+	rc := mutate.Extract(img)
+	defer rc.Close()
+	if err := untar(rc, dir); err != nil {
+		return "", fmt.Errorf("failed to extract image %q: %w", image, err)
+	}
+	return digest.String(), nil
+}
+
+// PushPackage publishes the content of the package directory dir as the
+// image and returns the digest of the image manifest. The image has a single
+// layer holding the files of the package, which is the format consumed by
+// Config Sync and the rollouts controller. The .git directory is not
+// published and symlinks are ignored.
+func PushPackage(ctx context.Context, dir string, image string) (string, error) {
+	tag, err := name.NewTag(image)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse %q as tag: %w", image, err)
+	}
+	b, err := tarDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to archive package %q: %w", dir, err)
+	}
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create image layer: %w", err)
+	}
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		return "", fmt.Errorf("failed to append image layers: %w", err)
+	}
+	if err := remote.Write(tag, img, remoteOptions(ctx)...); err != nil {
+		return "", fmt.Errorf("failed to push image %q: %w", image, err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return "", fmt.Errorf("failed to get digest of image %q: %w", image, err)
+	}
+	return digest.String(), nil
+}
+
+// ReplaceReference returns the image with its tag or digest replaced by
+// ref. A ref starting with `sha256:` is treated as a digest.
+func ReplaceReference(image, ref string) (string, error) {
+	r, err := name.ParseReference(image)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse image name %q: %w", image, err)
+	}
+	if strings.HasPrefix(ref, "sha256:") {
+		return r.Context().Digest(ref).String(), nil
+	}
+	return r.Context().Tag(ref).String(), nil
+}
+
+// ImageReference returns the tag or digest of the image.
+func ImageReference(image string) (string, error) {
+	r, err := name.ParseReference(image)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse image name %q: %w", image, err)
+	}
+	return r.Identifier(), nil
+}
+
+func remoteOptions(ctx context.Context) []remote.Option {
+	return []remote.Option{
+		remote.WithAuthFromKeychain(gcrane.Keychain),
+		remote.WithContext(ctx),
+	}
+}
+
+// tarDir returns a tar archive of the files of dir. Headers carry no
+// timestamps or ownership so pushing the same content twice results in
+// the same digest.
+func tarDir(dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{
+			Name: filepath.ToSlash(rel),
+			Mode: int64(info.Mode().Perm()),
+		}
+		switch {
+		case d.IsDir():
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+			return tw.WriteHeader(hdr)
+		case info.Mode().IsRegular():
+			b, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = int64(len(b))
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			_, err = tw.Write(b)
+			return err
+		default:
+			// symlinks and other special files are not part of a package
+			return nil
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// untar extracts the regular files and directories of the tar stream into
+// dir. Entries escaping dir are rejected.
+func untar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		p := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if p != filepath.Clean(dir) && !strings.HasPrefix(p, filepath.Clean(dir)+string(filepath.Separator)) {
+			return fmt.Errorf("entry %q is outside of the package", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(p, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(p, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fs.FileMode(hdr.Mode).Perm()|0600)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		default:
+			// symlinks and other special entries are ignored, like for git
+			// upstreams
+		}
+	}
+}
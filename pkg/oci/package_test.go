@@ -0,0 +1,123 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushPullPackage(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	image := u.Host + "/blueprints/pkg:v1"
+
+	src := t.TempDir()
+	files := map[string]string{
+		"Kptfile":              "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: pkg\n",
+		"cm.yaml":              "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+		"sub/deployment.yaml":  "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\n",
+		".git/HEAD":            "ref: refs/heads/main\n",
+		"sub/nested/README.md": "# nested\n",
+	}
+	for p, content := range files {
+		assert.NoError(t, os.MkdirAll(filepath.Join(src, filepath.Dir(p)), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(src, p), []byte(content), 0644))
+	}
+
+	ctx := context.Background()
+	digest, err := PushPackage(ctx, src, image)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// the digest only depends on the content of the package
+	again, err := PushPackage(ctx, src, image)
+	assert.NoError(t, err)
+	assert.Equal(t, digest, again)
+
+	dest := t.TempDir()
+	pulled, err := PullPackage(ctx, image, dest)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, digest, pulled)
+	for p, content := range files {
+		b, err := os.ReadFile(filepath.Join(dest, p))
+		if filepath.Dir(p) == ".git" {
+			assert.True(t, os.IsNotExist(err), "%s should not be published", p)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(b))
+	}
+
+	byDigest, err := ReplaceReference(image, digest)
+	assert.NoError(t, err)
+	pulled, err = PullPackage(ctx, byDigest, t.TempDir())
+	assert.NoError(t, err)
+	assert.Equal(t, digest, pulled)
+}
+
+func TestUntarOutsideOfPackage(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	assert.NoError(t, tw.WriteHeader(&tar.Header{Name: "../escape.yaml", Typeflag: tar.TypeReg, Size: 1, Mode: 0644}))
+	_, err := tw.Write([]byte("a"))
+	assert.NoError(t, err)
+	assert.NoError(t, tw.Close())
+
+	err = untar(&buf, t.TempDir())
+	assert.EqualError(t, err, `entry "../escape.yaml" is outside of the package`)
+}
+
+func TestReplaceReference(t *testing.T) {
+	testCases := map[string]struct {
+		image    string
+		ref      string
+		expected string
+	}{
+		"tag": {
+			image:    "us-docker.pkg.dev/my-project/blueprints/pkg:v1",
+			ref:      "v2",
+			expected: "us-docker.pkg.dev/my-project/blueprints/pkg:v2",
+		},
+		"digest": {
+			image:    "us-docker.pkg.dev/my-project/blueprints/pkg:v1",
+			ref:      "sha256:8ae0ee1a1b2bb2b88f0a1e4bbf6bb0b1f0bc9e1a4b0e5e2df1a6d0f5c1d3e2f4",
+			expected: "us-docker.pkg.dev/my-project/blueprints/pkg@sha256:8ae0ee1a1b2bb2b88f0a1e4bbf6bb0b1f0bc9e1a4b0e5e2df1a6d0f5c1d3e2f4",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			actual, err := ReplaceReference(tc.image, tc.ref)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signature
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+var (
+	// oidIssuer and oidIssuerV2 are the certificate extensions holding the
+	// OIDC issuer of the identity of the signer, see
+	// https://github.com/sigstore/fulcio/blob/main/docs/oid-info.md
+	oidIssuer   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// TrustedRoot is a sigstore trusted root bundle, i.e. the certificate
+// authorities issuing the certificates of keyless signatures and the keys
+// of the transparency logs recording them.
+type TrustedRoot struct {
+	Tlogs                  []TransparencyLog      `json:"tlogs"`
+	CertificateAuthorities []CertificateAuthority `json:"certificateAuthorities"`
+}
+
+type TransparencyLog struct {
+	BaseURL   string    `json:"baseUrl"`
+	PublicKey PublicKey `json:"publicKey"`
+}
+
+type PublicKey struct {
+	RawBytes []byte   `json:"rawBytes"`
+	ValidFor ValidFor `json:"validFor"`
+}
+
+type CertificateAuthority struct {
+	URI       string `json:"uri"`
+	CertChain struct {
+		Certificates []struct {
+			RawBytes []byte `json:"rawBytes"`
+		} `json:"certificates"`
+	} `json:"certChain"`
+	ValidFor ValidFor `json:"validFor"`
+}
+
+type ValidFor struct {
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+}
+
+func (v ValidFor) contains(t time.Time) bool {
+	return (v.Start == nil || !t.Before(*v.Start)) && (v.End == nil || !t.After(*v.End))
+}
+
+// rekorBundle is the transparency log entry of a keyless signature.
+type rekorBundle struct {
+	SignedEntryTimestamp []byte       `json:"SignedEntryTimestamp"`
+	Payload              rekorPayload `json:"Payload"`
+}
+
+// rekorPayload is signed by the transparency log. The fields are sorted so
+// that its JSON encoding is canonical.
+type rekorPayload struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+// hashedRekord is the transparency log entry of a signature.
+type hashedRekord struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content []byte `json:"content"`
+		} `json:"signature"`
+	} `json:"spec"`
+}
+
+// LoadTrustedRoot reads the sigstore trusted root bundle at path, e.g. the
+// output of `cosign trusted-root create` or the `trusted_root.json` target
+// of the sigstore TUF repository.
+func LoadTrustedRoot(path string) (*TrustedRoot, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trusted root: %w", err)
+	}
+	var root TrustedRoot
+	if err := json.Unmarshal(b, &root); err != nil {
+		return nil, fmt.Errorf("invalid trusted root %q: %w", path, err)
+	}
+	if len(root.Tlogs) == 0 || len(root.CertificateAuthorities) == 0 {
+		return nil, fmt.Errorf("invalid trusted root %q: it must have transparency logs and certificate authorities", path)
+	}
+	return &root, nil
+}
+
+// KeylessVerifier verifies signatures made with short-lived certificates
+// issued to an identity, e.g. by Fulcio, and recorded in a transparency log,
+// e.g. Rekor. The certificate must be valid when the signature was recorded
+// in the transparency log.
+type KeylessVerifier struct {
+	root     *TrustedRoot
+	identity string
+	issuer   string
+}
+
+// NewKeylessVerifier returns a verifier of the signatures made by identity,
+// an email address or a URI, authenticated by the OIDC issuer. The issuer
+// isn't checked if empty.
+func NewKeylessVerifier(root *TrustedRoot, identity, issuer string) *KeylessVerifier {
+	return &KeylessVerifier{root: root, identity: identity, issuer: issuer}
+}
+
+// Verify implements Verifier.
+func (v *KeylessVerifier) Verify(payload []byte, sig []byte, annotations map[string]string) (*Verification, error) {
+	cert, err := parseCertificate(annotations[CertificateAnnotation])
+	if err != nil {
+		return nil, err
+	}
+	var bundle rekorBundle
+	if err := json.Unmarshal([]byte(annotations[BundleAnnotation]), &bundle); err != nil {
+		return nil, fmt.Errorf("the signature has no valid transparency log bundle: %w", err)
+	}
+	if err := v.verifyBundle(bundle, cert, payload, sig); err != nil {
+		return nil, err
+	}
+	signedAt := time.Unix(bundle.Payload.IntegratedTime, 0)
+	if err := v.verifyCertificate(cert, annotations[ChainAnnotation], signedAt); err != nil {
+		return nil, err
+	}
+	if err := verifyPayload(cert.PublicKey, payload, sig); err != nil {
+		return nil, err
+	}
+	return &Verification{Signer: v.identity, Issuer: v.issuer}, nil
+}
+
+// verifyBundle verifies that the transparency log entry is signed by a
+// trusted log and records the signature.
+func (v *KeylessVerifier) verifyBundle(bundle rekorBundle, cert *x509.Certificate, payload, sig []byte) error {
+	canonical, err := json.Marshal(bundle.Payload)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(canonical)
+	signedAt := time.Unix(bundle.Payload.IntegratedTime, 0)
+	verified := false
+	for _, tlog := range v.root.Tlogs {
+		logID := sha256.Sum256(tlog.PublicKey.RawBytes)
+		if hex.EncodeToString(logID[:]) != bundle.Payload.LogID || !tlog.PublicKey.ValidFor.contains(signedAt) {
+			continue
+		}
+		key, err := x509.ParsePKIXPublicKey(tlog.PublicKey.RawBytes)
+		if err != nil {
+			return fmt.Errorf("invalid transparency log key: %w", err)
+		}
+		if k, ok := key.(*ecdsa.PublicKey); ok && ecdsa.VerifyASN1(k, digest[:], bundle.SignedEntryTimestamp) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return errors.New("the transparency log bundle is not signed by a trusted transparency log")
+	}
+
+	body, err := base64.StdEncoding.DecodeString(bundle.Payload.Body)
+	if err != nil {
+		return fmt.Errorf("invalid transparency log entry: %w", err)
+	}
+	var entry hashedRekord
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return fmt.Errorf("invalid transparency log entry: %w", err)
+	}
+	if entry.Kind != "hashedrekord" {
+		return fmt.Errorf("unsupported transparency log entry kind %q", entry.Kind)
+	}
+	payloadDigest := sha256.Sum256(payload)
+	if entry.Spec.Data.Hash.Algorithm != "sha256" || entry.Spec.Data.Hash.Value != hex.EncodeToString(payloadDigest[:]) ||
+		!bytes.Equal(entry.Spec.Signature.Content, sig) {
+		return errors.New("the transparency log entry doesn't match the signature")
+	}
+	return nil
+}
+
+// verifyCertificate verifies that the certificate was issued by a trusted
+// certificate authority to the expected identity at the signing time.
+func (v *KeylessVerifier) verifyCertificate(cert *x509.Certificate, chain string, signedAt time.Time) error {
+	roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	for _, ca := range v.root.CertificateAuthorities {
+		if !ca.ValidFor.contains(signedAt) {
+			continue
+		}
+		for _, c := range ca.CertChain.Certificates {
+			caCert, err := x509.ParseCertificate(c.RawBytes)
+			if err != nil {
+				return fmt.Errorf("invalid certificate authority %q: %w", ca.URI, err)
+			}
+			if bytes.Equal(caCert.RawIssuer, caCert.RawSubject) {
+				roots.AddCert(caCert)
+			} else {
+				intermediates.AddCert(caCert)
+			}
+		}
+	}
+	for rest := []byte(chain); ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		// only intermediates are taken from the signature, roots must be
+		// trusted.
+		if c, err := x509.ParseCertificate(block.Bytes); err == nil && !bytes.Equal(c.RawIssuer, c.RawSubject) {
+			intermediates.AddCert(c)
+		}
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   signedAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return fmt.Errorf("the signing certificate is not trusted: %w", err)
+	}
+
+	identities := append([]string{}, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		identities = append(identities, u.String())
+	}
+	if !contains(identities, v.identity) {
+		return fmt.Errorf("the signing certificate identities %q don't match %q", identities, v.identity)
+	}
+	if v.issuer != "" {
+		if issuer := certificateIssuer(cert); issuer != v.issuer {
+			return fmt.Errorf("the signing certificate issuer %q doesn't match %q", issuer, v.issuer)
+		}
+	}
+	return nil
+}
+
+// certificateIssuer returns the OIDC issuer of the identity of the
+// certificate.
+func certificateIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidIssuerV2):
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				return issuer
+			}
+		case ext.Id.Equal(oidIssuer):
+			return string(ext.Value)
+		}
+	}
+	return ""
+}
+
+func parseCertificate(s string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("the signature has no certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing certificate: %w", err)
+	}
+	return cert, nil
+}
+
+func contains(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
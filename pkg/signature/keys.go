@@ -0,0 +1,172 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signature
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PasswordEnv is the name of the environment variable holding the password
+// of encrypted private keys, as for cosign.
+const PasswordEnv = "COSIGN_PASSWORD"
+
+const (
+	// encryptedKeyType and legacyEncryptedKeyType are the PEM types of the
+	// private keys encrypted by cosign.
+	encryptedKeyType       = "ENCRYPTED SIGSTORE PRIVATE KEY"
+	legacyEncryptedKeyType = "ENCRYPTED COSIGN PRIVATE KEY"
+)
+
+// encryptedKey is an encrypted private key generated by
+// `cosign generate-key-pair`.
+type encryptedKey struct {
+	KDF struct {
+		Name   string `json:"name"`
+		Params struct {
+			N int `json:"N"`
+			R int `json:"r"`
+			P int `json:"p"`
+		} `json:"params"`
+		Salt []byte `json:"salt"`
+	} `json:"kdf"`
+	Cipher struct {
+		Name  string `json:"name"`
+		Nonce []byte `json:"nonce"`
+	} `json:"cipher"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// LoadPrivateKey reads the PEM encoded private key at path. Keys encrypted
+// by cosign are decrypted with the password from the COSIGN_PASSWORD
+// environment variable.
+func LoadPrivateKey(path string) (crypto.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("invalid private key %q: no PEM block found", path)
+	}
+	var key interface{}
+	switch block.Type {
+	case encryptedKeyType, legacyEncryptedKeyType:
+		der, err := decryptKey(block.Bytes, []byte(os.Getenv(PasswordEnv)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key %q: %w", path, err)
+		}
+		key, err = x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key %q: %w", path, err)
+		}
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("invalid private key %q: unsupported PEM type %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key %q: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("invalid private key %q: unsupported key type %T", path, key)
+	}
+	return signer, nil
+}
+
+// decryptKey decrypts a private key encrypted by cosign.
+func decryptKey(b []byte, password []byte) ([]byte, error) {
+	var k encryptedKey
+	if err := json.Unmarshal(b, &k); err != nil {
+		return nil, err
+	}
+	if k.KDF.Name != "scrypt" || k.Cipher.Name != "nacl/secretbox" {
+		return nil, fmt.Errorf("unsupported encryption %s/%s", k.KDF.Name, k.Cipher.Name)
+	}
+	if len(k.Cipher.Nonce) != 24 {
+		return nil, errors.New("invalid nonce")
+	}
+	secret, err := scrypt.Key(password, k.KDF.Salt, k.KDF.Params.N, k.KDF.Params.R, k.KDF.Params.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [24]byte
+	var key [32]byte
+	copy(nonce[:], k.Cipher.Nonce)
+	copy(key[:], secret)
+	der, ok := secretbox.Open(nil, k.Ciphertext, &nonce, &key)
+	if !ok {
+		return nil, fmt.Errorf("decryption failed, check the password in $%s", PasswordEnv)
+	}
+	return der, nil
+}
+
+// LoadPublicKey reads the PEM encoded public key at path.
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("invalid public key %q: no PEM encoded public key found", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %q: %w", path, err)
+	}
+	return key, nil
+}
+
+// KeyVerifier verifies signatures made with a private key.
+type KeyVerifier struct {
+	key crypto.PublicKey
+	id  string
+}
+
+// NewKeyVerifier returns a verifier of the signatures made with the private
+// key of pub.
+func NewKeyVerifier(pub crypto.PublicKey) (*KeyVerifier, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+	return &KeyVerifier{key: pub, id: "sha256:" + hex.EncodeToString(sum[:])}, nil
+}
+
+// Verify implements Verifier. The signer of the verification is the sha256
+// digest of the DER encoded public key.
+func (v *KeyVerifier) Verify(payload []byte, sig []byte, _ map[string]string) (*Verification, error) {
+	if err := verifyPayload(v.key, payload, sig); err != nil {
+		return nil, err
+	}
+	return &Verification{Signer: v.id}, nil
+}
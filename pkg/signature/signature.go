@@ -0,0 +1,303 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signature signs and verifies OCI images with signatures compatible
+// with cosign. A signature of the image `REPO@sha256:HEX` is stored in the
+// image `REPO:sha256-HEX.sig`. Each layer of the signature image holds a
+// simple signing payload identifying the signed digest, and its signature is
+// stored in the annotations of the layer.
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/gcrane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const (
+	// PayloadMediaType is the media type of the layers holding the signed
+	// payloads.
+	PayloadMediaType types.MediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// SignatureAnnotation is the annotation holding the base64 encoded
+	// signature of the payload.
+	SignatureAnnotation = "dev.cosignproject.cosign/signature"
+	// CertificateAnnotation is the annotation holding the PEM encoded
+	// certificate of keyless signatures.
+	CertificateAnnotation = "dev.sigstore.cosign/certificate"
+	// ChainAnnotation is the annotation holding the PEM encoded chain of the
+	// certificate of keyless signatures.
+	ChainAnnotation = "dev.sigstore.cosign/chain"
+	// BundleAnnotation is the annotation holding the transparency log entry
+	// of keyless signatures.
+	BundleAnnotation = "dev.sigstore.cosign/bundle"
+
+	// payloadType is the type of the simple signing payloads.
+	payloadType = "cosign container image signature"
+)
+
+// Payload is the simple signing payload of a signature.
+type Payload struct {
+	Critical Critical               `json:"critical"`
+	Optional map[string]interface{} `json:"optional"`
+}
+
+type Critical struct {
+	Identity Identity `json:"identity"`
+	Image    Image    `json:"image"`
+	Type     string   `json:"type"`
+}
+
+type Identity struct {
+	DockerReference string `json:"docker-reference"`
+}
+
+type Image struct {
+	DockerManifestDigest string `json:"docker-manifest-digest"`
+}
+
+// Verification is the result of the verification of the signature of an
+// image.
+type Verification struct {
+	// Digest is the verified digest of the image.
+	Digest string
+	// Signer is the public key or the certificate identity of the signer.
+	Signer string
+	// Issuer is the OIDC issuer of the certificate of keyless signatures.
+	Issuer string
+}
+
+// Verifier verifies the signature of a payload.
+type Verifier interface {
+	// Verify verifies the signature sig of payload. annotations are the
+	// annotations of the signature layer.
+	Verify(payload []byte, sig []byte, annotations map[string]string) (*Verification, error)
+}
+
+// SignatureTag returns the tag of the signature image of the image digest.
+func SignatureTag(ref name.Digest) (name.Tag, error) {
+	h, err := v1.NewHash(ref.DigestStr())
+	if err != nil {
+		return name.Tag{}, err
+	}
+	return ref.Context().Tag(fmt.Sprintf("%s-%s.sig", h.Algorithm, h.Hex)), nil
+}
+
+// SignImage signs the image digest with the private key and pushes the
+// signature. Existing signatures of the image are kept.
+func SignImage(ctx context.Context, ref name.Digest, signer crypto.Signer, opts ...remote.Option) error {
+	opts = remoteOptions(ctx, opts)
+	payload, err := json.Marshal(Payload{
+		Critical: Critical{
+			Identity: Identity{DockerReference: ref.Context().Name()},
+			Image:    Image{DockerManifestDigest: ref.DigestStr()},
+			Type:     payloadType,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	sig, err := signPayload(signer, payload)
+	if err != nil {
+		return fmt.Errorf("failed to sign %q: %w", ref, err)
+	}
+
+	tag, err := SignatureTag(ref)
+	if err != nil {
+		return err
+	}
+	img, err := remote.Image(tag, opts...)
+	if err != nil {
+		if !isNotFound(err) {
+			return fmt.Errorf("failed to get the signatures of %q: %w", ref, err)
+		}
+		img = mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+		img = mutate.ConfigMediaType(img, types.OCIConfigJSON)
+	}
+	img, err = mutate.Append(img, mutate.Addendum{
+		Layer: static.NewLayer(payload, PayloadMediaType),
+		Annotations: map[string]string{
+			SignatureAnnotation: base64.StdEncoding.EncodeToString(sig),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := remote.Write(tag, img, opts...); err != nil {
+		return fmt.Errorf("failed to push the signature of %q: %w", ref, err)
+	}
+	return nil
+}
+
+// VerifyImage verifies that the image digest has a signature accepted by
+// the verifier. It returns the verification of the first valid signature.
+func VerifyImage(ctx context.Context, ref name.Digest, verifier Verifier, opts ...remote.Option) (*Verification, error) {
+	opts = remoteOptions(ctx, opts)
+	tag, err := SignatureTag(ref)
+	if err != nil {
+		return nil, err
+	}
+	img, err := remote.Image(tag, opts...)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fmt.Errorf("no signature found for %q", ref)
+		}
+		return nil, fmt.Errorf("failed to get the signatures of %q: %w", ref, err)
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []string
+	for _, desc := range manifest.Layers {
+		if desc.MediaType != PayloadMediaType {
+			continue
+		}
+		v, err := verifyLayer(img, desc, ref, verifier)
+		if err == nil {
+			return v, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no signature found for %q", ref)
+	}
+	return nil, fmt.Errorf("no valid signature found for %q: %s", ref, strings.Join(errs, "; "))
+}
+
+func verifyLayer(img v1.Image, desc v1.Descriptor, ref name.Digest, verifier Verifier) (*Verification, error) {
+	sig, err := base64.StdEncoding.DecodeString(desc.Annotations[SignatureAnnotation])
+	if err != nil || len(sig) == 0 {
+		return nil, fmt.Errorf("invalid signature annotation")
+	}
+	layer, err := img.LayerByDigest(desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	payload, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := verifier.Verify(payload, sig, desc.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	// the payload is only trusted once its signature is verified.
+	var p Payload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, fmt.Errorf("invalid signature payload: %w", err)
+	}
+	if p.Critical.Type != payloadType {
+		return nil, fmt.Errorf("unexpected signature payload type %q", p.Critical.Type)
+	}
+	if p.Critical.Image.DockerManifestDigest != ref.DigestStr() {
+		return nil, fmt.Errorf("the signature is for digest %q", p.Critical.Image.DockerManifestDigest)
+	}
+	v.Digest = ref.DigestStr()
+	return v, nil
+}
+
+// signPayload signs the payload like cosign does, i.e. the sha256 digest of
+// the payload for ECDSA and RSA keys, and the payload itself for ed25519.
+func signPayload(signer crypto.Signer, payload []byte) ([]byte, error) {
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		return signer.Sign(rand.Reader, payload, crypto.Hash(0))
+	}
+	digest := sha256.Sum256(payload)
+	return signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+// verifyPayload verifies the signature of the payload made by signPayload.
+func verifyPayload(pub crypto.PublicKey, payload, sig []byte) error {
+	digest := sha256.Sum256(payload)
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, digest[:], sig) {
+			return errors.New("invalid signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig); err != nil {
+			return errors.New("invalid signature")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, payload, sig) {
+			return errors.New("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+	return nil
+}
+
+// ResolveDigest returns the digest reference of the image.
+func ResolveDigest(ctx context.Context, image string, opts ...remote.Option) (name.Digest, error) {
+	if d, err := name.NewDigest(image); err == nil {
+		return d, nil
+	}
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("cannot parse image name %q: %w", image, err)
+	}
+	desc, err := remote.Head(ref, remoteOptions(ctx, opts)...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("failed to resolve the digest of %q: %w", image, err)
+	}
+	return ref.Context().Digest(desc.Digest.String()), nil
+}
+
+func remoteOptions(ctx context.Context, opts []remote.Option) []remote.Option {
+	return append([]remote.Option{
+		remote.WithAuthFromKeychain(gcrane.Keychain),
+		remote.WithContext(ctx),
+	}, opts...)
+}
+
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
+	}
+	for _, e := range terr.Errors {
+		if e.Code == transport.ManifestUnknownErrorCode || e.Code == transport.NameUnknownErrorCode {
+			return true
+		}
+	}
+	return terr.StatusCode == 404
+}
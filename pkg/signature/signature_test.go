@@ -0,0 +1,403 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// pushRandomImage pushes a random image to a local registry and returns
+// its digest.
+func pushRandomImage(t *testing.T, repo string) name.Digest {
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	tag, err := name.NewTag(repo + ":v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(tag, img))
+	d, err := img.Digest()
+	require.NoError(t, err)
+	return tag.Context().Digest(d.String())
+}
+
+func newRegistry(t *testing.T) string {
+	s := httptest.NewServer(registry.New())
+	t.Cleanup(s.Close)
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+func TestSignAndVerify(t *testing.T) {
+	ctx := context.Background()
+	host := newRegistry(t)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	for name, key := range map[string]crypto.Signer{"ecdsa": ecKey, "ed25519": edKey, "rsa": rsaKey} {
+		t.Run(name, func(t *testing.T) {
+			ref := pushRandomImage(t, host+"/fn/"+name)
+			require.NoError(t, SignImage(ctx, ref, key))
+			v, err := NewKeyVerifier(key.Public())
+			require.NoError(t, err)
+			verification, err := VerifyImage(ctx, ref, v)
+			require.NoError(t, err)
+			assert.Equal(t, ref.DigestStr(), verification.Digest)
+			assert.True(t, strings.HasPrefix(verification.Signer, "sha256:"))
+
+			// the resolved digest of the tag is the signed digest.
+			resolved, err := ResolveDigest(ctx, ref.Context().Tag("v1").String())
+			require.NoError(t, err)
+			assert.Equal(t, ref.String(), resolved.String())
+		})
+	}
+
+	t.Run("multiple signatures", func(t *testing.T) {
+		ref := pushRandomImage(t, host+"/fn/multiple")
+		require.NoError(t, SignImage(ctx, ref, otherKey))
+		require.NoError(t, SignImage(ctx, ref, ecKey))
+		for _, key := range []*ecdsa.PrivateKey{ecKey, otherKey} {
+			v, err := NewKeyVerifier(key.Public())
+			require.NoError(t, err)
+			_, err = VerifyImage(ctx, ref, v)
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		ref := pushRandomImage(t, host+"/fn/wrong-key")
+		require.NoError(t, SignImage(ctx, ref, ecKey))
+		v, err := NewKeyVerifier(otherKey.Public())
+		require.NoError(t, err)
+		_, err = VerifyImage(ctx, ref, v)
+		assert.ErrorContains(t, err, "no valid signature found")
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		ref := pushRandomImage(t, host+"/fn/unsigned")
+		v, err := NewKeyVerifier(ecKey.Public())
+		require.NoError(t, err)
+		_, err = VerifyImage(ctx, ref, v)
+		assert.ErrorContains(t, err, "no signature found")
+	})
+
+	t.Run("signature of another digest", func(t *testing.T) {
+		signed := pushRandomImage(t, host+"/fn/copied")
+		require.NoError(t, SignImage(ctx, signed, ecKey))
+		ref := pushRandomImage(t, host+"/fn/copied")
+		sigTag, err := SignatureTag(signed)
+		require.NoError(t, err)
+		sigImg, err := remote.Image(sigTag)
+		require.NoError(t, err)
+		copyTag, err := SignatureTag(ref)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(copyTag, sigImg))
+
+		v, err := NewKeyVerifier(ecKey.Public())
+		require.NoError(t, err)
+		_, err = VerifyImage(ctx, ref, v)
+		assert.ErrorContains(t, err, "the signature is for digest")
+	})
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+
+	// encrypt the key like `cosign generate-key-pair`.
+	var ek encryptedKey
+	ek.KDF.Name = "scrypt"
+	ek.KDF.Params.N, ek.KDF.Params.R, ek.KDF.Params.P = 1024, 8, 1
+	ek.KDF.Salt = []byte("0123456789abcdef0123456789abcdef")
+	ek.Cipher.Name = "nacl/secretbox"
+	ek.Cipher.Nonce = []byte("0123456789abcdef01234567")
+	secret, err := scrypt.Key([]byte("secret"), ek.KDF.Salt, 1024, 8, 1, 32)
+	require.NoError(t, err)
+	var nonce [24]byte
+	var box [32]byte
+	copy(nonce[:], ek.Cipher.Nonce)
+	copy(box[:], secret)
+	ek.Ciphertext = secretbox.Seal(nil, der, &nonce, &box)
+	encrypted, err := json.Marshal(ek)
+	require.NoError(t, err)
+
+	write := func(name, typ string, b []byte) string {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(p, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b}), 0600))
+		return p
+	}
+	encryptedPath := write("cosign.key", encryptedKeyType, encrypted)
+	plainPath := write("plain.key", "PRIVATE KEY", der)
+	pubPath := write("cosign.pub", "PUBLIC KEY", pubDER)
+
+	t.Setenv(PasswordEnv, "secret")
+	signer, err := LoadPrivateKey(encryptedPath)
+	require.NoError(t, err)
+	assert.True(t, key.Equal(signer))
+	signer, err = LoadPrivateKey(plainPath)
+	require.NoError(t, err)
+	assert.True(t, key.Equal(signer))
+	pub, err := LoadPublicKey(pubPath)
+	require.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(pub))
+
+	t.Setenv(PasswordEnv, "wrong")
+	_, err = LoadPrivateKey(encryptedPath)
+	assert.ErrorContains(t, err, "decryption failed")
+	_, err = LoadPublicKey(plainPath)
+	assert.ErrorContains(t, err, "no PEM encoded public key found")
+}
+
+// keylessFixture holds a certificate authority and a transparency log to
+// make keyless signatures offline.
+type keylessFixture struct {
+	root     *TrustedRoot
+	caKey    *ecdsa.PrivateKey
+	caCert   *x509.Certificate
+	tlogKey  *ecdsa.PrivateKey
+	signedAt time.Time
+	leafKey  *ecdsa.PrivateKey
+	leafCert []byte
+}
+
+func newKeylessFixture(t *testing.T) *keylessFixture {
+	f := &keylessFixture{signedAt: time.Now().Add(-24 * time.Hour).Truncate(time.Second)}
+	var err error
+	f.caKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             f.signedAt.Add(-time.Hour),
+		NotAfter:              f.signedAt.Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, f.caKey.Public(), f.caKey)
+	require.NoError(t, err)
+	f.caCert, err = x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+	f.tlogKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tlogDER, err := x509.MarshalPKIXPublicKey(f.tlogKey.Public())
+	require.NoError(t, err)
+
+	root := fmtJSON(t, map[string]interface{}{
+		"mediaType": "application/vnd.dev.sigstore.trustedroot+json;version=0.1",
+		"tlogs": []interface{}{map[string]interface{}{
+			"baseUrl":       "https://rekor.example.com",
+			"hashAlgorithm": "SHA2_256",
+			"publicKey":     map[string]interface{}{"rawBytes": tlogDER, "keyDetails": "PKIX_ECDSA_P256_SHA_256"},
+		}},
+		"certificateAuthorities": []interface{}{map[string]interface{}{
+			"uri":       "https://fulcio.example.com",
+			"certChain": map[string]interface{}{"certificates": []interface{}{map[string]interface{}{"rawBytes": caDER}}},
+		}},
+	})
+	p := filepath.Join(t.TempDir(), "trusted_root.json")
+	require.NoError(t, os.WriteFile(p, root, 0644))
+	f.root, err = LoadTrustedRoot(p)
+	require.NoError(t, err)
+	f.issueCertificate(t, "jane@example.com", "https://accounts.example.com", f.signedAt.Add(10*time.Minute))
+	return f
+}
+
+// issueCertificate issues the signing certificate of the identity.
+func (f *keylessFixture) issueCertificate(t *testing.T, identity, issuer string, notAfter time.Time) {
+	var err error
+	f.leafKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	issuerExt, err := asn1.Marshal(issuer)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       f.signedAt.Add(-time.Minute),
+		NotAfter:        notAfter,
+		EmailAddresses:  []string{identity},
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		ExtraExtensions: []pkix.Extension{{Id: oidIssuerV2, Value: issuerExt}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, f.caCert, f.leafKey.Public(), f.caKey)
+	require.NoError(t, err)
+	f.leafCert = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// sign pushes a keyless signature of the image digest. tamper modifies the
+// transparency log bundle after it is signed.
+func (f *keylessFixture) sign(t *testing.T, ref name.Digest, tamper func(*rekorBundle)) {
+	payload := fmtJSON(t, Payload{Critical: Critical{
+		Identity: Identity{DockerReference: ref.Context().Name()},
+		Image:    Image{DockerManifestDigest: ref.DigestStr()},
+		Type:     payloadType,
+	}})
+	sig, err := signPayload(f.leafKey, payload)
+	require.NoError(t, err)
+
+	var entry hashedRekord
+	entry.Kind = "hashedrekord"
+	entry.Spec.Data.Hash.Algorithm = "sha256"
+	digest := sha256.Sum256(payload)
+	entry.Spec.Data.Hash.Value = hex.EncodeToString(digest[:])
+	entry.Spec.Signature.Content = sig
+	tlogDER, err := x509.MarshalPKIXPublicKey(f.tlogKey.Public())
+	require.NoError(t, err)
+	logID := sha256.Sum256(tlogDER)
+	bundle := rekorBundle{Payload: rekorPayload{
+		Body:           base64.StdEncoding.EncodeToString(fmtJSON(t, entry)),
+		IntegratedTime: f.signedAt.Unix(),
+		LogID:          hex.EncodeToString(logID[:]),
+		LogIndex:       42,
+	}}
+	setDigest := sha256.Sum256(fmtJSON(t, bundle.Payload))
+	bundle.SignedEntryTimestamp, err = ecdsa.SignASN1(rand.Reader, f.tlogKey, setDigest[:])
+	require.NoError(t, err)
+	if tamper != nil {
+		tamper(&bundle)
+	}
+
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer: static.NewLayer(payload, PayloadMediaType),
+		Annotations: map[string]string{
+			SignatureAnnotation:   base64.StdEncoding.EncodeToString(sig),
+			CertificateAnnotation: string(f.leafCert),
+			BundleAnnotation:      string(fmtJSON(t, bundle)),
+		},
+	})
+	require.NoError(t, err)
+	tag, err := SignatureTag(ref)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(tag, img))
+}
+
+func fmtJSON(t *testing.T, v interface{}) []byte {
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return b
+}
+
+func TestKeylessVerify(t *testing.T) {
+	ctx := context.Background()
+	host := newRegistry(t)
+	const identity, issuer = "jane@example.com", "https://accounts.example.com"
+
+	testCases := map[string]struct {
+		identity string
+		issuer   string
+		setup    func(t *testing.T, f *keylessFixture)
+		tamper   func(*rekorBundle)
+		err      string
+	}{
+		"valid": {
+			identity: identity,
+			issuer:   issuer,
+		},
+		"any issuer": {
+			identity: identity,
+		},
+		"wrong identity": {
+			identity: "john@example.com",
+			issuer:   issuer,
+			err:      `don't match "john@example.com"`,
+		},
+		"wrong issuer": {
+			identity: identity,
+			issuer:   "https://other.example.com",
+			err:      `issuer "https://accounts.example.com" doesn't match`,
+		},
+		"tampered bundle": {
+			identity: identity,
+			issuer:   issuer,
+			tamper:   func(b *rekorBundle) { b.Payload.IntegratedTime++ },
+			err:      "not signed by a trusted transparency log",
+		},
+		"expired certificate": {
+			identity: identity,
+			issuer:   issuer,
+			setup: func(t *testing.T, f *keylessFixture) {
+				f.issueCertificate(t, identity, issuer, f.signedAt.Add(-time.Second))
+			},
+			err: "the signing certificate is not trusted",
+		},
+		"untrusted certificate authority": {
+			identity: identity,
+			issuer:   issuer,
+			setup: func(t *testing.T, f *keylessFixture) {
+				other := newKeylessFixture(t)
+				f.leafKey, f.leafCert = other.leafKey, other.leafCert
+			},
+			err: "the signing certificate is not trusted",
+		},
+	}
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			f := newKeylessFixture(t)
+			if tc.setup != nil {
+				tc.setup(t, f)
+			}
+			ref := pushRandomImage(t, host+"/fn/"+strings.ReplaceAll(tn, " ", "-"))
+			f.sign(t, ref, tc.tamper)
+			v, err := VerifyImage(ctx, ref, NewKeylessVerifier(f.root, tc.identity, tc.issuer))
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, &Verification{Digest: ref.DigestStr(), Signer: tc.identity, Issuer: tc.issuer}, v)
+		})
+	}
+}
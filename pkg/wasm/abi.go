@@ -0,0 +1,175 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ABI is the interface a wasm module uses to communicate with its host.
+type ABI string
+
+const (
+	// ABIJS is the ABI of the modules compiled by Go for `js/wasm`, which
+	// run with the `wasm_exec.js` glue.
+	ABIJS ABI = "js"
+
+	// ABIWasip1 is the ABI of the modules compiled for WASI preview1, e.g.
+	// by Go for `wasip1/wasm`, TinyGo or Rust. The ResourceList is read from
+	// stdin and written to stdout.
+	ABIWasip1 ABI = "wasip1"
+
+	// ABILabel is the label of the OCI image config recording the ABI of
+	// the wasm module of the image.
+	ABILabel = "dev.kpt.fn.wasm.abi"
+)
+
+// The import modules identifying the ABIs.
+const (
+	wasip1Module = "wasi_snapshot_preview1"
+	goModule     = "go"
+	gojsModule   = "gojs"
+)
+
+var wasmMagic = []byte{0x00, 'a', 's', 'm'}
+
+// DetectABI returns the ABI of the wasm module from the modules it imports
+// functions from.
+func DetectABI(module []byte) (ABI, error) {
+	imports, err := importModules(module)
+	if err != nil {
+		return "", err
+	}
+	for _, m := range imports {
+		switch m {
+		case wasip1Module:
+			return ABIWasip1, nil
+		case goModule, gojsModule:
+			return ABIJS, nil
+		}
+	}
+	return "", fmt.Errorf("unable to detect the ABI of the wasm module: it doesn't import %q or %q", wasip1Module, gojsModule)
+}
+
+// importModules returns the names of the modules imported by the wasm
+// module, as declared in its import section.
+func importModules(module []byte) ([]string, error) {
+	if len(module) < 8 || !bytes.Equal(module[:4], wasmMagic) {
+		return nil, errors.New("invalid wasm module: missing magic number")
+	}
+	r := &wasmReader{b: module[8:]}
+	for len(r.b) > 0 {
+		id := r.byte()
+		size := r.uint()
+		if r.err != nil || uint64(len(r.b)) < size {
+			return nil, errors.New("invalid wasm module: truncated section")
+		}
+		section := r.b[:size]
+		r.b = r.b[size:]
+		// the import section is the section 2.
+		if id == 2 {
+			return (&wasmReader{b: section}).imports()
+		}
+	}
+	return nil, nil
+}
+
+// wasmReader reads the values encoded in a wasm module. The first error
+// is recorded and the subsequent reads return zero values.
+type wasmReader struct {
+	b   []byte
+	err error
+}
+
+func (r *wasmReader) byte() byte {
+	if r.err != nil || len(r.b) == 0 {
+		r.fail()
+		return 0
+	}
+	v := r.b[0]
+	r.b = r.b[1:]
+	return v
+}
+
+func (r *wasmReader) uint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *wasmReader) name() string {
+	n := r.uint()
+	if r.err != nil || uint64(len(r.b)) < n {
+		r.fail()
+		return ""
+	}
+	v := string(r.b[:n])
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *wasmReader) limits() {
+	if r.byte()&1 != 0 {
+		r.uint()
+	}
+	r.uint()
+}
+
+func (r *wasmReader) fail() {
+	if r.err == nil {
+		r.err = errors.New("invalid wasm module: truncated import section")
+	}
+	r.b = nil
+}
+
+// imports reads the module names of the import section.
+func (r *wasmReader) imports() ([]string, error) {
+	count := r.uint()
+	var modules []string
+	for i := uint64(0); i < count && r.err == nil; i++ {
+		modules = append(modules, r.name())
+		r.name()
+		switch kind := r.byte(); kind {
+		case 0: // function: type index
+			r.uint()
+		case 1: // table: reference type and limits
+			r.byte()
+			r.limits()
+		case 2: // memory: limits
+			r.limits()
+		case 3: // global: value type and mutability
+			r.byte()
+			r.byte()
+		default:
+			if r.err == nil {
+				r.err = fmt.Errorf("invalid wasm module: unknown import kind %d", kind)
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return modules, nil
+}
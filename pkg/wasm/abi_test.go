@@ -0,0 +1,90 @@
+// Copyright 2023 The kpt Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// wasmModule returns a wasm module importing a function from each of the
+// given modules, after a memory import.
+func wasmModule(imports ...string) []byte {
+	var section []byte
+	section = append(section, byte(len(imports)+1))
+	section = append(section, 3, 'e', 'n', 'v', 3, 'm', 'e', 'm', 2, 1, 1, 2)
+	for _, m := range imports {
+		section = append(section, byte(len(m)))
+		section = append(section, m...)
+		section = append(section, 2, 'f', 'n', 0, 0)
+	}
+	module := []byte{0x00, 'a', 's', 'm', 1, 0, 0, 0}
+	// a custom section, which is skipped.
+	module = append(module, 0, 3, 1, 'x', 0)
+	module = append(module, 2, byte(len(section)))
+	return append(module, section...)
+}
+
+func TestDetectABI(t *testing.T) {
+	testCases := map[string]struct {
+		module   []byte
+		expected ABI
+		errMsg   string
+	}{
+		"wasip1": {
+			module:   wasmModule("env", wasip1Module),
+			expected: ABIWasip1,
+		},
+		"go js": {
+			module:   wasmModule(gojsModule),
+			expected: ABIJS,
+		},
+		"go js before 1.21": {
+			module:   wasmModule(goModule),
+			expected: ABIJS,
+		},
+		"unknown": {
+			module: wasmModule("env"),
+			errMsg: "unable to detect the ABI of the wasm module",
+		},
+		"no imports": {
+			module: []byte{0x00, 'a', 's', 'm', 1, 0, 0, 0},
+			errMsg: "unable to detect the ABI of the wasm module",
+		},
+		"not wasm": {
+			module: []byte("#!/bin/sh\necho"),
+			errMsg: "missing magic number",
+		},
+		"truncated": {
+			module: wasmModule(wasip1Module)[:20],
+			errMsg: "truncated section",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			abi, err := DetectABI(tc.module)
+			if tc.errMsg != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.errMsg)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, abi)
+		})
+	}
+}
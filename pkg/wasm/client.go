@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto"
 	"errors"
 	"fmt"
 	"io"
@@ -31,6 +32,8 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/match"
 
 	"github.com/GoogleContainerTools/kpt/pkg/oci"
+	"github.com/GoogleContainerTools/kpt/pkg/signature"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/gcrane"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -44,6 +47,10 @@ import (
 
 type Client struct {
 	*oci.Storage
+
+	// Keychain provides the registry credentials. Defaults to the
+	// credentials of the docker config and of the credential helpers.
+	Keychain authn.Keychain
 }
 
 func NewClient(cacheDir string) (*Client, error) {
@@ -54,14 +61,23 @@ func NewClient(cacheDir string) (*Client, error) {
 	return &Client{Storage: store}, nil
 }
 
-func (r *Client) PushWasm(ctx context.Context, wasmFile string, imageName string) error {
+func (r *Client) keychain() authn.Keychain {
+	if r.Keychain == nil {
+		return gcrane.Keychain
+	}
+	return r.Keychain
+}
+
+// PushWasm pushes the wasm module as an image to the image index of the tag.
+// If signer is not nil, the image and the image index are signed with it.
+func (r *Client) PushWasm(ctx context.Context, wasmFile string, imageName string, signer crypto.Signer) error {
 	tag, err := name.NewTag(imageName)
 	if err != nil {
 		return fmt.Errorf("unable to parse tag %q: %v", imageName, err)
 	}
 
 	options := []remote.Option{
-		remote.WithAuthFromKeychain(gcrane.Keychain),
+		remote.WithAuthFromKeychain(r.keychain()),
 		remote.WithContext(ctx),
 	}
 
@@ -79,6 +95,15 @@ func (r *Client) PushWasm(ctx context.Context, wasmFile string, imageName string
 		}
 	}
 
+	module, err := os.ReadFile(wasmFile)
+	if err != nil {
+		return fmt.Errorf("failed to read from file %v: %w", wasmFile, err)
+	}
+	abi, err := DetectABI(module)
+	if err != nil {
+		return err
+	}
+
 	// Compress the wasm file.
 	tarReader, err := wasmFileToTar(wasmFile)
 	if err != nil {
@@ -94,6 +119,11 @@ func (r *Client) PushWasm(ctx context.Context, wasmFile string, imageName string
 	if err != nil {
 		return fmt.Errorf("failed to append image layers: %w", err)
 	}
+	// Record the ABI of the module, so it is run with the right runtime.
+	img, err = mutate.Config(img, v1.Config{Labels: map[string]string{ABILabel: string(abi)}})
+	if err != nil {
+		return fmt.Errorf("failed to set the config of the image: %w", err)
+	}
 	img = mutate.MediaType(img, types.DockerManifestSchema2)
 	img, err = mutate.CreatedAt(img, v1.Time{Time: time.Now()})
 	if err != nil {
@@ -109,6 +139,7 @@ func (r *Client) PushWasm(ctx context.Context, wasmFile string, imageName string
 		return fmt.Errorf("failed to push image %s: %w", tag, err)
 	}
 	fmt.Printf("digest of the image: %v\n", hash.String())
+	fmt.Printf("ABI of the wasm module: %v\n", abi)
 
 	// Construct the image index.
 	var index v1.ImageIndex = empty.Index
@@ -119,9 +150,10 @@ func (r *Client) PushWasm(ctx context.Context, wasmFile string, imageName string
 		}
 	}
 
+	// The modules of each ABI have their own platform in the index.
 	wasmPlatform := v1.Platform{
 		Architecture: "wasm",
-		OS:           "js",
+		OS:           string(abi),
 	}
 	index = mutate.RemoveManifests(index, match.Platforms(wasmPlatform))
 	index = mutate.AppendManifests(index, mutate.IndexAddendum{
@@ -144,6 +176,15 @@ func (r *Client) PushWasm(ctx context.Context, wasmFile string, imageName string
 		return fmt.Errorf("unable to get digest for the image index: %w", err)
 	}
 	fmt.Printf("digest of the image index: %v\n", indexHash.String())
+
+	if signer != nil {
+		for _, h := range []v1.Hash{hash, indexHash} {
+			if err := signature.SignImage(ctx, tag.Repository.Digest(h.String()), signer, options...); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("the image and the image index have been signed\n")
+	}
 	return nil
 }
 
@@ -171,25 +212,40 @@ func wasmFileToTar(filename string) (io.Reader, error) {
 	return buf, nil
 }
 
-func (r *Client) LoadWasm(ctx context.Context, imageName string) (io.ReadCloser, error) {
+// LoadWasm returns a reader of the wasm module of the image and the ABI
+// of the module recorded in the image. The ABI is empty if the image
+// doesn't record it.
+func (r *Client) LoadWasm(ctx context.Context, imageName string) (io.ReadCloser, ABI, error) {
 	ref, err := name.ParseReference(imageName)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	cacheFile := filepath.Join(r.GetCacheDir(), "wasm", ref.String())
 
+	var abi ABI
 	fetcher := func() (io.ReadCloser, error) {
 		options := []remote.Option{
 			remote.WithContext(ctx),
-			remote.WithAuthFromKeychain(gcrane.Keychain),
-			remote.WithPlatform(v1.Platform{
-				Architecture: "wasm",
-				OS:           "js",
-			}),
+			remote.WithAuthFromKeychain(r.keychain()),
 		}
-		ociImage, err := remote.Image(ref, options...)
+		ociImage, platformABI, err := remoteWasmImage(ref, options...)
 		if err != nil {
 			return nil, fmt.Errorf("unable to get remote image: %w", err)
 		}
+		config, err := ociImage.ConfigFile()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get the config of the image: %w", err)
+		}
+		abi = ABI(config.Config.Labels[ABILabel])
+		if abi == "" {
+			abi = platformABI
+		}
+		if err := os.MkdirAll(filepath.Dir(cacheFile), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+		if err := os.WriteFile(cacheFile+".abi", []byte(abi), 0644); err != nil {
+			return nil, fmt.Errorf("failed to cache the ABI of the image: %w", err)
+		}
 
 		reader := mutate.Extract(ociImage)
 		return reader, nil
@@ -198,9 +254,15 @@ func (r *Client) LoadWasm(ctx context.Context, imageName string) (io.ReadCloser,
 	// We need the per-digest cache here because otherwise we have to make a network request to look up the manifest in remote.Image
 	// (this could be cached by the go-containerregistry library, for some reason it is not...)
 	// TODO: Is there then any real reason to _also_ have the image-layer cache?
-	f, err := oci.WithCacheFile(filepath.Join(r.GetCacheDir(), "wasm", ref.String()), fetcher)
+	f, err := oci.WithCacheFile(cacheFile, fetcher)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	if abi == "" {
+		// the image was cached, images cached by older versions don't record the ABI.
+		if b, err := os.ReadFile(cacheFile + ".abi"); err == nil {
+			abi = ABI(b)
+		}
 	}
 
 	wrapper := &tarReadCloser{
@@ -210,9 +272,45 @@ func (r *Client) LoadWasm(ctx context.Context, imageName string) (io.ReadCloser,
 
 	wasmBytesReader, err := loadWasmFromTar(wrapper)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	return wasmBytesReader, abi, nil
+}
+
+// remoteWasmImage returns the wasm image of ref and the ABI of its platform
+// in the image index. If ref is an image index, the image of the wasip1
+// platform is preferred over the image of the js platform.
+func remoteWasmImage(ref name.Reference, options ...remote.Option) (v1.Image, ABI, error) {
+	desc, err := remote.Get(ref, options...)
+	if err != nil {
+		return nil, "", err
+	}
+	if !desc.MediaType.IsIndex() {
+		img, err := desc.Image()
+		return img, "", err
+	}
+	index, err := desc.ImageIndex()
+	if err != nil {
+		return nil, "", err
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, "", err
+	}
+	var selected *v1.Descriptor
+	for i, m := range manifest.Manifests {
+		if m.Platform == nil || m.Platform.Architecture != "wasm" {
+			continue
+		}
+		if m.Platform.OS == string(ABIWasip1) || (selected == nil && m.Platform.OS == string(ABIJS)) {
+			selected = &manifest.Manifests[i]
+		}
+	}
+	if selected == nil {
+		return nil, "", fmt.Errorf("no wasm image found in image index %s", ref)
 	}
-	return wasmBytesReader, nil
+	img, err := index.Image(selected.Digest)
+	return img, ABI(selected.Platform.OS), err
 }
 
 type tarReadCloser struct {
@@ -266,7 +364,8 @@ func isManifestNotFoundErr(err error) bool {
 	}
 	foundManifestUnknown := false
 	for _, e := range terr.Errors {
-		if e.Code == transport.ManifestUnknownErrorCode {
+		// registries return NAME_UNKNOWN for the repositories which don't exist yet.
+		if e.Code == transport.ManifestUnknownErrorCode || e.Code == transport.NameUnknownErrorCode {
 			foundManifestUnknown = true
 		}
 	}
@@ -39,7 +39,7 @@ func GetEvalFnRunner(ctx context.Context, parent string) *EvalFnRunner {
 	r.InitDefaults()
 
 	c := &cobra.Command{
-		Use:     "eval [DIR | -] [flags] [--fn-args]",
+		Use:     "eval [DIR... | -] [flags] [--fn-args]",
 		Short:   docs.EvalShort,
 		Long:    docs.EvalShort + "\n" + docs.EvalLong,
 		Example: docs.EvalExamples,
@@ -69,12 +69,17 @@ func GetEvalFnRunner(ctx context.Context, parent string) *EvalFnRunner {
 		"save the function and its arguments to Kptfile")
 	r.Command.Flags().StringVar(
 		&r.Exec, "exec", "", "run an executable as a function")
+	r.Command.Flags().BoolVar(
+		&r.RunnerOptions.SandboxExec, "sandbox-exec", false, "run the executable in a sandbox without access to the host filesystem, the environment or, unless --network is set, the network. Linux only.")
 	r.Command.Flags().StringVar(
 		&r.FnConfigPath, "fn-config", "", "path to the function config file")
 	r.Command.Flags().BoolVarP(
 		&r.IncludeMetaResources, "include-meta-resources", "m", false, "include package meta resources in function input")
 	r.Command.Flags().StringVar(
 		&r.ResultsDir, "results-dir", "", "write function results to this dir")
+	r.Command.Flags().StringSliceVar(
+		&r.ResultsFormats, "results-format", nil,
+		fmt.Sprintf("formats to write function results in, can be repeated. Allowed values: %s. Defaults to %s.", strings.Join(fnruntime.ResultsFormats, "|"), fnruntime.ResultsFormatYAML))
 	r.Command.Flags().BoolVar(
 		&r.Network, "network", false, "enable network access for functions that declare it")
 	r.Command.Flags().StringArrayVar(
@@ -83,6 +88,8 @@ func GetEvalFnRunner(ctx context.Context, parent string) *EvalFnRunner {
 	r.Command.Flags().StringArrayVarP(
 		&r.Env, "env", "e", []string{},
 		"a list of environment variables to be used by functions")
+	r.Command.Flags().BoolVar(
+		&r.Batch, "batch", false, "run the function once on the resources of all the packages instead of once per package")
 	r.Command.Flags().BoolVar(
 		&r.AsCurrentUser, "as-current-user", false, "use the uid and gid that kpt is running with to run the function in the container")
 
@@ -91,9 +98,18 @@ func GetEvalFnRunner(ctx context.Context, parent string) *EvalFnRunner {
 	_ = r.Command.RegisterFlagCompletionFunc("image-pull-policy", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return r.RunnerOptions.ImagePullPolicy.AllStrings(), cobra.ShellCompDirectiveDefault
 	})
+	r.Command.Flags().Var(&r.RunnerOptions.BuiltinCatalog, "builtin-catalog",
+		"run the images of the catalog functions implemented by kpt with the builtin function instead, when auto "+r.RunnerOptions.BuiltinCatalog.HelpAllowedValues())
+	_ = r.Command.RegisterFlagCompletionFunc("builtin-catalog", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return r.RunnerOptions.BuiltinCatalog.AllStrings(), cobra.ShellCompDirectiveDefault
+	})
 
 	r.Command.Flags().BoolVar(
 		&r.RunnerOptions.AllowWasm, "allow-alpha-wasm", false, "allow alpha wasm functions to be run. If true, you can specify a wasm image with --image flag or a path to a wasm file (must have the .wasm file extension) with --exec flag.")
+	r.Command.Flags().StringVar(
+		&r.FnPolicy, "fn-policy", "", fmt.Sprintf("path to a function policy file restricting the functions that can be run, in addition to the %s/%s files of the package repository and of the user home directory.", ".kpt", fnruntime.FnPolicyFileName))
+	r.Command.Flags().StringVar(
+		&r.FnRuntimeEndpoint, "fn-runtime-endpoint", "", fmt.Sprintf("endpoint of a function evaluator service to run the function image with, of the form [grpc://|grpcs://]HOST:PORT. Defaults to $%s if it is a grpc:// or grpcs:// endpoint.", fnruntime.ContainerRuntimeEnv))
 
 	// selector flags
 	r.Command.Flags().StringVar(
@@ -147,10 +163,14 @@ type EvalFnRunner struct {
 	Exec                 string
 	FnConfigPath         string
 	ResultsDir           string
+	ResultsFormats       []string
 	Network              bool
 	Mounts               []string
 	Env                  []string
 	AsCurrentUser        bool
+	Batch                bool
+	FnRuntimeEndpoint    string
+	FnPolicy             string
 	IncludeMetaResources bool
 	Ctx                  context.Context
 	Selector             kptfile.Selector
@@ -173,6 +193,9 @@ func (r *EvalFnRunner) InitDefaults() {
 }
 
 func (r *EvalFnRunner) runE(c *cobra.Command, _ []string) error {
+	if runtime, ok := r.runFns.Runtime.(*fnruntime.RemoteRuntime); ok {
+		defer runtime.Close()
+	}
 	err := runner.HandleError(r.Ctx, r.runFns.Execute())
 	if err != nil {
 		return err
@@ -394,8 +417,9 @@ func (r *EvalFnRunner) getFunctionSpec() (*runtimeutil.FunctionSpec, []string, e
 	} else if r.Exec != "" {
 		// check the flags that doesn't make sense with exec function
 		// --mount, --as-current-user, --network and --env are
-		// only used with container functions
-		if r.AsCurrentUser || r.Network ||
+		// only used with container functions, --network is also used
+		// with sandboxed exec functions.
+		if r.AsCurrentUser || (r.Network && !r.RunnerOptions.SandboxExec) ||
 			len(r.Mounts) != 0 || len(r.Env) != 0 {
 			return nil, nil, fmt.Errorf("--mount, --as-current-user, --network and --env can only be used with container functions")
 		}
@@ -442,6 +466,9 @@ func (r *EvalFnRunner) validateOptionalFlags() error {
 			return fmt.Errorf("--type must be either `mutator` or `validator`")
 		}
 	}
+	if err := fnruntime.ValidateResultsFormats(r.ResultsFormats); err != nil {
+		return err
+	}
 	// ResultsDir stores the hydrated output in a structured format to result dir. If not specified, only make
 	// in-place changes.
 	if r.ResultsDir != "" {
@@ -476,8 +503,17 @@ func (r *EvalFnRunner) preRunE(c *cobra.Command, args []string) error {
 		// default to current working directory
 		args = append(args, ".")
 	}
-	if len(args) > 1 {
-		return errors.Errorf("0 or 1 arguments supported, function arguments go after '--'")
+	paths, err := expandPackagePaths(args)
+	if err != nil {
+		return err
+	}
+	if len(paths) > 1 {
+		if r.Dest != "" {
+			return errors.Errorf("--output can't be used with several packages")
+		}
+		if r.SaveFn {
+			return errors.Errorf("--save can't be used with several packages")
+		}
 	}
 	if len(dataItems) > 0 && r.FnConfigPath != "" {
 		return fmt.Errorf("function arguments can only be specified without function config file")
@@ -496,22 +532,28 @@ func (r *EvalFnRunner) preRunE(c *cobra.Command, args []string) error {
 	var output io.Writer
 	var input io.Reader
 	r.OutContent = bytes.Buffer{}
-	if args[0] == "-" {
+	if paths[0] == "-" {
 		output = &r.OutContent
 		input = c.InOrStdin()
 		r.FromStdin = true
 
-		// clear args as it indicates stdin and not path
-		args = []string{}
+		// clear paths as it indicates stdin and not path
+		paths = []string{}
 	} else if r.Dest != "" {
 		output = &r.OutContent
 	}
 
-	// set the path if specified as an argument
+	for i := range paths {
+		paths[i], err = argutil.ResolveSymlink(r.Ctx, paths[i])
+		if err != nil {
+			return err
+		}
+	}
+	// set the path if a single directory is specified as an argument
 	var path string
-	if len(args) == 1 {
-		// argument is the directory
-		path = args[0]
+	if len(paths) == 1 {
+		path = paths[0]
+		paths = nil
 	}
 
 	// parse mounts to set storageMounts
@@ -524,12 +566,6 @@ func (r *EvalFnRunner) preRunE(c *cobra.Command, args []string) error {
 		}
 	}
 
-	if path != "" {
-		path, err = argutil.ResolveSymlink(r.Ctx, path)
-		if err != nil {
-			return err
-		}
-	}
 	if r.SaveFn && r.FnConfigPath != "" {
 		fnConfigAbsPath, _, _ := pathutil.ResolveAbsAndRelPaths(r.FnConfigPath)
 		pkgAbsPath, _, _ := pathutil.ResolveAbsAndRelPaths(path)
@@ -538,22 +574,29 @@ func (r *EvalFnRunner) preRunE(c *cobra.Command, args []string) error {
 				pkgAbsPath)
 		}
 	}
+	r.RunnerOptions.Policies, err = findFunctionPolicies(r.FnPolicy, path, paths)
+	if err != nil {
+		return err
+	}
 	r.parseSelectors()
 	r.runFns = runfn.RunFns{
-		Ctx:           r.Ctx,
-		Function:      fnSpec,
-		ExecArgs:      execArgs,
-		OriginalExec:  r.Exec,
-		Output:        output,
-		Input:         input,
-		Path:          path,
-		Network:       r.Network,
-		StorageMounts: storageMounts,
-		ResultsDir:    r.ResultsDir,
-		Env:           r.Env,
-		AsCurrentUser: r.AsCurrentUser,
-		FnConfig:      fnConfig,
-		FnConfigPath:  r.FnConfigPath,
+		Ctx:            r.Ctx,
+		Function:       fnSpec,
+		ExecArgs:       execArgs,
+		OriginalExec:   r.Exec,
+		Output:         output,
+		Input:          input,
+		Path:           path,
+		Paths:          paths,
+		Batch:          r.Batch,
+		Network:        r.Network,
+		StorageMounts:  storageMounts,
+		ResultsDir:     r.ResultsDir,
+		ResultsFormats: r.ResultsFormats,
+		Env:            r.Env,
+		AsCurrentUser:  r.AsCurrentUser,
+		FnConfig:       fnConfig,
+		FnConfigPath:   r.FnConfigPath,
 		// fn eval should remove all files when all resources
 		// are deleted.
 		ContinueOnEmptyResult: true,
@@ -561,10 +604,79 @@ func (r *EvalFnRunner) preRunE(c *cobra.Command, args []string) error {
 		Exclusion:             r.Exclusion,
 		RunnerOptions:         r.RunnerOptions,
 	}
+	// the function evaluator service can't mount files, set environment
+	// variables, give the function network access or run it as the current
+	// user, so the function is run locally if any of them is requested.
+	if endpoint := fnruntime.RemoteRuntimeEndpoint(r.FnRuntimeEndpoint); endpoint != "" && r.Image != "" &&
+		len(storageMounts) == 0 && len(r.Env) == 0 && !r.Network && !r.AsCurrentUser {
+		r.runFns.Runtime, err = fnruntime.NewRemoteRuntime(endpoint, nil)
+		if err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
+// expandPackagePaths returns the package paths of the arguments, with the
+// glob patterns expanded to the directories they match.
+func expandPackagePaths(args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		if arg == "-" {
+			if len(args) > 1 {
+				return nil, errors.Errorf("'-' can't be used with other package paths, function arguments go after '--'")
+			}
+			return args, nil
+		}
+		if !strings.ContainsAny(arg, "*?[") {
+			paths = append(paths, arg)
+			continue
+		}
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, errors.Errorf("invalid package path pattern %q: %v", arg, err)
+		}
+		var found bool
+		for _, m := range matches {
+			if info, err := os.Stat(m); err == nil && info.IsDir() {
+				paths = append(paths, m)
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("no package directory matches %q", arg)
+		}
+	}
+	return paths, nil
+}
+
+// findFunctionPolicies returns the function policies of the package at path,
+// or of all the packages of paths when the function is run on several
+// packages.
+func findFunctionPolicies(fnPolicy string, path string, paths []string) ([]*fnruntime.FunctionPolicy, error) {
+	if len(paths) == 0 {
+		if path == "" {
+			path = "."
+		}
+		return fnruntime.FindFunctionPolicies(fnPolicy, path)
+	}
+	var policies []*fnruntime.FunctionPolicy
+	for i, p := range paths {
+		if i > 0 {
+			// the given policy file and the user policy only need to be
+			// read once.
+			fnPolicy = ""
+		}
+		pkgPolicies, err := fnruntime.FindFunctionPolicies(fnPolicy, p)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, pkgPolicies...)
+	}
+	return policies, nil
+}
+
 // parses annotation and label based selectors and exclusion from the command line input
 func (r *EvalFnRunner) parseSelectors() {
 	r.Selector.Annotations = parseSelectorMap(r.selectorAnnotations)
@@ -220,6 +220,7 @@ apiVersion: v1
 				ResultsDir: "foo/",
 				RunnerOptions: fnruntime.RunnerOptions{
 					ImagePullPolicy: fnruntime.IfNotPresentPull,
+					BuiltinCatalog:  fnruntime.BuiltinCatalogOff,
 				},
 				Env:                   []string{},
 				ContinueOnEmptyResult: true,
@@ -239,9 +240,9 @@ apiVersion: v1
 `,
 		},
 		{
-			name: "config map multi args",
-			args: []string{"eval", dir, "dir2", "--image", "foo:bar", "--", "a=b", "c=d", "e=f"},
-			err:  "0 or 1 arguments supported",
+			name: "config map multi args with stdin",
+			args: []string{"eval", dir, "-", "--image", "foo:bar", "--", "a=b", "c=d", "e=f"},
+			err:  "'-' can't be used with other package paths",
 		},
 		{
 			name: "config map not image",
@@ -261,6 +262,7 @@ apiVersion: v1
 				Path: dir,
 				RunnerOptions: fnruntime.RunnerOptions{
 					ImagePullPolicy: fnruntime.IfNotPresentPull,
+					BuiltinCatalog:  fnruntime.BuiltinCatalogOff,
 				},
 				Env:                   []string{"FOO=BAR", "BAR"},
 				ContinueOnEmptyResult: true,
@@ -288,6 +290,7 @@ apiVersion: v1
 				AsCurrentUser: true,
 				RunnerOptions: fnruntime.RunnerOptions{
 					ImagePullPolicy: fnruntime.IfNotPresentPull,
+					BuiltinCatalog:  fnruntime.BuiltinCatalogOff,
 				},
 				Env:                   []string{},
 				ContinueOnEmptyResult: true,
@@ -441,6 +444,91 @@ apiVersion: v1
 	}
 }
 
+// TestRunFnCommand_preRunE_packages verifies that preRunE expands the package
+// paths and patterns of the arguments when the function is run on several
+// packages.
+func TestRunFnCommand_preRunE_packages(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []string{"a", "b", "c"} {
+		if !assert.NoError(t, os.Mkdir(filepath.Join(dir, p), 0700)) {
+			t.FailNow()
+		}
+	}
+	if !assert.NoError(t, os.WriteFile(filepath.Join(dir, "file.yaml"), nil, 0600)) {
+		t.FailNow()
+	}
+	defer testutil.Chdir(t, dir)()
+
+	tests := []struct {
+		name  string
+		args  []string
+		paths []string
+		path  string
+		batch bool
+		err   string
+	}{
+		{
+			name:  "paths",
+			args:  []string{"eval", "a", "c", "--image", "foo:bar"},
+			paths: []string{"a", "c"},
+		},
+		{
+			name:  "pattern",
+			args:  []string{"eval", "*", "--batch", "--image", "foo:bar"},
+			paths: []string{"a", "b", "c"},
+			batch: true,
+		},
+		{
+			name: "pattern matching one package",
+			args: []string{"eval", "[b]", "--image", "foo:bar"},
+			path: "b",
+		},
+		{
+			name: "pattern without match",
+			args: []string{"eval", "d*", "--image", "foo:bar"},
+			err:  `no package directory matches "d*"`,
+		},
+		{
+			name: "output",
+			args: []string{"eval", "a", "b", "-o", "stdout", "--image", "foo:bar"},
+			err:  "--output can't be used with several packages",
+		},
+		{
+			name: "save",
+			args: []string{"eval", "a", "b", "-s", "-t", "mutator", "--image", "foo:bar"},
+			err:  "--save can't be used with several packages",
+		},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			r := GetEvalFnRunner(context.TODO(), "kpt")
+			r.Command.RunE = func(cmd *cobra.Command, args []string) error { return nil }
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			root := &cobra.Command{Use: "root"}
+			root.AddCommand(r.Command)
+			root.SetArgs(tt.args)
+
+			err := r.Command.Execute()
+			if tt.err != "" {
+				if !assert.Error(t, err) {
+					t.FailNow()
+				}
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tt.path, r.runFns.Path)
+			assert.Equal(t, tt.paths, r.runFns.Paths)
+			assert.Equal(t, tt.batch, r.runFns.Batch)
+		})
+	}
+}
+
 func TestCmd_flagAndArgParsing_Symlink(t *testing.T) {
 	dir, err := os.MkdirTemp("", "")
 	if !assert.NoError(t, err) {
@@ -18,13 +18,16 @@ import (
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
 	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 
+	"github.com/GoogleContainerTools/kpt/internal/builtins"
 	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
 	"github.com/GoogleContainerTools/kpt/internal/types"
 	"github.com/GoogleContainerTools/kpt/internal/util/printerutil"
 	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	kptfile "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
 )
 
 // RunFns runs the set of configuration functions in a local directory against
@@ -37,6 +40,15 @@ type RunFns struct {
 	// Path is the path to the directory containing functions
 	Path string
 
+	// Paths are the paths of the packages to run the function on, when it is
+	// run on several packages. Path must be empty in that case.
+	Paths []string
+
+	// Batch sends the resources of all the packages of Paths to the function
+	// in a single ResourceList, so that the function is run once. Otherwise
+	// the function is run once per package.
+	Batch bool
+
 	// uniquePath is the absolute version of Path
 	uniquePath types.UniquePath
 
@@ -63,6 +75,10 @@ type RunFns struct {
 	// ResultsDir is where to write each functions results
 	ResultsDir string
 
+	// ResultsFormats are the formats to write the results in. Defaults to
+	// `yaml`.
+	ResultsFormats []string
+
 	fnResults *fnresult.ResultList
 
 	// functionFilterProvider provides a filter to perform the function.
@@ -87,6 +103,10 @@ type RunFns struct {
 
 	RunnerOptions fnruntime.RunnerOptions
 
+	// Runtime, if set, is asked for a runner of function images before
+	// falling back to the built-in container and wasm runtimes.
+	Runtime fn.FunctionRuntime
+
 	// ExecArgs are the arguments for exec commands
 	ExecArgs []string
 
@@ -105,6 +125,9 @@ func (r RunFns) Execute() error {
 	if err != nil {
 		return err
 	}
+	if len(r.Paths) > 0 {
+		return r.executePackages()
+	}
 	nodes, fltrs, output, err := r.getNodesAndFilters()
 	if err != nil {
 		return err
@@ -122,14 +145,7 @@ func (r RunFns) getNodesAndFilters() (
 	var outputPkg *kio.LocalPackageReadWriter
 
 	if r.Path != "" {
-		outputPkg = &kio.LocalPackageReadWriter{
-			PackagePath:        string(r.uniquePath),
-			MatchFilesGlob:     pkg.MatchAllKRM,
-			PreserveSeqIndent:  true,
-			PackageFileName:    kptfile.KptFileName,
-			IncludeSubpackages: true,
-			WrapBareSeqNode:    true,
-		}
+		outputPkg = newPackageReadWriter(string(r.uniquePath))
 	}
 
 	if r.Input == nil {
@@ -167,8 +183,28 @@ func (r RunFns) getFilters() ([]kio.Filter, error) {
 	return []kio.Filter{c}, nil
 }
 
+// newPackageReadWriter returns the reader and writer of the resources of
+// the package at path.
+func newPackageReadWriter(path string) *kio.LocalPackageReadWriter {
+	return &kio.LocalPackageReadWriter{
+		PackagePath:        path,
+		MatchFilesGlob:     pkg.MatchAllKRM,
+		PreserveSeqIndent:  true,
+		PackageFileName:    kptfile.KptFileName,
+		IncludeSubpackages: true,
+		WrapBareSeqNode:    true,
+	}
+}
+
 // runFunctions runs the fltrs against the input and writes to either r.Output or output
 func (r RunFns) runFunctions(input kio.Reader, output kio.Writer, fltrs []kio.Filter) error {
+	err := r.runFilters(input, output, fltrs, r.uniquePath)
+	return r.saveResults(err)
+}
+
+// runFilters runs the fltrs against the input and writes to either r.Output
+// or output. root is the path of the package of the input resources.
+func (r RunFns) runFilters(input kio.Reader, output kio.Writer, fltrs []kio.Filter, root types.UniquePath) error {
 	// use the previously read Resources as input
 	var outputs []kio.Writer
 	if r.Output == nil {
@@ -203,7 +239,7 @@ func (r RunFns) runFunctions(input kio.Reader, output kio.Writer, fltrs []kio.Fi
 			inputResources,
 			[]kptfile.Selector{r.Selector},
 			[]kptfile.Selector{r.Exclusion},
-			&fnruntime.SelectionContext{RootPackagePath: r.uniquePath})
+			&fnruntime.SelectionContext{RootPackagePath: root})
 		if err != nil {
 			return err
 		}
@@ -233,22 +269,28 @@ func (r RunFns) runFunctions(input kio.Reader, output kio.Writer, fltrs []kio.Fi
 			return writeErr
 		}
 	}
-	resultsFile, resultErr := fnruntime.SaveResults(filesys.FileSystemOrOnDisk{}, r.ResultsDir, r.fnResults)
+	return err
+}
+
+// saveResults saves the function results and prints where they have been
+// saved. err is the error of the function run, which is returned.
+func (r RunFns) saveResults(err error) error {
+	resultsFiles, resultErr := fnruntime.SaveResults(filesys.FileSystemOrOnDisk{}, r.ResultsDir, r.fnResults, r.ResultsFormats, r.Path)
 	if err != nil {
 		// function fails
 		if resultErr == nil {
-			r.printFnResultsStatus(resultsFile)
+			r.printFnResultsStatus(resultsFiles)
 		}
 		return err
 	}
 	if resultErr == nil {
-		r.printFnResultsStatus(resultsFile)
+		r.printFnResultsStatus(resultsFiles)
 	}
 	return nil
 }
 
-func (r RunFns) printFnResultsStatus(resultsFile string) {
-	printerutil.PrintFnResultInfo(r.Ctx, resultsFile, true)
+func (r RunFns) printFnResultsStatus(resultsFiles []string) {
+	printerutil.PrintFnResultInfo(r.Ctx, resultsFiles, true)
 }
 
 // mergeContainerEnv will merge the envs specified by command line (imperative) and config
@@ -270,14 +312,14 @@ func (r RunFns) mergeContainerEnv(envs []string) []string {
 // init initializes the RunFns with a containerFilterProvider.
 func (r *RunFns) init() error {
 	// if no path is specified, default reading from stdin and writing to stdout
-	if r.Path == "" {
+	if r.Path == "" && len(r.Paths) == 0 {
 		if r.Output == nil {
 			r.Output = printer.FromContextOrDie(r.Ctx).OutStream()
 		}
 		if r.Input == nil {
 			r.Input = os.Stdin
 		}
-	} else {
+	} else if r.Path != "" {
 		// make the path absolute so it works on mac
 		var err error
 		absPath, err := filepath.Abs(r.Path)
@@ -358,16 +400,29 @@ func (r *RunFns) defaultFnFilterProvider(spec runtimeutil.FunctionSpec, fnConfig
 		if err != nil {
 			return nil, err
 		}
-		// If AllowWasm is true, we try to use the image field as a wasm image.
-		// TODO: we can be smarter here. If the image doesn't support wasm/js platform,
-		// it should fallback to run it as container fn.
-		if r.RunnerOptions.AllowWasm {
-			wFn, err := fnruntime.NewWasmFn(fnruntime.NewOciLoader(filepath.Join(os.TempDir(), "kpt-fn-wasm"), resolvedImage))
-			if err != nil {
-				return nil, err
+		if err := r.RunnerOptions.CheckImagePolicies(resolvedImage, resolvedImage); err != nil {
+			return nil, err
+		}
+		resolvedImage, fnResult.Signature, err = r.RunnerOptions.VerifyImageSignatures(r.Ctx, resolvedImage)
+		if err != nil {
+			return nil, err
+		}
+		registries, err := r.RunnerOptions.RegistryConfig()
+		if err != nil {
+			return nil, err
+		}
+		builtin := func() (func(io.Reader, io.Writer) error, error) {
+			// If AllowWasm is true, we try to use the image field as a wasm image.
+			// TODO: we can be smarter here. If the image doesn't support wasm/js platform,
+			// it should fallback to run it as container fn.
+			if r.RunnerOptions.AllowWasm {
+				wFn, err := fnruntime.NewWasmFn(fnruntime.NewOciLoader(filepath.Join(os.TempDir(), "kpt-fn-wasm"), resolvedImage, registries.Keychain()))
+				if err != nil {
+					return nil, err
+				}
+				wFn.SetFnResult(fnResult)
+				return wFn.Run, nil
 			}
-			fltr.Run = wFn.Run
-		} else {
 			// TODO: Add a test for this behavior
 			uidgid, err := getUIDGID(r.AsCurrentUser, currentUser)
 			if err != nil {
@@ -380,6 +435,7 @@ func (r *RunFns) defaultFnFilterProvider(spec runtimeutil.FunctionSpec, fnConfig
 				StorageMounts:   r.StorageMounts,
 				Env:             spec.Container.Env,
 				FnResult:        fnResult,
+				Registries:      registries,
 				Perm: fnruntime.ContainerFnPermission{
 					AllowNetwork: r.Network,
 					// mounts are always from CLI flags so we allow
@@ -387,24 +443,56 @@ func (r *RunFns) defaultFnFilterProvider(spec runtimeutil.FunctionSpec, fnConfig
 					AllowMount: true,
 				},
 			}
-			fltr.Run = c.Run
+			return c.Run, nil
+		}
+		// functions built into kpt run in-process. Catalog functions are
+		// only run by their builtin if enabled, and unless they need the
+		// mounts or environment variables only containers provide.
+		builtinImage := resolvedImage
+		if r.RunnerOptions.BuiltinCatalog == fnruntime.BuiltinCatalogAuto && len(r.StorageMounts) == 0 && len(spec.Container.Env) == 0 {
+			if b, found := builtins.CatalogBuiltin(resolvedImage); found {
+				builtinImage, fnResult.Builtin = b, b
+			}
+		}
+		if run, found := builtins.Lookup(builtinImage); found {
+			if len(r.StorageMounts) > 0 || len(spec.Container.Env) > 0 {
+				return nil, fmt.Errorf("mounts and environment variables are not supported for builtin function %q", resolvedImage)
+			}
+			fltr.Run = run
+		} else {
+			// the image is pulled from its mirror, which the policies
+			// were checked against.
+			if mirror := registries.Mirror(resolvedImage); mirror != resolvedImage {
+				fnResult.OriginalImage = fnResult.Image
+				resolvedImage, fnResult.Image = mirror, mirror
+			}
+			fltr.Run, err = fnruntime.RunWithRuntime(r.Ctx, r.Runtime, &kptfile.Function{Image: resolvedImage}, fnResult, builtin)
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
 
 	if spec.Exec.Path != "" {
 		fnResult.ExecPath = r.OriginalExec
+		if err := r.RunnerOptions.CheckExecPolicies(r.OriginalExec); err != nil {
+			return nil, err
+		}
 
 		if r.RunnerOptions.AllowWasm && strings.HasSuffix(spec.Exec.Path, ".wasm") {
 			wFn, err := fnruntime.NewWasmFn(&fnruntime.FsLoader{Filename: spec.Exec.Path})
 			if err != nil {
 				return nil, err
 			}
+			wFn.SetFnResult(fnResult)
 			fltr.Run = wFn.Run
 		} else {
 			e := &fnruntime.ExecFn{
-				Path:     spec.Exec.Path,
-				Args:     r.ExecArgs,
-				FnResult: fnResult,
+				Path:         spec.Exec.Path,
+				Args:         r.ExecArgs,
+				FnResult:     fnResult,
+				Sandbox:      r.RunnerOptions.SandboxExec,
+				AllowNetwork: r.Network,
 			}
 			fltr.Run = e.Run
 		}
@@ -417,3 +505,246 @@ func (r *RunFns) defaultFnFilterProvider(spec runtimeutil.FunctionSpec, fnConfig
 
 	return fnruntime.NewFunctionRunner(r.Ctx, fltr, "", fnResult, r.fnResults, opts)
 }
+
+// evalPackage is one of the packages the function is run on.
+type evalPackage struct {
+	// path is the path of the package as given by the user.
+	path string
+	// rel is the slash-separated path of the package relative to the
+	// common parent directory of the packages.
+	rel string
+	// uniquePath is the absolute path of the package.
+	uniquePath types.UniquePath
+	rw         *kio.LocalPackageReadWriter
+}
+
+// packages returns the packages of Paths and their common parent directory.
+// Packages must not be nested since the resources of a package include the
+// resources of its subpackages.
+func (r RunFns) packages() ([]*evalPackage, types.UniquePath, error) {
+	var pkgs []*evalPackage
+	for _, p := range r.Paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, "", errors.Wrap(err)
+		}
+		for _, other := range pkgs {
+			a, b := string(other.uniquePath), abs
+			if a == b || strings.HasPrefix(b, a+string(filepath.Separator)) || strings.HasPrefix(a, b+string(filepath.Separator)) {
+				return nil, "", fmt.Errorf("packages %q and %q overlap, a package includes its subpackages", other.path, p)
+			}
+		}
+		pkgs = append(pkgs, &evalPackage{path: p, uniquePath: types.UniquePath(abs), rw: newPackageReadWriter(abs)})
+	}
+
+	base := string(pkgs[0].uniquePath)
+	for _, p := range pkgs {
+		for !isWithin(string(p.uniquePath), base) {
+			base = filepath.Dir(base)
+		}
+	}
+	for _, p := range pkgs {
+		rel, err := filepath.Rel(base, string(p.uniquePath))
+		if err != nil {
+			return nil, "", errors.Wrap(err)
+		}
+		p.rel = filepath.ToSlash(rel)
+	}
+	return pkgs, types.UniquePath(base), nil
+}
+
+// isWithin returns true if path is dir or is in dir.
+func isWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// executePackages runs the function on the packages of Paths, either once
+// per package or once for all of them in batch mode.
+func (r RunFns) executePackages() error {
+	pkgs, base, err := r.packages()
+	if err != nil {
+		return err
+	}
+	fltrs, err := r.getFilters()
+	if err != nil {
+		return err
+	}
+	if r.Batch {
+		return r.saveResults(r.runBatch(pkgs, base, fltrs))
+	}
+
+	pr := printer.FromContextOrDie(r.Ctx)
+	for _, p := range pkgs {
+		pr.OptPrintf(printer.NewOpt().PkgDisplay(types.DisplayPath(p.path)), "\n")
+		// the function is reused for all the packages, so that the image
+		// is resolved and verified once.
+		for _, fltr := range fltrs {
+			if fr, ok := fltr.(*fnruntime.FunctionRunner); ok {
+				res := fr.Result()
+				*res = fnresult.Result{
+					Image:         res.Image,
+					OriginalImage: res.OriginalImage,
+					Builtin:       res.Builtin,
+					ExecPath:      res.ExecPath,
+					Signature:     res.Signature,
+					Pkg:           p.path,
+				}
+			}
+		}
+		if err := r.runFilters(p.rw, p.rw, fltrs, p.uniquePath); err != nil {
+			return r.saveResults(err)
+		}
+	}
+	return r.saveResults(nil)
+}
+
+// runBatch runs the function once on the resources of all the packages.
+// The path annotations of the resources are made relative to base during
+// the run, so the resources of the output are written back to the package
+// their path is in.
+func (r RunFns) runBatch(pkgs []*evalPackage, base types.UniquePath, fltrs []kio.Filter) error {
+	var nodes []*yaml.RNode
+	for _, p := range pkgs {
+		pkgNodes, err := p.rw.Read()
+		if err != nil {
+			return err
+		}
+		for _, n := range pkgNodes {
+			if err := setPathPrefix(n, p.rel); err != nil {
+				return err
+			}
+		}
+		nodes = append(nodes, pkgNodes...)
+	}
+	first := len(r.fnResults.Items)
+	err := r.runFilters(&kio.PackageBuffer{Nodes: nodes}, &batchWriter{pkgs: pkgs}, fltrs, base)
+
+	// attribute the results to the packages of the files they refer to.
+	var items []fnresult.Result
+	for _, item := range r.fnResults.Items[first:] {
+		items = append(items, splitResult(item, pkgs)...)
+	}
+	r.fnResults.Items = append(r.fnResults.Items[:first], items...)
+	return err
+}
+
+// batchWriter writes the resources of a batch run back to their packages.
+// The resources without a path, e.g. the ones generated by the function,
+// are written to the first package.
+type batchWriter struct {
+	pkgs []*evalPackage
+}
+
+func (w *batchWriter) Write(nodes []*yaml.RNode) error {
+	byPkg := map[*evalPackage][]*yaml.RNode{}
+	for _, n := range nodes {
+		path, _, err := kioutil.GetFileAnnotations(n)
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			byPkg[w.pkgs[0]] = append(byPkg[w.pkgs[0]], n)
+			continue
+		}
+		p, rel := findPackage(w.pkgs, path)
+		if p == nil {
+			return fmt.Errorf("resource %s %q has path %q which is not in any of the packages", n.GetKind(), n.GetName(), path)
+		}
+		if err := setPath(n, rel); err != nil {
+			return err
+		}
+		byPkg[p] = append(byPkg[p], n)
+	}
+	// packages without resources are written too, so their deleted
+	// resources are removed.
+	for _, p := range w.pkgs {
+		if err := p.rw.Write(byPkg[p]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findPackage returns the package of the slash-separated path, relative to
+// the common parent directory of the packages, and the path relative to
+// the package.
+func findPackage(pkgs []*evalPackage, path string) (*evalPackage, string) {
+	path = filepath.ToSlash(filepath.Clean(path))
+	for _, p := range pkgs {
+		if p.rel == "." {
+			return p, path
+		}
+		if strings.HasPrefix(path, p.rel+"/") {
+			return p, strings.TrimPrefix(path, p.rel+"/")
+		}
+	}
+	return nil, ""
+}
+
+// setPathPrefix prefixes the path annotations of the resource.
+func setPathPrefix(n *yaml.RNode, prefix string) error {
+	path, _, err := kioutil.GetFileAnnotations(n)
+	if err != nil {
+		return err
+	}
+	return setPath(n, filepath.ToSlash(filepath.Join(prefix, path)))
+}
+
+// setPath sets the path annotations of the resource.
+func setPath(n *yaml.RNode, path string) error {
+	annotations := n.GetAnnotations()
+	for _, a := range []string{kioutil.PathAnnotation, kioutil.LegacyPathAnnotation} {
+		if _, found := annotations[a]; !found && a == kioutil.LegacyPathAnnotation {
+			continue
+		}
+		if err := n.PipeE(yaml.SetAnnotation(a, path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitResult splits the result of a batch run into a result per package
+// holding the results referring to the files of the package. The results
+// which don't refer to a file, the stderr and the signature stay in the
+// result of the run.
+func splitResult(res fnresult.Result, pkgs []*evalPackage) []fnresult.Result {
+	run := res
+	run.Results = nil
+	byPkg := map[*evalPackage]*fnresult.Result{}
+	var order []*evalPackage
+	for _, item := range res.Results {
+		var p *evalPackage
+		var rel string
+		if item.File != nil {
+			p, rel = findPackage(pkgs, item.File.Path)
+		}
+		if p == nil {
+			run.Results = append(run.Results, item)
+			continue
+		}
+		pr, found := byPkg[p]
+		if !found {
+			pr = &fnresult.Result{
+				Image:         res.Image,
+				OriginalImage: res.OriginalImage,
+				Builtin:       res.Builtin,
+				ExecPath:      res.ExecPath,
+				ExitCode:      res.ExitCode,
+				Pkg:           p.path,
+			}
+			byPkg[p] = pr
+			order = append(order, p)
+		}
+		attributed := *item
+		file := *item.File
+		file.Path = rel
+		attributed.File = &file
+		pr.Results = append(pr.Results, &attributed)
+	}
+	items := []fnresult.Result{run}
+	for _, p := range order {
+		items = append(items, *byPkg[p])
+	}
+	return items
+}
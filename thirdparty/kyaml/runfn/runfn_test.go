@@ -5,21 +5,28 @@ package runfn
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"testing"
 
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	fnregistry "github.com/GoogleContainerTools/kpt/pkg/api/fnregistry/v1alpha1"
 	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	v1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
 	"github.com/GoogleContainerTools/kpt/pkg/printer/fake"
 	"github.com/stretchr/testify/assert"
 
 	"sigs.k8s.io/kustomize/kyaml/copyutil"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
 	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
 	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/kustomize/kyaml/kio/filters"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
@@ -357,6 +364,350 @@ func TestCmd_Execute_setOutput(t *testing.T) {
 	assert.Contains(t, out.String(), "kind: StatefulSet")
 }
 
+func TestCmd_Execute_packages(t *testing.T) {
+	for _, batch := range []bool{false, true} {
+		dir := t.TempDir()
+		_, filename, _, ok := runtime.Caller(0)
+		if !assert.True(t, ok) {
+			t.FailNow()
+		}
+		ds := filepath.Join(filepath.Dir(filename), "test", "testdata")
+		var paths []string
+		for _, p := range []string{"pkg-a", "pkg-b"} {
+			paths = append(paths, filepath.Join(dir, p))
+			if !assert.NoError(t, copyutil.CopyDir(filesys.MakeFsOnDisk(), ds, filepath.Join(dir, p))) {
+				t.FailNow()
+			}
+		}
+
+		fnConfig, err := yaml.Parse(ValueReplacerYAMLData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn := &runtimeutil.FunctionSpec{
+			Container: runtimeutil.ContainerSpec{
+				Image: "gcr.io/example.com/image:version",
+			},
+		}
+		// record the paths of the resources of each run of the function.
+		var runs [][]string
+		provider := getFilterProvider(t)
+		instance := RunFns{
+			Ctx:   fake.CtxWithDefaultPrinter(),
+			Paths: paths,
+			Batch: batch,
+			functionFilterProvider: func(f runtimeutil.FunctionSpec, node *yaml.RNode, currentUser currentUserFunc) (kio.Filter, error) {
+				fltr, err := provider(f, node, currentUser)
+				if err != nil {
+					return nil, err
+				}
+				return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+					var run []string
+					for _, n := range nodes {
+						path, _, err := kioutil.GetFileAnnotations(n)
+						if err != nil {
+							return nil, err
+						}
+						run = append(run, path)
+					}
+					runs = append(runs, run)
+					return fltr.Filter(nodes)
+				}), nil
+			},
+			Function:  fn,
+			FnConfig:  fnConfig,
+			fnResults: fnresult.NewResultList(),
+		}
+		if !assert.NoError(t, instance.Execute()) {
+			t.FailNow()
+		}
+
+		if batch {
+			assert.Equal(t, [][]string{{
+				"pkg-a/java/java-configmap.resource.yaml",
+				"pkg-a/java/java-deployment.resource.yaml",
+				"pkg-a/java/java-service.resource.yaml",
+				"pkg-b/java/java-configmap.resource.yaml",
+				"pkg-b/java/java-deployment.resource.yaml",
+				"pkg-b/java/java-service.resource.yaml",
+			}}, runs)
+		} else {
+			assert.Len(t, runs, 2)
+		}
+		for _, p := range paths {
+			b, err := os.ReadFile(filepath.Join(p, "java", "java-deployment.resource.yaml"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Contains(t, string(b), "kind: StatefulSet")
+			assert.NotContains(t, string(b), "pkg-")
+		}
+	}
+}
+
+// TestCmd_Execute_batchGenerator verifies that the resources generated
+// without a path in a batch run are written to the first package.
+func TestCmd_Execute_batchGenerator(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for _, p := range []string{"pkg-a", "pkg-b"} {
+		paths = append(paths, filepath.Join(dir, p))
+		if !assert.NoError(t, os.MkdirAll(filepath.Join(dir, p), 0700)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, os.WriteFile(filepath.Join(dir, p, "cm.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: `+p+`
+`), 0600)) {
+			t.FailNow()
+		}
+	}
+
+	instance := RunFns{
+		Ctx:   fake.CtxWithDefaultPrinter(),
+		Paths: paths,
+		Batch: true,
+		functionFilterProvider: func(runtimeutil.FunctionSpec, *yaml.RNode, currentUserFunc) (kio.Filter, error) {
+			return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+				generated, err := yaml.Parse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: generated
+`)
+				if err != nil {
+					return nil, err
+				}
+				return append(nodes, generated), nil
+			}), nil
+		},
+		Function: &runtimeutil.FunctionSpec{
+			Container: runtimeutil.ContainerSpec{Image: "gcr.io/example.com/image:version"},
+		},
+		fnResults: fnresult.NewResultList(),
+	}
+	if !assert.NoError(t, instance.Execute()) {
+		t.FailNow()
+	}
+
+	contents := func(pkg string) string {
+		var all string
+		files, err := os.ReadDir(filepath.Join(dir, pkg))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		for _, f := range files {
+			b, err := os.ReadFile(filepath.Join(dir, pkg, f.Name()))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			all += string(b)
+		}
+		return all
+	}
+	assert.Contains(t, contents("pkg-a"), "name: pkg-a")
+	assert.Contains(t, contents("pkg-a"), "name: generated")
+	assert.Contains(t, contents("pkg-b"), "name: pkg-b")
+	assert.NotContains(t, contents("pkg-b"), "name: generated")
+}
+
+// resultRuntime is a function runtime running all the functions with a
+// function reporting a result for each resource.
+type resultRuntime struct{}
+
+func (resultRuntime) GetRunner(context.Context, *v1.Function) (fn.FunctionRunner, error) {
+	return resultRuntime{}, nil
+}
+
+func (resultRuntime) Run(r io.Reader, w io.Writer) error {
+	return framework.Execute(framework.ResourceListProcessorFunc(func(rl *framework.ResourceList) error {
+		for _, n := range rl.Items {
+			path, _, err := kioutil.GetFileAnnotations(n)
+			if err != nil {
+				return err
+			}
+			rl.Results = append(rl.Results, &framework.Result{
+				Message:  "checked",
+				Severity: framework.Info,
+				File:     &framework.File{Path: path},
+			})
+		}
+		return nil
+	}), &kio.ByteReadWriter{Reader: r, Writer: w})
+}
+
+// TestCmd_Execute_mirroredPackages verifies that the results of each
+// package record the declared image of a function pulled from a mirror.
+func TestCmd_Execute_mirroredPackages(t *testing.T) {
+	for _, batch := range []bool{false, true} {
+		paths := writeConfigMapPackages(t, "")
+		registries := &fnruntime.RegistryConfig{}
+		registries.Mirrors = []fnregistry.Mirror{{Source: "gcr.io/kpt-fn/*", Mirror: "mirror.example.com/kpt-fn/*"}}
+		resultsDir := t.TempDir()
+
+		instance := RunFns{
+			Ctx:        fake.CtxWithDefaultPrinter(),
+			Paths:      paths,
+			Batch:      batch,
+			ResultsDir: resultsDir,
+			Runtime:    resultRuntime{},
+			RunnerOptions: fnruntime.RunnerOptions{
+				ResolveToImage: func(_ context.Context, image string) (string, error) { return image, nil },
+				Registries:     registries,
+			},
+			Function: &runtimeutil.FunctionSpec{
+				Container: runtimeutil.ContainerSpec{Image: "gcr.io/kpt-fn/set-labels:v0.1"},
+			},
+		}
+		if !assert.NoError(t, instance.Execute()) {
+			t.FailNow()
+		}
+
+		b, err := os.ReadFile(filepath.Join(resultsDir, "results.yaml"))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		var results fnresult.ResultList
+		if !assert.NoError(t, yaml.Unmarshal(b, &results)) {
+			t.FailNow()
+		}
+		var pkgs []string
+		for _, item := range results.Items {
+			assert.Equal(t, "mirror.example.com/kpt-fn/set-labels:v0.1", item.Image)
+			assert.Equal(t, "gcr.io/kpt-fn/set-labels:v0.1", item.OriginalImage)
+			if item.Pkg != "" {
+				pkgs = append(pkgs, item.Pkg)
+			}
+		}
+		assert.Equal(t, paths, pkgs, "batch: %t", batch)
+	}
+}
+
+// TestCmd_Execute_builtinCatalogPackages verifies that the results of each
+// package record the builtin run instead of a catalog function image.
+func TestCmd_Execute_builtinCatalogPackages(t *testing.T) {
+	fnConfig, err := yaml.Parse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: setters
+data:
+  env: prod
+`)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for _, batch := range []bool{false, true} {
+		paths := writeConfigMapPackages(t, "  env: dev # kpt-set: ${env}\n")
+		resultsDir := t.TempDir()
+
+		instance := RunFns{
+			Ctx:        fake.CtxWithDefaultPrinter(),
+			Paths:      paths,
+			Batch:      batch,
+			ResultsDir: resultsDir,
+			FnConfig:   fnConfig,
+			RunnerOptions: fnruntime.RunnerOptions{
+				ResolveToImage: func(_ context.Context, image string) (string, error) { return image, nil },
+				BuiltinCatalog: fnruntime.BuiltinCatalogAuto,
+				Registries:     &fnruntime.RegistryConfig{},
+			},
+			Function: &runtimeutil.FunctionSpec{
+				Container: runtimeutil.ContainerSpec{Image: "gcr.io/kpt-fn/apply-setters:v0.2.0"},
+			},
+		}
+		if !assert.NoError(t, instance.Execute()) {
+			t.FailNow()
+		}
+
+		b, err := os.ReadFile(filepath.Join(resultsDir, "results.yaml"))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		var results fnresult.ResultList
+		if !assert.NoError(t, yaml.Unmarshal(b, &results)) {
+			t.FailNow()
+		}
+		var pkgs []string
+		for _, item := range results.Items {
+			assert.Equal(t, "gcr.io/kpt-fn/apply-setters:v0.2.0", item.Image)
+			assert.Equal(t, "builtins/apply-setters", item.Builtin)
+			if item.Pkg != "" {
+				pkgs = append(pkgs, item.Pkg)
+			}
+		}
+		assert.Equal(t, paths, pkgs, "batch: %t", batch)
+	}
+}
+
+// writeConfigMapPackages writes two packages with a ConfigMap, with the
+// given data, and returns their paths.
+func writeConfigMapPackages(t *testing.T, data string) []string {
+	dir := t.TempDir()
+	var paths []string
+	for _, p := range []string{"pkg-a", "pkg-b"} {
+		cm := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + p + "\n"
+		if data != "" {
+			cm += "data:\n" + data
+		}
+		paths = append(paths, filepath.Join(dir, p))
+		if !assert.NoError(t, os.MkdirAll(filepath.Join(dir, p), 0700)) ||
+			!assert.NoError(t, os.WriteFile(filepath.Join(dir, p, "cm.yaml"), []byte(cm), 0600)) {
+			t.FailNow()
+		}
+	}
+	return paths
+}
+
+func TestCmd_Execute_nestedPackages(t *testing.T) {
+	dir := t.TempDir()
+	instance := RunFns{
+		Ctx:                    fake.CtxWithDefaultPrinter(),
+		Paths:                  []string{dir, filepath.Join(dir, "java")},
+		functionFilterProvider: getFilterProvider(t),
+		fnResults:              fnresult.NewResultList(),
+	}
+	err := instance.Execute()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "overlap")
+	}
+}
+
+func TestSplitResult(t *testing.T) {
+	pkgs := []*evalPackage{{path: "a", rel: "a"}, {path: "b/c", rel: "b/c"}}
+	res := fnresult.Result{
+		Image:    "gcr.io/example.com/image:version",
+		ExitCode: 1,
+		Results: []*framework.Result{
+			{Message: "no file"},
+			{Message: "in a", File: &framework.File{Path: "a/deployment.yaml"}},
+			{Message: "in c", File: &framework.File{Path: "b/c/service.yaml", Index: 1}},
+			{Message: "unknown", File: &framework.File{Path: "d/service.yaml"}},
+		},
+	}
+	assert.Equal(t, []fnresult.Result{
+		{
+			Image:    "gcr.io/example.com/image:version",
+			ExitCode: 1,
+			Results: []*framework.Result{
+				{Message: "no file"},
+				{Message: "unknown", File: &framework.File{Path: "d/service.yaml"}},
+			},
+		},
+		{
+			Image:    "gcr.io/example.com/image:version",
+			ExitCode: 1,
+			Pkg:      "a",
+			Results:  []*framework.Result{{Message: "in a", File: &framework.File{Path: "deployment.yaml"}}},
+		},
+		{
+			Image:    "gcr.io/example.com/image:version",
+			ExitCode: 1,
+			Pkg:      "b/c",
+			Results:  []*framework.Result{{Message: "in c", File: &framework.File{Path: "service.yaml", Index: 1}}},
+		},
+	}, splitResult(res, pkgs))
+}
+
 // TestCmd_Execute_setInput tests the execution of a filter using an io.Reader as input
 func TestCmd_Execute_setInput(t *testing.T) {
 	dir := setupTest(t)